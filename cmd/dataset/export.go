@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	app "github-stat/internal"
+	"github-stat/internal/databases/driver"
+	"github-stat/internal/databases/valkey"
+	"github-stat/internal/export"
+	"github-stat/internal/staging"
+)
+
+// exportOptions holds the command line arguments of the export command.
+type exportOptions struct {
+	ID       string
+	DBType   string
+	DSN      string
+	Database string
+	Store    string
+	Format   string
+	Out      string
+}
+
+// runExport writes the repositories and pull requests of a database or of the dataset store into files
+// that the dataset loader reads with DATASET_LOAD_TYPE=csv, and returns the exit code of the process.
+//
+// Usage:
+//
+//	dataset export --db mysql-1 --format csv --out export
+//	dataset export --type postgres --dsn "user=... host=..." --format parquet
+//	dataset export --store data/store --format ndjson
+func runExport(args []string) int {
+	var opts exportOptions
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.StringVar(&opts.ID, "db", "", "ID of a database configured on the control panel, e.g. mysql-1")
+	flags.StringVar(&opts.DBType, "type", "", "Database type without the control panel: mysql, postgres or mongodb")
+	flags.StringVar(&opts.DSN, "dsn", "", "Connection string, used with --type")
+	flags.StringVar(&opts.Database, "database", "dataset", "MongoDB database name, used with --type")
+	flags.StringVar(&opts.Store, "store", "", "Directory of the dataset store to export instead of a database, e.g. data/store")
+	flags.StringVar(&opts.Format, "format", export.FormatCSV, "File format: csv, ndjson or parquet")
+	flags.StringVar(&opts.Out, "out", "export", "Directory of the repositories and pulls files")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Ctrl+C stops the export, the files written so far are incomplete
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	w, err := export.Create(opts.Out, opts.Format)
+	if err != nil {
+		log.Printf("Export: Error: %v", err)
+		return 1
+	}
+
+	err = exportDataset(ctx, opts, w)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("Export: Error: %v", err)
+		return 1
+	}
+
+	reposName, pullsName := export.FileNames(opts.Format)
+	log.Printf("Export: Done: %d repositories in %s, %d pull requests in %s",
+		w.Repos, filepath.Join(opts.Out, reposName), w.Pulls, filepath.Join(opts.Out, pullsName))
+	return 0
+}
+
+// exportDataset writes the dataset store of --store, or the database of --db or --type and --dsn, into w.
+func exportDataset(ctx context.Context, opts exportOptions, w *export.Writer) error {
+	if opts.Store != "" {
+		if opts.ID != "" || opts.DBType != "" {
+			return errors.New("--store cannot be used with --db or --type")
+		}
+		// The dataset loader may be writing the store, it is read without changes
+		s, err := staging.OpenReadOnly(opts.Store)
+		if err != nil {
+			return err
+		}
+		return exportStore(ctx, s, w)
+	}
+
+	var db app.DatabaseConfig
+	switch {
+	case opts.ID != "":
+		if opts.DBType != "" || opts.DSN != "" {
+			return errors.New("--db cannot be used with --type or --dsn")
+		}
+		envVars, err := app.GetEnvVars("export")
+		if err != nil {
+			return err
+		}
+		valkey.InitValkey(envVars)
+		defer valkey.Valkey.Close()
+		if db, err = valkey.GetDatabase(opts.ID); err != nil {
+			return err
+		}
+	case opts.DBType != "" && opts.DSN != "":
+		db = app.NewDatabaseConfig("export-"+opts.DBType, opts.DBType, opts.DSN)
+		db.Database = opts.Database
+	default:
+		return errors.New("either --store, --db or --type and --dsn are required")
+	}
+
+	drv, err := driver.Get(db.DBType)
+	if err != nil {
+		return err
+	}
+	log.Printf("Export: %s %s to %s", drv.Name(), db.ID, opts.Out)
+	return drv.ExportDataset(ctx, db, w)
+}
+
+// exportStore writes the repositories and pull requests of the dataset store into w, one repository at a time.
+func exportStore(ctx context.Context, s *staging.Store, w app.DatasetWriter) error {
+	dataset := app.Dataset{Source: s}
+	return dataset.Each(ctx, func(data app.RepoData) error {
+		if err := w.WriteRepo(data.Repo); err != nil {
+			return err
+		}
+		for _, pull := range data.Pulls {
+			if err := w.WritePull(data.Repo.GetName(), pull); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
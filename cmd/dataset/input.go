@@ -0,0 +1,380 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Formats of the dataset files, detected from the first bytes of the content.
+const (
+	inputGzip   = "gzip"
+	inputTar    = "tar"
+	inputZip    = "zip"
+	inputNDJSON = "ndjson"
+	inputCSV    = "csv"
+)
+
+// sniffSize is the number of bytes read to detect the format, the tar header magic ends at byte 262.
+const sniffSize = 512
+
+// eachDatasetRecord reads a dataset file and calls fn for every record without reading the whole file into memory.
+//
+// The path is a file, a directory of shards or an http(s) URL. The format is detected from the content, not from
+// the extension: CSV with a header, NDJSON with one GitHub API object per line, a gzip file of one of them or of a
+// tar archive, a tar archive or a ZIP archive. Directories and archives are read entry by entry in the order of
+// their names; hidden entries and CSV entries without the .csv extension, e.g. a README, are skipped.
+//
+// Arguments:
+//   - name: string containing the name used in the log messages.
+//   - filePath: string containing the path or the URL of the file.
+//   - fn: function called for every record with the repository name of the second column of a CSV row
+//     with at least 3 columns, empty otherwise, and the JSON of the last column or of the NDJSON line.
+//     The reading stops at its first error, data is only valid during the call.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func eachDatasetRecord(name string, filePath string, fn func(repo string, data []byte) error) error {
+	if strings.HasPrefix(filePath, "http://") || strings.HasPrefix(filePath, "https://") {
+		return readURL(name, filePath, fn)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		log.Printf("%s: Open: Error: %v", name, err)
+		return err
+	}
+	if info.IsDir() {
+		return readDir(name, filePath, fn)
+	}
+	return readFile(name, filePath, false, fn)
+}
+
+// readURL streams the file of the URL, a ZIP archive is downloaded into a temporary file first
+// because its directory is at the end.
+func readURL(name string, url string, fn func(repo string, data []byte) error) error {
+	log.Printf("%s: Reading file from URL: %s", name, url)
+	resp, err := http.Get(url)
+	if err != nil {
+		log.Printf("%s: Get URL: Error: %v", name, err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("%s: unexpected status %s of %s", name, resp.Status, url)
+		log.Printf("%s: Get URL: Error: %v", name, err)
+		return err
+	}
+
+	r := bufio.NewReaderSize(resp.Body, sniffSize)
+	if sniffFormat(r) != inputZip {
+		return readStream(name, url, r, false, fn)
+	}
+
+	log.Printf("%s: Downloading ZIP archive from URL: %s", name, url)
+	tempFile, err := downloadToTemp(r)
+	if err != nil {
+		log.Printf("%s: Download: Error: %v", name, err)
+		return err
+	}
+	defer os.Remove(tempFile)
+
+	return readZip(name, tempFile, fn)
+}
+
+// readDir reads the files of a directory and its subdirectories in the order of their paths.
+func readDir(name string, dir string, fn func(repo string, data []byte) error) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("%s: Read directory: Error: %v", name, err)
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return readFile(name, path, true, fn)
+	})
+}
+
+// readFile reads a file of one of the formats, entry tells whether it is a shard of a directory.
+func readFile(name string, path string, entry bool, fn func(repo string, data []byte) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Printf("%s: Open: Error: %v", name, err)
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReaderSize(file, sniffSize)
+	if sniffFormat(r) == inputZip {
+		return readZip(name, path, fn)
+	}
+	return readStream(name, path, r, entry, fn)
+}
+
+// readZip reads the entries of a ZIP archive.
+func readZip(name string, path string, fn func(repo string, data []byte) error) error {
+	zipFile, err := zip.OpenReader(path)
+	if err != nil {
+		log.Printf("%s: Open ZIP: Error: %v", name, err)
+		return err
+	}
+	defer zipFile.Close()
+
+	for _, f := range zipFile.File {
+		if f.FileInfo().IsDir() || hiddenEntry(f.Name) {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			log.Printf("%s: Open %s in ZIP: Error: %v", name, f.Name, err)
+			return err
+		}
+		err = readStream(name, f.Name, bufio.NewReaderSize(rc, sniffSize), true, fn)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readStream reads a stream of one of the formats except ZIP. entryName is the file name used in the messages
+// and to skip entries of directories and archives that are not dataset files.
+func readStream(name string, entryName string, r *bufio.Reader, entry bool, fn func(repo string, data []byte) error) error {
+	format := sniffFormat(r)
+
+	switch format {
+	case inputGzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			log.Printf("%s: Open gzip %s: Error: %v", name, entryName, err)
+			return err
+		}
+		defer gz.Close()
+		// A .tar.gz or a gzipped CSV or NDJSON file
+		return readStream(name, strings.TrimSuffix(entryName, filepath.Ext(entryName)), bufio.NewReaderSize(gz, sniffSize), entry, fn)
+	case inputTar:
+		return readTar(name, r, fn)
+	case inputZip:
+		err := fmt.Errorf("%s: the ZIP archive %s must be a file, not an entry of a gzip or tar archive", name, entryName)
+		log.Printf("%s: Error: %v", name, err)
+		return err
+	case inputNDJSON:
+		return readNDJSON(name, entryName, r, fn)
+	}
+
+	// CSV cannot be told from other text, so the entries of directories and archives need the extension
+	if entry && !strings.EqualFold(filepath.Ext(entryName), ".csv") {
+		log.Printf("%s: Skipping %s, it is neither CSV nor NDJSON", name, entryName)
+		return nil
+	}
+	return readCSV(name, entryName, r, fn)
+}
+
+// readTar reads the regular files of a tar archive.
+func readTar(name string, r io.Reader, fn func(repo string, data []byte) error) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			log.Printf("%s: Read tar: Error: %v", name, err)
+			return err
+		}
+		if header.Typeflag != tar.TypeReg || hiddenEntry(header.Name) {
+			continue
+		}
+
+		if err := readStream(name, header.Name, bufio.NewReaderSize(tr, sniffSize), true, fn); err != nil {
+			return err
+		}
+	}
+}
+
+// readCSV reads the rows of a CSV file except the header, the JSON is in the last column.
+func readCSV(name string, entryName string, r io.Reader, fn func(repo string, data []byte) error) error {
+	reader := csv.NewReader(r)
+	reader.LazyQuotes = true
+	reader.ReuseRecord = true
+	reader.FieldsPerRecord = -1
+
+	for i := 0; ; i++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			log.Printf("%s: Read: CSV %s: Error: %v", name, entryName, err)
+			return err
+		}
+		if i == 0 {
+			continue
+		}
+		if len(record) < 2 {
+			return fmt.Errorf("%s: %s: expected at least 2 columns, got %d", name, entryName, len(record))
+		}
+
+		repo := ""
+		if len(record) >= 3 {
+			repo = record[1]
+		}
+		if err := fn(repo, []byte(record[len(record)-1])); err != nil {
+			return err
+		}
+	}
+}
+
+// readNDJSON reads the JSON objects of an NDJSON file, an object may span several lines.
+func readNDJSON(name string, entryName string, r io.Reader, fn func(repo string, data []byte) error) error {
+	dec := json.NewDecoder(r)
+	var data json.RawMessage
+
+	for {
+		err := dec.Decode(&data)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			log.Printf("%s: Read: NDJSON %s: Error: %v", name, entryName, err)
+			return err
+		}
+		if err := fn("", data); err != nil {
+			return err
+		}
+	}
+}
+
+// sniffFormat detects the format of a stream from its first bytes without consuming them.
+func sniffFormat(r *bufio.Reader) string {
+	// Peek returns the available bytes of a short stream with an error
+	head, _ := r.Peek(sniffSize)
+
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return inputGzip
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return inputZip
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return inputTar
+	}
+
+	text := bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if bytes.HasPrefix(text, []byte("{")) {
+		return inputNDJSON
+	}
+	return inputCSV
+}
+
+// hiddenEntry tells whether an entry of an archive is hidden, e.g. the macOS metadata of a ZIP archive.
+func hiddenEntry(entryName string) bool {
+	for _, part := range strings.Split(entryName, "/") {
+		if (strings.HasPrefix(part, ".") && part != "." && part != "..") || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
+
+// downloadToTemp saves a stream to a temporary file and returns the path to the file.
+//
+// Arguments:
+//   - r: io.Reader of the content to save.
+//
+// Returns:
+//   - string: The path to the downloaded file.
+//   - error: An error object if an error occurs, otherwise nil.
+func downloadToTemp(r io.Reader) (string, error) {
+	tmpFile, err := os.CreateTemp("", "dataset-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer tmpFile.Close()
+
+	if _, err := io.Copy(tmpFile, r); err != nil {
+		os.Remove(tmpFile.Name())
+		return "", err
+	}
+
+	return tmpFile.Name(), nil
+}
+
+// archiveEvents are the GH Archive events with an entity of the dataset by entity, and the key of the entity
+// in the payload. Other events, e.g. the PullRequestReviewEvent with a pull request without its line counts,
+// are skipped.
+var archiveEvents = map[string]struct {
+	eventType string
+	key       string
+}{
+	"pulls":           {"PullRequestEvent", "pull_request"},
+	"issues":          {"IssuesEvent", "issue"},
+	"reviews":         {"PullRequestReviewEvent", "review"},
+	"review_comments": {"PullRequestReviewCommentEvent", "comment"},
+}
+
+// archiveEvent is an event of a GH Archive dump, e.g. {"type":"PullRequestEvent","repo":{"name":"percona/pmm"},"payload":{...}}.
+type archiveEvent struct {
+	Type string `json:"type"`
+	Repo struct {
+		Name string `json:"name"`
+	} `json:"repo"`
+	Payload map[string]json.RawMessage `json:"payload"`
+}
+
+// decodeRecord decodes the entity of a record of a dataset file. A record may be a GH Archive event,
+// then the entity is taken from its payload and the repository name from the event.
+//
+// Arguments:
+//   - entity: string containing the kind of the entity, e.g. "pulls", one of the keys of archiveEvents.
+//   - repo: string containing the repository name of the record, empty if the file has none.
+//   - data: []byte containing the JSON of the record.
+//   - v: pointer to the entity to decode into.
+//
+// Returns:
+//   - string: The repository name of the record or of the event.
+//   - bool: False if the record is an event without an entity of the kind, it is skipped.
+//   - error: An error object if the JSON is not valid, otherwise nil.
+func decodeRecord(entity string, repo string, data []byte, v interface{}) (string, bool, error) {
+	// Only the events have a payload at the top level, the entities are decoded once
+	if bytes.Contains(data, []byte(`"payload"`)) {
+		var event archiveEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return "", false, err
+		}
+		if event.Type != "" && event.Payload != nil {
+			want, ok := archiveEvents[entity]
+			payload := event.Payload[want.key]
+			if !ok || event.Type != want.eventType || payload == nil {
+				return "", false, nil
+			}
+			// The name of the event is "owner/repo"
+			_, name, _ := strings.Cut(event.Repo.Name, "/")
+			return name, true, json.Unmarshal(payload, v)
+		}
+	}
+
+	return repo, true, json.Unmarshal(data, v)
+}
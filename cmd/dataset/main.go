@@ -1,44 +1,35 @@
 package main
 
 import (
-	"archive/zip"
 	"context"
-	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	app "github-stat/internal"
 
-	"github-stat/internal/databases/mongodb"
-	"github-stat/internal/databases/mysql"
-	"github-stat/internal/databases/postgres"
+	"github-stat/internal/databases/driver"
 	"github-stat/internal/databases/valkey"
+	"github-stat/internal/metrics"
+	"github-stat/internal/staging"
 
 	"github.com/google/go-github/github"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// allPullsData stores pull request data for each repository.
-// The outer map key is the repository name, and the inner map key is the pull request ID.
-var allPullsData map[string]map[int64]*github.PullRequest = make(map[string]map[int64]*github.PullRequest)
-
-// allReposData stores repository data.
-// The map key is the repository ID.
-var allReposData map[int64]*github.Repository = make(map[int64]*github.Repository)
+// store keeps the repositories and pull requests on disk, one segment per repository.
+// It survives restarts, so the GitHub data is not fetched again after a pod restart.
+var store *staging.Store
 
 // databases holds the configuration for each database.
-var databases []map[string]string
+var databases []app.DatabaseConfig
 
 // statusData holds the current status of the dataset update process.
 var statusData string
@@ -46,9 +37,20 @@ var statusData string
 // maxHeapAlloc keeps track of the maximum heap allocation observed.
 var maxHeapAlloc uint64
 
+var (
+	importsMu sync.Mutex
+	// imports holds the cancel functions of the running database imports by database ID.
+	imports = make(map[string]context.CancelFunc)
+)
+
 // main is the entry point of the application. It initializes the configuration, starts the dataset update process,
 // starts the process to update databases, and keeps the main function running indefinitely.
 func main() {
+	// "dataset export" writes a database or the dataset store into files and exits
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		os.Exit(runExport(os.Args[2:]))
+	}
+
 	// Get the configuration from environment variables or .env file.
 	app.InitConfig("dataset")
 
@@ -56,23 +58,45 @@ func main() {
 	valkey.InitValkey(app.Config)
 	defer valkey.Valkey.Close()
 
-	// Initialize status to "Initializing"
-	setStatus("Initializing")
+	// Serve the import progress, GitHub API and memory metrics for Prometheus
+	metrics.RegisterDataset()
+	metrics.Serve(app.Config.App.MetricsPort)
+
+	// Open the on-disk store with the data of the previous runs
+	var err error
+	store, err = staging.Open(app.Config.App.DatasetStoreDir)
+	if err != nil {
+		log.Fatalf("Dataset: Error: Opening the store %s: %v", app.Config.App.DatasetStoreDir, err)
+	}
+
+	// Initialize status to "Initializing", the stored data can be written into databases right away
+	if repos, _ := store.Counts(); repos > 0 {
+		setStatus("Updating")
+	} else {
+		setStatus("Initializing")
+	}
 	// Handle termination signals
 	handleTerminationSignals()
 
+	// Show the waits for the GitHub API rate limit in the status
+	app.OnGitHubRateWait = func(app.GitHubRate) { publishStatus() }
+
 	// Start the dataset data update process in a separate goroutine
 	go updateDatasetData()
 
 	// Start the process to update databases from Valkey in a separate goroutine
 	go updateDatabases()
 
+	// Cancel the imports stopped on the control panel
+	go watchImports()
+
 	// Keep the main function running
 	select {}
 }
 
 // updateDatabases periodically checks and updates the status of databases from Valkey.
-// It processes each database by writing data from memory into the database based on its type.
+// It processes each database by streaming the data from the store into the database based on its type,
+// and verifies the databases after the import or when the verification is requested on the control panel.
 func updateDatabases() {
 	for {
 		// Wait until the status is no longer "Initializing"
@@ -87,55 +111,92 @@ func updateDatabases() {
 		} else {
 
 			for _, db := range databases {
-				if db["datasetStatus"] == "Waiting" {
-					log.Printf("Processing Database ID: %s, Type: %s", db["id"], db["dbType"])
+				if db.DatasetStatus == "Waiting" {
+					log.Printf("Processing Database ID: %s, Type: %s", db.ID, db.DBType)
 
-					// Set status to In Progress before starting the work
-					err = updateDatabaseStatus(db["id"], "In Progress")
+					drv, err := driver.Get(db.DBType)
 					if err != nil {
+						log.Printf("Check Databases: Error: %v", err)
 						continue
 					}
 
-					switch db["dbType"] {
-					case "mysql":
-						go func(db map[string]string) {
-
-							err := writeDataFromMemoryToMySQL(db)
-
-							if err != nil {
-								log.Printf("MySQL process error: %v", err)
-								updateDatabaseStatus(db["id"], "Error")
-							} else {
-								updateDatabaseStatus(db["id"], "Done")
-							}
-						}(db)
-
-					case "postgres":
-						go func(db map[string]string) {
-
-							err := writeDataFromMemoryToPostgres(db)
-
-							if err != nil {
-								log.Printf("Postgres process error: %v", err)
-								updateDatabaseStatus(db["id"], "Error")
-							} else {
-								updateDatabaseStatus(db["id"], "Done")
+					// Set status to In Progress before starting the work, unless the import was stopped meanwhile
+					started, _, err := valkey.CompareAndSetDatabaseField(db.ID, app.FieldDatasetStatus, "Waiting", "In Progress")
+					if err != nil {
+						log.Printf("Error updating status for database %s: %v", db.ID, err)
+						continue
+					}
+					if !started {
+						continue
+					}
+					log.Printf("Status for database %s updated to In Progress", db.ID)
+
+					ctx, cancel := context.WithCancel(context.Background())
+					importsMu.Lock()
+					imports[db.ID] = cancel
+					importsMu.Unlock()
+
+					go func(db app.DatabaseConfig) {
+						metrics.DatabaseImportsInProgress.Inc()
+						defer metrics.DatabaseImportsInProgress.Dec()
+
+						defer func() {
+							importsMu.Lock()
+							delete(imports, db.ID)
+							importsMu.Unlock()
+							cancel()
+						}()
+
+						err := drv.ImportDataset(ctx, db, app.Dataset{Source: store, BatchSize: app.Config.App.DatasetBatchSize})
+
+						var schemaBehind *app.SchemaBehindError
+						switch {
+						case errors.As(err, &schemaBehind):
+							log.Printf("%s process error: %s: %v", drv.Name(), db.ID, err)
+							metrics.DatabaseImports.WithLabelValues(db.ID, "error").Inc()
+							// The schema was reverted, the status tells the operator to migrate it before the next import
+							updateDatabaseStatus(db.ID, schemaBehind.Error())
+						case errors.Is(err, context.Canceled):
+							log.Printf("%s process cancelled: %s", drv.Name(), db.ID)
+							metrics.DatabaseImports.WithLabelValues(db.ID, "cancelled").Inc()
+							// The status of a deleted database would recreate it
+							if _, err := valkey.GetDatabase(db.ID); err == nil {
+								updateDatabaseStatus(db.ID, "Cancelled")
 							}
-						}(db)
-
-					case "mongodb":
-						go func(db map[string]string) {
+						case err != nil:
+							log.Printf("%s process error: %v", drv.Name(), err)
+							metrics.DatabaseImports.WithLabelValues(db.ID, "error").Inc()
+							updateDatabaseStatus(db.ID, "Error")
+						default:
+							metrics.DatabaseImports.WithLabelValues(db.ID, "done").Inc()
+							updateDatabaseStatus(db.ID, "Done")
+							// The imported data is verified on the next check
+							updateVerifyStatus(db.ID, "Waiting")
+						}
+					}(db)
+					continue
+				}
 
-							err := writeDataFromMemoryToMongoDB(db)
+				// A stop requested when the import had finished already or before a restart of the dataset loader
+				// has no import to cancel
+				if db.DatasetStatus == "Cancelling" {
+					importsMu.Lock()
+					_, running := imports[db.ID]
+					importsMu.Unlock()
+					if !running {
+						resolveCancelling(db.ID)
+					}
+					continue
+				}
 
-							if err != nil {
-								log.Printf("MongoDB process error: %v", err)
-								updateDatabaseStatus(db["id"], "Error")
-							} else {
-								updateDatabaseStatus(db["id"], "Done")
-							}
-						}(db)
+				// A verification waits for the import of the database to finish
+				if db.VerifyStatus == "Waiting" && db.DatasetStatus != "In Progress" && db.DatasetStatus != "Cancelling" {
+					drv, err := driver.Get(db.DBType)
+					if err != nil {
+						log.Printf("Check Databases: Error: %v", err)
+						continue
 					}
+					startVerification(drv, db)
 				}
 			}
 
@@ -146,430 +207,97 @@ func updateDatabases() {
 	}
 }
 
-// updateStatus updates the dataset status of a given database in Valkey.
-//
-// Arguments:
-//   - dbID: string containing the database ID.
-//   - status: string containing the new status for the database.
-//
-// Returns:
-//   - error: An error object if an error occurs, otherwise nil.
-func updateDatabaseStatus(dbID, status string) error {
-	fields := map[string]string{
-		"datasetStatus": status,
-	}
+// watchImports cancels the running imports whose dataset status is no longer "In Progress": the control panel
+// sets "Cancelling" to stop an import and clears the status of a waiting one. Changes are applied as soon
+// as they are published, and the statuses are checked every 5 seconds in case an event was missed.
+func watchImports() {
+	events := valkey.SubscribeDatabaseEvents()
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
 
-	err := valkey.AddDatabase(dbID, fields)
-	if err != nil {
-		log.Printf("Error updating status for database %s: %v", dbID, err)
-		return err
-	}
-
-	log.Printf("Status for database %s updated to %s", dbID, status)
-	return nil
-}
-
-// writeDataFromMemoryToPostgres imports repository and pull request data into a PostgreSQL database.
-// It connects to the PostgreSQL database using the provided connection string,
-// fetches the latest update times for each repository, and then updates the database
-// with new or updated repositories and pull requests based on their last update time.
-//
-// Arguments:
-//   - dbConfig: map[string]string containing the database configuration,
-//     including the connection string under the key "connectionString".
-//
-// Returns:
-//   - error: An error object if an error occurs, otherwise nil.
-func writeDataFromMemoryToPostgres(dbConfig map[string]string) error {
-
-	log.Printf("%s process start: %v", dbConfig["dbType"], dbConfig["id"])
-
-	// Initialize the report for tracking the import process.
-	report := app.ReportDatabases{
-		Type:          "GitHub Pulls",
-		DB:            "PostgreSQL",
-		StartedAt:     time.Now().Format("2006-01-02T15:04:05.000"),
-		StartedAtUnix: time.Now().UnixMilli(),
-	}
-
-	// Connect to the PostgreSQL database.
-	db, err := postgres.ConnectByString(dbConfig["connectionString"])
-	if err != nil {
-		log.Printf("Databases: PostgreSQL: Start: Error: %s", err)
-		return err
-	}
-	defer db.Close()
-
-	log.Printf("Databases: PostgreSQL: Start")
-
-	// Get the latest update times for each repository from the PostgreSQL database.
-	pullsLastUpdate, err := postgres.GetPullsLatestUpdates(dbConfig)
-	if err != nil {
-		log.Printf("Error getting latest updates: %v", err)
-		return err
-	}
-
-	// Iterate over all repositories and update the database with new or updated repositories and pull requests.
-	for _, repo := range allReposData {
-		report.Counter.Repos++
-		id := repo.ID
-		repoJSON, err := json.Marshal(repo)
-		if err != nil {
-			return err
-		}
-
-		_, err = db.Exec("INSERT INTO github.repositories (id, data) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET data = $2", id, repoJSON)
-		if err != nil {
-			return err
-		}
-
-		if len(allPullsData) > 0 {
-			repoName := *repo.Name
-			pullRequests, exists := allPullsData[repoName]
-			if !exists || len(pullRequests) == 0 {
-				report.Counter.ReposWithoutPRs++
-			} else {
-				report.Counter.ReposWithPRs++
-
-				// Get the last update time for the current repository.
-				pullLastUpdate := pullsLastUpdate[repoName]
-				var lastUpdatedTime time.Time
-				if pullLastUpdate != "" {
-					lastUpdatedTime, err = time.Parse(time.RFC3339, pullLastUpdate)
-					if err != nil {
-						log.Printf("Error: PostgreSQL: lastUpdatedTime: parsing startedAt: %v", err)
-						lastUpdatedTime = time.Time{} // Reset lastUpdatedTime in case of error
-					}
-				}
-
-				// Iterate over all pull requests and update the database with new or updated pull requests.
-				for _, pull := range pullRequests {
-					// Skip processing if lastUpdatedTime is not empty and the pull request is older
-					if !lastUpdatedTime.IsZero() && pull.UpdatedAt != nil && lastUpdatedTime.After(*pull.UpdatedAt) {
-						continue
-					}
-
-					id := pull.ID
-					pullJSON, err := json.Marshal(pull)
-					if err != nil {
-						return err
-					}
-
-					res, err := db.Exec("INSERT INTO github.pulls (id, repo, data) VALUES ($1, $2, $3) ON CONFLICT (id, repo) DO UPDATE SET data = $3", id, *repo.Name, pullJSON)
-					if err != nil {
-						return err
-					}
-
-					// Check if the row has been updated or inserted.
-					rowsAffected, err := res.RowsAffected()
-					if err != nil {
-						return err
-					}
-
-					if rowsAffected == 1 {
-						report.Counter.PullsInserted++
-					} else {
-						report.Counter.PullsUpdated++
-					}
-				}
-
-				report.Counter.Pulls += len(pullRequests)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				log.Printf("Valkey: Subscription closed, falling back to polling")
+				events = nil
+				continue
 			}
+			importsMu.Lock()
+			cancel, running := imports[event.ID]
+			importsMu.Unlock()
+			if !running {
+				continue
+			}
+			if event.Action == "delete" {
+				log.Printf("Check Databases: Cancelling the import of %s: the database is deleted", event.ID)
+				cancel()
+				continue
+			}
+		case <-ticker.C:
 		}
-	}
 
-	// Finalize the report with end times and total duration.
-	report.FinishedAt = time.Now().Format("2006-01-02T15:04:05.000")
-	report.FinishedAtUnix = time.Now().UnixMilli()
-	report.TotalMilli = report.FinishedAtUnix - report.StartedAtUnix
-	reportJSON, err := json.Marshal(report)
-	if err != nil {
-		return err
-	}
-	log.Printf("Datasets: PostgreSQL: Finish: Report: %s", reportJSON)
-	_, err = db.Exec("INSERT INTO github.reports_dataset (data) VALUES ($1)", reportJSON)
-	if err != nil {
-		return err
+		cancelStoppedImports()
 	}
-
-	log.Printf("%s process complete for database ID: %v", dbConfig["dbType"], dbConfig["id"])
-
-	return nil
 }
 
-// writeDataFromMemoryToMySQL imports repository and pull request data into a MySQL database.
-// It connects to the MySQL database using the provided connection string,
-// fetches the latest update times for each repository, and then updates the database
-// with new or updated repositories and pull requests based on their last update time.
-//
-// Arguments:
-//   - dbConfig: map[string]string containing the database configuration,
-//     including the connection string under the key "connectionString".
-//
-// Returns:
-//   - error: An error object if an error occurs, otherwise nil.
-func writeDataFromMemoryToMySQL(dbConfig map[string]string) error {
-	log.Printf("%s process start: %v", dbConfig["dbType"], dbConfig["id"])
-
-	// Initialize the report for tracking the import process.
-	report := app.ReportDatabases{
-		Type:          "GitHub Pulls",
-		DB:            "MySQL",
-		StartedAt:     time.Now().Format("2006-01-02T15:04:05.000"),
-		StartedAtUnix: time.Now().UnixMilli(),
-	}
-
-	// Connect to the MySQL database.
-	db, err := mysql.ConnectByString(dbConfig["connectionString"])
-	if err != nil {
-		log.Printf("Databases: MySQL: Error: message: %s", err)
-		return err
-	}
-	defer db.Close()
-
-	log.Printf("Databases: MySQL: Start")
-
-	// Get the latest update times for each repository from the MySQL database.
-	pullsLastUpdate, err := mysql.GetPullsLatestUpdates(dbConfig)
-	if err != nil {
-		log.Printf("Error getting latest updates: %v", err)
-		return err
+// cancelStoppedImports cancels the running imports of the databases that are no longer "In Progress".
+func cancelStoppedImports() {
+	importsMu.Lock()
+	running := make(map[string]context.CancelFunc, len(imports))
+	for id, cancel := range imports {
+		running[id] = cancel
 	}
+	importsMu.Unlock()
 
-	// Iterate over all repositories and update the database with new or updated repositories and pull requests.
-	for _, repo := range allReposData {
-		report.Counter.Repos++
-		id := repo.ID
-		repoJSON, err := json.Marshal(repo)
+	for id, cancel := range running {
+		db, err := valkey.GetDatabase(id)
 		if err != nil {
-			return err
+			log.Printf("Check Databases: Error: %v", err)
+			continue
 		}
-
-		_, err = db.Exec("INSERT INTO repositories (id, data) VALUES (?, ?) ON DUPLICATE KEY UPDATE data = ?", id, repoJSON, repoJSON)
-		if err != nil {
-			return err
-		}
-
-		if len(allPullsData) > 0 {
-			repoName := *repo.Name
-
-			pullRequests, exists := allPullsData[repoName]
-			if !exists || len(pullRequests) == 0 {
-				report.Counter.ReposWithoutPRs++
-			} else {
-				report.Counter.ReposWithPRs++
-
-				// Get the last update time for the current repository.
-				pullLastUpdate := pullsLastUpdate[repoName]
-				var lastUpdatedTime time.Time
-				if pullLastUpdate != "" {
-					lastUpdatedTime, err = time.Parse(time.RFC3339, pullLastUpdate)
-					if err != nil {
-						log.Printf("Error parsing startedAt: %v", err)
-						lastUpdatedTime = time.Time{} // Reset lastUpdatedTime in case of error
-					}
-				}
-
-				// Iterate over all pull requests and update the database with new or updated pull requests.
-				for _, pull := range pullRequests {
-					// Skip processing if lastUpdatedTime is not empty and the pull request is older
-					if !lastUpdatedTime.IsZero() && pull.UpdatedAt != nil && lastUpdatedTime.After(*pull.UpdatedAt) {
-						continue
-					}
-
-					id := pull.ID
-					pullJSON, err := json.Marshal(pull)
-					if err != nil {
-						return err
-					}
-
-					res, err := db.Exec("INSERT INTO pulls (id, repo, data) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE data = ?", id, *repo.Name, pullJSON, pullJSON)
-					if err != nil {
-						return err
-					}
-
-					rowsAffected, err := res.RowsAffected()
-					if err != nil {
-						return err
-					}
-
-					if rowsAffected == 1 {
-						report.Counter.PullsInserted++
-					} else if rowsAffected == 2 {
-						report.Counter.PullsUpdated++
-					}
-				}
-
-				report.Counter.Pulls += len(pullRequests)
-			}
+		if db.DatasetStatus != "In Progress" {
+			log.Printf("Check Databases: Cancelling the import of %s: status %q", id, db.DatasetStatus)
+			cancel()
 		}
 	}
+}
 
-	// Finalize the report with end times and total duration.
-	report.FinishedAt = time.Now().Format("2006-01-02T15:04:05.000")
-	report.FinishedAtUnix = time.Now().UnixMilli()
-	report.TotalMilli = report.FinishedAtUnix - report.StartedAtUnix
-
-	reportJSON, err := json.Marshal(report)
+// resolveCancelling sets "Cancelled" for a database that is "Cancelling" without a running import,
+// e.g. after a restart of the dataset loader. The status is changed only if it has not been changed meanwhile.
+func resolveCancelling(dbID string) {
+	set, _, err := valkey.CompareAndSetDatabaseField(dbID, app.FieldDatasetStatus, "Cancelling", "Cancelled")
 	if err != nil {
-		return err
+		log.Printf("Error updating status for database %s: %v", dbID, err)
+		return
 	}
-	log.Printf("Databases: MySQL: Finish: Report: %s", reportJSON)
-	_, err = db.Exec("INSERT INTO reports_dataset (data) VALUES (?)", reportJSON)
-	if err != nil {
-		return err
+	if set {
+		log.Printf("Check Databases: Import of %s is not running, status updated to Cancelled", dbID)
 	}
-
-	log.Printf("%s process complete for database ID: %v", dbConfig["dbType"], dbConfig["id"])
-
-	return nil
 }
 
-// writeDataFromMemoryToMongoDB imports repository and pull request data into a MongoDB database.
-// It connects to the MongoDB database using the provided connection string,
-// fetches the latest update times for each repository, and then updates the database
-// with new or updated repositories and pull requests based on their last update time.
+// updateStatus updates the dataset status of a given database in Valkey.
 //
 // Arguments:
-//   - dbConfig: map[string]string containing the database configuration,
-//     including the connection string under the key "connectionString".
+//   - dbID: string containing the database ID.
+//   - status: string containing the new status for the database.
 //
 // Returns:
 //   - error: An error object if an error occurs, otherwise nil.
-func writeDataFromMemoryToMongoDB(dbConfig map[string]string) error {
-	log.Printf("%s process start: %v", dbConfig["dbType"], dbConfig["id"])
-
-	// Initialize the report for tracking the import process.
-	report := app.ReportDatabases{
-		Type:          "GitHub Pulls",
-		DB:            "MongoDB",
-		StartedAt:     time.Now().Format("2006-01-02T15:04:05.000"),
-		StartedAtUnix: time.Now().UnixMilli(),
-	}
-
-	ctx := context.Background()
-
-	// Connect to the MongoDB database.
-	client, err := mongodb.ConnectByString(dbConfig["connectionString"], ctx)
-	if err != nil {
-		log.Printf("MongoDB: Connect Error: message: %s", err)
-		return err
-	}
-	defer client.Disconnect(ctx)
-
-	log.Printf("Databases: MongoDB: Start")
-
-	db := client.Database(dbConfig["database"])
-	dbCollectionRepos := db.Collection("repositories")
-	dbCollectionPulls := db.Collection("pulls")
-
-	profileCmd := bson.D{
-		{Key: "profile", Value: 2},
-		{Key: "slowms", Value: 200},
-		{Key: "ratelimit", Value: 100},
-	}
-	var result bson.M
-	if err := db.RunCommand(ctx, profileCmd).Decode(&result); err != nil {
-		log.Printf("Error setting profiling: %v", err)
-	} else {
-		log.Printf("Profiling command result: %v", result)
-	}
-
-	// Create an index by id and repo fields
-	indexKeys := bson.D{
-		{Key: "id", Value: 1},
-		{Key: "repo", Value: 1},
-	}
-	if err := mongodb.CreateIndex(client, dbConfig["database"], "pulls", indexKeys, true); err != nil {
-		log.Printf("Error: MongoDB: %s: CreateIndex: %v", dbConfig["id"], err)
-		return err
-	}
-
-	// Get the latest update times for each repository from the MongoDB database.
-	pullsLastUpdate, err := mongodb.GetPullsLatestUpdates(dbConfig)
-	if err != nil {
-		log.Printf("Error getting latest updates: %v", err)
-		return err
-	}
-
-	// Iterate over all repositories and update the database with new or updated repositories and pull requests.
-	for _, repo := range allReposData {
-		report.Counter.Repos++
-		filter := bson.M{"id": repo.ID}
-		update := bson.M{"$set": repo}
-
-		_, err := dbCollectionRepos.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
-		if err != nil {
-			return err
-		}
-
-		if len(allPullsData) > 0 {
-			repoName := *repo.Name
-
-			pullRequests, exists := allPullsData[repoName]
-			if !exists || len(pullRequests) == 0 {
-				report.Counter.ReposWithoutPRs++
-			} else {
-				report.Counter.ReposWithPRs++
-
-				// Get the last update time for the current repository.
-				pullLastUpdate := pullsLastUpdate[repoName]
-				var lastUpdatedTime time.Time
-				if pullLastUpdate != "" {
-					lastUpdatedTime, err = time.Parse(time.RFC3339, pullLastUpdate)
-					if err != nil {
-						log.Printf("Error parsing startedAt: %v", err)
-						lastUpdatedTime = time.Time{} // Reset lastUpdatedTime in case of error
-					}
-				}
-
-				// Iterate over all pull requests and update the database with new or updated pull requests.
-				for _, pull := range pullRequests {
-					// Skip processing if lastUpdatedTime is not empty and the pull request is older
-					if !lastUpdatedTime.IsZero() && pull.UpdatedAt != nil && lastUpdatedTime.After(*pull.UpdatedAt) {
-						continue
-					}
-
-					filter := bson.M{"id": pull.ID, "repo": repoName}
-					update := bson.M{"$set": pull}
-
-					res, err := dbCollectionPulls.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
-					if err != nil {
-						return err
-					}
-					if res.UpsertedCount > 0 {
-						report.Counter.PullsInserted++
-					} else if res.MatchedCount > 0 {
-						report.Counter.PullsUpdated++
-					}
-				}
-
-				report.Counter.Pulls += len(pullRequests)
-			}
-		}
-	}
-
-	// Finalize the report with end times and total duration.
-	report.FinishedAt = time.Now().Format("2006-01-02T15:04:05.000")
-	report.FinishedAtUnix = time.Now().UnixMilli()
-	report.TotalMilli = report.FinishedAtUnix - report.StartedAtUnix
+func updateDatabaseStatus(dbID, status string) error {
+	db := app.DatabaseConfig{ID: dbID, DatasetStatus: status}
 
-	reportJSON, err := json.Marshal(report)
-	if err != nil {
-		return err
-	}
-	log.Printf("Databases: MongoDB: Finish: Report: %s", reportJSON)
-	dbCollectionReport := db.Collection("reports_dataset")
-	_, err = dbCollectionReport.InsertOne(ctx, report)
+	err := valkey.AddDatabase(db, app.FieldDatasetStatus)
 	if err != nil {
+		log.Printf("Error updating status for database %s: %v", dbID, err)
 		return err
 	}
 
-	log.Printf("%s process complete for database ID: %v", dbConfig["dbType"], dbConfig["id"])
+	log.Printf("Status for database %s updated to %s", dbID, status)
 	return nil
 }
 
-// updateDatasetData continuously updates dataset data by importing from GitHub API or CSV files into memory.
+// updateDatasetData continuously updates dataset data by importing from GitHub API or CSV files into the store.
 // It logs memory usage and waits for a specified delay before the next import cycle.
 func updateDatasetData() {
 	for {
@@ -579,11 +307,14 @@ func updateDatasetData() {
 			setStatus("Updating")
 		}
 
-		// The main process of getting data from GitHub API and storing it into memory.
-		if app.Config.App.DatasetLoadType == "github" {
-			importGitHubToMemory(app.Config)
-		} else {
-			importCSVToMemory(app.Config)
+		// The main process of getting data from GitHub API, CSV files or the generator and storing it on disk.
+		switch app.Config.App.DatasetLoadType {
+		case "github", "github_graphql":
+			importGitHubToStore(app.Config)
+		case "synthetic":
+			importSyntheticToStore(app.Config)
+		default:
+			importCSVToStore(app.Config)
 		}
 
 		// Log current memory usage
@@ -596,22 +327,56 @@ func updateDatasetData() {
 	}
 }
 
-// importGitHubToMemory imports data from GitHub repositories and stores it in memory.
-// It fetches all repositories and their pull requests, then updates global data structures.
-func importGitHubToMemory(envVars app.EnvVars) {
+// githubStoreSource returns the source of the GitHub data in the store. It does not depend on GITHUB_SOURCES,
+// so adding or removing a source keeps the stored repositories, the ones that left the sources are removed by Retain.
+// A store of an earlier version is keyed by the organization, e.g. "github:percona", and keeps its key.
+func githubStoreSource() string {
+	if current, _ := store.Source(); strings.HasPrefix(current, "github:") {
+		return current
+	}
+	return "github"
+}
+
+// importGitHubToStore imports data from GitHub repositories and stores it on disk.
+// It fetches all repositories and their pull requests and merges them into the store one repository at a time.
+// The fetch progress of every repository is saved in Valkey, so only the updated pull requests are fetched
+// and an interrupted fetch continues from its last page, also after a restart.
+// With DATASET_LOAD_TYPE=github_graphql the repositories and pull requests are fetched with the GraphQL API,
+// the pull requests of several repositories by one query.
+func importGitHubToStore(envVars app.EnvVars) {
 
 	report := helperReportStart()
 
-	// Get all the repositories of the organization.
-	allRepos, counterReposApi, err := app.FetchGitHubRepos(envVars)
+	if err := store.Begin(githubStoreSource()); err != nil {
+		log.Printf("Error: importGitHubToStore: %v", err)
+		return
+	}
+
+	graphQL := envVars.App.DatasetLoadType == "github_graphql"
+	fetchRepos := app.FetchGitHubRepos
+	if graphQL {
+		fetchRepos = app.FetchGitHubReposGraphQL
+	}
+
+	// Get the repositories of all organizations, users and repositories of GITHUB_SOURCES.
+	allRepos, repoSources, counterReposApi, err := fetchRepos(envVars)
 	if err != nil {
 		log.Printf("Error: FetchGitHubRepos: %v", err)
 	}
+	reposComplete := err == nil
 
 	report.Timer["ApiRepos"] = time.Now().UnixMilli()
 
-	// For DEBUG mode. Keep only 4 repositories out of many to speed up the complete process.
-	allRepos = filterRepos(envVars, allRepos)
+	report.Sources = make(map[string]*app.ReportSource)
+	for _, source := range envVars.GitHub.Sources {
+		report.Sources[source.String()] = &app.ReportSource{}
+	}
+	for _, repo := range allRepos {
+		report.Sources[repoSources[repo.GetID()]].Repos++
+	}
+
+	metrics.ImportRepos.WithLabelValues("total").Set(float64(len(allRepos)))
+	metrics.ImportRepos.WithLabelValues("processed").Set(0)
 
 	counter := map[string]*int{
 		"pulls_api_requests": new(int),
@@ -621,99 +386,227 @@ func importGitHubToMemory(envVars app.EnvVars) {
 		"repos":              new(int),
 		"repos_full":         new(int),
 		"repos_latest":       new(int),
+		"repos_not_modified": new(int),
+		// Entities besides the pull requests (DATASET_GITHUB_ENTITIES)
+		"details_api_requests": new(int),
+		"issues":               new(int),
+		"reviews":              new(int),
+		"review_comments":      new(int),
+		"commits":              new(int),
+		// Queries of the GraphQL API, their rate limit points and the REST requests of the same pages
+		"graphql_api_requests": new(int),
+		"graphql_cost":         new(int),
+		"pulls_rest_estimate":  new(int),
 	}
 
-	if envVars.GitHub.Token != "" {
+	// Pull requests are fetched only with authenticated requests, the anonymous rate limit is too low
+	if envVars.GitHub.Authenticated() {
 		log.Printf("Check Latest Updates: Start")
-		// Get the latest Pull Requests updates to download only the new ones. Will download all Pull Requests on the first run.
-		pullsLastUpdate, err := getLatestUpdatesFromData(allRepos)
-		if err != nil {
-			log.Printf("getLatestUpdates: %v", err)
-		}
+		// Get the checkpoints to download only the new Pull Requests. Will download all Pull Requests on the first run.
+		checkpoints := getCheckpoints(allRepos)
 
 		report.Timer["DBLatestUpdates"] = time.Now().UnixMilli()
 
-		// Get Pull Requests for all repositories.
-		for _, repo := range allRepos {
-			log.Printf("GitHub API: Start: Repo: %s", *repo.Name)
-
-			repoID := repo.GetID()
-			repoName := repo.GetName()
+		// Every page is kept on disk with its checkpoint, a restarted fetch continues from the last page
+		savePage := func(repo *github.Repository, pulls []*github.PullRequest, checkpoint app.GitHubCheckpoint) error {
+			if err := store.AppendPending(app.RepoData{Repo: repo, Pulls: pulls}); err != nil {
+				return err
+			}
+			report.Sources[repoSources[repo.GetID()]].Pulls += len(pulls)
+			return valkey.SaveGitHubCheckpoint(repo.GetFullName(), checkpoint)
+		}
 
-			var allPulls []*github.PullRequest
+		// The other entities are fetched when the pull requests of the repository are complete,
+		// then the repository is written into the store
+		done := func(repo *github.Repository, checkpoint app.GitHubCheckpoint, err error) {
+			repoName := repo.GetFullName()
+			full := checkpoints[repoName].Full()
 
-			allPulls, err = app.FetchGitHubPullsByRepo(envVars, repo, pullsLastUpdate, counter)
 			if err != nil {
-				log.Printf("FetchGitHubPullsByRepos: %v", err)
+				log.Printf("FetchGitHubPullsByRepos: Repo: %s: %v", repoName, err)
+			} else if err := fetchGitHubDetails(envVars, repo, &checkpoint, full, counter); err != nil {
+				log.Printf("FetchGitHubDetails: Repo: %s: %v", repoName, err)
+			} else if err := store.CommitPending(repo, full); err != nil {
+				log.Printf("Error: importGitHubToStore: Repo: %s: %v", repoName, err)
+			} else if err := valkey.SaveGitHubCheckpoint(repoName, checkpoint); err != nil {
+				log.Printf("Error: importGitHubToStore: Checkpoint: %s: %v", repoName, err)
 			}
 
-			allReposData[repoID] = repo
-
-			if _, exists := allPullsData[repoName]; !exists {
-				allPullsData[repoName] = make(map[int64]*github.PullRequest)
-			}
+			metrics.ImportRepos.WithLabelValues("processed").Inc()
 
-			for _, pull := range allPulls {
-				allPullsData[repoName][pull.GetID()] = pull
+			reposCount, _ := store.Counts()
+			if statusData == "Initializing" && reposCount > 20 {
+				setStatus("Updating")
 			}
 
-			if statusData == "Initializing" && len(allReposData) > 20 {
+			// Simple update of the amount of stored data for the control panel.
+			if statusData == "Active" && reposCount > 30 {
 				setStatus("Updating")
 			}
 
-			// Simple update of the amount of data in memory for the control panel.
-			if statusData == "Active" && len(allReposData) > 30 {
-				setStatus("Updating")
+			// Refresh the amount of data and the GitHub API rate limit in the status
+			publishStatus()
+		}
+
+		// Get Pull Requests for all repositories.
+		if graphQL {
+			app.FetchGitHubPullsGraphQL(envVars, allRepos, checkpoints, counter, savePage, done)
+		} else {
+			for _, repo := range allRepos {
+				log.Printf("GitHub API: Start: Repo: %s", *repo.Name)
+
+				checkpoint, err := app.FetchGitHubPullsByRepo(envVars, repo, checkpoints[repo.GetFullName()], counter,
+					func(pulls []*github.PullRequest, checkpoint app.GitHubCheckpoint) error {
+						return savePage(repo, pulls, checkpoint)
+					})
+				done(repo, checkpoint, err)
 			}
 		}
 
 		report.Timer["ApiPulls"] = time.Now().UnixMilli()
+
+		// Repositories excluded by the filters or removed from the sources leave the dataset,
+		// unless a source has failed and its repositories are unknown
+		if reposComplete {
+			keep := make(map[int64]bool, len(allRepos))
+			for _, repo := range allRepos {
+				keep[repo.GetID()] = true
+			}
+			if err := store.Retain(keep); err != nil {
+				log.Printf("Error: importGitHubToStore: %v", err)
+			}
+		}
+
+		if err := store.Finish(); err != nil {
+			log.Printf("Error: importGitHubToStore: %v", err)
+		}
 	}
 
 	counterMap := make(map[string]int)
 	for k, v := range counter {
 		counterMap[k] = *v
 	}
-	counterMap["repos_api_requests"] = counterReposApi
+
+	// The other entities are always fetched with the REST API
+	requests := &app.ReportRequests{REST: counterMap["details_api_requests"]}
+	if graphQL {
+		counterMap["graphql_api_requests"] += counterReposApi
+		requests.GraphQL = counterMap["graphql_api_requests"]
+		requests.GraphQLCost = counterMap["graphql_cost"]
+		requests.RESTEstimate = requests.REST + counterReposApi + counterMap["pulls_rest_estimate"]
+	} else {
+		counterMap["repos_api_requests"] = counterReposApi
+		requests.REST += counterReposApi + counterMap["pulls_api_requests"]
+		requests.RESTEstimate = requests.REST
+	}
+	report.Requests = requests
+
+	log.Printf("GitHub API: Requests: REST: %d, GraphQL: %d (cost %d), REST only: %d",
+		requests.REST, requests.GraphQL, requests.GraphQLCost, requests.RESTEstimate)
 
 	helperReportFinish(envVars, report, counterMap)
 }
 
-// getLatestUpdatesFromData retrieves the latest update times for each repository from the in-memory data.
-// It iterates over all repositories and calculates the latest update time for each repository.
+// fetchGitHubDetails fetches the issues, reviews, review comments and commits of the repository
+// enabled in DATASET_GITHUB_ENTITIES into its pending file. An update fetches the entities changed since
+// the previous fetch and the reviews of the updated pull requests, a full fetch fetches all of them.
+// DetailsSince of the checkpoint is set to the start of the fetch.
 //
 // Arguments:
-//   - allRepos: []*github.Repository containing all repositories.
+//   - envVars: app.EnvVars containing environment variables and configuration.
+//   - repo: *github.Repository to fetch the entities of.
+//   - checkpoint: *app.GitHubCheckpoint containing the fetch progress of the repository.
+//   - full: bool, true if the pull requests of the repository were fetched completely.
+//   - counter: map[string]*int containing the counters of the report.
 //
 // Returns:
-//   - map[string]*app.PullsLastUpdate: A map where keys are repository names and values are the latest update times.
 //   - error: An error object if an error occurs, otherwise nil.
-func getLatestUpdatesFromData(allRepos []*github.Repository) (map[string]*app.PullsLastUpdate, error) {
-	lastUpdates := make(map[string]*app.PullsLastUpdate)
+func fetchGitHubDetails(envVars app.EnvVars, repo *github.Repository, checkpoint *app.GitHubCheckpoint, full bool, counter map[string]*int) error {
+	if len(envVars.GitHub.Entities) == 0 {
+		return nil
+	}
+
+	startedAt := time.Now().UTC()
 
+	var since time.Time
+	if !full && checkpoint.DetailsSince != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, checkpoint.DetailsSince); err != nil {
+			log.Printf("Error: fetchGitHubDetails: Repo: %s: DetailsSince: %v", repo.GetFullName(), err)
+		}
+	}
+
+	// Reviews are listed per pull request, only the pull requests of this fetch can have new ones
+	var pullNumbers []int
+	if envVars.GitHub.Entities["reviews"] {
+		pending, err := store.Pending(repo.GetID())
+		if err != nil {
+			return err
+		}
+		for _, pull := range pending.Pulls {
+			pullNumbers = append(pullNumbers, pull.GetNumber())
+		}
+	}
+
+	err := app.FetchGitHubDetails(envVars, repo, since, pullNumbers, counter, func(details app.RepoDetails) error {
+		return store.AppendPending(app.RepoData{Repo: repo, RepoDetails: details})
+	})
+	if err != nil {
+		return err
+	}
+
+	checkpoint.DetailsSince = startedAt.Format(time.RFC3339)
+	return nil
+}
+
+// getCheckpoints retrieves the GitHub fetch checkpoints of the repositories from Valkey.
+// Repositories missing in the store are fetched completely. If there are no checkpoints yet,
+// e.g. after an upgrade or when Valkey is not available, they are created from the stored data.
+//
+// Arguments:
+//   - allRepos: []*github.Repository containing all repositories.
+//
+// Returns:
+//   - map[string]app.GitHubCheckpoint: The checkpoints by full repository name.
+func getCheckpoints(allRepos []*github.Repository) map[string]app.GitHubCheckpoint {
+	checkpoints, err := valkey.GetGitHubCheckpoints()
+	if err != nil {
+		log.Printf("Error: getCheckpoints: %v", err)
+		checkpoints = make(map[string]app.GitHubCheckpoint)
+	}
+
+	fromStore := len(checkpoints) == 0
+	stored := store.LastUpdates()
+
+	result := make(map[string]app.GitHubCheckpoint, len(allRepos))
 	for _, repo := range allRepos {
-		repoName := repo.GetName()
-		lastUpdates[repoName] = &app.PullsLastUpdate{}
-
-		if pulls, ok := allPullsData[repoName]; ok {
-			var lastUpdateTime time.Time
-			for _, pull := range pulls {
-				if pull.UpdatedAt != nil && pull.UpdatedAt.After(lastUpdateTime) {
-					lastUpdateTime = *pull.UpdatedAt
-				}
+		repoName := repo.GetFullName()
+		checkpoint, ok := checkpoints[repoName]
+
+		switch {
+		case !store.Has(repo.GetID()):
+			checkpoint = app.GitHubCheckpoint{}
+		case !ok && fromStore:
+			if lastUpdate, ok := stored[repo.GetName()]; ok {
+				checkpoint = app.GitHubCheckpoint{LastUpdate: lastUpdate.UTC().Format(time.RFC3339)}
 			}
-			lastUpdates[repoName].Minimum = lastUpdateTime.Format(time.RFC3339)
-		} else {
-			lastUpdates[repoName].Force = true
 		}
+
+		result[repoName] = checkpoint
 	}
 
-	return lastUpdates, nil
+	return result
 }
 
-// importCSVToMemory imports data from CSV files and stores it in memory.
-// It reads repository and pull request data from CSV files and updates global data structures.
-func importCSVToMemory(envVars app.EnvVars) error {
+// csvFlushRecords is the number of entities read from the CSV files before they are written into the store.
+const csvFlushRecords = 10000
+
+// importCSVToStore imports data from CSV files and stores it on disk.
+// The pull requests and the other entities are read record by record and appended to the pending files
+// of their repositories, so only csvFlushRecords entities are held in memory. Once all files are read,
+// the pending entities replace the stored ones, one repository at a time. Repositories missing in the files
+// are removed from the store.
+func importCSVToStore(envVars app.EnvVars) error {
 	report := helperReportStart()
 
 	allRepos, err := getReposCSV(envVars)
@@ -722,333 +615,316 @@ func importCSVToMemory(envVars app.EnvVars) error {
 		return err
 	}
 
-	if statusData == "Initializing" {
-		setStatus("Updating")
-	}
-
 	report.Timer["allRepos"] = time.Now().UnixMilli()
 
-	allPulls, err := getPullsCSV(envVars)
-	if err != nil {
-		log.Printf("importCSVToDB: Pulls: Error: %v", err)
-		return err
+	// The files of the other entities are optional
+	entityFiles := []struct {
+		entity   string
+		filePath string
+	}{
+		{"issues", envVars.App.DatasetDemoIssues},
+		{"reviews", envVars.App.DatasetDemoReviews},
+		{"review_comments", envVars.App.DatasetDemoReviewComments},
+		{"commits", envVars.App.DatasetDemoCommits},
 	}
 
-	report.Timer["allPulls"] = time.Now().UnixMilli()
-
-	allPullsData = make(map[string]map[int64]*github.PullRequest)
-	for _, pull := range allPulls {
-		repoName := pull.Base.Repo.GetName()
-		if allPullsData[repoName] == nil {
-			allPullsData[repoName] = make(map[int64]*github.PullRequest)
+	source := "csv:" + envVars.App.DatasetDemoRepos + ":" + envVars.App.DatasetDemoPulls
+	for _, f := range entityFiles {
+		if f.filePath != "" {
+			source += ":" + f.filePath
 		}
-		allPullsData[repoName][pull.GetID()] = pull
 	}
+	if err := store.Begin(source); err != nil {
+		log.Printf("importCSVToDB: Store: Error: %v", err)
+		return err
+	}
+
+	metrics.ImportRepos.WithLabelValues("total").Set(float64(len(allRepos)))
+	metrics.ImportRepos.WithLabelValues("processed").Set(0)
 
-	allReposData = make(map[int64]*github.Repository)
+	reposByName := make(map[string]*github.Repository, len(allRepos))
 	for _, repo := range allRepos {
-		repoID := repo.GetID()
-		allReposData[repoID] = repo
+		reposByName[repo.GetName()] = repo
 	}
 
-	counter := map[string]int{
-		"pulls": len(allPulls),
-		"repos": len(allRepos),
+	// The files are read from the start, the entities left by an interrupted import are read again
+	for _, repo := range allRepos {
+		if err := store.DiscardPending(repo.GetID()); err != nil {
+			log.Printf("importCSVToDB: Store: Error: %v", err)
+			return err
+		}
 	}
 
-	helperReportFinish(envVars, report, counter)
-
-	return nil
-}
-
-func getPullsCSV(envVars app.EnvVars) ([]*github.PullRequest, error) {
-	filePath := envVars.App.DatasetDemoPulls
+	pending := make(map[string]*app.RepoData)
+	pendingCount := 0
+	counter := make(map[string]int)
 
-	if strings.HasPrefix(filePath, "http://") || strings.HasPrefix(filePath, "https://") {
-		log.Printf("getPullsCSV: Downloading file from URL: %s", filePath)
-		tempFile, err := downloadFileFromURL(filePath)
-		if err != nil {
-			log.Printf("getPullsCSV: DownloadFileFromURL: Error: %v", err)
-			return nil, err
+	flush := func() error {
+		for _, data := range pending {
+			if err := store.AppendPending(*data); err != nil {
+				return err
+			}
 		}
-		defer os.Remove(tempFile)
-		filePath = tempFile
+		pending = make(map[string]*app.RepoData)
+		pendingCount = 0
+		return nil
 	}
 
-	var allPulls []*github.PullRequest
-
-	ext := strings.ToLower(filepath.Ext(filePath))
-	if ext == ".zip" {
-		zipFile, err := zip.OpenReader(filePath)
-		if err != nil {
-			log.Printf("getPullsCSV: Open ZIP: Error: %v", err)
-			return nil, err
+	// add adds an entity to the pending data of its repository, entities of unknown repositories are skipped
+	add := func(entity string, repoName string, addTo func(data *app.RepoData)) error {
+		repo, ok := reposByName[repoName]
+		if !ok {
+			return nil
 		}
-		defer zipFile.Close()
 
-		for _, f := range zipFile.File {
-			if !strings.HasSuffix(f.Name, ".csv") {
-				continue
-			}
+		data := pending[repoName]
+		if data == nil {
+			data = &app.RepoData{Repo: repo}
+			pending[repoName] = data
+		}
+		addTo(data)
+		pendingCount++
+		counter[entity]++
 
-			rc, err := f.Open()
-			if err != nil {
-				log.Printf("getPullsCSV: Open CSV in ZIP: Error: %v", err)
-				return nil, err
+		if pendingCount >= csvFlushRecords {
+			if err := flush(); err != nil {
+				return err
 			}
-			defer rc.Close()
-
-			reader := csv.NewReader(rc)
-			reader.LazyQuotes = true
-			records, err := reader.ReadAll()
-			if err != nil {
-				log.Printf("getPullsCSV: ZIP: CSV: ReadAll: Error: %v", err)
-				return nil, err
-			}
-
-			allPulls, err = processPullsRecords(records, allPulls)
-			if err != nil {
-				return nil, err
+			if statusData == "Initializing" {
+				setStatus("Updating")
 			}
 		}
-	} else if ext == ".csv" {
-		file, err := os.Open(filePath)
-		if err != nil {
-			log.Printf("getPullsCSV: Open CSV: Error: %v", err)
-			return nil, err
-		}
-		defer file.Close()
+		return nil
+	}
 
-		reader := csv.NewReader(file)
-		reader.LazyQuotes = true
-		records, err := reader.ReadAll()
-		if err != nil {
-			log.Printf("getPullsCSV: ReadAll: CSV: Error: %v", err)
-			return nil, err
+	err = eachPullCSV(envVars, func(pull *github.PullRequest) error {
+		return add("pulls", pull.Base.Repo.GetName(), func(data *app.RepoData) { data.Pulls = append(data.Pulls, pull) })
+	})
+
+	for _, f := range entityFiles {
+		if err != nil || f.filePath == "" {
+			continue
 		}
 
-		allPulls, err = processPullsRecords(records, allPulls)
-		if err != nil {
-			return nil, err
+		switch f.entity {
+		case "issues":
+			err = eachEntityCSV("getIssuesCSV", f.filePath, f.entity, repoOfIssue, func(repoName string, issue *github.Issue) error {
+				return add(f.entity, repoName, func(data *app.RepoData) { data.Issues = append(data.Issues, issue) })
+			})
+		case "reviews":
+			err = eachEntityCSV("getReviewsCSV", f.filePath, f.entity, repoOfReview, func(repoName string, review *github.PullRequestReview) error {
+				return add(f.entity, repoName, func(data *app.RepoData) { data.Reviews = append(data.Reviews, review) })
+			})
+		case "review_comments":
+			err = eachEntityCSV("getReviewCommentsCSV", f.filePath, f.entity, repoOfReviewComment, func(repoName string, comment *github.PullRequestComment) error {
+				return add(f.entity, repoName, func(data *app.RepoData) { data.ReviewComments = append(data.ReviewComments, comment) })
+			})
+		case "commits":
+			err = eachEntityCSV("getCommitsCSV", f.filePath, f.entity, repoOfCommit, func(repoName string, commit *github.RepositoryCommit) error {
+				return add(f.entity, repoName, func(data *app.RepoData) { data.Commits = append(data.Commits, commit) })
+			})
 		}
-	} else {
-		return nil, fmt.Errorf("getPullsCSV: unsupported file type: %s", ext)
+	}
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		log.Printf("importCSVToDB: Entities: Error: %v", err)
+		return err
 	}
 
-	return allPulls, nil
-}
+	report.Timer["allPulls"] = time.Now().UnixMilli()
 
-func processPullsRecords(records [][]string, allPulls []*github.PullRequest) ([]*github.PullRequest, error) {
-	for i, record := range records {
-		if i == 0 {
-			continue
+	// The entities of every repository replace those of the previous import, repositories without entities
+	// are stored empty, and the repositories that are not in the files anymore are removed
+	keep := make(map[int64]bool, len(allRepos))
+	for _, repo := range allRepos {
+		keep[repo.GetID()] = true
+		if err := store.CommitPending(repo, true); err != nil {
+			log.Printf("importCSVToDB: Repos: Error: %v", err)
+			return err
 		}
+		metrics.ImportRepos.WithLabelValues("processed").Inc()
+	}
+	if err := store.Retain(keep); err != nil {
+		log.Printf("importCSVToDB: Store: Error: %v", err)
+		return err
+	}
+	if err := store.Finish(); err != nil {
+		log.Printf("importCSVToDB: Store: Error: %v", err)
+		return err
+	}
 
-		data := record[2]
+	if statusData == "Initializing" {
+		setStatus("Updating")
+	}
 
-		var pullRequest github.PullRequest
+	counter["repos"] = len(allRepos)
 
-		err := json.Unmarshal([]byte(data), &pullRequest)
-		if err != nil {
-			log.Printf("processPullsRecords: Unmarshal: Error: %v", err)
-			return nil, err
-		}
+	helperReportFinish(envVars, report, counter)
 
-		allPulls = append(allPulls, &pullRequest)
-	}
-	return allPulls, nil
+	return nil
 }
 
-func getReposCSV(envVars app.EnvVars) ([]*github.Repository, error) {
-	filePath := envVars.App.DatasetDemoRepos
+// importSyntheticToStore generates a synthetic dataset and stores it on disk.
+// The data depends only on the DATASET_SYNTHETIC_* settings, so every run and every database get the same data.
+// The generation is skipped when the store already holds the complete dataset of the same settings.
+func importSyntheticToStore(envVars app.EnvVars) {
+	report := helperReportStart()
 
-	if strings.HasPrefix(filePath, "http://") || strings.HasPrefix(filePath, "https://") {
-		log.Printf("getReposCSV: Downloading file from URL: %s", filePath)
-		tempFile, err := downloadFileFromURL(filePath)
-		if err != nil {
-			log.Printf("getReposCSV: DownloadFileFromURL: Error: %v", err)
-			return nil, err
-		}
-		defer os.Remove(tempFile)
-		filePath = tempFile
+	org := envVars.GitHub.Organisation
+	if org == "" {
+		org = "synthetic"
 	}
 
-	var allRepos []*github.Repository
+	repos := envVars.Synthetic.RepoCount()
+	metrics.ImportRepos.WithLabelValues("total").Set(float64(repos))
+	metrics.ImportRepos.WithLabelValues("processed").Set(0)
 
-	ext := strings.ToLower(filepath.Ext(filePath))
-	if ext == ".zip" {
-		zipFile, err := zip.OpenReader(filePath)
-		if err != nil {
-			log.Printf("getReposCSV: Open ZIP: Error: %v", err)
-			return nil, err
-		}
-		defer zipFile.Close()
+	source := fmt.Sprintf("synthetic:%s:%+v", org, envVars.Synthetic)
+	if current, complete := store.Source(); current == source && complete {
+		log.Printf("importSyntheticToStore: The store already holds the dataset, seed: %d, repos: %d", envVars.Synthetic.Seed, repos)
+		metrics.ImportRepos.WithLabelValues("processed").Set(float64(repos))
+		return
+	}
 
-		for _, f := range zipFile.File {
-			if !strings.HasSuffix(f.Name, ".csv") {
-				continue
-			}
+	// Data of other settings is removed, an interrupted generation of the same settings starts from the beginning
+	if err := store.Begin(source); err != nil {
+		log.Printf("Error: importSyntheticToStore: %v", err)
+		return
+	}
 
-			rc, err := f.Open()
-			if err != nil {
-				log.Printf("getReposCSV: Open CSV in ZIP: Error: %v", err)
-				return nil, err
-			}
-			defer rc.Close()
+	log.Printf("importSyntheticToStore: Start: seed: %d, repos: %d, distribution: %s", envVars.Synthetic.Seed, repos, envVars.Synthetic.Distribution)
 
-			reader := csv.NewReader(rc)
-			reader.LazyQuotes = true
-			records, err := reader.ReadAll()
-			if err != nil {
-				log.Printf("getReposCSV: ReadAll: Error: %v", err)
-				return nil, err
-			}
+	pullsCount := 0
+	var storeErr error
 
-			allRepos, err = processRepoRecords(records, allRepos)
-			if err != nil {
-				return nil, err
-			}
+	app.GenerateSyntheticDataset(envVars.Synthetic, org, func(repo *github.Repository, pulls []*github.PullRequest) {
+		if storeErr != nil {
+			return
 		}
-	} else if ext == ".csv" {
-		file, err := os.Open(filePath)
-		if err != nil {
-			log.Printf("getReposCSV: Open CSV: Error: %v", err)
-			return nil, err
-		}
-		defer file.Close()
-
-		reader := csv.NewReader(file)
-		reader.LazyQuotes = true
-		records, err := reader.ReadAll()
-		if err != nil {
-			log.Printf("getReposCSV: ReadAll: Error: %v", err)
-			return nil, err
+		if storeErr = store.PutRepo(app.RepoData{Repo: repo, Pulls: pulls}); storeErr != nil {
+			return
 		}
+		pullsCount += len(pulls)
 
-		allRepos, err = processRepoRecords(records, allRepos)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		return nil, fmt.Errorf("getReposCSV: unsupported file type: %s", ext)
+		metrics.ImportRepos.WithLabelValues("processed").Inc()
+	})
+	if storeErr == nil {
+		storeErr = store.Finish()
+	}
+	if storeErr != nil {
+		log.Printf("Error: importSyntheticToStore: %v", storeErr)
+		return
 	}
 
-	return allRepos, nil
-}
+	if statusData == "Initializing" {
+		setStatus("Updating")
+	}
 
-func processRepoRecords(records [][]string, allRepos []*github.Repository) ([]*github.Repository, error) {
-	for i, record := range records {
-		if i == 0 {
-			continue
-		}
+	counter := map[string]int{
+		"pulls": pullsCount,
+		"repos": repos,
+	}
 
-		data := record[1]
+	helperReportFinish(envVars, report, counter)
+}
 
-		var repo github.Repository
+// eachPullCSV reads the pull requests file (DATASET_DEMO_CSV_PULLS) record by record and calls fn for every pull request.
+// The file is CSV, NDJSON or a GH Archive dump, see eachDatasetRecord; other events than PullRequestEvent are skipped.
+func eachPullCSV(envVars app.EnvVars, fn func(pull *github.PullRequest) error) error {
+	skipped := 0
+	err := eachDatasetRecord("getPullsCSV", envVars.App.DatasetDemoPulls, func(repo string, data []byte) error {
+		var pullRequest github.PullRequest
 
-		err := json.Unmarshal([]byte(data), &repo)
+		_, ok, err := decodeRecord("pulls", repo, data, &pullRequest)
 		if err != nil {
-			log.Printf("processRepoRecords: Unmarshal: Error: %v", err)
-			return nil, err
+			log.Printf("processPullsRecords: Unmarshal: Error: %v", err)
+			return err
+		}
+		if !ok {
+			skipped++
+			return nil
 		}
 
-		allRepos = append(allRepos, &repo)
+		return fn(&pullRequest)
+	})
+	if skipped > 0 {
+		log.Printf("getPullsCSV: Skipped %d events without a pull request", skipped)
 	}
-	return allRepos, nil
+	return err
 }
 
-// downloadFileFromURL downloads a file from the given URL and saves it to a temporary file.
-// It returns the path to the downloaded file.
-//
-// Arguments:
-//   - url: string containing the URL of the file to download.
-//
-// Returns:
-//   - string: The path to the downloaded file.
-//   - error: An error object if an error occurs, otherwise nil.
-func downloadFileFromURL(url string) (string, error) {
-
-	// Extract the file extension from the URL.
-	ext := filepath.Ext(url)
+// eachEntityCSV reads a file of entities record by record and calls fn for every entity with the name
+// of its repository. In CSV files the second column is the repository name and the last one the entity in JSON,
+// e.g. "id,repo,data" for issues or "id,repo,pull,data" for reviews. NDJSON files have the entities only,
+// repoOf returns the repository name from the entity, e.g. from its API URL.
+func eachEntityCSV[T any](name string, filePath string, entity string, repoOf func(entity *T) string, fn func(repoName string, entity *T) error) error {
+	skipped := 0
+	err := eachDatasetRecord(name, filePath, func(repo string, data []byte) error {
+		var value T
+		repoName, ok, err := decodeRecord(entity, repo, data, &value)
+		if err != nil {
+			log.Printf("%s: Unmarshal: Error: %v", name, err)
+			return err
+		}
+		if !ok {
+			skipped++
+			return nil
+		}
+		if repoName == "" {
+			repoName = repoOf(&value)
+		}
 
-	// Create a temporary file to save the downloaded content.
-	tmpFile, err := os.CreateTemp("", "dataset-*"+ext)
-	if err != nil {
-		return "", err
+		return fn(repoName, &value)
+	})
+	if skipped > 0 {
+		log.Printf("%s: Skipped %d events without %s", name, skipped, entity)
 	}
-	defer tmpFile.Close()
+	return err
+}
 
-	// Send a GET request to the URL.
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
+// repoOfIssue returns the repository name of an issue of an NDJSON file from its API URL.
+func repoOfIssue(issue *github.Issue) string {
+	return app.RepoName(issue.GetRepositoryURL())
+}
 
-	// Copy the response body to the temporary file.
-	_, err = io.Copy(tmpFile, resp.Body)
-	if err != nil {
-		return "", err
-	}
+// repoOfReview returns the repository name of a review of an NDJSON file from its API URL.
+func repoOfReview(review *github.PullRequestReview) string {
+	return app.RepoName(review.GetPullRequestURL())
+}
 
-	// Return the path to the downloaded file.
-	return tmpFile.Name(), nil
+// repoOfReviewComment returns the repository name of a review comment of an NDJSON file from its API URL.
+func repoOfReviewComment(comment *github.PullRequestComment) string {
+	return app.RepoName(comment.GetPullRequestURL())
 }
 
-// filterRepos filters the given list of repositories based on a predefined set of included repositories.
-// It returns the filtered list of repositories if the application is in debug mode.
-//
-// Arguments:
-//   - envVars: app.EnvVars containing environment variables and configuration.
-//   - allRepos: []*github.Repository containing all repositories.
-//
-// Returns:
-//   - []*github.Repository: The filtered list of repositories.
-func filterRepos(envVars app.EnvVars, allRepos []*github.Repository) []*github.Repository {
-
-	// Define the set of included repositories.
-	includedRepos := map[string]bool{
-		"pxc-docs":           true,
-		"ivee-docs":          true,
-		"percona-valkey-doc": true,
-		"ab":                 true,
-		"documentation":      true,
-		"pg_tde":             true,
-		"postgres_exporter":  true,
-		"pg_stat_monitor":    true,
-		"roadmap":            true,
-		"everest-catalog":    true,
-		"openstack_ansible":  true,
-		"go-mysql":           true,
-		"rds_exporter":       true,
-		"postgres":           true,
-		"awesome-pmm":        true,
-		"pmm-demo":           true,
-		"qan-api":            true,
-		"percona-toolkit":    true,
-		"mongodb_exporter":   true,
-		"everest":            true,
-		"community":          true,
-		"rocksdb":            true,
-		"mysql-wsrep":        true,
-		"vagrant-fabric":     true,
-		"debian":             true,
-		"jemalloc":           true,
-	}
+// repoOfCommit returns the repository name of a commit of an NDJSON file from its API URL.
+func repoOfCommit(commit *github.RepositoryCommit) string {
+	return app.RepoName(commit.GetURL())
+}
 
-	var allReposFiltered []*github.Repository
-	// Filter repositories if the application is in debug mode.
-	if envVars.App.Debug {
-		for _, repo := range allRepos {
-			if includedRepos[*repo.Name] {
-				allReposFiltered = append(allReposFiltered, repo)
-			}
+// getReposCSV reads all repositories from the repositories file (DATASET_DEMO_CSV_REPOS), CSV or NDJSON.
+func getReposCSV(envVars app.EnvVars) ([]*github.Repository, error) {
+	var allRepos []*github.Repository
+
+	err := eachDatasetRecord("getReposCSV", envVars.App.DatasetDemoRepos, func(repo string, data []byte) error {
+		var repository github.Repository
+
+		err := json.Unmarshal(data, &repository)
+		if err != nil {
+			log.Printf("processRepoRecords: Unmarshal: Error: %v", err)
+			return err
 		}
 
-		return allReposFiltered
+		allRepos = append(allRepos, &repository)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return allRepos
+	return allRepos, nil
 }
 
 // helperSleep pauses execution for a specified number of minutes.
@@ -1058,7 +934,7 @@ func filterRepos(envVars app.EnvVars, allRepos []*github.Repository) []*github.R
 //   - envVars: app.EnvVars containing environment variables and configuration.
 func helperSleep(envVars app.EnvVars) {
 	minutes := time.Duration(envVars.App.DelayMinutes) * time.Minute
-	log.Printf("Dataset update memory data: The repeat run will start automatically after %v", minutes)
+	log.Printf("Dataset update store data: The repeat run will start automatically after %v", minutes)
 	time.Sleep(minutes)
 }
 
@@ -1107,6 +983,16 @@ func helperReportFinish(envVars app.EnvVars, report app.Report, counter map[stri
 		"Counter":        string(counterJSON),
 	}
 
+	if len(report.Sources) > 0 {
+		sourcesJSON, _ := json.Marshal(report.Sources)
+		reportMap["Sources"] = string(sourcesJSON)
+	}
+
+	if report.Requests != nil {
+		requestsJSON, _ := json.Marshal(report.Requests)
+		reportMap["Requests"] = string(requestsJSON)
+	}
+
 	startedAtTime, err := time.Parse("2006-01-02T15:04:05.000", report.StartedAt)
 	if err != nil {
 		log.Printf("Error parsing StartedAt: %v", err)
@@ -1135,6 +1021,8 @@ func logMemoryUsage(name string) {
 		maxHeapAlloc = alloc
 	}
 
+	metrics.UpdateHeap(&m)
+
 	log.Printf("%s: Memory Usage: Alloc = %v MiB, TotalAlloc = %v MiB, Sys = %v MiB, HeapAlloc = %v MiB, NumGC = %v, MaxHeapAlloc = %v MiB",
 		name, bToMb(m.Alloc), bToMb(m.TotalAlloc), bToMb(m.Sys), alloc, m.NumGC, maxHeapAlloc)
 }
@@ -1172,20 +1060,28 @@ func setStatus(status string) {
 	log.Printf("DatasetLoad: Status: Changed: %s -> %s", statusData, status)
 	statusData = status
 
-	// Calculate the number of repositories and pull requests
-	reposCount := len(allReposData)
-	pullsCount := 0
-	for _, pulls := range allPullsData {
-		pullsCount += len(pulls)
-	}
+	publishStatus()
+}
+
+// publishStatus saves the status, the amount of stored data and the GitHub API rate limit in Valkey.
+func publishStatus() {
+	// Get the number of stored repositories and pull requests
+	reposCount, pullsCount := store.Counts()
+
+	metrics.DatasetSize.WithLabelValues("repos").Set(float64(reposCount))
+	metrics.DatasetSize.WithLabelValues("pulls").Set(float64(pullsCount))
 
 	// Prepare a map with status and counts
 	data := map[string]interface{}{
-		"status":      status,
+		"status":      statusData,
 		"type":        app.Config.App.DatasetLoadType,
 		"repos_count": reposCount,
 		"pulls_count": pullsCount,
 	}
 
+	if rate := app.GitHubRateState(); rate.Limit > 0 {
+		data["github_rate"] = rate
+	}
+
 	valkey.SaveDatasetLoader(data)
 }
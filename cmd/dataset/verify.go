@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"time"
+
+	app "github-stat/internal"
+	"github-stat/internal/databases/driver"
+	"github-stat/internal/databases/valkey"
+	"github-stat/internal/metrics"
+
+	"github.com/google/go-github/github"
+)
+
+// verifySamples is the number of pull requests of every repository compared field by field.
+const verifySamples = 2
+
+// startVerification runs the verification of a database in a goroutine and saves its result in Valkey and in the
+// latest run report. The verification status is "In Progress" while it runs and the status of the result after it.
+func startVerification(drv driver.Driver, db app.DatabaseConfig) {
+	if err := updateVerifyStatus(db.ID, "In Progress"); err != nil {
+		return
+	}
+
+	go func() {
+		result := verifyDatabase(drv, db)
+
+		metrics.DatabaseMismatches.WithLabelValues(db.ID).Set(float64(result.MismatchCount))
+		if err := valkey.SaveVerifyResult(result); err != nil {
+			log.Printf("Verify: %s: Error: Saving the result: %v", db.ID, err)
+		}
+
+		// The result is added to the report of the run that fetched the verified dataset
+		resultJSON, _ := json.Marshal(result)
+		if err := valkey.UpdateLatestReport(map[string]interface{}{"Verification:" + db.ID: string(resultJSON)}); err != nil {
+			log.Printf("Verify: %s: Error: Updating the report: %v", db.ID, err)
+		}
+
+		// The status of a deleted database would recreate it
+		if _, err := valkey.GetDatabase(db.ID); err == nil {
+			updateVerifyStatus(db.ID, result.Status)
+		}
+	}()
+}
+
+// verifyDatabase compares the pull requests of a database with the dataset in the store. The number of pull
+// requests and the checksum of their IDs and update times are compared by repository, and a few random
+// pull requests of every repository are compared field by field.
+//
+// Arguments:
+//   - drv: driver.Driver of the database type.
+//   - db: app.DatabaseConfig of the database.
+//
+// Returns:
+//   - app.VerifyResult: The differences found, or the error that stopped the verification.
+func verifyDatabase(drv driver.Driver, db app.DatabaseConfig) app.VerifyResult {
+	started := time.Now()
+	result := app.VerifyResult{
+		ID:        db.ID,
+		DBType:    db.DBType,
+		CheckedAt: started.Format("2006-01-02T15:04:05.000"),
+	}
+	log.Printf("Verify: %s process start: %s", drv.Name(), db.ID)
+
+	fail := func(step string, err error) app.VerifyResult {
+		log.Printf("Verify: %s: Error: %s: %v", db.ID, step, err)
+		result.Status = "Error"
+		result.Error = fmt.Sprintf("%s: %v", step, err)
+		result.Milliseconds = time.Since(started).Milliseconds()
+		return result
+	}
+
+	// The dataset is read before the database, pull requests updated in the store in between are reported
+	expected := make(app.DatasetDigest)
+	samples := make(map[string][]*github.PullRequest)
+	err := store.Each(func(data app.RepoData) error {
+		repoName := data.Repo.GetName()
+		result.Repos++
+		for _, pull := range data.Pulls {
+			expected.Add(repoName, pull.GetID(), pull.GetUpdatedAt())
+			result.Pulls++
+		}
+		for _, i := range rand.Perm(len(data.Pulls)) {
+			if len(samples[repoName]) == verifySamples {
+				break
+			}
+			samples[repoName] = append(samples[repoName], data.Pulls[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return fail("Reading the dataset", err)
+	}
+
+	info, err := drv.DatasetInfo(db)
+	if err != nil {
+		return fail("Counting the repositories", err)
+	}
+	if info.Repositories != result.Repos {
+		result.AddMismatches(app.VerifyMismatch{Kind: app.MismatchRepositories, Expected: fmt.Sprint(result.Repos), Actual: fmt.Sprint(info.Repositories)})
+	}
+
+	actual, err := drv.PullDigest(db)
+	if err != nil {
+		return fail("Reading the pull requests", err)
+	}
+	result.AddMismatches(app.CompareDigests(expected, actual)...)
+
+	repos := make([]string, 0, len(samples))
+	for repo := range samples {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+
+	for _, repo := range repos {
+		ids := make([]int64, len(samples[repo]))
+		for i, pull := range samples[repo] {
+			ids[i] = pull.GetID()
+		}
+		pulls, err := drv.GetPulls(db, repo, ids)
+		if err != nil {
+			return fail("Reading the sampled pull requests", err)
+		}
+		found := make(map[int64]*github.PullRequest, len(pulls))
+		for _, pull := range pulls {
+			found[pull.GetID()] = pull
+		}
+		for _, pull := range samples[repo] {
+			result.AddMismatches(app.ComparePulls(repo, pull, found[pull.GetID()])...)
+			result.Sampled++
+		}
+	}
+
+	result.Status = "OK"
+	if result.MismatchCount > 0 {
+		result.Status = "Mismatch"
+	}
+	result.Milliseconds = time.Since(started).Milliseconds()
+
+	log.Printf("Verify: %s process finish: %s: %s, %d mismatches, %d pulls, %d sampled, %d ms",
+		drv.Name(), db.ID, result.Status, result.MismatchCount, result.Pulls, result.Sampled, result.Milliseconds)
+
+	return result
+}
+
+// updateVerifyStatus updates the verification status of a given database in Valkey.
+//
+// Arguments:
+//   - dbID: string containing the database ID.
+//   - status: string containing the new status for the database.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func updateVerifyStatus(dbID, status string) error {
+	db := app.DatabaseConfig{ID: dbID, VerifyStatus: status}
+
+	err := valkey.AddDatabase(db, app.FieldVerifyStatus)
+	if err != nil {
+		log.Printf("Verify: Error updating status for database %s: %v", dbID, err)
+		return err
+	}
+
+	log.Printf("Verify: Status for database %s updated to %s", dbID, status)
+	return nil
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	app "github-stat/internal"
+	"github-stat/internal/databases/driver"
+	"github-stat/internal/databases/valkey"
+	"github-stat/internal/load"
+	"github-stat/internal/metrics"
+)
+
+// Exit codes of the bench command.
+const (
+	benchPassed = 0 // All thresholds are met
+	benchFailed = 1 // At least one threshold is breached
+	benchError  = 2 // Invalid arguments or the database cannot be used
+)
+
+// benchOptions holds the command line arguments of the bench command.
+type benchOptions struct {
+	ID           string
+	DBType       string
+	DSN          string
+	Database     string
+	SchemaMode   string
+	Duration     time.Duration
+	Connections  int
+	Sleep        int
+	TargetQPS    int
+	Switches     string
+	Workloads    string
+	WorkloadsDir string
+	Report       string
+	MaxP99       time.Duration
+	MaxErrorRate float64
+	MinQPS       float64
+}
+
+// benchLatency holds latency statistics in milliseconds.
+type benchLatency struct {
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P95  float64 `json:"p95_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// benchOperation holds the statistics of one query of a workload.
+type benchOperation struct {
+	Workload  string       `json:"workload"`
+	Operation string       `json:"operation"`
+	Count     uint64       `json:"count"`
+	Errors    uint64       `json:"errors"`
+	ErrorRate float64      `json:"error_rate"`
+	Latency   benchLatency `json:"latency"`
+}
+
+// benchThresholds are the limits given on the command line, the limits not set are omitted.
+// The error rate is a pointer because 0 is a valid limit that allows no errors.
+type benchThresholds struct {
+	MaxP99       string   `json:"max_p99,omitempty"`
+	MaxErrorRate *float64 `json:"max_error_rate,omitempty"`
+	MinQPS       float64  `json:"min_qps,omitempty"`
+}
+
+// benchReport is the result of a benchmark run, written as JSON and Markdown.
+// As on the control panel, the QPS counts every query of the workloads, Runs the runs of all enabled workloads.
+type benchReport struct {
+	Database            string           `json:"database"`
+	DBType              string           `json:"db_type"`
+	Workloads           []string         `json:"workloads"`
+	Connections         int              `json:"connections"`
+	Sleep               int              `json:"sleep_ms"`
+	TargetQPS           int              `json:"target_qps"`
+	StartedAt           string           `json:"started_at"`
+	Duration            float64          `json:"duration_seconds"`
+	Interrupted         bool             `json:"interrupted"`
+	Runs                int64            `json:"runs"`
+	QPS                 float64          `json:"qps"`
+	Operations          uint64           `json:"operations"`
+	OperationsPerSecond float64          `json:"operations_per_second"`
+	Errors              uint64           `json:"errors"`
+	ErrorRate           float64          `json:"error_rate"`
+	Latency             benchLatency     `json:"latency"`
+	PerOperation        []benchOperation `json:"per_operation"`
+	Thresholds          benchThresholds  `json:"thresholds"`
+	Violations          []string         `json:"violations"`
+	Passed              bool             `json:"passed"`
+}
+
+// runBench runs the load against one database for a fixed duration without the control panel,
+// writes a report and returns the exit code of the process.
+//
+// Usage:
+//
+//	load bench --db mysql-1 --duration 10m --connections 32 --switches 1,2 --max-p99 50ms --max-error-rate 1 --min-qps 500
+//	load bench --type postgres --dsn "user=... host=..." --duration 1m --workloads recent_pulls
+func runBench(args []string) int {
+	// Read the .env file before the defaults of the flags are taken from the environment
+	envVars, envErr := app.GetEnvVars("load")
+
+	var opts benchOptions
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	flags.StringVar(&opts.ID, "db", "", "ID of a database configured on the control panel, e.g. mysql-1")
+	flags.StringVar(&opts.DBType, "type", "", "Database type without the control panel: mysql, postgres or mongodb")
+	flags.StringVar(&opts.DSN, "dsn", "", "Connection string, used with --type")
+	flags.StringVar(&opts.Database, "database", "dataset", "MongoDB database name, used with --type")
+	flags.StringVar(&opts.SchemaMode, "schema-mode", app.SchemaModeJSON, "Schema mode of a MySQL or PostgreSQL database, used with --type: json or normalized")
+	flags.DurationVar(&opts.Duration, "duration", time.Minute, "Duration of the benchmark")
+	flags.IntVar(&opts.Connections, "connections", 8, "Number of parallel connections")
+	flags.IntVar(&opts.Sleep, "sleep", 0, "Delay in milliseconds between workload runs in each connection")
+	flags.IntVar(&opts.TargetQPS, "qps", 0, "Target queries per second for all connections, 0 runs queries back-to-back")
+	flags.StringVar(&opts.Switches, "switches", "", "Built-in workloads to run, e.g. 1,2")
+	flags.StringVar(&opts.Workloads, "workloads", "", "Workloads loaded from files to run, comma-separated")
+	flags.StringVar(&opts.WorkloadsDir, "workloads-dir", os.Getenv("LOAD_WORKLOADS_DIR"), "Directory with workload files")
+	flags.StringVar(&opts.Report, "report", "bench-report", "Path of the report without extension, .json and .md files are written")
+	flags.DurationVar(&opts.MaxP99, "max-p99", 0, "Fail if the p99 latency of all queries is higher, e.g. 50ms")
+	flags.Float64Var(&opts.MaxErrorRate, "max-error-rate", -1, "Fail if the percentage of failed queries is higher, 0 fails on any error, negative values disable the check")
+	flags.Float64Var(&opts.MinQPS, "min-qps", 0, "Fail if fewer queries per second are achieved")
+	if err := flags.Parse(args); err != nil {
+		return benchError
+	}
+
+	db, err := benchDatabase(opts, envVars, envErr)
+	if err != nil {
+		log.Printf("Bench: Error: %v", err)
+		return benchError
+	}
+
+	if opts.WorkloadsDir != "" {
+		if _, err := load.LoadWorkloads(opts.WorkloadsDir); err != nil {
+			log.Printf("Bench: Error: Loading workloads from %s: %v", opts.WorkloadsDir, err)
+			return benchError
+		}
+	}
+
+	if opts.Switches != "" || opts.Workloads != "" {
+		names, err := benchWorkloadNames(opts.Switches, opts.Workloads)
+		if err != nil {
+			log.Printf("Bench: Error: %v", err)
+			return benchError
+		}
+		db.SetWorkloads(names)
+	}
+	db.Connections = opts.Connections
+	db.Sleep = opts.Sleep
+	db.TargetQPS = opts.TargetQPS
+	db.Profile = nil
+
+	if err := validateBench(db, opts); err != nil {
+		log.Printf("Bench: Error: %v", err)
+		return benchError
+	}
+
+	drv, err := driver.Get(db.DBType)
+	if err != nil {
+		log.Printf("Bench: Error: %v", err)
+		return benchError
+	}
+	if status := drv.Check(db); status != "Connected" {
+		log.Printf("Bench: Error: %s: %s", db.ID, status)
+		return benchError
+	}
+
+	report := runBenchLoad(db, opts.Duration)
+	report.Thresholds = benchThresholds{MinQPS: opts.MinQPS}
+	if opts.MaxP99 > 0 {
+		report.Thresholds.MaxP99 = opts.MaxP99.String()
+	}
+	if opts.MaxErrorRate >= 0 {
+		report.Thresholds.MaxErrorRate = &opts.MaxErrorRate
+	}
+	report.check(opts)
+
+	if err := report.write(opts.Report); err != nil {
+		log.Printf("Bench: Error: Writing report: %v", err)
+		return benchError
+	}
+	fmt.Print(report.markdown())
+
+	if !report.Passed {
+		log.Printf("Bench: Failed: %s", strings.Join(report.Violations, "; "))
+		return benchFailed
+	}
+	log.Printf("Bench: Passed")
+	return benchPassed
+}
+
+// benchDatabase returns the configuration of the database to load: from Valkey if --db is set,
+// otherwise a configuration built from --type and --dsn.
+func benchDatabase(opts benchOptions, envVars app.EnvVars, envErr error) (app.DatabaseConfig, error) {
+	if opts.ID != "" {
+		if opts.DBType != "" || opts.DSN != "" {
+			return app.DatabaseConfig{}, errors.New("--db cannot be used with --type or --dsn")
+		}
+		if envErr != nil {
+			return app.DatabaseConfig{}, envErr
+		}
+		valkey.InitValkey(envVars)
+		defer valkey.Valkey.Close()
+		return valkey.GetDatabase(opts.ID)
+	}
+
+	if opts.DBType == "" || opts.DSN == "" {
+		return app.DatabaseConfig{}, errors.New("either --db or --type and --dsn are required")
+	}
+	db := app.NewDatabaseConfig("bench-"+opts.DBType, opts.DBType, opts.DSN)
+	db.Database = opts.Database
+	db.SchemaMode = opts.SchemaMode
+	return db, nil
+}
+
+// benchWorkloadNames converts the --switches and --workloads arguments into workload names.
+func benchWorkloadNames(switches, workloads string) ([]string, error) {
+	var names []string
+	for _, s := range strings.Split(switches, ",") {
+		s = strings.TrimPrefix(strings.TrimSpace(s), "switch")
+		switch s {
+		case "":
+		case "1", "2", "3", "4":
+			names = append(names, "switch"+s)
+		default:
+			return nil, fmt.Errorf("unknown switch %q, expected 1 to 4", s)
+		}
+	}
+	for _, name := range strings.Split(workloads, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// validateBench checks the configuration of the benchmark before connecting.
+func validateBench(db app.DatabaseConfig, opts benchOptions) error {
+	var errs []error
+
+	if opts.Duration <= 0 {
+		errs = append(errs, errors.New("duration must be positive"))
+	}
+	if err := db.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	enabled := db.EnabledWorkloads()
+	if len(enabled) == 0 {
+		errs = append(errs, errors.New("no workloads enabled, use --switches or --workloads"))
+	}
+	for _, name := range enabled {
+		w, ok := load.GetWorkload(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown workload %q", name))
+		} else if !w.Supports(db.DBType) {
+			errs = append(errs, fmt.Errorf("workload %q has no queries for %s", name, db.DBType))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// runBenchLoad runs the connections of the database with runDB until the duration expires
+// or the process is interrupted, and collects the statistics of the queries.
+func runBenchLoad(db app.DatabaseConfig, duration time.Duration) benchReport {
+	// runDB refreshes the configuration from databasesLoad, which holds only the benchmarked database
+	databasesLoadMutex.Lock()
+	databasesLoad = DatabasesLoad{db.DBType: {db}}
+	databasesLoadMutex.Unlock()
+	metrics.Delete(db.ID)
+
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(signalCtx, duration)
+	defer cancel()
+
+	log.Printf("Bench: %s: Start: %d connections, workloads: %v, duration: %s", db.ID, db.Connections, db.EnabledWorkloads(), duration)
+
+	limiter := newLoadLimiter(db.TargetQPS)
+	started := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < db.Connections; i++ {
+		wg.Add(1)
+		go func(connID int) {
+			defer wg.Done()
+			runDB(db, ctx, connID, limiter)
+		}(i)
+	}
+
+	// Log the progress until the connections are stopped
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case <-ticker.C:
+			log.Printf("Bench: %s: %s elapsed, %.1f queries/s", db.ID, formatMinutes(time.Since(started)), limiter.Stats().AchievedQPS)
+		}
+	}
+	elapsed := time.Since(started)
+
+	report := benchReport{
+		Database:    db.ID,
+		DBType:      db.DBType,
+		Workloads:   db.EnabledWorkloads(),
+		Connections: db.Connections,
+		Sleep:       db.Sleep,
+		TargetQPS:   db.TargetQPS,
+		StartedAt:   started.Format(time.RFC3339),
+		Duration:    elapsed.Seconds(),
+		Interrupted: signalCtx.Err() != nil,
+		Runs:        limiter.Runs(),
+		Violations:  []string{},
+	}
+	report.QPS = float64(limiter.Queries()) / elapsed.Seconds()
+
+	var total metrics.HistogramSnapshot
+	for _, series := range metrics.Snapshot() {
+		if series.Database != db.ID {
+			continue
+		}
+		total.Merge(series.Latency)
+		report.Errors += series.Errors
+		report.PerOperation = append(report.PerOperation, benchOperation{
+			Workload:  series.Switch,
+			Operation: series.Operation,
+			Count:     series.Latency.Count,
+			Errors:    series.Errors,
+			ErrorRate: errorRate(series.Errors, series.Latency.Count),
+			Latency:   latencyMillis(series.Latency),
+		})
+	}
+	report.Operations = total.Count
+	report.OperationsPerSecond = float64(total.Count) / elapsed.Seconds()
+	report.ErrorRate = errorRate(report.Errors, total.Count)
+	report.Latency = latencyMillis(total)
+
+	log.Printf("Bench: %s: Finish: %d runs in %s", db.ID, report.Runs, formatMinutes(elapsed))
+
+	return report
+}
+
+// check compares the results with the thresholds and sets the violations.
+func (r *benchReport) check(opts benchOptions) {
+	if r.Operations == 0 {
+		r.Violations = append(r.Violations, "no queries were run")
+	}
+	if opts.MaxP99 > 0 && r.Latency.P99 > millis(opts.MaxP99) {
+		r.Violations = append(r.Violations, fmt.Sprintf("p99 latency %.2f ms is above %.2f ms", r.Latency.P99, millis(opts.MaxP99)))
+	}
+	if opts.MaxErrorRate >= 0 && r.ErrorRate > opts.MaxErrorRate {
+		r.Violations = append(r.Violations, fmt.Sprintf("error rate %.2f%% is above %.2f%%", r.ErrorRate, opts.MaxErrorRate))
+	}
+	if opts.MinQPS > 0 && r.QPS < opts.MinQPS {
+		r.Violations = append(r.Violations, fmt.Sprintf("QPS %.1f is below %.1f", r.QPS, opts.MinQPS))
+	}
+	r.Passed = len(r.Violations) == 0
+}
+
+// write saves the report as <path>.json and <path>.md.
+func (r benchReport) write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".json", append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(path+".md", []byte(r.markdown()), 0644)
+}
+
+// markdown formats the report as a Markdown document.
+func (r benchReport) markdown() string {
+	var b strings.Builder
+
+	result := "PASSED"
+	if !r.Passed {
+		result = "FAILED"
+	}
+	fmt.Fprintf(&b, "# Benchmark %s: %s\n\n", r.Database, result)
+
+	fmt.Fprintf(&b, "| Setting | Value |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Database | %s (%s) |\n", r.Database, r.DBType)
+	fmt.Fprintf(&b, "| Workloads | %s |\n", strings.Join(r.Workloads, ", "))
+	fmt.Fprintf(&b, "| Connections | %d |\n", r.Connections)
+	fmt.Fprintf(&b, "| Sleep | %d ms |\n", r.Sleep)
+	fmt.Fprintf(&b, "| Target QPS | %d |\n", r.TargetQPS)
+	fmt.Fprintf(&b, "| Started | %s |\n", r.StartedAt)
+	fmt.Fprintf(&b, "| Duration | %.1f s |\n", r.Duration)
+	if r.Interrupted {
+		fmt.Fprintf(&b, "| Interrupted | yes |\n")
+	}
+
+	fmt.Fprintf(&b, "\n## Results\n\n")
+	fmt.Fprintf(&b, "| Runs | QPS | Queries | Queries/s | Errors | Error rate | Mean | p50 | p95 | p99 | Max |\n")
+	fmt.Fprintf(&b, "|---:|---:|---:|---:|---:|---:|---:|---:|---:|---:|---:|\n")
+	fmt.Fprintf(&b, "| %d | %.1f | %d | %.1f | %d | %.2f%% | %s |\n",
+		r.Runs, r.QPS, r.Operations, r.OperationsPerSecond, r.Errors, r.ErrorRate, r.Latency.cells())
+
+	fmt.Fprintf(&b, "\n## Queries\n\n")
+	fmt.Fprintf(&b, "| Workload | Query | Count | Errors | Error rate | Mean | p50 | p95 | p99 | Max |\n")
+	fmt.Fprintf(&b, "|---|---|---:|---:|---:|---:|---:|---:|---:|---:|\n")
+	for _, op := range r.PerOperation {
+		fmt.Fprintf(&b, "| %s | %s | %d | %d | %.2f%% | %s |\n",
+			op.Workload, op.Operation, op.Count, op.Errors, op.ErrorRate, op.Latency.cells())
+	}
+
+	fmt.Fprintf(&b, "\n## Thresholds\n\n")
+	if r.Thresholds == (benchThresholds{}) {
+		fmt.Fprintf(&b, "No thresholds set.\n")
+	} else {
+		if r.Thresholds.MaxP99 != "" {
+			fmt.Fprintf(&b, "- p99 latency at most %s\n", r.Thresholds.MaxP99)
+		}
+		if r.Thresholds.MaxErrorRate != nil {
+			fmt.Fprintf(&b, "- Error rate at most %.2f%%\n", *r.Thresholds.MaxErrorRate)
+		}
+		if r.Thresholds.MinQPS > 0 {
+			fmt.Fprintf(&b, "- QPS at least %.1f\n", r.Thresholds.MinQPS)
+		}
+	}
+	for _, violation := range r.Violations {
+		fmt.Fprintf(&b, "\n**Failed:** %s\n", violation)
+	}
+
+	return b.String()
+}
+
+// cells formats the latencies as Markdown table cells.
+func (l benchLatency) cells() string {
+	return fmt.Sprintf("%.2f ms | %.2f ms | %.2f ms | %.2f ms | %.2f ms", l.Mean, l.P50, l.P95, l.P99, l.Max)
+}
+
+// latencyMillis converts the latency statistics of a histogram into milliseconds.
+func latencyMillis(s metrics.HistogramSnapshot) benchLatency {
+	return benchLatency{
+		Mean: millis(s.Mean()),
+		P50:  millis(s.Percentile(50)),
+		P95:  millis(s.Percentile(95)),
+		P99:  millis(s.Percentile(99)),
+		Max:  millis(s.Max),
+	}
+}
+
+// millis converts a duration into milliseconds.
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// errorRate returns the percentage of failed executions.
+func errorRate(failed, count uint64) float64 {
+	if count == 0 {
+		return 0
+	}
+	return float64(failed) * 100 / float64(count)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBenchCheckErrorRate(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxErrorRate float64
+		errorRate    float64
+		passed       bool
+	}{
+		{name: "disabled", maxErrorRate: -1, errorRate: 50, passed: true},
+		{name: "zero allows no errors", maxErrorRate: 0, errorRate: 0.01, passed: false},
+		{name: "zero without errors", maxErrorRate: 0, errorRate: 0, passed: true},
+		{name: "at the limit", maxErrorRate: 1, errorRate: 1, passed: true},
+		{name: "above the limit", maxErrorRate: 1, errorRate: 1.5, passed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := benchReport{Operations: 100, ErrorRate: tt.errorRate}
+			r.check(benchOptions{MaxErrorRate: tt.maxErrorRate})
+			if r.Passed != tt.passed {
+				t.Errorf("Passed = %v, want %v (violations: %v)", r.Passed, tt.passed, r.Violations)
+			}
+		})
+	}
+}
+
+func TestBenchMarkdownThresholds(t *testing.T) {
+	zero := 0.0
+	r := benchReport{Thresholds: benchThresholds{MaxErrorRate: &zero}}
+	if md := r.markdown(); !strings.Contains(md, "- Error rate at most 0.00%") {
+		t.Errorf("markdown() does not list the zero error rate threshold:\n%s", md)
+	}
+
+	r = benchReport{}
+	if md := r.markdown(); !strings.Contains(md, "No thresholds set.") {
+		t.Errorf("markdown() lists thresholds that are not set:\n%s", md)
+	}
+}
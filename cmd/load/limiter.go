@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	app "github-stat/internal"
+
+	"golang.org/x/time/rate"
+)
+
+// loadLimiter spreads the target rate of a database across all its worker goroutines
+// with a token bucket and measures the rate that is actually achieved. Every query of a workload
+// takes a token, so the rate counts the statements sent to the database.
+type loadLimiter struct {
+	limiter *rate.Limiter
+	target  atomic.Int64
+	queries atomic.Int64
+	runs    atomic.Int64
+
+	// Used only by the goroutine that calls Stats
+	lastQueries int64
+	lastTime    time.Time
+	lastStats   app.LoadStats
+}
+
+// newLoadLimiter creates a limiter for the target rate, 0 disables rate limiting.
+func newLoadLimiter(targetQPS int) *loadLimiter {
+	l := &loadLimiter{
+		limiter:  rate.NewLimiter(rate.Inf, 1),
+		lastTime: time.Now(),
+	}
+	l.target.Store(-1)
+	l.SetTarget(targetQPS)
+	return l
+}
+
+// SetTarget changes the target rate. The burst allows 100 ms worth of queries,
+// so workers that start at the same time do not get ahead of the target.
+func (l *loadLimiter) SetTarget(targetQPS int) {
+	if l.target.Swap(int64(targetQPS)) == int64(targetQPS) {
+		return
+	}
+
+	if targetQPS <= 0 {
+		l.limiter.SetLimit(rate.Inf)
+		return
+	}
+
+	l.limiter.SetBurst(max(1, targetQPS/10))
+	l.limiter.SetLimit(rate.Limit(targetQPS))
+}
+
+// Limited reports whether a target rate is set.
+func (l *loadLimiter) Limited() bool {
+	return l.target.Load() > 0
+}
+
+// Wait blocks until the worker may run the next query or the context is done, and counts the query.
+// It is the load.WaitFunc of the workloads.
+func (l *loadLimiter) Wait(ctx context.Context) error {
+	if err := l.limiter.Wait(ctx); err != nil {
+		// The limiter fails early if the wait would exceed the deadline of ctx, e.g. at the end of a benchmark
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return context.DeadlineExceeded
+	}
+	l.queries.Add(1)
+	return nil
+}
+
+// RunDone records a run of the enabled workloads by a worker.
+func (l *loadLimiter) RunDone() {
+	l.runs.Add(1)
+}
+
+// Stats returns the rate achieved since the previous call.
+// Intervals shorter than a second are too noisy, the previous result is returned for them.
+func (l *loadLimiter) Stats() app.LoadStats {
+	now := time.Now()
+	elapsed := now.Sub(l.lastTime)
+	if elapsed < time.Second {
+		return l.lastStats
+	}
+
+	queries := l.queries.Load()
+	target := int(max(l.target.Load(), 0))
+	achieved := float64(queries-l.lastQueries) / elapsed.Seconds()
+
+	l.lastQueries = queries
+	l.lastTime = now
+	l.lastStats = app.LoadStats{
+		TargetQPS:   target,
+		AchievedQPS: achieved,
+		Reached:     target > 0 && achieved >= 0.95*float64(target),
+		UpdatedAt:   now.Format("2006-01-02T15:04:05.000"),
+	}
+
+	return l.lastStats
+}
+
+// Queries returns the number of queries run since the limiter was created.
+func (l *loadLimiter) Queries() int64 {
+	return l.queries.Load()
+}
+
+// Runs returns the number of runs of the enabled workloads since the limiter was created.
+func (l *loadLimiter) Runs() int64 {
+	return l.runs.Load()
+}
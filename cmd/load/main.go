@@ -2,21 +2,18 @@ package main
 
 import (
 	"context"
+	"fmt"
 	app "github-stat/internal"
-	"github-stat/internal/databases/mongodb"
-	"github-stat/internal/databases/mysql"
-	"github-stat/internal/databases/postgres"
+	"github-stat/internal/databases/driver"
 	"github-stat/internal/databases/valkey"
+	"github-stat/internal/load"
+	"github-stat/internal/metrics"
 	"log"
+	"os"
 	"sort"
-	"strconv"
+	"strings"
 	"sync"
 	"time"
-
-	"github-stat/internal/load"
-
-	_ "github.com/go-sql-driver/mysql"
-	_ "github.com/lib/pq"
 )
 
 // Environment variables for the application
@@ -25,27 +22,48 @@ var EnvVars app.EnvVars
 // Structure to hold load information for databases
 var databasesLoad DatabasesLoad
 
-// DatabasesLoad holds the load information for different types of databases
-type DatabasesLoad struct {
-	MySQL    []map[string]string
-	Postgres []map[string]string
-	MongoDB  []map[string]string
-}
+// DatabasesLoad holds the load information for databases of each type, keyed by dbType
+type DatabasesLoad map[string][]app.DatabaseConfig
 
 // Structure to hold IDs of databases that need to stop loading
-var stopLoadIDs StopLoadIDs
+var stopLoadIDs = StopLoadIDs{}
 
-// StopLoadIDs holds the IDs of databases that need to stop loading for different types of databases
-type StopLoadIDs struct {
-	MySQL    []string
-	Postgres []string
-	MongoDB  []string
-}
+// StopLoadIDs holds the IDs of databases that need to stop loading for each type of database, keyed by dbType
+type StopLoadIDs map[string][]string
 
 var databasesLoadMutex sync.Mutex
 var stopLoadIDsMutex sync.Mutex
 
+// configChanged is closed and replaced when a change event from the control panel has been applied.
+// Goroutines wait on it instead of sleeping, so changes take effect immediately.
+var configChanged = make(chan struct{})
+var configChangedMutex sync.Mutex
+
+// loadFields are the database fields that affect the load. Events that change only other fields,
+// e.g. the dataset import status, are ignored.
+var loadFields = map[string]bool{
+	app.FieldConnectionString: true,
+	app.FieldDatabase:         true,
+	app.FieldLoadSwitch:       true,
+	app.FieldPosition:         true,
+	app.FieldSleep:            true,
+	app.FieldConnections:      true,
+	app.FieldTargetQPS:        true,
+	app.FieldSwitch1:          true,
+	app.FieldSwitch2:          true,
+	app.FieldSwitch3:          true,
+	app.FieldSwitch4:          true,
+	app.FieldWorkloads:        true,
+	app.FieldProfile:          true,
+	app.FieldSchemaMode:       true,
+}
+
 func main() {
+	// "load bench" runs a fixed-duration benchmark without the control panel and exits
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		os.Exit(runBench(os.Args[2:]))
+	}
+
 	// Get the configuration from environment variables or .env file.
 	app.InitConfig("load")
 
@@ -53,6 +71,18 @@ func main() {
 	valkey.InitValkey(app.Config)
 	defer valkey.Valkey.Close()
 
+	// Load the workload files and publish the list of workloads for the control panel
+	if dir := app.Config.LoadGenerator.WorkloadsDir; dir != "" {
+		count, err := load.LoadWorkloads(dir)
+		if err != nil {
+			log.Printf("Error: Loading workloads from %s: %v", dir, err)
+		}
+		log.Printf("Workloads: %d loaded from %s", count, dir)
+	}
+	if err := valkey.SaveWorkloads(load.Workloads()); err != nil {
+		log.Printf("Error: Publishing workloads: %v", err)
+	}
+
 	// Retrieve the load configurations for the databases
 	err := getLoadDatabases()
 	if err != nil {
@@ -60,32 +90,69 @@ func main() {
 	}
 
 	// Start managing load for each type of database based on configuration
-	if app.Config.LoadGenerator.MySQL {
-		log.Printf("Load MySQL: %v", databasesLoad.MySQL)
-		go manageAllLoad("mysql")
+	for _, dbType := range driver.Types() {
+		if app.Config.LoadGenerator.Enabled(dbType) {
+			log.Printf("Load %s: %v", dbType, databasesLoad[dbType])
+			go manageAllLoad(dbType)
+		}
 	}
 
-	if app.Config.LoadGenerator.MongoDB {
-		log.Printf("Load MongoDB: %v", databasesLoad.MongoDB)
-		go manageAllLoad("mongodb")
-	}
+	// Log the client-side statistics of the queries and export them to Prometheus
+	go logMetrics(time.Minute)
+	metrics.RegisterLoad()
+	metrics.Serve(app.Config.App.MetricsPort)
 
-	if app.Config.LoadGenerator.Postgres {
-		log.Printf("Load Postgres: %v", databasesLoad.Postgres)
-		go manageAllLoad("postgres")
-	}
+	// Apply changes from the control panel as soon as they are published,
+	// and check the configuration every 5 seconds in case an event was missed
+	events := valkey.SubscribeDatabaseEvents()
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
 
-	// Continuously check and update the configuration from the control panel every 10 seconds
 	for {
-		time.Sleep(5 * time.Second)
+		changed := false
+
+		select {
+		case event, ok := <-events:
+			if !ok {
+				log.Printf("Valkey: Subscription closed, falling back to polling")
+				events = nil
+				continue
+			}
+			if !affectsLoad(event) {
+				continue
+			}
+			log.Printf("Database event: %s: %s: %v", event.Action, event.ID, event.Fields)
+			if event.Action == "delete" {
+				metrics.Delete(event.ID)
+			}
+			changed = true
+		case <-ticker.C:
+		}
 
 		err := updateLoadDatabases()
 		if err != nil {
 			log.Printf("Error: Updating databases: %v", err)
 		}
 
-		log.Printf("Updated Load MySQL: %d, Postgres: %d, MongoDB: %d ... Stop Load: %v", len(databasesLoad.MySQL), len(databasesLoad.Postgres), len(databasesLoad.MongoDB), stopLoadIDs)
+		if changed {
+			notifyConfigChanged()
+		}
+
+		log.Printf("Updated Load %s ... Stop Load: %v", loadCounts(), stopLoadIDs)
+	}
+}
+
+// loadCounts returns the number of databases under load of every registered type, e.g. "MongoDB: 1, MySQL: 2".
+func loadCounts() string {
+	var counts []string
+	for _, dbType := range driver.Types() {
+		name := dbType
+		if drv, err := driver.Get(dbType); err == nil {
+			name = drv.Name()
+		}
+		counts = append(counts, fmt.Sprintf("%s: %d", name, len(databasesLoad[dbType])))
 	}
+	return strings.Join(counts, ", ")
 }
 
 // manageAllLoad manages the load for all databases of a specific type
@@ -94,31 +161,24 @@ func manageAllLoad(dbType string) {
 	runningIDs := make(map[string]context.CancelFunc)
 
 	for {
-		var databases []map[string]string
+		var databases []app.DatabaseConfig
 		var stopIDs []string
 
+		changed := configChangedSignal()
+
 		databasesLoadMutex.Lock()
-		switch dbType {
-		case "mysql":
-			databases = deepCopy(databasesLoad.MySQL)
-			stopIDs = stopLoadIDs.MySQL
-		case "postgres":
-			databases = deepCopy(databasesLoad.Postgres)
-			stopIDs = stopLoadIDs.Postgres
-		case "mongodb":
-			databases = deepCopy(databasesLoad.MongoDB)
-			stopIDs = stopLoadIDs.MongoDB
-		}
+		databases = deepCopy(databasesLoad[dbType])
+		stopIDs = stopLoadIDs[dbType]
 		databasesLoadMutex.Unlock()
 
 		for _, db := range databases {
-			id := db["id"]
+			id := db.ID
 
 			if _, exists := runningIDs[id]; !exists {
 				ctx, cancel := context.WithCancel(context.Background())
 				runningIDs[id] = cancel
 				wg.Add(1)
-				go func(db map[string]string, ctx context.Context) {
+				go func(db app.DatabaseConfig, ctx context.Context) {
 					defer wg.Done()
 					manageLoad(db, ctx)
 				}(db, ctx)
@@ -136,31 +196,101 @@ func manageAllLoad(dbType string) {
 			}
 		}
 
+		// Stop databases that were removed between two updates and are not in stopIDs
+		for id, cancel := range runningIDs {
+			if !containsDatabase(databases, id) {
+				log.Printf("Stopping load for removed database %s", id)
+				cancel()
+				delete(runningIDs, id)
+			}
+		}
+
 		log.Printf("manageAllLoad: %s, stopIDs: %v, RunningIDs: %v", dbType, stopIDs, runningIDs)
 
-		time.Sleep(5 * time.Second)
+		waitConfigChanged(changed, 5*time.Second)
 	}
 }
 
-func deepCopy(original []map[string]string) []map[string]string {
-	copy := make([]map[string]string, len(original))
-	for i, v := range original {
-		innerCopy := make(map[string]string)
-		for key, value := range v {
-			innerCopy[key] = value
+// logMetrics periodically logs the number of executions, errors and latency percentiles of every operation.
+func logMetrics(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+
+		for _, series := range metrics.Snapshot() {
+			latency := series.Latency
+			log.Printf("Metrics: %s: %s: %s: count: %d, errors: %d, mean: %v, p50: %v, p95: %v, p99: %v, max: %v",
+				series.Database, series.Switch, series.Operation, latency.Count, series.Errors,
+				latency.Mean(), latency.Percentile(50), latency.Percentile(95), latency.Percentile(99), latency.Max)
 		}
-		copy[i] = innerCopy
 	}
-	return copy
+}
+
+func deepCopy(original []app.DatabaseConfig) []app.DatabaseConfig {
+	return append([]app.DatabaseConfig(nil), original...)
+}
+
+func containsDatabase(databases []app.DatabaseConfig, id string) bool {
+	for _, db := range databases {
+		if db.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// affectsLoad reports whether a change event can change the load on the databases.
+func affectsLoad(event app.DatabaseEvent) bool {
+	if event.Action == "delete" || len(event.Fields) == 0 {
+		return true
+	}
+	for _, field := range event.Fields {
+		if loadFields[field] {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyConfigChanged wakes up all goroutines waiting for a configuration change.
+func notifyConfigChanged() {
+	configChangedMutex.Lock()
+	defer configChangedMutex.Unlock()
+
+	close(configChanged)
+	configChanged = make(chan struct{})
+}
+
+// configChangedSignal returns a channel that is closed on the next configuration change.
+// It must be taken before reading the configuration, so that a change made in between is not missed.
+func configChangedSignal() <-chan struct{} {
+	configChangedMutex.Lock()
+	defer configChangedMutex.Unlock()
+
+	return configChanged
+}
+
+// waitConfigChanged waits until the configuration changes or the timeout expires.
+func waitConfigChanged(changed <-chan struct{}, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-changed:
+	case <-timer.C:
+	}
 }
 
 // manageLoad manages the load for a single database
-func manageLoad(dbConfig map[string]string, ctx context.Context) {
-	dbType := dbConfig["dbType"]
-	id := dbConfig["id"]
+func manageLoad(dbConfig app.DatabaseConfig, ctx context.Context) {
+	dbType := dbConfig.DBType
+	id := dbConfig.ID
 
 	routines := make(map[int]context.CancelFunc)
 
+	// Export the number of running goroutines, the series is removed when the load stops
+	activeGoroutines := metrics.ActiveGoroutines.WithLabelValues(id, dbType)
+	defer metrics.ActiveGoroutines.DeleteLabelValues(id, dbType)
+
 	db := checkOrWaitDB(id, dbType)
 	if db == nil {
 		log.Printf("Start: manageLoad: %s: %s: Database no longer exists", dbType, id)
@@ -168,25 +298,25 @@ func manageLoad(dbConfig map[string]string, ctx context.Context) {
 	}
 
 	var wg sync.WaitGroup
-	currentConnections, err := strconv.Atoi(db["connections"])
-	if err != nil {
-		log.Printf("%s: %s: Start: Error converting connections for database: %v", dbType, id, err)
-		return
-	}
+	currentConnections, phase := scheduledConnections(*db, time.Now())
+
+	// The target rate is shared by all connections of the database
+	limiter := newLoadLimiter(db.TargetQPS)
 
 	// Initial startup of Go routines
 	for i := 0; i < currentConnections; i++ {
 		wg.Add(1)
 		rctx, rcancel := context.WithCancel(ctx)
 		routines[i] = rcancel
-		go func(connID int, rctx context.Context) {
+		go func(connID int, rctx context.Context, dbConfig app.DatabaseConfig) {
 			defer wg.Done()
-			runDB(db, rctx, connID)
-		}(i, rctx)
+			runDB(dbConfig, rctx, connID, limiter)
+		}(i, rctx, *db)
 		time.Sleep(20 * time.Millisecond)
 	}
 
 	log.Printf("%s: %s: Manage Load: Start: Connections: %d routines started", dbType, id, len(routines))
+	activeGoroutines.Set(float64(len(routines)))
 
 	for {
 		select {
@@ -194,6 +324,8 @@ func manageLoad(dbConfig map[string]string, ctx context.Context) {
 			wg.Wait()
 			return
 		default:
+			changed := configChangedSignal()
+
 			// Get updated database configuration
 			db = getDatabaseByID(id, dbType)
 
@@ -206,14 +338,13 @@ func manageLoad(dbConfig map[string]string, ctx context.Context) {
 				return
 			}
 
-			newConnections, err := strconv.Atoi(db["connections"])
-			if err != nil {
-				log.Printf("Update: Error converting connections for database %s: %v", id, err)
-				continue
-			}
+			// The load profile, if set, overrides the connections of the control panel
+			var newConnections int
+			newConnections, phase = scheduledConnections(*db, time.Now())
+			limiter.SetTarget(db.TargetQPS)
 
 			// Check DB connection status
-			checkStatus := checkConnection(db)
+			checkStatus := checkConnection(*db)
 
 			if checkStatus != "Connected" {
 
@@ -238,23 +369,20 @@ func manageLoad(dbConfig map[string]string, ctx context.Context) {
 					log.Printf("%s: %s: Database no longer exists after reconnection, stopping all routines", dbType, id)
 					return
 				} else {
-					log.Printf("%s: %s: Database connection has been restored. Restarting %s routines. ", dbType, id, db["connections"])
+					log.Printf("%s: %s: Database connection has been restored. Restarting %d routines. ", dbType, id, db.Connections)
 				}
 
-				newConnections, err = strconv.Atoi(db["connections"])
-				if err != nil {
-					log.Printf("Reconnect: Error converting connections for database %s: %v", id, err)
-					return
-				}
+				newConnections, phase = scheduledConnections(*db, time.Now())
+				currentConnections = newConnections
 
 				for i := 0; i < newConnections; i++ {
 					wg.Add(1)
 					rctx, rcancel := context.WithCancel(ctx)
 					routines[i] = rcancel
-					go func(connID int, rctx context.Context) {
+					go func(connID int, rctx context.Context, dbConfig app.DatabaseConfig) {
 						defer wg.Done()
-						runDB(db, rctx, connID)
-					}(i, rctx)
+						runDB(dbConfig, rctx, connID, limiter)
+					}(i, rctx, *db)
 					time.Sleep(20 * time.Millisecond)
 				}
 				log.Printf("%s: %s: Manage Load: %d routines in progress", dbType, id, len(routines))
@@ -270,7 +398,7 @@ func manageLoad(dbConfig map[string]string, ctx context.Context) {
 						if rcancel, exists := routines[i]; exists {
 							rcancel()
 							delete(routines, i)
-							log.Printf("%s: %s: Stopped routine %d", dbType, db["id"], i)
+							log.Printf("%s: %s: Stopped routine %d", dbType, db.ID, i)
 						}
 					}
 				}
@@ -281,11 +409,11 @@ func manageLoad(dbConfig map[string]string, ctx context.Context) {
 						wg.Add(1)
 						rctx, rcancel := context.WithCancel(ctx)
 						routines[i] = rcancel
-						go func(connID int, rctx context.Context) {
+						go func(connID int, rctx context.Context, dbConfig app.DatabaseConfig) {
 							defer wg.Done()
-							runDB(db, rctx, connID)
-						}(i, rctx)
-						log.Printf("%s: %s: Started routine %d", dbType, db["id"], i)
+							runDB(dbConfig, rctx, connID, limiter)
+						}(i, rctx, *db)
+						log.Printf("%s: %s: Started routine %d", dbType, db.ID, i)
 						time.Sleep(20 * time.Millisecond)
 					}
 				}
@@ -295,229 +423,93 @@ func manageLoad(dbConfig map[string]string, ctx context.Context) {
 				currentConnections = newConnections
 			}
 
-			time.Sleep(3 * time.Second)
-		}
-	}
-}
-
-// runDB runs the database operations for a specific connection
-func runDB(db map[string]string, ctx context.Context, id int) {
-	dbType := db["dbType"]
-
-	switch dbType {
-	case "mongodb":
-		runMongoDB(ctx, id, db)
-	case "mysql":
-		runMySQL(ctx, id, db)
-	case "postgres":
-		runPostgreSQL(ctx, id, db)
-	default:
-		log.Printf("Unknown database type %s for ID %d", dbType, id)
-	}
-}
-
-// runMySQL runs the MySQL database operations for a specific connection
-func runMySQL(ctx context.Context, routineId int, dbConfig map[string]string) {
-	connectionString := dbConfig["connectionString"]
-
-	// Connect to the MySQL database
-	db, err := mysql.ConnectByString(connectionString)
-	if err != nil {
-		log.Printf("MySQL: %s: Error: goroutine: %d: message: %s", dbConfig["id"], routineId, err)
-		return
-	}
-	defer db.Close()
-
-	log.Printf("MySQL: %s: goroutine %d in progress", dbConfig["id"], routineId)
-
-	// Create an independent copy of dbConfig for use in the loop
-	localDBConfig := make(map[string]string)
-	for k, v := range dbConfig {
-		localDBConfig[k] = v
-	}
-
-	// Variable to store the time of the last configuration update
-	lastUpdate := time.Now()
-
-	for {
-		select {
-		case <-ctx.Done():
-			log.Printf("MySQL: %s goroutine: %d stopped", localDBConfig["id"], routineId)
-			return
-		default:
-			// Update localDBConfig every 2 seconds
-			if time.Since(lastUpdate) > 1*time.Second {
-				localDBConfig = getDatabaseByID(localDBConfig["id"], "mysql")
-				if localDBConfig == nil {
-					log.Printf("MySQL: %s: goroutine: %d: database has been removed, stopping goroutine", dbConfig["id"], routineId)
-					return
-				}
-				// log.Printf("MySQL: %s: goroutine: %d: Config: %s, %s, %s, %s", dbConfig["id"], routineId, localDBConfig["switch1"], localDBConfig["switch2"], localDBConfig["switch3"], localDBConfig["switch4"])
-				lastUpdate = time.Now()
-			}
-
-			// Use localDBConfig for other operations
-			if localDBConfig["switch1"] == "true" {
-
-				load.MySQLSwitch1(db, routineId, localDBConfig)
-			}
-
-			if localDBConfig["switch2"] == "true" {
-
-				load.MySQLSwitch2(db, routineId, localDBConfig)
-			}
-
-			if localDBConfig["switch3"] == "true" {
+			activeGoroutines.Set(float64(len(routines)))
 
-				load.MySQLSwitch3(db, routineId, localDBConfig)
+			// Report the achieved rate and the profile phase to the control panel
+			stats := limiter.Stats()
+			stats.Connections = len(routines)
+			stats.Phase = phase
+			if limiter.Limited() && !stats.Reached && stats.UpdatedAt != "" {
+				log.Printf("%s: %s: Target rate not reached: %d/s, achieved: %.1f/s", dbType, id, stats.TargetQPS, stats.AchievedQPS)
 			}
-
-			if localDBConfig["switch4"] == "true" {
-
-				load.MySQLSwitch4(db, routineId, localDBConfig)
+			if err := valkey.SaveLoadStats(id, stats); err != nil {
+				log.Printf("%s: %s: Error: Saving load stats: %v", dbType, id, err)
 			}
 
-			// Check that sleep is not empty before attempting conversion
-			if sleepDurationStr, ok := localDBConfig["sleep"]; ok && sleepDurationStr != "" {
-				sleepDuration, err := strconv.Atoi(sleepDurationStr)
-				if err == nil && sleepDuration > 0 {
-					time.Sleep(time.Duration(sleepDuration) * time.Millisecond)
-				}
-			}
+			waitConfigChanged(changed, 3*time.Second)
 		}
 	}
 }
 
-func runPostgreSQL(ctx context.Context, routineId int, dbConfig map[string]string) {
-	connectionString := dbConfig["connectionString"]
-
-	db, err := postgres.ConnectByString(connectionString)
+// runDB runs the database operations for a specific connection
+func runDB(dbConfig app.DatabaseConfig, ctx context.Context, routineId int, limiter *loadLimiter) {
+	drv, err := driver.Get(dbConfig.DBType)
 	if err != nil {
-		log.Printf("Postgres: Error: goroutine: %d: %s: message: %s", routineId+1, dbConfig["id"], err)
+		log.Printf("Unknown database type %s for ID %d", dbConfig.DBType, routineId)
 		return
 	}
-	defer db.Close()
-
-	log.Printf("Postgres: goroutine %d in progress for %s", routineId+1, dbConfig["id"])
-
-	// Variable to store the time of the last configuration update
-	lastUpdate := time.Now()
-
-	localDBConfig := make(map[string]string)
-	for k, v := range dbConfig {
-		localDBConfig[k] = v
-	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			log.Printf("Postgres: goroutine: %d stopped for %s", routineId, dbConfig["id"])
-			return
-		default:
-
-			if time.Since(lastUpdate) > 1*time.Second {
-				localDBConfig = getDatabaseByID(dbConfig["id"], "postgres")
-
-				if localDBConfig == nil {
-					log.Printf("Postgres: goroutine: %d: database %s has been removed, stopping goroutine", routineId, dbConfig["id"])
-					return
-				}
-
-				lastUpdate = time.Now()
-			}
-
-			// Debug
-			// newConnections, _ := strconv.Atoi(localDBConfig["connections"])
-			// if newConnections > 0 {
-			// 	log.Printf("Postgres: goroutine: %d: id: %s in progress. Switches: %s, %s, %s, %s, Sleep: %s", routineId+1, dbConfig["id"], localDBConfig["switch1"], localDBConfig["switch2"], localDBConfig["switch3"], localDBConfig["switch4"], localDBConfig["sleep"])
-			// }
-
-			if localDBConfig["switch1"] == "true" {
-				load.PostgresSwitch1(db, routineId, localDBConfig)
-			}
-
-			if localDBConfig["switch2"] == "true" {
-				load.PostgresSwitch2(db, routineId, localDBConfig)
-			}
-
-			if localDBConfig["switch3"] == "true" {
-				load.PostgresSwitch3(db, routineId, localDBConfig)
-			}
-
-			if localDBConfig["switch4"] == "true" {
-				load.PostgresSwitch4(db, routineId, localDBConfig)
-			}
-
-			sleepDuration, err := strconv.Atoi(localDBConfig["sleep"])
-			if err == nil && sleepDuration > 0 {
-				time.Sleep(time.Duration(sleepDuration) * time.Millisecond)
-			}
-		}
-	}
-}
-
-func runMongoDB(ctx context.Context, routineId int, dbConfig map[string]string) {
-	connectionString := dbConfig["connectionString"]
-
-	mongo_ctx := context.Background()
-
-	client, err := mongodb.ConnectByString(connectionString, mongo_ctx)
+	// Connect to the database
+	conn, err := drv.Connect(dbConfig)
 	if err != nil {
-		log.Printf("MongoDB: Connect Error: goroutine: %d: %s: message: %s", routineId+1, dbConfig["id"], err)
+		log.Printf("%s: %s: Error: goroutine: %d: message: %s", drv.Name(), dbConfig.ID, routineId, err)
 		return
 	}
-	defer client.Disconnect(mongo_ctx)
+	defer conn.Close()
 
-	db := dbConfig["database"]
+	log.Printf("%s: %s: goroutine %d in progress", drv.Name(), dbConfig.ID, routineId)
 
-	log.Printf("MongoDB: goroutine %d in progress for %s", routineId+1, dbConfig["id"])
+	// Create an independent copy of dbConfig for use in the loop
+	localDBConfig := dbConfig
 
 	// Variable to store the time of the last configuration update
 	lastUpdate := time.Now()
-
-	localDBConfig := make(map[string]string)
-	for k, v := range dbConfig {
-		localDBConfig[k] = v
-	}
+	changed := configChangedSignal()
+	wait := func() error { return limiter.Wait(ctx) }
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf("MongoDB: goroutine: %d stopped for %s", routineId, dbConfig["id"])
+			log.Printf("%s: %s goroutine: %d stopped", drv.Name(), dbConfig.ID, routineId)
 			return
 		default:
+			// Update localDBConfig on a change event or every second
+			refresh := time.Since(lastUpdate) > 1*time.Second
+			select {
+			case <-changed:
+				refresh = true
+			default:
+			}
 
-			if time.Since(lastUpdate) > 1*time.Second {
-				localDBConfig = getDatabaseByID(dbConfig["id"], "mongodb")
-
-				if localDBConfig == nil {
-					log.Printf("MongoDB: goroutine: %d: database %s has been removed, stopping goroutine", routineId, dbConfig["id"])
+			if refresh {
+				changed = configChangedSignal()
+				updatedDBConfig := getDatabaseByID(dbConfig.ID, dbConfig.DBType)
+				if updatedDBConfig == nil {
+					log.Printf("%s: %s: goroutine: %d: database has been removed, stopping goroutine", drv.Name(), dbConfig.ID, routineId)
 					return
 				}
-
+				localDBConfig = *updatedDBConfig
 				lastUpdate = time.Now()
 			}
-			// log.Printf("MongoDB: goroutine: %d: id: %s in progress. Switches: %s, %s, %s, %s, Sleep: %s", routineId+1, dbConfig["id"], updatedDBConfig["switch1"], updatedDBConfig["switch2"], updatedDBConfig["switch3"], updatedDBConfig["switch4"], updatedDBConfig["sleep"])
 
-			if localDBConfig["switch1"] == "true" {
-				load.MongoDBSwitch1(client, db, routineId, localDBConfig)
-			}
+			// Run the queries enabled by the switches on the control panel, every query waits for its turn
+			drv.RunWorkload(conn, routineId, localDBConfig, wait)
+			limiter.RunDone()
 
-			if localDBConfig["switch2"] == "true" {
-				load.MongoDBSwitch2(client, db, routineId, localDBConfig)
-			}
-
-			if localDBConfig["switch3"] == "true" {
-				load.MongoDBSwitch3(client, db, routineId, localDBConfig)
-			}
-
-			if localDBConfig["switch4"] == "true" {
-				load.MongoDBSwitch4(client, db, routineId, localDBConfig)
+			// With a target rate the queries wait for their turn instead of sleeping
+			if limiter.Limited() && len(localDBConfig.EnabledWorkloads()) > 0 {
+				continue
 			}
 
-			sleepDuration, err := strconv.Atoi(localDBConfig["sleep"])
-			if err == nil && sleepDuration > 0 {
-				time.Sleep(time.Duration(sleepDuration) * time.Millisecond)
+			// Sleep between runs, a change of the configuration interrupts the sleep
+			if localDBConfig.Sleep > 0 {
+				timer := time.NewTimer(time.Duration(localDBConfig.Sleep) * time.Millisecond)
+				select {
+				case <-ctx.Done():
+				case <-changed:
+				case <-timer.C:
+				}
+				timer.Stop()
 			}
 		}
 	}
@@ -533,9 +525,7 @@ func updateLoadDatabases() error {
 
 	// Sort databases by their position
 	sort.Slice(databases, func(i, j int) bool {
-		pos1, _ := strconv.Atoi(databases[i]["position"])
-		pos2, _ := strconv.Atoi(databases[j]["position"])
-		return pos1 < pos2
+		return databases[i].Position < databases[j].Position
 	})
 
 	// Initialize a new DatabasesLoad structure
@@ -549,47 +539,28 @@ func updateLoadDatabases() error {
 
 	// Iterate over each database configuration
 	for _, db := range databases {
-		if db["loadSwitch"] == "true" {
+		if db.LoadSwitch {
 			// Add databases to the respective type in newDatabasesLoad
-			switch db["dbType"] {
-			case "mysql":
-				newDatabasesLoad.MySQL = append(newDatabasesLoad.MySQL, db)
-			case "postgres":
-				newDatabasesLoad.Postgres = append(newDatabasesLoad.Postgres, db)
-			case "mongodb":
-				newDatabasesLoad.MongoDB = append(newDatabasesLoad.MongoDB, db)
-			}
+			newDatabasesLoad[db.DBType] = append(newDatabasesLoad[db.DBType], db)
 
 			// Remove IDs from stopLoadIDs if they are still in load
-			switch db["dbType"] {
-			case "mysql":
-				stopLoadIDs.MySQL = removeIDFromList(stopLoadIDs.MySQL, db["id"])
-			case "postgres":
-				stopLoadIDs.Postgres = removeIDFromList(stopLoadIDs.Postgres, db["id"])
-			case "mongodb":
-				stopLoadIDs.MongoDB = removeIDFromList(stopLoadIDs.MongoDB, db["id"])
-			}
+			stopLoadIDs[db.DBType] = removeIDFromList(stopLoadIDs[db.DBType], db.ID)
 		}
 	}
 
 	// Update the stopLoadIDs for databases that are no longer in load
 	stopLoadIDs = StopLoadIDs{}
-	for _, oldDB := range append(databasesLoad.MySQL, append(databasesLoad.Postgres, databasesLoad.MongoDB...)...) {
-		found := false
-		for _, newDB := range append(newDatabasesLoad.MySQL, append(newDatabasesLoad.Postgres, newDatabasesLoad.MongoDB...)...) {
-			if oldDB["id"] == newDB["id"] {
-				found = true
-				break
+	for dbType, oldDatabases := range databasesLoad {
+		for _, oldDB := range oldDatabases {
+			found := false
+			for _, newDB := range newDatabasesLoad[dbType] {
+				if oldDB.ID == newDB.ID {
+					found = true
+					break
+				}
 			}
-		}
-		if !found {
-			switch oldDB["dbType"] {
-			case "mysql":
-				stopLoadIDs.MySQL = append(stopLoadIDs.MySQL, oldDB["id"])
-			case "postgres":
-				stopLoadIDs.Postgres = append(stopLoadIDs.Postgres, oldDB["id"])
-			case "mongodb":
-				stopLoadIDs.MongoDB = append(stopLoadIDs.MongoDB, oldDB["id"])
+			if !found {
+				stopLoadIDs[dbType] = append(stopLoadIDs[dbType], oldDB.ID)
 			}
 		}
 	}
@@ -617,30 +588,21 @@ func getLoadDatabases() error {
 	}
 
 	sort.Slice(databases, func(i, j int) bool {
-		pos1, _ := strconv.Atoi(databases[i]["position"])
-		pos2, _ := strconv.Atoi(databases[j]["position"])
-		return pos1 < pos2
+		return databases[i].Position < databases[j].Position
 	})
 
 	databasesLoad = DatabasesLoad{}
 
 	for _, db := range databases {
-		if db["loadSwitch"] == "true" {
-			switch db["dbType"] {
-			case "mysql":
-				databasesLoad.MySQL = append(databasesLoad.MySQL, db)
-			case "postgres":
-				databasesLoad.Postgres = append(databasesLoad.Postgres, db)
-			case "mongodb":
-				databasesLoad.MongoDB = append(databasesLoad.MongoDB, db)
-			}
+		if db.LoadSwitch {
+			databasesLoad[db.DBType] = append(databasesLoad[db.DBType], db)
 		}
 	}
 
 	return nil
 }
 
-func checkOrWaitDB(id string, dbType string) map[string]string {
+func checkOrWaitDB(id string, dbType string) *app.DatabaseConfig {
 
 	for {
 		db := getDatabaseByID(id, dbType)
@@ -650,10 +612,10 @@ func checkOrWaitDB(id string, dbType string) map[string]string {
 			return nil
 		}
 
-		checkStatus := checkConnection(db)
+		checkStatus := checkConnection(*db)
 
 		if checkStatus == "Connected" {
-			db["connectionStatus"] = checkStatus
+			db.ConnectionStatus = checkStatus
 			log.Printf("checkOrWaitDB: %s: %s: Status: Connected", dbType, id)
 			return db
 		}
@@ -666,46 +628,33 @@ func checkOrWaitDB(id string, dbType string) map[string]string {
 }
 
 // getDatabaseByID retrieves the database configuration by its ID.
-func getDatabaseByID(id string, dbType string) map[string]string {
-	var databasesForProcess []map[string]string
+func getDatabaseByID(id string, dbType string) *app.DatabaseConfig {
+	var databasesForProcess []app.DatabaseConfig
 
 	// Lock the mutex only for the time needed to copy the reference to the data
 	databasesLoadMutex.Lock()
-	switch dbType {
-	case "mysql":
-		databasesForProcess = databasesLoad.MySQL
-	case "postgres":
-		databasesForProcess = databasesLoad.Postgres
-	case "mongodb":
-		databasesForProcess = databasesLoad.MongoDB
-	}
+	databasesForProcess = databasesLoad[dbType]
 	databasesLoadMutex.Unlock()
 
 	// Search for the database ID outside of the mutex lock
 	for _, db := range databasesForProcess {
-		if db["id"] == id {
-			return db
+		if db.ID == id {
+			return &db
 		}
 	}
 
 	return nil
 }
 
-func checkConnection(db map[string]string) string {
-	connectionString := db["connectionString"]
-	dbType := db["dbType"]
-
-	var result string
-	switch dbType {
-	case "mysql":
-		result = mysql.CheckMySQL(connectionString)
-	case "postgres":
-		result = postgres.CheckPostgreSQL(connectionString)
-	case "mongodb":
-		result = mongodb.CheckMongoDB(connectionString)
+func checkConnection(db app.DatabaseConfig) string {
+	drv, err := driver.Get(db.DBType)
+	if err != nil {
+		return err.Error()
 	}
 
-	// updateConnectionStatus(dbType, db["id"], result)
+	result := drv.Check(db)
+
+	// updateConnectionStatus(dbType, db.ID, result)
 
 	return result
 }
@@ -728,7 +677,7 @@ func checkConnection(db map[string]string) string {
 
 // 	// Update the connection status outside of the mutex lock
 // 	for i, db := range databasesForProcess {
-// 		if db["id"] == id {
+// 		if db.ID == id {
 // 			databasesLoadMutex.Lock()
 // 			switch dbType {
 // 			case "mysql":
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	app "github-stat/internal"
+)
+
+// scheduledConnections returns the number of connections a database should run at the given time
+// and a description of the current phase of its load profile. Without a profile the connections
+// set on the control panel are returned with an empty phase.
+func scheduledConnections(db app.DatabaseConfig, now time.Time) (int, string) {
+	p := db.Profile
+	if p == nil || p.Validate() != nil {
+		return db.Connections, ""
+	}
+
+	elapsed := now.Sub(time.Unix(p.StartedAt, 0))
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	duration := time.Duration(p.Minutes) * time.Minute
+	span := float64(p.To - p.From)
+
+	switch p.Type {
+	case app.ProfileRamp:
+		if elapsed >= duration {
+			return p.To, fmt.Sprintf("Ramp finished, holding %d connections", p.To)
+		}
+		progress := float64(elapsed) / float64(duration)
+		connections := p.From + int(math.Round(span*progress))
+		return connections, fmt.Sprintf("Ramp %d -> %d: %.0f%%, %s left", p.From, p.To, progress*100, formatMinutes(duration-elapsed))
+
+	case app.ProfileStep:
+		stepDuration := duration / time.Duration(p.Steps)
+		step := int(elapsed / stepDuration)
+		if step >= p.Steps {
+			return p.To, fmt.Sprintf("Steps finished, holding %d connections", p.To)
+		}
+		connections := p.From + int(math.Round(span*float64(step)/float64(p.Steps)))
+		next := stepDuration*time.Duration(step+1) - elapsed
+		return connections, fmt.Sprintf("Step %d of %d, next step in %s", step+1, p.Steps, formatMinutes(next))
+
+	case app.ProfileSine:
+		// The wave starts at the minimum, like a day that starts at night
+		position := math.Mod(float64(elapsed), float64(duration)) / float64(duration)
+		level := (1 - math.Cos(2*math.Pi*position)) / 2
+		connections := p.From + int(math.Round(span*level))
+		direction := "rising"
+		if position >= 0.5 {
+			direction = "falling"
+		}
+		return connections, fmt.Sprintf("Sine %s: %.0f%% of the period", direction, position*100)
+
+	case app.ProfileSpike:
+		inPeriod := elapsed % duration
+		spike := time.Duration(p.SpikeMinutes) * time.Minute
+		if inPeriod < spike {
+			return p.To, fmt.Sprintf("Spike, %s left", formatMinutes(spike-inPeriod))
+		}
+		return p.From, fmt.Sprintf("Base, next spike in %s", formatMinutes(duration-inPeriod))
+	}
+
+	return db.Connections, ""
+}
+
+// formatMinutes formats a duration rounded to seconds, e.g. "4m30s".
+func formatMinutes(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	app "github-stat/internal"
+)
+
+func TestScheduledConnections(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	ramp := &app.LoadProfile{Type: app.ProfileRamp, From: 0, To: 10, Minutes: 10, StartedAt: start.Unix()}
+	rampDown := &app.LoadProfile{Type: app.ProfileRamp, From: 10, To: 2, Minutes: 10, StartedAt: start.Unix()}
+	step := &app.LoadProfile{Type: app.ProfileStep, From: 0, To: 10, Minutes: 8, Steps: 4, StartedAt: start.Unix()}
+	sine := &app.LoadProfile{Type: app.ProfileSine, From: 0, To: 10, Minutes: 4, StartedAt: start.Unix()}
+	spike := &app.LoadProfile{Type: app.ProfileSpike, From: 2, To: 20, Minutes: 10, SpikeMinutes: 2, StartedAt: start.Unix()}
+	invalid := &app.LoadProfile{Type: app.ProfileRamp, From: 0, To: 10, Minutes: 0, StartedAt: start.Unix()}
+
+	tests := []struct {
+		name        string
+		profile     *app.LoadProfile
+		elapsed     time.Duration
+		connections int
+		phase       string
+	}{
+		{name: "no profile", profile: nil, connections: 5, phase: ""},
+		{name: "invalid profile", profile: invalid, connections: 5, phase: ""},
+
+		{name: "ramp before the start", profile: ramp, elapsed: -time.Minute, connections: 0, phase: "Ramp 0 -> 10: 0%, 10m0s left"},
+		{name: "ramp start", profile: ramp, elapsed: 0, connections: 0, phase: "Ramp 0 -> 10: 0%, 10m0s left"},
+		{name: "ramp rounds half up", profile: ramp, elapsed: 150 * time.Second, connections: 3, phase: "Ramp 0 -> 10: 25%, 7m30s left"},
+		{name: "ramp middle", profile: ramp, elapsed: 5 * time.Minute, connections: 5, phase: "Ramp 0 -> 10: 50%, 5m0s left"},
+		{name: "ramp end", profile: ramp, elapsed: 10 * time.Minute, connections: 10, phase: "Ramp finished, holding 10 connections"},
+		{name: "ramp down", profile: rampDown, elapsed: 5 * time.Minute, connections: 6, phase: "Ramp 10 -> 2: 50%, 5m0s left"},
+
+		{name: "first step", profile: step, elapsed: 0, connections: 0, phase: "Step 1 of 4, next step in 2m0s"},
+		{name: "second step", profile: step, elapsed: 3 * time.Minute, connections: 3, phase: "Step 2 of 4, next step in 1m0s"},
+		{name: "last step", profile: step, elapsed: 8*time.Minute - time.Second, connections: 8, phase: "Step 4 of 4, next step in 1s"},
+		{name: "steps finished", profile: step, elapsed: 8 * time.Minute, connections: 10, phase: "Steps finished, holding 10 connections"},
+
+		{name: "sine minimum", profile: sine, elapsed: 0, connections: 0, phase: "Sine rising: 0% of the period"},
+		{name: "sine rising", profile: sine, elapsed: time.Minute, connections: 5, phase: "Sine rising: 25% of the period"},
+		{name: "sine maximum", profile: sine, elapsed: 2 * time.Minute, connections: 10, phase: "Sine falling: 50% of the period"},
+		{name: "sine falling", profile: sine, elapsed: 3 * time.Minute, connections: 5, phase: "Sine falling: 75% of the period"},
+		{name: "sine next period", profile: sine, elapsed: 4 * time.Minute, connections: 0, phase: "Sine rising: 0% of the period"},
+
+		{name: "spike start", profile: spike, elapsed: 0, connections: 20, phase: "Spike, 2m0s left"},
+		{name: "spike end", profile: spike, elapsed: 2*time.Minute - time.Second, connections: 20, phase: "Spike, 1s left"},
+		{name: "base", profile: spike, elapsed: 2 * time.Minute, connections: 2, phase: "Base, next spike in 8m0s"},
+		{name: "next spike", profile: spike, elapsed: 11 * time.Minute, connections: 20, phase: "Spike, 1m0s left"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := app.DatabaseConfig{Connections: 5, Profile: tt.profile}
+			connections, phase := scheduledConnections(db, start.Add(tt.elapsed))
+			if connections != tt.connections {
+				t.Errorf("connections = %d, want %d", connections, tt.connections)
+			}
+			if phase != tt.phase {
+				t.Errorf("phase = %q, want %q", phase, tt.phase)
+			}
+		})
+	}
+}
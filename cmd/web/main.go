@@ -1,25 +1,28 @@
 package main
 
 import (
-	"context"
+	"archive/zip"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	app "github-stat/internal"
-	"github-stat/internal/databases/mongodb"
-	"github-stat/internal/databases/mysql"
-	"github-stat/internal/databases/postgres"
-
+	"github-stat/internal/databases/driver"
 	"github-stat/internal/databases/valkey"
-
-	"go.mongodb.org/mongo-driver/bson"
+	"github-stat/internal/export"
+	"github-stat/internal/load"
+	"github-stat/internal/metrics"
 )
 
 func main() {
@@ -47,15 +50,23 @@ func initConfig() {
 
 func handleRequest() {
 
-	http.HandleFunc("/", index)
-	http.HandleFunc("/dataset", dataset)
-	http.HandleFunc("/create_db", createDatabase)
-	http.HandleFunc("/database_list", databaseList)
-	http.HandleFunc("/update_db/", updateDatabase)
-	http.HandleFunc("/delete_db", deleteDatabase)
-	http.HandleFunc("/load_db", loadDatabase)
-	http.HandleFunc("/manage-dataset/", manageDataset)
-
+	metrics.RegisterWeb()
+
+	// Every handler counts its requests for Prometheus
+	http.HandleFunc("/", metrics.InstrumentHandler("index", index))
+	http.HandleFunc("/dataset", metrics.InstrumentHandler("dataset", dataset))
+	http.HandleFunc("/create_db", metrics.InstrumentHandler("create_db", createDatabase))
+	http.HandleFunc("/database_list", metrics.InstrumentHandler("database_list", databaseList))
+	http.HandleFunc("/update_db/", metrics.InstrumentHandler("update_db", updateDatabase))
+	http.HandleFunc("/delete_db", metrics.InstrumentHandler("delete_db", deleteDatabase))
+	http.HandleFunc("/load_db", metrics.InstrumentHandler("load_db", loadDatabase))
+	http.HandleFunc("/load_stats", metrics.InstrumentHandler("load_stats", loadStats))
+	http.HandleFunc("/load_profile", metrics.InstrumentHandler("load_profile", loadProfile))
+	http.HandleFunc("/manage-dataset/", metrics.InstrumentHandler("manage_dataset", manageDataset))
+	http.HandleFunc("/reset_checkpoint", metrics.InstrumentHandler("reset_checkpoint", resetCheckpoint))
+	http.HandleFunc("/export-dataset/", metrics.InstrumentHandler("export_dataset", exportDataset))
+
+	http.Handle("/metrics", metrics.Handler())
 	http.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir("assets"))))
 
 	port := app.Config.ControlPanel.Port
@@ -80,30 +91,187 @@ func manageDataset(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("manageDataset: Action: %s, id: %s", action, id)
 
-	fields := make(map[string]string)
+	db := app.DatabaseConfig{ID: id}
+	field := app.FieldDatasetStatus
 
-	if action == "stop" {
-		fields["datasetStatus"] = ""
-	} else {
-		fields["datasetStatus"] = "Waiting"
+	switch action {
+	case "stop":
+		// A running import is cancelled by the dataset loader, it stops after the current batch.
+		// A waiting import is removed. The status is changed only if the loader has not changed it meanwhile.
+		status, err := stopImport(id)
+		if errors.Is(err, valkey.ErrDatabaseNotFound) {
+			http.Error(w, "Database not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error: Updating database: %v", err)
+			http.Error(w, "Error updating database", http.StatusInternalServerError)
+			return
+		}
+		db.DatasetStatus = status
+		field = ""
+	case "verify":
+		// The dataset loader compares the database with the dataset on its next check
+		db.VerifyStatus = "Waiting"
+		field = app.FieldVerifyStatus
+	default:
+		db.DatasetStatus = "Waiting"
 	}
 
-	err := valkey.AddDatabase(id, fields)
-	if err != nil {
-		log.Printf("Error: Updating database: %v", err)
-		http.Error(w, "Error updating database", http.StatusInternalServerError)
-		return
+	// The stop action has changed the status already
+	if field != "" {
+		err := valkey.AddDatabase(db, field)
+		if err != nil {
+			log.Printf("Error: Updating database: %v", err)
+			http.Error(w, "Error updating database", http.StatusInternalServerError)
+			return
+		}
 	}
 
 	data := map[string]string{
 		"status":        "success",
-		"datasetStatus": fields["datasetStatus"],
+		"datasetStatus": db.DatasetStatus,
+		"verifyStatus":  db.VerifyStatus,
 	}
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(data)
 }
 
+// stopImport sets the dataset status "Cancelling" of a running import or clears the status of a waiting one
+// and returns the status after the change. An import that has finished meanwhile keeps its status.
+func stopImport(id string) (string, error) {
+	for _, change := range []struct{ from, to string }{
+		{"In Progress", "Cancelling"},
+		{"Waiting", ""},
+	} {
+		set, status, err := valkey.CompareAndSetDatabaseField(id, app.FieldDatasetStatus, change.from, change.to)
+		if err != nil || set {
+			return status, err
+		}
+	}
+
+	db, err := valkey.GetDatabase(id)
+	return db.DatasetStatus, err
+}
+
+// exportDataset sends the repositories and pull requests of a database as a ZIP archive with the files
+// of the "format" query parameter: csv (the default), ndjson or parquet. The files are written into
+// a temporary directory first, because the database returns all repositories before the pull requests.
+// Only databases are exported, the store of the dataset loader is not available to the control panel
+// and is exported with "dataset export --store".
+func exportDataset(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/export-dataset/")
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if !slices.Contains(export.Formats, format) {
+		http.Error(w, "Unsupported export format", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("exportDataset: Format: %s, id: %s", format, id)
+
+	db, err := valkey.GetDatabase(id)
+	if err != nil {
+		log.Printf("Error: Getting database: %v", err)
+		http.Error(w, "Database not found", http.StatusNotFound)
+		return
+	}
+
+	drv, err := driver.Get(db.DBType)
+	if err != nil {
+		log.Printf("Error: Exporting dataset: %v", err)
+		http.Error(w, "Unsupported database type", http.StatusBadRequest)
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "export-*")
+	if err != nil {
+		log.Printf("Error: Exporting dataset: %v", err)
+		http.Error(w, "Error exporting dataset", http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	writer, err := export.Create(dir, format)
+	if err == nil {
+		// The export stops when the download is cancelled
+		err = drv.ExportDataset(r.Context(), db, writer)
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		log.Printf("Error: Exporting dataset: %s: %v", id, err)
+		http.Error(w, "Error exporting dataset", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("exportDataset: %s: %d repositories, %d pull requests", id, writer.Repos, writer.Pulls)
+
+	// Parquet files are compressed already
+	method := zip.Deflate
+	if format == export.FormatParquet {
+		method = zip.Store
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-%s.zip", id, format)))
+
+	archive := zip.NewWriter(w)
+	reposName, pullsName := export.FileNames(format)
+	for _, name := range []string{reposName, pullsName} {
+		if err := addFileToZip(archive, filepath.Join(dir, name), method); err != nil {
+			// The response has started, the client gets a broken archive
+			log.Printf("Error: Exporting dataset: %s: %v", id, err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		log.Printf("Error: Exporting dataset: %s: %v", id, err)
+	}
+}
+
+// addFileToZip copies a file into the archive under its base name.
+func addFileToZip(archive *zip.Writer, path string, method uint16) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entry, err := archive.CreateHeader(&zip.FileHeader{Name: filepath.Base(path), Method: method, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, file)
+	return err
+}
+
+// resetCheckpoint removes the GitHub fetch checkpoint of a repository ("owner/repo"),
+// so the dataset loader fetches all its pull requests again on the next run. An empty repo resets all repositories.
+func resetCheckpoint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	repo := strings.TrimSpace(r.FormValue("repo"))
+
+	log.Printf("resetCheckpoint: Repo: %q", repo)
+
+	if err := valkey.ResetGitHubCheckpoint(repo); err != nil {
+		log.Printf("Error: Resetting checkpoint: %v", err)
+		http.Error(w, "Error resetting checkpoint", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "repo": repo})
+}
+
 func prepareIndexData() app.IndexData {
 	// Fetch database configurations from Redis
 	databases, err := valkey.GetDatabases()
@@ -113,15 +281,13 @@ func prepareIndexData() app.IndexData {
 
 	// Sort databases by position
 	sort.Slice(databases, func(i, j int) bool {
-		pos1, _ := strconv.Atoi(databases[i]["position"])
-		pos2, _ := strconv.Atoi(databases[j]["position"])
-		return pos1 < pos2
+		return databases[i].Position < databases[j].Position
 	})
 
 	// Filter databases with loadSwitch set to true
-	var databasesLoad []map[string]string
+	var databasesLoad []app.DatabaseConfig
 	for _, db := range databases {
-		if db["loadSwitch"] == "true" {
+		if db.LoadSwitch {
 			databasesLoad = append(databasesLoad, db)
 		}
 	}
@@ -132,11 +298,25 @@ func prepareIndexData() app.IndexData {
 		DatabasesLoad:    databasesLoad,
 		DatabasesDataset: fetchDatabasesDataset(databases),
 		DatasetState:     fetchDatasetState(),
+		Workloads:        fetchWorkloads(),
 	}
 
 	return data
 }
 
+// fetchWorkloads returns the workloads published by the load generator,
+// or the built-in workloads if the load generator has not published them yet.
+func fetchWorkloads() []app.WorkloadInfo {
+	workloads, err := valkey.GetWorkloads()
+	if err != nil {
+		log.Printf("Error: Getting workloads: %v", err)
+	}
+	if len(workloads) == 0 {
+		return load.Workloads()
+	}
+	return workloads
+}
+
 // fetchDatasetState retrieves the dataset state from Valkey and returns it as an app.DatasetState.
 func fetchDatasetState() app.DatasetState {
 	// Get data from Valkey
@@ -160,6 +340,16 @@ func fetchDatasetState() app.DatasetState {
 		PullsCount: int(data["pulls_count"].(float64)), // assuming the numbers come back as float64
 	}
 
+	// The GitHub API rate limit is published by the GitHub import
+	if rate, ok := data["github_rate"]; ok {
+		rateJSON, _ := json.Marshal(rate)
+		datasetState.GitHubRate = &app.GitHubRate{}
+		if err := json.Unmarshal(rateJSON, datasetState.GitHubRate); err != nil {
+			log.Printf("Error parsing GitHub rate limit: %v", err)
+			datasetState.GitHubRate = nil
+		}
+	}
+
 	// Get the latest report from Valkey
 	report, err := valkey.GetLatestDatasetReport()
 	if err != nil {
@@ -177,7 +367,7 @@ func fetchDatasetState() app.DatasetState {
 	return datasetState
 }
 
-func fetchDatabasesDataset(databases []map[string]string) []app.DatabaseInfo {
+func fetchDatabasesDataset(databases []app.DatabaseConfig) []app.DatabaseInfo {
 	// Channel to collect results from go-routines
 	results := make(chan struct {
 		db  app.DatabaseInfo
@@ -186,7 +376,7 @@ func fetchDatabasesDataset(databases []map[string]string) []app.DatabaseInfo {
 
 	// Fetch dataset information from each database
 	for _, db := range databases {
-		go func(db map[string]string) {
+		go func(db app.DatabaseConfig) {
 			dbData, err := getDataFromDatabase(db)
 			results <- struct {
 				db  app.DatabaseInfo
@@ -198,11 +388,24 @@ func fetchDatabasesDataset(databases []map[string]string) []app.DatabaseInfo {
 		}(db)
 	}
 
+	// The results of the verifications are saved by the dataset loader
+	ids := make([]string, len(databases))
+	for i, db := range databases {
+		ids[i] = db.ID
+	}
+	verifyResults, err := valkey.GetVerifyResults(ids)
+	if err != nil {
+		log.Printf("Error: Getting verification results: %v", err)
+	}
+
 	// Collect results
 	var databasesDataset []app.DatabaseInfo
 	for i := 0; i < len(databases); i++ {
 		result := <-results
 		if result.err == nil {
+			if verify, ok := verifyResults[result.db.ID]; ok {
+				result.db.Verify = &verify
+			}
 			databasesDataset = append(databasesDataset, result.db)
 		}
 	}
@@ -212,347 +415,281 @@ func fetchDatabasesDataset(databases []map[string]string) []app.DatabaseInfo {
 	return databasesDataset
 }
 
-// getDataFromDatabase retrieves the dataset information from a database based on its type and connection string.
-func getDataFromDatabase(db map[string]string) (app.DatabaseInfo, error) {
-	id := db["id"]
-	dbType := db["dbType"]
-	connectionString := db["connectionString"]
-	datasetStatus := db["datasetStatus"]
-	var dbData app.DatasetInfo
-	var err error
-
-	switch dbType {
-	case "mysql":
-		dbData, err = getMySQLData(connectionString)
-	case "postgres":
-		dbData, err = getPostgresData(connectionString)
-	case "mongodb":
-		dbData, err = getMongoDBData(db)
-	default:
-		return app.DatabaseInfo{}, fmt.Errorf("unsupported database type: %s", dbType)
+// getDataFromDatabase retrieves the dataset information from a database using the driver for its type.
+func getDataFromDatabase(db app.DatabaseConfig) (app.DatabaseInfo, error) {
+	drv, err := driver.Get(db.DBType)
+	if err != nil {
+		return app.DatabaseInfo{}, err
 	}
 
+	dbData, err := drv.DatasetInfo(db)
 	if err != nil {
 		return app.DatabaseInfo{}, err
 	}
 
 	return app.DatabaseInfo{
-		ID:           id,
-		DBType:       dbType,
-		DBName:       dbData.DBName,
-		Repositories: dbData.Repositories,
-		PullRequests: dbData.PullRequests,
-		LastUpdate:   dbData.LastUpdate,
-		Status:       datasetStatus,
+		ID:             db.ID,
+		DBType:         db.DBType,
+		DBName:         dbData.DBName,
+		Repositories:   dbData.Repositories,
+		PullRequests:   dbData.PullRequests,
+		Issues:         dbData.Issues,
+		Reviews:        dbData.Reviews,
+		ReviewComments: dbData.ReviewComments,
+		Commits:        dbData.Commits,
+		Users:          dbData.Users,
+		LastUpdate:     dbData.LastUpdate,
+		Status:         db.DatasetStatus,
+		VerifyStatus:   db.VerifyStatus,
 	}, nil
 }
 
-// getMySQLData retrieves the dataset information from a MySQL database.
-func getMySQLData(connectionString string) (app.DatasetInfo, error) {
-	my, err := mysql.ConnectByString(connectionString)
-	if err != nil {
-		log.Printf("Error: Dataset: MySQL: Connect: %s", err)
-		return app.DatasetInfo{}, err
-	}
-	defer my.Close()
+func createDatabase(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		dbType := r.FormValue("dbType")
+		connectionString := r.FormValue("connectionString")
+		mongodbDatabase := r.FormValue("mongodbDatabase")
 
-	dbName := ""
-	err = my.QueryRow(`SELECT DATABASE();`).Scan(&dbName)
-	if err != nil {
-		log.Printf("Error: Dataset: MySQL: Getting MySQL DB name: %v", err)
-		dbName, err = mysql.GetDbName(connectionString)
-		if err != nil {
-			log.Printf("Error: Dataset: MySQL: Getting PostgreSQL DB name: %v", err)
-		}
-		return app.DatasetInfo{
-			DBName: dbName,
-		}, nil
-	} else {
-		mysql_pulls, err := mysql.SelectInt(my, `SELECT COUNT(*) FROM pulls;`)
+		drv, err := driver.Get(dbType)
 		if err != nil {
-			log.Printf("Error: Dataset: MySQL: %s: Pulls: %v", dbName, err)
-		}
-		mysql_repositories, err := mysql.SelectInt(my, `SELECT COUNT(*) FROM repositories;`)
-		if err != nil {
-			log.Printf("Error: Dataset: MySQL: %s: Repos: %v", dbName, err)
+			log.Printf("Error: Creating database: %v", err)
+			http.Error(w, "Unsupported database type", http.StatusBadRequest)
+			return
 		}
 
-		// Get the latest update from the reports_dataset table
-		var lastUpdate string
-		err = my.QueryRow(`
-			SELECT JSON_UNQUOTE(JSON_EXTRACT(data, '$.finished_at'))
-			FROM reports_dataset
-			ORDER BY JSON_UNQUOTE(JSON_EXTRACT(data, '$.finished_at')) DESC
-			LIMIT 1
-		`).Scan(&lastUpdate)
+		id, err := valkey.NextDatabaseID(dbType)
 		if err != nil {
-			log.Printf("Error: Dataset: MySQL: %s: Last update: %v", dbName, err)
+			log.Printf("Error: Allocating database ID: %v", err)
+			http.Error(w, "Error allocating database ID", http.StatusInternalServerError)
+			return
 		}
 
-		return app.DatasetInfo{
-			DBName:       dbName,
-			Repositories: mysql_repositories,
-			PullRequests: mysql_pulls,
-			LastUpdate:   lastUpdate,
-		}, nil
-	}
-}
+		db := app.NewDatabaseConfig(id, dbType, connectionString)
 
-// getPostgresData retrieves the dataset information from a PostgreSQL database.
-func getPostgresData(connectionString string) (app.DatasetInfo, error) {
-	pg, err := postgres.ConnectByString(connectionString)
-	if err != nil {
-		log.Printf("Error: Dataset: Postgres: Connect: %s", err)
+		if dbType == "mongodb" {
+			db.Database = mongodbDatabase
+		}
 
-	}
-	defer pg.Close()
+		db.ConnectionStatus = drv.Check(db)
 
-	dbName := ""
-	err = pg.QueryRow(`SELECT current_database();`).Scan(&dbName)
-	if err != nil {
-		log.Printf("Error: Dataset: Postgres: Getting PostgreSQL DB name: %v", err)
-		dbName, err = postgres.GetDbName(connectionString)
-		if err != nil {
-			log.Printf("Error: Dataset: Postgres: Getting PostgreSQL DB name: %v", err)
+		textMessage := ""
+		if db.ConnectionStatus == "Connected" {
+			db.SchemaStatus = true
+			updateSchemaVersion(drv, &db)
+
+			textMessage = fmt.Sprintf(
+				`Database connection (ID: <a href="#formDatabases-%s">%s</a>) has been successfully created. To add to the Load Generator Control Panel enable <a href="#formDatabases-%s">the Enable Load</a> switch.`,
+				id, id, id,
+			)
+		} else if drv.SchemaMissing(db.ConnectionStatus) {
+
+			db.UpdateStatus = fmt.Sprintf("Database connection (id: %s) has been successfully created, but the database schema is missing. To create it, click the Create Schema button below.", id)
+			db.SchemaStatus = false
+			textMessage = fmt.Sprintf(
+				`Database connection (ID: <a href="#formDatabases-%s">%s</a>) has been successfully created, but the database schema is missing. To create it, click the <a href="#formDatabases-%s">Create Schema</a> button below.`,
+				id, id, id,
+			)
+		} else {
+			textMessage = fmt.Sprintf(
+				`Connection (ID: <a href="#formDatabases-%s">%s</a>) to the database was created, but an error occurred while trying to connect. Please check the connection string in the list below. Error: %s`,
+				id, id, db.ConnectionStatus,
+			)
 		}
-		return app.DatasetInfo{
-			DBName: dbName,
-		}, nil
-	} else {
 
-		pg_pulls, err := postgres.SelectInt(pg, `SELECT COUNT(*) FROM github.pulls;`)
-		if err != nil {
-			log.Printf("Error: Dataset: Postgres: %s: Pulls: %v", dbName, err)
-		}
-		pg_repositories, err := postgres.SelectInt(pg, `SELECT COUNT(*) FROM github.repositories;`)
+		err = valkey.AddDatabase(db)
 		if err != nil {
-			log.Printf("Error: Dataset: Postgres: %s: Repos: %v", dbName, err)
+			log.Printf("Error: Creating database: %v", err)
+			http.Error(w, "Error creating database", http.StatusInternalServerError)
+			return
 		}
 
-		// Get the latest update from the reports_dataset table
-		var lastUpdate string
-		err = pg.QueryRow(`
-			SELECT data->>'finished_at'
-			FROM github.reports_dataset
-			ORDER BY data->>'finished_at' DESC
-			LIMIT 1
-		`).Scan(&lastUpdate)
-		if err != nil {
-			log.Printf("Error: Dataset: Postgres: %s: Last update: %v", dbName, err)
+		data := map[string]string{
+			"status":       "success",
+			"id":           id,
+			"updateStatus": db.UpdateStatus,
+			"textMessage":  textMessage,
 		}
 
-		return app.DatasetInfo{
-			DBName:       dbName,
-			Repositories: pg_repositories,
-			PullRequests: pg_pulls,
-			LastUpdate:   lastUpdate,
-		}, nil
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(data)
+	} else {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 	}
-
 }
 
-// getMongoDBData retrieves the dataset information from a MongoDB database.
-func getMongoDBData(db map[string]string) (app.DatasetInfo, error) {
-	connectionString := db["connectionString"]
-	dbName := db["database"] // Use the database field from db map
+// loadDatabase sets the connections, the target rate and the workloads of an existing database.
+// The values are validated with the whole record before they are saved.
+func loadDatabase(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
 
-	mongo_ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
-	defer cancel()
+	id := r.FormValue("id")
 
-	mongo, err := mongodb.ConnectByString(connectionString, mongo_ctx)
+	connections, err := strconv.Atoi(r.FormValue("connections"))
 	if err != nil {
-		log.Printf("Error: Dataset: MongoDB: Connect: %s", err)
-		return app.DatasetInfo{
-			DBName: dbName,
-		}, nil
+		http.Error(w, "Invalid number of connections", http.StatusBadRequest)
+		return
 	}
-	defer mongo.Disconnect(mongo_ctx)
 
-	mongo_pulls, err := mongodb.CountDocuments(mongo, dbName, "pulls", bson.D{})
-	if err != nil {
-		log.Printf("Error: Dataset: MongoDB: Count Pulls: %s", err)
-		mongo_pulls = 0
+	targetQPS, err := parseFormInt(r, "targetQPS")
+	if err != nil || targetQPS < 0 {
+		http.Error(w, "Invalid target queries per second", http.StatusBadRequest)
+		return
 	}
 
-	mongo_repositories, err := mongodb.CountDocuments(mongo, dbName, "repositories", bson.D{})
-	if err != nil {
-		log.Printf("Error: Dataset: MongoDB: Count Repos: %s", err)
-		mongo_repositories = 0
+	db, ok := getDatabase(w, id)
+	if !ok {
+		return
 	}
 
-	// Use the new function to get the latest update from the reports_dataset collection
-	lastUpdate, err := mongodb.GetDatasetLatestUpdates(db)
-	if err != nil {
-		log.Printf("Error: Dataset: MongoDB: Last update: %v", err)
-	}
+	db.Connections = connections
+	db.TargetQPS = targetQPS
+	db.SetWorkloads(r.Form["workloads"])
 
-	return app.DatasetInfo{
-		DBName:       dbName,
-		Repositories: int(mongo_repositories),
-		PullRequests: int(mongo_pulls),
-		LastUpdate:   lastUpdate,
-	}, nil
-}
+	if err := db.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-func createDatabase(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodPost {
-		dbType := r.FormValue("dbType")
-		connectionString := r.FormValue("connectionString")
-		mongodbDatabase := r.FormValue("mongodbDatabase")
+	fieldsToUpdate := []string{
+		app.FieldConnections,
+		app.FieldTargetQPS,
+		app.FieldSwitch1,
+		app.FieldSwitch2,
+		app.FieldSwitch3,
+		app.FieldSwitch4,
+		app.FieldWorkloads,
+	}
 
-		maxID, err := valkey.GetMaxID(dbType)
-		if err != nil {
-			log.Printf("Error: Getting max ID: %v", err)
-			http.Error(w, "Error getting max ID", http.StatusInternalServerError)
-			return
-		}
+	err = valkey.AddDatabase(db, fieldsToUpdate...)
+	if err != nil {
+		log.Printf("Error: Updating database load settings: %v", err)
+		http.Error(w, "Error updating database load settings", http.StatusInternalServerError)
+		return
+	}
 
-		newID := maxID + 1
-		id := fmt.Sprintf("%s-%d", dbType, newID)
-
-		fields := map[string]string{
-			"id":               id,
-			"dbType":           dbType,
-			"connectionString": connectionString,
-			"loadSwitch":       "false",
-			"position":         "0",
-			"sleep":            "100",
-			"connections":      "0",
-			"switch1":          "false",
-			"switch2":          "false",
-			"switch3":          "false",
-			"switch4":          "false",
-		}
+	response := db.Hash(fieldsToUpdate...)
+	response["id"] = id
 
-		if dbType == "mongodb" {
-			fields["database"] = mongodbDatabase
-		}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
 
-		textMessage := ""
-		if dbType == "mysql" {
-			fields["connectionStatus"] = mysql.CheckMySQL(connectionString)
-
-			if fields["connectionStatus"] == "Connected" {
-				fields["schemaStatus"] = "true"
-
-				textMessage = fmt.Sprintf(
-					`Database connection (ID: <a href="#formDatabases-%s">%s</a>) has been successfully created. To add to the Load Generator Control Panel enable <a href="#formDatabases-%s">the Enable Load</a> switch.`,
-					id, id, id,
-				)
-			} else if strings.Contains(fields["connectionStatus"], "Unknown database") {
-
-				fields["updateStatus"] = fmt.Sprintf("Database connection (id: %s) has been successfully created, but the database schema is missing. To create it, click the Create Schema button below.", id)
-				fields["schemaStatus"] = "false"
-				textMessage = fmt.Sprintf(
-					`Database connection (ID: <a href="#formDatabases-%s">%s</a>) has been successfully created, but the database schema is missing. To create it, click the <a href="#formDatabases-%s">Create Schema</a> button below.`,
-					id, id, id,
-				)
-			} else {
-				textMessage = fmt.Sprintf(
-					`Connection (ID: <a href="#formDatabases-%s">%s</a>) to the database was created, but an error occurred while trying to connect. Please check the connection string in the list below. Error: %s`,
-					id, id, fields["connectionStatus"],
-				)
-			}
+// loadStats returns the load achieved by the load generator for the databases on the control panel.
+func loadStats(w http.ResponseWriter, r *http.Request) {
+	databases, err := valkey.GetDatabases()
+	if err != nil {
+		log.Printf("Error: Getting databases: %v", err)
+		http.Error(w, "Error getting databases", http.StatusInternalServerError)
+		return
+	}
 
-		} else if dbType == "postgres" {
-			fields["connectionStatus"] = postgres.CheckPostgreSQL(connectionString)
+	var ids []string
+	for _, db := range databases {
+		if db.LoadSwitch {
+			ids = append(ids, db.ID)
+		}
+	}
 
-			if fields["connectionStatus"] == "Connected" {
-				fields["schemaStatus"] = "true"
+	stats, err := valkey.GetLoadStats(ids)
+	if err != nil {
+		log.Printf("Error: Getting load stats: %v", err)
+		http.Error(w, "Error getting load stats", http.StatusInternalServerError)
+		return
+	}
 
-				textMessage = fmt.Sprintf(
-					`Database connection (ID: <a href="#formDatabases-%s">%s</a>) has been successfully created. To add to the Load Generator Control Panel enable <a href="#formDatabases-%s">the Enable Load</a> switch.`,
-					id, id, id,
-				)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
 
-			} else if strings.Contains(fields["connectionStatus"], "does not exist") || strings.Contains(fields["connectionStatus"], "server login has been failing") {
+// loadProfile starts or stops the load profile of a database. An empty type stops the profile,
+// the load generator then returns to the connections set with the slider.
+func loadProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
 
-				fields["updateStatus"] = fmt.Sprintf("Database connection (id: %s) has been successfully created, but the database schema is missing. To create it, click the Create Schema button below.", id)
-				fields["schemaStatus"] = "false"
-				textMessage = fmt.Sprintf(
-					`Database connection (ID: <a href="#formDatabases-%s">%s</a>) has been successfully created, but the database schema is missing. To create it, click the <a href="#formDatabases-%s">Create Schema</a> button below.`,
-					id, id, id,
-				)
+	id := r.FormValue("id")
+	profileType := r.FormValue("type")
 
-			} else {
-				textMessage = fmt.Sprintf(
-					`Connection (ID: <a href="#formDatabases-%s">%s</a>) to the database was created, but an error occurred while trying to connect. Please check the connection string in the list below. Error: %s`,
-					id, id, fields["connectionStatus"],
-				)
-			}
+	db, ok := getDatabase(w, id)
+	if !ok {
+		return
+	}
+	db.Profile = nil
 
-		} else if dbType == "mongodb" {
+	if profileType != "" {
+		profile := app.LoadProfile{
+			Type:      profileType,
+			StartedAt: time.Now().Unix(),
+		}
 
-			fields["connectionStatus"] = mongodb.CheckMongoDB(connectionString)
-			if fields["connectionStatus"] == "Connected" {
-				textMessage = fmt.Sprintf(
-					`Database connection (ID: <a href="#formDatabases-%s">%s</a>) has been successfully created. To add to the Load Generator Control Panel enable <a href="#formDatabases-%s">the Enable Load</a> switch.`,
-					id, id, id,
-				)
-			} else {
-				textMessage = fmt.Sprintf(
-					`Connection (ID: <a href="#formDatabases-%s">%s</a>) to the database was created, but an error occurred while trying to connect. Please check the connection string in the list below. Error: %s`,
-					id, id, fields["connectionStatus"],
-				)
+		var errs []error
+		for key, value := range map[string]*int{
+			"from":         &profile.From,
+			"to":           &profile.To,
+			"minutes":      &profile.Minutes,
+			"steps":        &profile.Steps,
+			"spikeMinutes": &profile.SpikeMinutes,
+		} {
+			var err error
+			*value, err = parseFormInt(r, key)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s", key))
 			}
 		}
-
-		err = valkey.AddDatabase(id, fields)
-		if err != nil {
-			log.Printf("Error: Creating database: %v", err)
-			http.Error(w, "Error creating database", http.StatusInternalServerError)
+		if err := errors.Join(errs...); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		data := map[string]string{
-			"status":       "success",
-			"id":           id,
-			"updateStatus": fields["updateStatus"],
-			"textMessage":  textMessage,
+		if err := profile.Validate(); err != nil {
+			http.Error(w, "Invalid load profile: "+err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(data)
-	} else {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		db.Profile = &profile
 	}
-}
 
-func loadDatabase(w http.ResponseWriter, r *http.Request) {
-	id := r.FormValue("id")
-	connections := r.FormValue("connections")
-	switch1 := convertSwitch(r.FormValue("switch1"))
-	switch2 := convertSwitch(r.FormValue("switch2"))
-	switch3 := convertSwitch(r.FormValue("switch3"))
-	switch4 := convertSwitch(r.FormValue("switch4"))
-
-	fieldsToUpdate := map[string]string{
-		"connections": connections,
-		"switch1":     switch1,
-		"switch2":     switch2,
-		"switch3":     switch3,
-		"switch4":     switch4,
+	if err := db.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	err := valkey.AddDatabase(id, fieldsToUpdate)
+	err := valkey.AddDatabase(db, app.FieldProfile)
 	if err != nil {
-		log.Printf("Error: Updating database load settings: %v", err)
-		http.Error(w, "Error updating database load settings", http.StatusInternalServerError)
+		log.Printf("Error: Updating load profile: %v", err)
+		http.Error(w, "Error updating load profile", http.StatusInternalServerError)
 		return
 	}
 
-	response := fieldsToUpdate
+	response := db.Hash(app.FieldProfile)
 	response["id"] = id
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
 
-func convertSwitch(value string) string {
-	if value == "on" {
-		return "true"
+// getDatabase reads the database for a request that changes it. A missing database is answered
+// with 404 and is not created by the change, other errors with 500.
+func getDatabase(w http.ResponseWriter, id string) (app.DatabaseConfig, bool) {
+	db, err := valkey.GetDatabase(id)
+	if errors.Is(err, valkey.ErrDatabaseNotFound) {
+		http.Error(w, "Database not found", http.StatusNotFound)
+		return db, false
+	}
+	if err != nil {
+		log.Printf("Error: Getting database: %v", err)
+		http.Error(w, "Error getting database", http.StatusInternalServerError)
+		return db, false
 	}
-	return "false"
+	return db, true
+}
+
+func convertSwitch(value string) bool {
+	return value == "on"
 }
 
 func updateDatabase(w http.ResponseWriter, r *http.Request) {
@@ -561,26 +698,29 @@ func updateDatabase(w http.ResponseWriter, r *http.Request) {
 	database := r.FormValue("database")
 	dbType := r.FormValue("dbType")
 	loadSwitch := convertSwitch(r.FormValue("loadSwitch"))
-	position := r.FormValue("position")
-	sleep := r.FormValue("sleep")
 
 	init_schema := r.FormValue("init_schema")
 	delete_schema := r.FormValue("delete_schema")
+	migrate_schema := r.FormValue("migrate_schema")
 
 	updateStatus := ""
-	if position == "" {
-		position = "0"
+
+	position, err := parseFormInt(r, "position")
+	if err != nil {
+		http.Error(w, "Invalid position", http.StatusBadRequest)
+		return
 	}
-	if sleep == "" {
-		sleep = "0"
+	sleep, err := parseFormInt(r, "sleep")
+	if err != nil {
+		http.Error(w, "Invalid sleep", http.StatusBadRequest)
+		return
 	}
 
-	fieldsToUpdate := map[string]string{
-		"connectionString": connectionString,
-		"database":         database,
-		"loadSwitch":       loadSwitch,
-		"position":         position,
-		"sleep":            sleep,
+	drv, err := driver.Get(dbType)
+	if err != nil {
+		log.Printf("Error: Updating database: %v", err)
+		http.Error(w, "Unsupported database type", http.StatusBadRequest)
+		return
 	}
 
 	currentDB, err := valkey.GetDatabase(id)
@@ -590,114 +730,110 @@ func updateDatabase(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	for key, value := range fieldsToUpdate {
-		currentDB[key] = value
+	currentDB.ConnectionString = connectionString
+	currentDB.Database = database
+	currentDB.LoadSwitch = loadSwitch
+	currentDB.Position = position
+	currentDB.Sleep = sleep
+
+	if err := currentDB.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The schema mode is selected when the schema is created, Migrate Schema switches the mode of an existing schema.
+	// It is stored only after InitSchema or MigrateSchema has changed the schema.
+	schemaMode := r.FormValue("schemaMode")
+	if (init_schema != "" || migrate_schema != "") && schemaMode != "" && schemaMode != app.SchemaModeJSON && schemaMode != app.SchemaModeNormalized {
+		http.Error(w, fmt.Sprintf("schemaMode must be %q or %q", app.SchemaModeJSON, app.SchemaModeNormalized), http.StatusBadRequest)
+		return
 	}
 
 	if delete_schema != "" {
-		switch dbType {
-		case "mysql":
-			err = mysql.DeleteSchema(connectionString)
-		case "postgres":
-			err = postgres.DeleteSchema(connectionString)
-		case "mongodb":
-			err = mongodb.DeleteSchema(connectionString, database)
-		}
+		err = drv.DeleteSchema(currentDB)
 		if err != nil {
 			log.Printf("Error: Deleting schema: %v", err)
 			http.Error(w, "Error deleting schema", http.StatusInternalServerError)
 			return
 		} else {
 			updateStatus = "Schema deletion successful."
-			currentDB["datasetStatus"] = ""
-			currentDB["schemaStatus"] = "false"
+			currentDB.DatasetStatus = ""
+			currentDB.SchemaStatus = false
+			currentDB.SchemaVersion = 0
 		}
 	}
 
-	if dbType == "mysql" {
-		currentDB["connectionStatus"] = mysql.CheckMySQL(connectionString)
-
-		if currentDB["connectionStatus"] == "Connected" {
-			currentDB["schemaStatus"] = "true"
-			currentDB["updateStatus"] = ""
-		} else if strings.Contains(currentDB["connectionStatus"], "Unknown database") {
-			if init_schema != "" {
-				err := mysql.InitSchema(connectionString)
-				if err != nil {
-					currentDB["connectionStatus"] = fmt.Sprintf("Error: MySQL Database creation error: %v", err)
-					currentDB["schemaStatus"] = "false"
-				} else {
-
-					currentDB["connectionStatus"] = mysql.CheckMySQL(connectionString)
-					if currentDB["connectionStatus"] == "Connected" {
-						updateStatus = "The database and schema have been created. Connection is successful."
-						currentDB["schemaStatus"] = "true"
-						currentDB["updateStatus"] = ""
-					}
-				}
-			} else {
-				updateStatus = "You need to create a test database and a schema. Click the Create schema button."
-				currentDB["updateStatus"] = updateStatus
-				currentDB["schemaStatus"] = "false"
-			}
-		}
+	currentDB.ConnectionStatus = drv.Check(currentDB)
 
-	} else if dbType == "postgres" {
-		currentDB["connectionStatus"] = postgres.CheckPostgreSQL(connectionString)
-
-		log.Printf("Connect 1: %v", currentDB["connectionStatus"])
-		if currentDB["connectionStatus"] == "Connected" {
-			currentDB["schemaStatus"] = "true"
-			currentDB["updateStatus"] = ""
-		} else if strings.Contains(currentDB["connectionStatus"], "does not exist") || strings.Contains(currentDB["connectionStatus"], "server login has been failing") {
-
-			if init_schema != "" {
-				err := postgres.InitSchema(connectionString)
-				if err != nil {
-					currentDB["connectionStatus"] = fmt.Sprintf("Error: Postgres Database creation error: %v", err)
-					currentDB["schemaStatus"] = "false"
-				} else {
-
-					currentDB["connectionStatus"] = postgres.CheckPostgreSQL(connectionString)
-
-					if currentDB["connectionStatus"] == "Connected" {
-						updateStatus = "The database and schema have been created. Connection is successful."
-						currentDB["schemaStatus"] = "true"
-						currentDB["updateStatus"] = ""
-					}
-				}
-			} else {
-				updateStatus = "You need to create a test database and a schema. Click the Create schema button."
-				currentDB["updateStatus"] = updateStatus
-				currentDB["schemaStatus"] = "false"
-			}
-		}
+	log.Printf("%s: %s: Connection status: %s", drv.Name(), id, currentDB.ConnectionStatus)
 
-	} else if dbType == "mongodb" {
+	if currentDB.ConnectionStatus == "Connected" {
+		currentDB.SchemaStatus = true
+		currentDB.UpdateStatus = ""
 
-		currentDB["connectionStatus"] = mongodb.CheckMongoDB(connectionString)
+		// Create Schema does not change the mode of an existing schema, Migrate Schema does
+		if init_schema != "" {
+			updateStatus = "The schema exists already, its mode is not changed. Use Migrate Schema to change it."
+		}
 
-		log.Printf("MongoDB Status: %s", currentDB["connectionStatus"])
+		if delete_schema == "" {
+			err := drv.Prepare(currentDB)
+			if err != nil {
+				log.Printf("Error: %s: %s: Prepare: %v", drv.Name(), id, err)
+			}
+		}
+	} else if drv.SchemaMissing(currentDB.ConnectionStatus) {
+		if init_schema != "" {
+			initDB := currentDB
+			initDB.SchemaMode = schemaMode
+			err := drv.InitSchema(initDB)
+			if err != nil {
+				currentDB.ConnectionStatus = fmt.Sprintf("Error: %s Database creation error: %v", drv.Name(), err)
+				currentDB.SchemaStatus = false
+			} else {
+				currentDB.SchemaMode = schemaMode
 
-		if currentDB["connectionStatus"] != "Connected" {
-			currentDB["datasetStatus"] = ""
-		} else {
-			if delete_schema == "" {
-				err := mongodb.InitProfileOptions(connectionString, database)
-				if err != nil {
-					log.Printf("Error: MongoDB: %s: InitProfileOptions: %v", id, err)
-				}
-				err = mongodb.InitProfileOptions(connectionString, "admin")
-				if err != nil {
-					log.Printf("Error: MongoDB: %s: InitProfileOptions (admin): %v", id, err)
+				currentDB.ConnectionStatus = drv.Check(currentDB)
+				if currentDB.ConnectionStatus == "Connected" {
+					updateStatus = "The database and schema have been created. Connection is successful."
+					currentDB.SchemaStatus = true
+					currentDB.UpdateStatus = ""
 				}
 			}
+		} else {
+			updateStatus = "You need to create a test database and a schema. Click the Create schema button."
+			currentDB.UpdateStatus = updateStatus
+			currentDB.SchemaStatus = false
 		}
+	} else {
+		currentDB.DatasetStatus = ""
 	}
 
-	log.Printf("Update: ID: %s, Fields: %v", id, currentDB)
-
-	err = valkey.AddDatabase(id, currentDB)
+	if currentDB.ConnectionStatus == "Connected" {
+		if migrate_schema != "" {
+			updateStatus = migrateSchema(drv, currentDB, migrate_schema, schemaMode)
+		}
+		updateSchemaVersion(drv, &currentDB)
+	}
+
+	log.Printf("Update: ID: %s, Fields: %+v", id, currentDB)
+
+	// Connections and query switches are set by loadDatabase and are not overwritten here
+	err = valkey.AddDatabase(currentDB,
+		app.FieldVersion,
+		app.FieldConnectionString,
+		app.FieldDatabase,
+		app.FieldLoadSwitch,
+		app.FieldPosition,
+		app.FieldSleep,
+		app.FieldConnectionStatus,
+		app.FieldSchemaStatus,
+		app.FieldSchemaVersion,
+		app.FieldSchemaLatest,
+		app.FieldSchemaMode,
+		app.FieldUpdateStatus,
+		app.FieldDatasetStatus,
+	)
 	if err != nil {
 		log.Printf("Error: Updating database: %v", err)
 		http.Error(w, "Error updating database", http.StatusInternalServerError)
@@ -710,8 +846,10 @@ func updateDatabase(w http.ResponseWriter, r *http.Request) {
 
 	data := map[string]string{
 		"status":           "success",
-		"connectionStatus": currentDB["connectionStatus"],
-		"schemaStatus":     currentDB["schemaStatus"],
+		"connectionStatus": currentDB.ConnectionStatus,
+		"schemaStatus":     strconv.FormatBool(currentDB.SchemaStatus),
+		"schemaVersion":    strconv.Itoa(currentDB.SchemaVersion),
+		"schemaLatest":     strconv.Itoa(currentDB.SchemaLatest),
 		"updateStatus":     updateStatus,
 	}
 
@@ -719,6 +857,72 @@ func updateDatabase(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(data)
 }
 
+// migrateSchema applies all pending schema migrations ("up") or reverts the last applied one ("down")
+// and returns the message shown on the settings page. "up" with a mode other than the one of the schema
+// switches the schema to the mode, an empty mode keeps it. "down" always keeps the mode of the schema.
+func migrateSchema(drv driver.Driver, db app.DatabaseConfig, direction string, mode string) string {
+	version, err := drv.SchemaVersion(db)
+	if err != nil {
+		log.Printf("Error: %s: %s: Getting schema version: %v", drv.Name(), db.ID, err)
+		return fmt.Sprintf("Schema migration error: %v", err)
+	}
+
+	if version.Mode != "" {
+		db.SchemaMode = version.Mode
+	}
+	target := app.LatestMigration
+	if direction == "down" {
+		target = version.Version - 1
+	} else if mode != "" {
+		db.SchemaMode = mode
+	}
+
+	if err := drv.MigrateSchema(db, target); err != nil {
+		log.Printf("Error: %s: %s: Migrating schema to version %d: %v", drv.Name(), db.ID, target, err)
+		return fmt.Sprintf("Schema migration error: %v", err)
+	}
+
+	version, err = drv.SchemaVersion(db)
+	if err != nil {
+		log.Printf("Error: %s: %s: Getting schema version: %v", drv.Name(), db.ID, err)
+		return fmt.Sprintf("Schema migration error: %v", err)
+	}
+	if version.Mode != "" {
+		return fmt.Sprintf("The schema has been migrated to version %d in the %s mode.", version.Version, version.Mode)
+	}
+	return fmt.Sprintf("The schema has been migrated to version %d.", version.Version)
+}
+
+// updateSchemaVersion sets the migration versions and the mode of the test schema, they are shown on the settings page.
+// The mode stored in the test schema replaces the one stored in Valkey, e.g. after the schema was
+// migrated by another control panel, so the load generator runs the queries of the actual tables.
+func updateSchemaVersion(drv driver.Driver, db *app.DatabaseConfig) {
+	version, err := drv.SchemaVersion(*db)
+	if err != nil {
+		log.Printf("Error: %s: %s: Getting schema version: %v", drv.Name(), db.ID, err)
+		return
+	}
+	db.SchemaVersion = version.Version
+	db.SchemaLatest = version.Latest
+
+	if version.Mode == "" {
+		return
+	}
+	if (version.Mode == app.SchemaModeNormalized) != db.Normalized() {
+		log.Printf("%s: %s: The test schema is in the %s mode, updating the schema mode %q", drv.Name(), db.ID, version.Mode, db.SchemaMode)
+	}
+	db.SchemaMode = version.Mode
+}
+
+// parseFormInt parses an integer form value. An empty value is treated as 0.
+func parseFormInt(r *http.Request, key string) (int, error) {
+	value := strings.TrimSpace(r.FormValue(key))
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
 func deleteDatabase(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodPost {
 		id := r.FormValue("id")
@@ -752,9 +956,7 @@ func databaseList(w http.ResponseWriter, r *http.Request) {
 	}
 
 	sort.Slice(databases, func(i, j int) bool {
-		pos1, _ := strconv.Atoi(databases[i]["position"])
-		pos2, _ := strconv.Atoi(databases[j]["position"])
-		return pos1 < pos2
+		return databases[i].Position < databases[j].Position
 	})
 
 	data := map[string]interface{}{
@@ -31,4 +31,45 @@ CREATE TABLE IF NOT EXISTS pullsTest (
 CREATE TABLE IF NOT EXISTS reports_dataset (
     id INT AUTO_INCREMENT PRIMARY KEY,
     data JSON
+);
+
+CREATE TABLE IF NOT EXISTS issues (
+    id BIGINT NOT NULL,
+    repo VARCHAR(255) NOT NULL,
+    data JSON,
+    PRIMARY KEY (id, repo),
+    INDEX idx_repo (repo)
+);
+
+CREATE TABLE IF NOT EXISTS reviews (
+    id BIGINT NOT NULL,
+    repo VARCHAR(255) NOT NULL,
+    pull INT NOT NULL,
+    data JSON,
+    PRIMARY KEY (id, repo),
+    INDEX idx_repo_pull (repo, pull)
+);
+
+CREATE TABLE IF NOT EXISTS review_comments (
+    id BIGINT NOT NULL,
+    repo VARCHAR(255) NOT NULL,
+    pull INT NOT NULL,
+    data JSON,
+    PRIMARY KEY (id, repo),
+    INDEX idx_repo_pull (repo, pull)
+);
+
+CREATE TABLE IF NOT EXISTS commits (
+    sha CHAR(40) NOT NULL,
+    repo VARCHAR(255) NOT NULL,
+    data JSON,
+    PRIMARY KEY (sha, repo),
+    INDEX idx_repo (repo)
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id BIGINT NOT NULL PRIMARY KEY,
+    login VARCHAR(255) NOT NULL,
+    data JSON,
+    INDEX idx_login (login)
 );
\ No newline at end of file
@@ -41,3 +41,45 @@ CREATE INDEX idx_id_pulls ON github.pulls (id);
 CREATE INDEX idx_repo_pulls ON github.pulls (repo);
 CREATE INDEX idx_id_pulls_test ON github.pulls_test (id);
 CREATE INDEX idx_repo_pulls_test ON github.pulls_test (repo);
+
+CREATE TABLE IF NOT EXISTS github.issues (
+    id BIGINT NOT NULL,
+    repo VARCHAR(255) NOT NULL,
+    data JSONB,
+    PRIMARY KEY (id, repo)
+);
+
+CREATE TABLE IF NOT EXISTS github.reviews (
+    id BIGINT NOT NULL,
+    repo VARCHAR(255) NOT NULL,
+    pull INT NOT NULL,
+    data JSONB,
+    PRIMARY KEY (id, repo)
+);
+
+CREATE TABLE IF NOT EXISTS github.review_comments (
+    id BIGINT NOT NULL,
+    repo VARCHAR(255) NOT NULL,
+    pull INT NOT NULL,
+    data JSONB,
+    PRIMARY KEY (id, repo)
+);
+
+CREATE TABLE IF NOT EXISTS github.commits (
+    sha CHAR(40) NOT NULL,
+    repo VARCHAR(255) NOT NULL,
+    data JSONB,
+    PRIMARY KEY (sha, repo)
+);
+
+CREATE TABLE IF NOT EXISTS github.users (
+    id BIGINT PRIMARY KEY,
+    login VARCHAR(255) NOT NULL,
+    data JSONB
+);
+
+CREATE INDEX idx_repo_issues ON github.issues (repo);
+CREATE INDEX idx_repo_pull_reviews ON github.reviews (repo, pull);
+CREATE INDEX idx_repo_pull_review_comments ON github.review_comments (repo, pull);
+CREATE INDEX idx_repo_commits ON github.commits (repo);
+CREATE INDEX idx_login_users ON github.users (login);
@@ -1,10 +1,15 @@
 package internal
 
 import (
+	"crypto/rsa"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -15,6 +20,7 @@ type EnvVars struct {
 	ControlPanel  ConfigControlPanel
 	App           ConfigApp
 	LoadGenerator ConfigLoad
+	Synthetic     ConfigSynthetic
 }
 
 type ConfigApp struct {
@@ -23,12 +29,49 @@ type ConfigApp struct {
 	DatasetDemoRepos string
 	DatasetDemoPulls string
 	Debug            bool
+	MetricsPort      string // Port of the /metrics endpoint of the load and dataset services
+	DatasetBatchSize int    // Rows written by one statement during the dataset import
+	DatasetStoreDir  string // Directory of the on-disk store with the staged dataset
+
+	// CSV files of the entities besides repositories and pull requests, optional
+	DatasetDemoIssues         string
+	DatasetDemoReviews        string
+	DatasetDemoReviewComments string
+	DatasetDemoCommits        string
 }
 
 type ConfigLoad struct {
-	MySQL    bool
-	Postgres bool
-	MongoDB  bool
+	MySQL        bool
+	Postgres     bool
+	MongoDB      bool
+	WorkloadsDir string // Directory with additional workload files (*.yaml)
+}
+
+// Enabled reports whether the load generator should run for the given database type.
+// Types without a dedicated field are enabled by the LOAD_<TYPE> environment variable.
+func (c ConfigLoad) Enabled(dbType string) bool {
+	switch dbType {
+	case "mysql":
+		return c.MySQL
+	case "postgres":
+		return c.Postgres
+	case "mongodb":
+		return c.MongoDB
+	}
+	enabled, _ := parseBool("LOAD_" + strings.ToUpper(dbType))
+	return enabled
+}
+
+// ConfigSynthetic holds the settings of the synthetic dataset (DATASET_LOAD_TYPE=synthetic).
+type ConfigSynthetic struct {
+	Seed         int64     // Seed of the random generator, the same seed produces the same data
+	Scale        float64   // Scale factor, multiplies the number of repositories
+	Repos        int       // Number of repositories at scale 1
+	PullsPerRepo int       // Average number of pull requests per repository
+	Distribution string    // Distribution of pull requests across repositories: "fixed", "uniform" or "zipf"
+	Days         int       // Pull requests are created within this number of days before EndDate
+	EndDate      time.Time // Date of the newest pull request
+	BodySize     int       // Size of the pull request body in bytes
 }
 
 type ConfigControlPanel struct {
@@ -39,6 +82,22 @@ type ConfigControlPanel struct {
 type ConfigGitHub struct {
 	Token        string
 	Organisation string
+	Entities     map[string]bool // Entities fetched besides repositories and pull requests, see GitHubEntities
+	Sources      []GitHubSource  // Organizations, users and repositories of the dataset, GITHUB_SOURCES or GITHUB_ORG
+	Include      []string        // Glob patterns of the repositories to fetch, all if empty
+	Exclude      []string        // Glob patterns of the repositories to skip
+
+	// API of GitHub Enterprise Server or a stand-in server, GitHub.com if empty
+	APIURL    string
+	UploadURL string
+
+	// GitHub App authentication, used instead of Token if AppID is set
+	AppID             int64
+	AppInstallationID int64 // Found automatically if the app has one installation or one for the owner of the first source
+	AppPrivateKey     *rsa.PrivateKey
+
+	// Repositories whose pull requests are fetched by one query of DATASET_LOAD_TYPE=github_graphql
+	GraphQLReposPerQuery int
 }
 
 type ConfigValkey struct {
@@ -77,25 +136,82 @@ func GetEnvVars(appType string) (EnvVars, error) {
 	if appType == "dataset" {
 		envVars.App.DatasetLoadType = os.Getenv("DATASET_LOAD_TYPE")
 
-		if envVars.App.DatasetLoadType == "github" {
+		if envVars.App.DatasetLoadType == "github" || envVars.App.DatasetLoadType == "github_graphql" {
 			envVars.GitHub.Organisation = os.Getenv("GITHUB_ORG")
-			if envVars.GitHub.Organisation == "" {
-				return envVars, fmt.Errorf("required environment variable GITHUB_ORG is not set")
+
+			// GITHUB_SOURCES replaces GITHUB_ORG, a single organization is still accepted
+			value := os.Getenv("GITHUB_SOURCES")
+			if value == "" {
+				value = envVars.GitHub.Organisation
+			}
+			sources, err := ParseGitHubSources(value)
+			if err != nil {
+				return envVars, fmt.Errorf("invalid environment variable GITHUB_SOURCES: %v", err)
+			}
+			if len(sources) == 0 {
+				return envVars, fmt.Errorf("required environment variable GITHUB_SOURCES or GITHUB_ORG is not set")
+			}
+			envVars.GitHub.Sources = sources
+
+			if envVars.GitHub.Include, err = parseGlobs("GITHUB_REPOS_INCLUDE", os.Getenv("GITHUB_REPOS_INCLUDE")); err != nil {
+				return envVars, err
+			}
+			if envVars.GitHub.Exclude, err = parseGlobs("GITHUB_REPOS_EXCLUDE", os.Getenv("GITHUB_REPOS_EXCLUDE")); err != nil {
+				return envVars, err
+			}
+
+			value = getEnvDefault("GITHUB_GRAPHQL_REPOS_PER_QUERY", "5")
+			if envVars.GitHub.GraphQLReposPerQuery, err = strconv.Atoi(value); err != nil || envVars.GitHub.GraphQLReposPerQuery < 1 {
+				return envVars, fmt.Errorf("invalid environment variable GITHUB_GRAPHQL_REPOS_PER_QUERY: %q, expected a positive number", value)
 			}
 		}
 
 		envVars.GitHub.Token = os.Getenv("GITHUB_TOKEN")
+		envVars.GitHub.APIURL = os.Getenv("GITHUB_API_URL")
+		envVars.GitHub.UploadURL = os.Getenv("GITHUB_UPLOAD_URL")
+		for key, value := range map[string]string{"GITHUB_API_URL": envVars.GitHub.APIURL, "GITHUB_UPLOAD_URL": envVars.GitHub.UploadURL} {
+			if _, err := githubURL(value); value != "" && err != nil {
+				return envVars, fmt.Errorf("invalid environment variable %s: %v", key, err)
+			}
+		}
+
+		if err := getGitHubApp(&envVars.GitHub); err != nil {
+			return envVars, err
+		}
+
+		entities, err := getGitHubEntities()
+		if err != nil {
+			return envVars, err
+		}
+		envVars.GitHub.Entities = entities
 
 		envVars.App.DatasetDemoRepos = os.Getenv("DATASET_DEMO_CSV_REPOS")
 		envVars.App.DatasetDemoPulls = os.Getenv("DATASET_DEMO_CSV_PULLS")
+		envVars.App.DatasetDemoIssues = os.Getenv("DATASET_DEMO_CSV_ISSUES")
+		envVars.App.DatasetDemoReviews = os.Getenv("DATASET_DEMO_CSV_REVIEWS")
+		envVars.App.DatasetDemoReviewComments = os.Getenv("DATASET_DEMO_CSV_REVIEW_COMMENTS")
+		envVars.App.DatasetDemoCommits = os.Getenv("DATASET_DEMO_CSV_COMMITS")
 		envVars.App.DelayMinutes, _ = parseInt("DELAY_MINUTES")
 		envVars.App.Debug, _ = parseBool("DEBUG")
+		envVars.App.MetricsPort = getEnvDefault("METRICS_PORT", "9102")
+		envVars.App.DatasetBatchSize, _ = parseInt("DATASET_BATCH_SIZE")
+		envVars.App.DatasetStoreDir = getEnvDefault("DATASET_STORE_DIR", "data/store")
+
+		if envVars.App.DatasetLoadType == "synthetic" {
+			synthetic, err := getSyntheticConfig()
+			if err != nil {
+				return envVars, err
+			}
+			envVars.Synthetic = synthetic
+		}
 	}
 
 	if appType == "load" {
 		envVars.LoadGenerator.MySQL, _ = parseBool("LOAD_MYSQL")
 		envVars.LoadGenerator.Postgres, _ = parseBool("LOAD_POSTGRES")
 		envVars.LoadGenerator.MongoDB, _ = parseBool("LOAD_MONGODB")
+		envVars.App.MetricsPort = getEnvDefault("METRICS_PORT", "9101")
+		envVars.LoadGenerator.WorkloadsDir = os.Getenv("LOAD_WORKLOADS_DIR")
 	}
 
 	if appType == "web" {
@@ -141,6 +257,112 @@ func parseInt(key string) (int, error) {
 	return result, nil
 }
 
+// getEnvDefault returns the value of the environment variable or the default value if it is not set.
+func getEnvDefault(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// getGitHubApp reads the GITHUB_APP_* environment variables. The private key is set
+// in GITHUB_APP_PRIVATE_KEY or in a file at GITHUB_APP_PRIVATE_KEY_FILE.
+func getGitHubApp(c *ConfigGitHub) error {
+	if os.Getenv("GITHUB_APP_ID") == "" {
+		return nil
+	}
+
+	var err error
+	if c.AppID, err = strconv.ParseInt(os.Getenv("GITHUB_APP_ID"), 10, 64); err != nil {
+		return fmt.Errorf("invalid environment variable GITHUB_APP_ID: %v", err)
+	}
+	if value := os.Getenv("GITHUB_APP_INSTALLATION_ID"); value != "" {
+		if c.AppInstallationID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("invalid environment variable GITHUB_APP_INSTALLATION_ID: %v", err)
+		}
+	}
+
+	// A key in a single-line variable, e.g. in a .env file, has escaped line breaks
+	key := []byte(strings.ReplaceAll(os.Getenv("GITHUB_APP_PRIVATE_KEY"), `\n`, "\n"))
+	if path := os.Getenv("GITHUB_APP_PRIVATE_KEY_FILE"); len(key) == 0 && path != "" {
+		if key, err = os.ReadFile(path); err != nil {
+			return fmt.Errorf("invalid environment variable GITHUB_APP_PRIVATE_KEY_FILE: %v", err)
+		}
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("required environment variable GITHUB_APP_PRIVATE_KEY or GITHUB_APP_PRIVATE_KEY_FILE is not set")
+	}
+	if c.AppPrivateKey, err = parseGitHubAppKey(key); err != nil {
+		return fmt.Errorf("invalid GitHub App private key: %v", err)
+	}
+
+	return nil
+}
+
+// getGitHubEntities reads the comma-separated DATASET_GITHUB_ENTITIES environment variable.
+// None is fetched by default, so the GitHub import fetches only the repositories and pull requests
+// and uses the same API requests as earlier versions.
+func getGitHubEntities() (map[string]bool, error) {
+	entities := make(map[string]bool)
+	for _, entity := range strings.Split(os.Getenv("DATASET_GITHUB_ENTITIES"), ",") {
+		entity = strings.TrimSpace(entity)
+		if entity == "" {
+			continue
+		}
+		if !slices.Contains(GitHubEntities, entity) {
+			return nil, fmt.Errorf("invalid environment variable DATASET_GITHUB_ENTITIES: unknown entity %q, expected %s", entity, strings.Join(GitHubEntities, ", "))
+		}
+		entities[entity] = true
+	}
+	return entities, nil
+}
+
+// getSyntheticConfig reads the DATASET_SYNTHETIC_* environment variables.
+func getSyntheticConfig() (ConfigSynthetic, error) {
+	var errs []error
+	parse := func(key, defaultValue string, convert func(string) error) {
+		if err := convert(getEnvDefault(key, defaultValue)); err != nil {
+			errs = append(errs, fmt.Errorf("invalid environment variable %s: %v", key, err))
+		}
+	}
+
+	var c ConfigSynthetic
+	parse("DATASET_SYNTHETIC_SEED", "1", func(v string) (err error) {
+		c.Seed, err = strconv.ParseInt(v, 10, 64)
+		return err
+	})
+	parse("DATASET_SYNTHETIC_SCALE", "1", func(v string) (err error) {
+		c.Scale, err = strconv.ParseFloat(v, 64)
+		return err
+	})
+	parse("DATASET_SYNTHETIC_REPOS", "100", func(v string) (err error) {
+		c.Repos, err = strconv.Atoi(v)
+		return err
+	})
+	parse("DATASET_SYNTHETIC_PULLS", "100", func(v string) (err error) {
+		c.PullsPerRepo, err = strconv.Atoi(v)
+		return err
+	})
+	parse("DATASET_SYNTHETIC_DAYS", "730", func(v string) (err error) {
+		c.Days, err = strconv.Atoi(v)
+		return err
+	})
+	parse("DATASET_SYNTHETIC_END_DATE", "2025-01-01", func(v string) (err error) {
+		c.EndDate, err = time.Parse(time.DateOnly, v)
+		return err
+	})
+	parse("DATASET_SYNTHETIC_BODY_SIZE", "1024", func(v string) (err error) {
+		c.BodySize, err = strconv.Atoi(v)
+		return err
+	})
+	c.Distribution = getEnvDefault("DATASET_SYNTHETIC_DISTRIBUTION", "zipf")
+
+	if len(errs) == 0 {
+		errs = append(errs, c.Validate())
+	}
+	return c, errors.Join(errs...)
+}
+
 func parseBool(key string) (bool, error) {
 
 	result_string := os.Getenv(key)
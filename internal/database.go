@@ -0,0 +1,334 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DatabaseConfigVersion is the version of the Valkey hash layout written by DatabaseConfig.Hash.
+// Hashes without the version field were written by older releases and are migrated on read.
+const DatabaseConfigVersion = 1
+
+// Field names of the Valkey hash "databases:<id>" that stores a DatabaseConfig.
+const (
+	FieldVersion          = "version"
+	FieldID               = "id"
+	FieldDBType           = "dbType"
+	FieldConnectionString = "connectionString"
+	FieldDatabase         = "database"
+	FieldLoadSwitch       = "loadSwitch"
+	FieldPosition         = "position"
+	FieldSleep            = "sleep"
+	FieldConnections      = "connections"
+	FieldTargetQPS        = "targetQPS"
+	FieldSwitch1          = "switch1"
+	FieldSwitch2          = "switch2"
+	FieldSwitch3          = "switch3"
+	FieldSwitch4          = "switch4"
+	FieldWorkloads        = "workloads"
+	FieldProfile          = "profile"
+	FieldConnectionStatus = "connectionStatus"
+	FieldSchemaStatus     = "schemaStatus"
+	FieldSchemaVersion    = "schemaVersion"
+	FieldSchemaLatest     = "schemaLatest"
+	FieldSchemaMode       = "schemaMode"
+	FieldUpdateStatus     = "updateStatus"
+	FieldDatasetStatus    = "datasetStatus"
+	FieldVerifyStatus     = "verifyStatus"
+)
+
+// Schema modes of the MySQL and PostgreSQL test schemas, selected by Create Schema.
+// The normalized schema has the key fields of the repositories and pull requests in generated columns
+// with secondary indexes, the workloads use query variants reading these columns.
+const (
+	SchemaModeJSON       = "json"
+	SchemaModeNormalized = "normalized"
+)
+
+// MaxConnections is the largest number of parallel load connections that can be set for a database.
+const MaxConnections = 1000
+
+// DatabaseConfig holds the settings and the state of a database added on the control panel
+type DatabaseConfig struct {
+	ID               string       // Database ID, e.g. "mysql-1"
+	DBType           string       // Database type: "mysql", "postgres" or "mongodb"
+	ConnectionString string       // Connection string or URI
+	Database         string       // Database name, used by MongoDB
+	LoadSwitch       bool         // Whether the database is shown on the load generator control panel
+	Position         int          // Sort position on the control panel
+	Sleep            int          // Delay in milliseconds between workload runs in each connection
+	Connections      int          // Number of parallel load connections
+	TargetQPS        int          // Target queries per second for all connections, every statement of a workload counts, 0 runs queries back-to-back
+	Switch1          bool         // Simple queries
+	Switch2          bool         // Standard queries
+	Switch3          bool         // Advanced queries
+	Switch4          bool         // Extreme queries
+	Workloads        []string     // Names of the enabled workloads loaded from files, the built-in ones use the switches
+	Profile          *LoadProfile // Scheduled change of the connections, nil if the connections are set by hand
+	ConnectionStatus string       // Result of the last connection check
+	SchemaStatus     bool         // Whether the test schema exists
+	SchemaVersion    int          // Version of the last schema migration applied to the test schema
+	SchemaLatest     int          // Version of the last schema migration of the backend, 0 if unknown
+	SchemaMode       string       // Test schema layout: SchemaModeJSON (also if empty) or SchemaModeNormalized
+	UpdateStatus     string       // Message shown after the last update
+	DatasetStatus    string       // Dataset import status: "", "Waiting", "In Progress", "Cancelling", "Cancelled", "Done", "Error" or the message of a SchemaBehindError
+	VerifyStatus     string       // Dataset verification status: "", "Waiting", "In Progress", "OK", "Mismatch" or "Error"
+}
+
+// NewDatabaseConfig returns a configuration with the defaults used for a newly created database.
+func NewDatabaseConfig(id, dbType, connectionString string) DatabaseConfig {
+	return DatabaseConfig{
+		ID:               id,
+		DBType:           dbType,
+		ConnectionString: connectionString,
+		Sleep:            100,
+	}
+}
+
+// ParseDatabaseConfig converts a Valkey hash into a DatabaseConfig.
+// Missing fields get zero values. Invalid values are replaced with the defaults of NewDatabaseConfig,
+// e.g. a sleep of 100 ms, and reported in the returned error, so a damaged hash still produces a usable configuration.
+//
+// Arguments:
+//   - fields: map[string]string containing the hash fields.
+//
+// Returns:
+//   - DatabaseConfig: The parsed configuration.
+//   - error: An error describing the invalid fields, otherwise nil.
+func ParseDatabaseConfig(fields map[string]string) (DatabaseConfig, error) {
+	var errs []error
+	defaults := NewDatabaseConfig("", "", "")
+
+	parseInt := func(key string, defaultValue int) int {
+		value := strings.TrimSpace(fields[key])
+		if value == "" {
+			return 0
+		}
+		result, err := strconv.Atoi(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid number %q", key, value))
+			return defaultValue
+		}
+		return result
+	}
+
+	parseBool := func(key string) bool {
+		value := strings.TrimSpace(fields[key])
+		switch strings.ToLower(value) {
+		case "", "false", "off", "0":
+			return false
+		case "true", "on", "1":
+			return true
+		}
+		errs = append(errs, fmt.Errorf("%s: invalid boolean %q", key, value))
+		return false
+	}
+
+	db := DatabaseConfig{
+		ID:               fields[FieldID],
+		DBType:           fields[FieldDBType],
+		ConnectionString: fields[FieldConnectionString],
+		Database:         fields[FieldDatabase],
+		LoadSwitch:       parseBool(FieldLoadSwitch),
+		Position:         parseInt(FieldPosition, defaults.Position),
+		Sleep:            parseInt(FieldSleep, defaults.Sleep),
+		Connections:      parseInt(FieldConnections, defaults.Connections),
+		TargetQPS:        parseInt(FieldTargetQPS, defaults.TargetQPS),
+		Switch1:          parseBool(FieldSwitch1),
+		Switch2:          parseBool(FieldSwitch2),
+		Switch3:          parseBool(FieldSwitch3),
+		Switch4:          parseBool(FieldSwitch4),
+		Workloads:        parseList(fields[FieldWorkloads]),
+		ConnectionStatus: fields[FieldConnectionStatus],
+		SchemaStatus:     parseBool(FieldSchemaStatus),
+		SchemaVersion:    parseInt(FieldSchemaVersion, defaults.SchemaVersion),
+		SchemaLatest:     parseInt(FieldSchemaLatest, defaults.SchemaLatest),
+		SchemaMode:       fields[FieldSchemaMode],
+		UpdateStatus:     fields[FieldUpdateStatus],
+		DatasetStatus:    fields[FieldDatasetStatus],
+		VerifyStatus:     fields[FieldVerifyStatus],
+	}
+
+	if value := strings.TrimSpace(fields[FieldProfile]); value != "" {
+		var profile LoadProfile
+		if err := json.Unmarshal([]byte(value), &profile); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid JSON: %v", FieldProfile, err))
+		} else {
+			db.Profile = &profile
+		}
+	}
+
+	// Old hashes have no dbType field, but the type is always the prefix of the ID
+	if db.DBType == "" {
+		if prefix, _, found := strings.Cut(db.ID, "-"); found {
+			db.DBType = prefix
+		}
+	}
+
+	if db.Sleep < 0 {
+		errs = append(errs, fmt.Errorf("%s: must not be negative", FieldSleep))
+		db.Sleep = defaults.Sleep
+	}
+	if db.Connections < 0 {
+		errs = append(errs, fmt.Errorf("%s: must not be negative", FieldConnections))
+		db.Connections = defaults.Connections
+	}
+	if db.TargetQPS < 0 {
+		errs = append(errs, fmt.Errorf("%s: must not be negative", FieldTargetQPS))
+		db.TargetQPS = defaults.TargetQPS
+	}
+
+	return db, errors.Join(errs...)
+}
+
+// NeedsMigration reports whether a Valkey hash was written by an older release
+// and should be rewritten in the current layout.
+func NeedsMigration(fields map[string]string) bool {
+	return fields[FieldVersion] != strconv.Itoa(DatabaseConfigVersion)
+}
+
+// Validate checks the settings that can be changed on the control panel.
+func (db DatabaseConfig) Validate() error {
+	var errs []error
+
+	if db.ID == "" {
+		errs = append(errs, errors.New("id is required"))
+	}
+	if db.DBType == "" {
+		errs = append(errs, errors.New("dbType is required"))
+	}
+	if db.Sleep < 0 {
+		errs = append(errs, errors.New("sleep must not be negative"))
+	}
+	if db.Connections < 0 || db.Connections > MaxConnections {
+		errs = append(errs, fmt.Errorf("connections must be between 0 and %d", MaxConnections))
+	}
+	if db.TargetQPS < 0 {
+		errs = append(errs, errors.New("targetQPS must not be negative"))
+	}
+	if db.SchemaMode != "" && db.SchemaMode != SchemaModeJSON && db.SchemaMode != SchemaModeNormalized {
+		errs = append(errs, fmt.Errorf("schemaMode must be %q or %q", SchemaModeJSON, SchemaModeNormalized))
+	}
+	if db.Profile != nil {
+		if err := db.Profile.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("profile: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Normalized reports whether the test schema has the normalized layout.
+func (db DatabaseConfig) Normalized() bool {
+	return db.SchemaMode == SchemaModeNormalized
+}
+
+// builtinSwitches maps the built-in workloads to the switches that enable them.
+var builtinSwitches = []string{"switch1", "switch2", "switch3", "switch4"}
+
+// EnabledWorkloads returns the names of the workloads turned on, the built-in switches first.
+func (db DatabaseConfig) EnabledWorkloads() []string {
+	var names []string
+	for i, enabled := range []bool{db.Switch1, db.Switch2, db.Switch3, db.Switch4} {
+		if enabled {
+			names = append(names, builtinSwitches[i])
+		}
+	}
+	for _, name := range db.Workloads {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// WorkloadEnabled reports whether the workload with the given name is turned on.
+func (db DatabaseConfig) WorkloadEnabled(name string) bool {
+	return slices.Contains(db.EnabledWorkloads(), name)
+}
+
+// SetWorkloads turns on the given workloads and turns off all others.
+// The built-in workloads are stored in the switch fields for compatibility with older releases.
+func (db *DatabaseConfig) SetWorkloads(names []string) {
+	db.Switch1 = slices.Contains(names, "switch1")
+	db.Switch2 = slices.Contains(names, "switch2")
+	db.Switch3 = slices.Contains(names, "switch3")
+	db.Switch4 = slices.Contains(names, "switch4")
+
+	db.Workloads = nil
+	for _, name := range names {
+		if name != "" && !slices.Contains(builtinSwitches, name) && !slices.Contains(db.Workloads, name) {
+			db.Workloads = append(db.Workloads, name)
+		}
+	}
+}
+
+// parseList splits a comma-separated hash field and drops empty items.
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Hash converts the configuration into Valkey hash fields.
+// If names are given, only these fields are returned, which allows partial updates
+// without overwriting fields changed by other services.
+//
+// Arguments:
+//   - names: optional list of field names to return.
+//
+// Returns:
+//   - map[string]string: The hash fields and values.
+func (db DatabaseConfig) Hash(names ...string) map[string]string {
+	all := map[string]string{
+		FieldVersion:          strconv.Itoa(DatabaseConfigVersion),
+		FieldID:               db.ID,
+		FieldDBType:           db.DBType,
+		FieldConnectionString: db.ConnectionString,
+		FieldDatabase:         db.Database,
+		FieldLoadSwitch:       strconv.FormatBool(db.LoadSwitch),
+		FieldPosition:         strconv.Itoa(db.Position),
+		FieldSleep:            strconv.Itoa(db.Sleep),
+		FieldConnections:      strconv.Itoa(db.Connections),
+		FieldTargetQPS:        strconv.Itoa(db.TargetQPS),
+		FieldSwitch1:          strconv.FormatBool(db.Switch1),
+		FieldSwitch2:          strconv.FormatBool(db.Switch2),
+		FieldSwitch3:          strconv.FormatBool(db.Switch3),
+		FieldSwitch4:          strconv.FormatBool(db.Switch4),
+		FieldWorkloads:        strings.Join(db.Workloads, ","),
+		FieldConnectionStatus: db.ConnectionStatus,
+		FieldSchemaStatus:     strconv.FormatBool(db.SchemaStatus),
+		FieldSchemaVersion:    strconv.Itoa(db.SchemaVersion),
+		FieldSchemaLatest:     strconv.Itoa(db.SchemaLatest),
+		FieldSchemaMode:       db.SchemaMode,
+		FieldUpdateStatus:     db.UpdateStatus,
+		FieldDatasetStatus:    db.DatasetStatus,
+		FieldVerifyStatus:     db.VerifyStatus,
+		FieldProfile:          "",
+	}
+
+	if db.Profile != nil {
+		profile, _ := json.Marshal(db.Profile)
+		all[FieldProfile] = string(profile)
+	}
+
+	if len(names) == 0 {
+		return all
+	}
+
+	fields := make(map[string]string, len(names))
+	for _, name := range names {
+		if value, ok := all[name]; ok {
+			fields[name] = value
+		}
+	}
+	return fields
+}
@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDatabaseConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]string
+		want   DatabaseConfig
+		err    string
+	}{
+		{
+			name:   "empty hash",
+			fields: map[string]string{},
+			want:   DatabaseConfig{},
+		},
+		{
+			name: "current layout",
+			fields: map[string]string{
+				FieldVersion:          "1",
+				FieldID:               "mysql-1",
+				FieldDBType:           "mysql",
+				FieldConnectionString: "root:secret@tcp(localhost:3306)/dataset",
+				FieldLoadSwitch:       "true",
+				FieldPosition:         "2",
+				FieldSleep:            "50",
+				FieldConnections:      "8",
+				FieldTargetQPS:        "500",
+				FieldSwitch1:          "true",
+				FieldSwitch3:          "false",
+				FieldWorkloads:        "recent_pulls, ,top_users",
+				FieldSchemaStatus:     "true",
+				FieldSchemaVersion:    "3",
+				FieldSchemaMode:       SchemaModeNormalized,
+				FieldProfile:          `{"type":"ramp","from":1,"to":10,"minutes":5,"started_at":100}`,
+			},
+			want: DatabaseConfig{
+				ID:               "mysql-1",
+				DBType:           "mysql",
+				ConnectionString: "root:secret@tcp(localhost:3306)/dataset",
+				LoadSwitch:       true,
+				Position:         2,
+				Sleep:            50,
+				Connections:      8,
+				TargetQPS:        500,
+				Switch1:          true,
+				Workloads:        []string{"recent_pulls", "top_users"},
+				Profile:          &LoadProfile{Type: ProfileRamp, From: 1, To: 10, Minutes: 5, StartedAt: 100},
+				SchemaStatus:     true,
+				SchemaVersion:    3,
+				SchemaMode:       SchemaModeNormalized,
+			},
+		},
+		{
+			name: "old layout with switch values and no type",
+			fields: map[string]string{
+				FieldID:         "postgres-4",
+				FieldLoadSwitch: "on",
+				FieldSwitch2:    "1",
+				FieldSwitch4:    "off",
+				FieldSleep:      " 100 ",
+			},
+			want: DatabaseConfig{
+				ID:         "postgres-4",
+				DBType:     "postgres",
+				LoadSwitch: true,
+				Switch2:    true,
+				Sleep:      100,
+			},
+		},
+		{
+			name: "invalid values are replaced with defaults",
+			fields: map[string]string{
+				FieldID:          "mongodb-1",
+				FieldDBType:      "mongodb",
+				FieldSleep:       "-5",
+				FieldConnections: "many",
+				FieldTargetQPS:   "-1",
+				FieldSwitch1:     "yes",
+				FieldProfile:     "{",
+			},
+			want: DatabaseConfig{ID: "mongodb-1", DBType: "mongodb", Sleep: 100},
+			err:  "sleep: must not be negative",
+		},
+		{
+			name: "invalid number is replaced with the default",
+			fields: map[string]string{
+				FieldID:          "mysql-2",
+				FieldSleep:       "fast",
+				FieldConnections: "4",
+			},
+			want: DatabaseConfig{ID: "mysql-2", DBType: "mysql", Sleep: 100, Connections: 4},
+			err:  `sleep: invalid number "fast"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDatabaseConfig(tt.fields)
+			if tt.err == "" && err != nil {
+				t.Errorf("ParseDatabaseConfig() error = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("ParseDatabaseConfig() error = %v, want %q", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDatabaseConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDatabaseConfigReportsAllErrors(t *testing.T) {
+	_, err := ParseDatabaseConfig(map[string]string{
+		FieldConnections: "many",
+		FieldSwitch1:     "yes",
+		FieldProfile:     "{",
+		FieldTargetQPS:   "-1",
+	})
+	if err == nil {
+		t.Fatal("ParseDatabaseConfig() succeeded with invalid fields")
+	}
+	for _, field := range []string{FieldConnections, FieldSwitch1, FieldProfile, FieldTargetQPS} {
+		if !strings.Contains(err.Error(), field+":") {
+			t.Errorf("ParseDatabaseConfig() error %q does not report %s", err, field)
+		}
+	}
+}
+
+func TestHashRoundTrip(t *testing.T) {
+	db := DatabaseConfig{
+		ID:          "mysql-2",
+		DBType:      "mysql",
+		LoadSwitch:  true,
+		Sleep:       10,
+		Connections: 4,
+		Switch3:     true,
+		Workloads:   []string{"recent_pulls"},
+		Profile:     &LoadProfile{Type: ProfileSine, From: 2, To: 8, Minutes: 10},
+		SchemaMode:  SchemaModeJSON,
+	}
+
+	fields := db.Hash()
+	if NeedsMigration(fields) {
+		t.Error("NeedsMigration() is true for a hash in the current layout")
+	}
+
+	got, err := ParseDatabaseConfig(fields)
+	if err != nil {
+		t.Fatalf("ParseDatabaseConfig() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, db) {
+		t.Errorf("ParseDatabaseConfig(Hash()) = %+v, want %+v", got, db)
+	}
+}
+
+func TestNeedsMigration(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]string
+		want   bool
+	}{
+		{name: "no version", fields: map[string]string{FieldID: "mysql-1"}, want: true},
+		{name: "older version", fields: map[string]string{FieldVersion: "0"}, want: true},
+		{name: "invalid version", fields: map[string]string{FieldVersion: "v1"}, want: true},
+		{name: "current version", fields: map[string]string{FieldVersion: "1"}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := NeedsMigration(tt.fields); got != tt.want {
+			t.Errorf("%s: NeedsMigration() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
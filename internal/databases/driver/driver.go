@@ -0,0 +1,118 @@
+// Package driver defines the interface implemented by every database backend
+// and a registry of backends keyed by dbType ("mysql", "postgres", "mongodb").
+//
+// The control panel, the dataset loader and the load generator work with
+// databases only through this package, so a new backend is added by
+// implementing Driver and registering it in an init function.
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	app "github-stat/internal"
+	"github-stat/internal/load"
+
+	"github.com/google/go-github/github"
+)
+
+// Driver is implemented by every supported database backend.
+type Driver interface {
+	// Name returns the human-readable name of the backend, e.g. "MySQL".
+	Name() string
+
+	// Check connects to the database and returns "Connected" or an error message.
+	Check(dbConfig app.DatabaseConfig) string
+
+	// SchemaMissing reports whether a status returned by Check means that
+	// the test database or schema has not been created yet.
+	SchemaMissing(status string) bool
+
+	// InitSchema creates the test database and applies all schema migrations.
+	InitSchema(dbConfig app.DatabaseConfig) error
+
+	// SchemaVersion returns the applied and the latest migration versions and the mode of the test schema.
+	SchemaVersion(dbConfig app.DatabaseConfig) (app.SchemaVersion, error)
+
+	// MigrateSchema applies or reverts the schema migrations until the test schema is at the version
+	// of dbConfig.SchemaMode, app.LatestMigration applies all of them. Another mode switches the test schema to it.
+	MigrateSchema(dbConfig app.DatabaseConfig, version int) error
+
+	// DeleteSchema drops the test database with all data.
+	DeleteSchema(dbConfig app.DatabaseConfig) error
+
+	// Prepare is called by the control panel after a successful connection check.
+	Prepare(dbConfig app.DatabaseConfig) error
+
+	// ImportDataset writes the dataset into the database. When ctx is cancelled the import
+	// stops after the current batch and returns the error of ctx.
+	ImportDataset(ctx context.Context, dbConfig app.DatabaseConfig, dataset app.Dataset) error
+
+	// DatasetInfo returns the amount of dataset data stored in the database.
+	DatasetInfo(dbConfig app.DatabaseConfig) (app.DatasetInfo, error)
+
+	// PullDigest returns the number of pull requests and the checksum of their IDs and update times by repository.
+	PullDigest(dbConfig app.DatabaseConfig) (app.DatasetDigest, error)
+
+	// GetPulls returns the pull requests of a repository with the IDs, missing ones are skipped.
+	GetPulls(dbConfig app.DatabaseConfig, repo string, ids []int64) ([]*github.PullRequest, error)
+
+	// ExportDataset writes all repositories and then all pull requests of the database into w.
+	// When ctx is cancelled the export stops and returns the error of ctx.
+	ExportDataset(ctx context.Context, dbConfig app.DatabaseConfig, w app.DatasetWriter) error
+
+	// Connect opens a connection used by a load generator goroutine.
+	Connect(dbConfig app.DatabaseConfig) (Conn, error)
+
+	// RunWorkload runs the workloads enabled on the control panel once, wait is called before every query.
+	RunWorkload(conn Conn, routineID int, dbConfig app.DatabaseConfig, wait load.WaitFunc)
+}
+
+// Conn is a connection returned by Driver.Connect.
+type Conn interface {
+	Close() error
+}
+
+var (
+	drivers      = make(map[string]Driver)
+	driversMutex sync.RWMutex
+)
+
+// Register makes a driver available for the given dbType.
+// It panics if a driver is registered twice for the same dbType.
+func Register(dbType string, d Driver) {
+	driversMutex.Lock()
+	defer driversMutex.Unlock()
+
+	if _, exists := drivers[dbType]; exists {
+		panic(fmt.Sprintf("driver: Register called twice for %s", dbType))
+	}
+	drivers[dbType] = d
+}
+
+// Get returns the driver registered for the given dbType.
+func Get(dbType string) (Driver, error) {
+	driversMutex.RLock()
+	defer driversMutex.RUnlock()
+
+	d, ok := drivers[dbType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+	}
+	return d, nil
+}
+
+// Types returns the sorted list of registered database types.
+func Types() []string {
+	driversMutex.RLock()
+	defer driversMutex.RUnlock()
+
+	types := make([]string, 0, len(drivers))
+	for dbType := range drivers {
+		types = append(types, dbType)
+	}
+	sort.Strings(types)
+	return types
+}
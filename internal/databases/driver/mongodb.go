@@ -0,0 +1,100 @@
+package driver
+
+import (
+	"context"
+	"errors"
+
+	app "github-stat/internal"
+	"github-stat/internal/databases/mongodb"
+	"github-stat/internal/load"
+
+	"github.com/google/go-github/github"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func init() {
+	Register("mongodb", MongoDB{})
+}
+
+// MongoDB implements Driver for MongoDB and compatible databases (FerretDB).
+type MongoDB struct{}
+
+// mongoConn is the connection returned by MongoDB.Connect.
+type mongoConn struct {
+	client   *mongo.Client
+	database string
+}
+
+func (c *mongoConn) Close() error {
+	return c.client.Disconnect(context.Background())
+}
+
+func (MongoDB) Name() string {
+	return "MongoDB"
+}
+
+func (MongoDB) Check(dbConfig app.DatabaseConfig) string {
+	return mongodb.CheckMongoDB(dbConfig.ConnectionString)
+}
+
+// SchemaMissing always returns false, MongoDB creates databases and collections on first write.
+func (MongoDB) SchemaMissing(status string) bool {
+	return false
+}
+
+// InitSchema applies all migrations, MongoDB creates the database and collections on first write.
+func (MongoDB) InitSchema(dbConfig app.DatabaseConfig) error {
+	return mongodb.MigrateSchema(dbConfig.ConnectionString, dbConfig.Database, mongodb.LatestSchemaVersion())
+}
+
+func (MongoDB) DeleteSchema(dbConfig app.DatabaseConfig) error {
+	return mongodb.DeleteSchema(dbConfig.ConnectionString, dbConfig.Database)
+}
+
+func (MongoDB) SchemaVersion(dbConfig app.DatabaseConfig) (app.SchemaVersion, error) {
+	return mongodb.GetSchemaVersion(dbConfig.ConnectionString, dbConfig.Database)
+}
+
+func (MongoDB) MigrateSchema(dbConfig app.DatabaseConfig, version int) error {
+	return mongodb.MigrateSchema(dbConfig.ConnectionString, dbConfig.Database, version)
+}
+
+// Prepare enables the profiler on the test and admin databases, so PMM Query Analytics can collect queries.
+func (MongoDB) Prepare(dbConfig app.DatabaseConfig) error {
+	errDB := mongodb.InitProfileOptions(dbConfig.ConnectionString, dbConfig.Database)
+	errAdmin := mongodb.InitProfileOptions(dbConfig.ConnectionString, "admin")
+	return errors.Join(errDB, errAdmin)
+}
+
+func (MongoDB) ImportDataset(ctx context.Context, dbConfig app.DatabaseConfig, dataset app.Dataset) error {
+	return mongodb.ImportDataset(ctx, dbConfig, dataset)
+}
+
+func (MongoDB) DatasetInfo(dbConfig app.DatabaseConfig) (app.DatasetInfo, error) {
+	return mongodb.GetDatasetInfo(dbConfig)
+}
+
+func (MongoDB) PullDigest(dbConfig app.DatabaseConfig) (app.DatasetDigest, error) {
+	return mongodb.GetPullDigest(dbConfig)
+}
+
+func (MongoDB) GetPulls(dbConfig app.DatabaseConfig, repo string, ids []int64) ([]*github.PullRequest, error) {
+	return mongodb.GetPulls(dbConfig, repo, ids)
+}
+
+func (MongoDB) ExportDataset(ctx context.Context, dbConfig app.DatabaseConfig, w app.DatasetWriter) error {
+	return mongodb.ExportDataset(ctx, dbConfig, w)
+}
+
+func (MongoDB) Connect(dbConfig app.DatabaseConfig) (Conn, error) {
+	client, err := mongodb.ConnectByString(dbConfig.ConnectionString, context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &mongoConn{client: client, database: dbConfig.Database}, nil
+}
+
+func (MongoDB) RunWorkload(conn Conn, routineID int, dbConfig app.DatabaseConfig, wait load.WaitFunc) {
+	c := conn.(*mongoConn)
+	load.RunEnabled(load.NewMongoExecutor(c.client, c.database), routineID, dbConfig, wait)
+}
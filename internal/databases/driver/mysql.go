@@ -0,0 +1,80 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	app "github-stat/internal"
+	"github-stat/internal/databases/mysql"
+	"github-stat/internal/load"
+
+	"github.com/google/go-github/github"
+)
+
+func init() {
+	Register("mysql", MySQL{})
+}
+
+// MySQL implements Driver for MySQL and compatible databases (MariaDB, Percona Server).
+type MySQL struct{}
+
+func (MySQL) Name() string {
+	return "MySQL"
+}
+
+func (MySQL) Check(dbConfig app.DatabaseConfig) string {
+	return mysql.CheckMySQL(dbConfig.ConnectionString)
+}
+
+func (MySQL) SchemaMissing(status string) bool {
+	return strings.Contains(status, "Unknown database")
+}
+
+func (MySQL) InitSchema(dbConfig app.DatabaseConfig) error {
+	return mysql.InitSchema(dbConfig.ConnectionString, dbConfig.SchemaMode)
+}
+
+func (MySQL) DeleteSchema(dbConfig app.DatabaseConfig) error {
+	return mysql.DeleteSchema(dbConfig.ConnectionString)
+}
+
+func (MySQL) SchemaVersion(dbConfig app.DatabaseConfig) (app.SchemaVersion, error) {
+	return mysql.GetSchemaVersion(dbConfig.ConnectionString)
+}
+
+func (MySQL) MigrateSchema(dbConfig app.DatabaseConfig, version int) error {
+	return mysql.MigrateSchema(dbConfig.ConnectionString, version, dbConfig.SchemaMode)
+}
+
+func (MySQL) Prepare(dbConfig app.DatabaseConfig) error {
+	return nil
+}
+
+func (MySQL) ImportDataset(ctx context.Context, dbConfig app.DatabaseConfig, dataset app.Dataset) error {
+	return mysql.ImportDataset(ctx, dbConfig, dataset)
+}
+
+func (MySQL) DatasetInfo(dbConfig app.DatabaseConfig) (app.DatasetInfo, error) {
+	return mysql.GetDatasetInfo(dbConfig.ConnectionString)
+}
+
+func (MySQL) PullDigest(dbConfig app.DatabaseConfig) (app.DatasetDigest, error) {
+	return mysql.GetPullDigest(dbConfig)
+}
+
+func (MySQL) GetPulls(dbConfig app.DatabaseConfig, repo string, ids []int64) ([]*github.PullRequest, error) {
+	return mysql.GetPulls(dbConfig, repo, ids)
+}
+
+func (MySQL) ExportDataset(ctx context.Context, dbConfig app.DatabaseConfig, w app.DatasetWriter) error {
+	return mysql.ExportDataset(ctx, dbConfig, w)
+}
+
+func (MySQL) Connect(dbConfig app.DatabaseConfig) (Conn, error) {
+	return mysql.ConnectByString(dbConfig.ConnectionString)
+}
+
+func (MySQL) RunWorkload(conn Conn, routineID int, dbConfig app.DatabaseConfig, wait load.WaitFunc) {
+	load.RunEnabled(load.NewSQLExecutor(conn.(*sql.DB), "mysql", dbConfig.SchemaMode), routineID, dbConfig, wait)
+}
@@ -0,0 +1,80 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	app "github-stat/internal"
+	"github-stat/internal/databases/postgres"
+	"github-stat/internal/load"
+
+	"github.com/google/go-github/github"
+)
+
+func init() {
+	Register("postgres", Postgres{})
+}
+
+// Postgres implements Driver for PostgreSQL and compatible databases (YugabyteDB).
+type Postgres struct{}
+
+func (Postgres) Name() string {
+	return "Postgres"
+}
+
+func (Postgres) Check(dbConfig app.DatabaseConfig) string {
+	return postgres.CheckPostgreSQL(dbConfig.ConnectionString)
+}
+
+func (Postgres) SchemaMissing(status string) bool {
+	return strings.Contains(status, "does not exist") || strings.Contains(status, "server login has been failing")
+}
+
+func (Postgres) InitSchema(dbConfig app.DatabaseConfig) error {
+	return postgres.InitSchema(dbConfig.ConnectionString, dbConfig.SchemaMode)
+}
+
+func (Postgres) DeleteSchema(dbConfig app.DatabaseConfig) error {
+	return postgres.DeleteSchema(dbConfig.ConnectionString)
+}
+
+func (Postgres) SchemaVersion(dbConfig app.DatabaseConfig) (app.SchemaVersion, error) {
+	return postgres.GetSchemaVersion(dbConfig.ConnectionString)
+}
+
+func (Postgres) MigrateSchema(dbConfig app.DatabaseConfig, version int) error {
+	return postgres.MigrateSchema(dbConfig.ConnectionString, version, dbConfig.SchemaMode)
+}
+
+func (Postgres) Prepare(dbConfig app.DatabaseConfig) error {
+	return nil
+}
+
+func (Postgres) ImportDataset(ctx context.Context, dbConfig app.DatabaseConfig, dataset app.Dataset) error {
+	return postgres.ImportDataset(ctx, dbConfig, dataset)
+}
+
+func (Postgres) DatasetInfo(dbConfig app.DatabaseConfig) (app.DatasetInfo, error) {
+	return postgres.GetDatasetInfo(dbConfig.ConnectionString)
+}
+
+func (Postgres) PullDigest(dbConfig app.DatabaseConfig) (app.DatasetDigest, error) {
+	return postgres.GetPullDigest(dbConfig)
+}
+
+func (Postgres) GetPulls(dbConfig app.DatabaseConfig, repo string, ids []int64) ([]*github.PullRequest, error) {
+	return postgres.GetPulls(dbConfig, repo, ids)
+}
+
+func (Postgres) ExportDataset(ctx context.Context, dbConfig app.DatabaseConfig, w app.DatasetWriter) error {
+	return postgres.ExportDataset(ctx, dbConfig, w)
+}
+
+func (Postgres) Connect(dbConfig app.DatabaseConfig) (Conn, error) {
+	return postgres.ConnectByString(dbConfig.ConnectionString)
+}
+
+func (Postgres) RunWorkload(conn Conn, routineID int, dbConfig app.DatabaseConfig, wait load.WaitFunc) {
+	load.RunEnabled(load.NewSQLExecutor(conn.(*sql.DB), "postgres", dbConfig.SchemaMode), routineID, dbConfig, wait)
+}
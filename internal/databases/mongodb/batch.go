@@ -0,0 +1,64 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// upsertModel returns an update model that inserts the document or replaces the fields of the document matching the filter.
+func upsertModel(filter interface{}, doc interface{}) mongo.WriteModel {
+	return mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(bson.M{"$set": doc}).SetUpsert(true)
+}
+
+// upsertMany writes the upserts with one unordered BulkWrite.
+//
+// Arguments:
+//   - ctx: context.Context for the operation.
+//   - coll: *mongo.Collection to write to.
+//   - models: []mongo.WriteModel containing the upserts created by upsertModel.
+//
+// Returns:
+//   - int: The number of inserted documents.
+//   - int: The number of updated documents.
+//   - error: An error object if an error occurs, otherwise nil.
+func upsertMany(ctx context.Context, coll *mongo.Collection, models []mongo.WriteModel) (int, int, error) {
+	if len(models) == 0 {
+		return 0, 0, nil
+	}
+
+	result, err := coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(result.UpsertedCount), int(result.MatchedCount), nil
+}
+
+// collectionBatch collects the upserts of a collection and writes them with upsertMany when the batch is full.
+type collectionBatch struct {
+	coll   *mongo.Collection
+	size   int
+	models []mongo.WriteModel
+}
+
+// add adds an upsert of the document matching the filter.
+func (b *collectionBatch) add(ctx context.Context, filter interface{}, doc interface{}) error {
+	b.models = append(b.models, upsertModel(filter, doc))
+	if len(b.models) >= b.size {
+		return b.flush(ctx)
+	}
+	return nil
+}
+
+// flush writes the collected upserts.
+func (b *collectionBatch) flush(ctx context.Context) error {
+	if _, _, err := upsertMany(ctx, b.coll, b.models); err != nil {
+		return fmt.Errorf("%s: %w", b.coll.Name(), err)
+	}
+	b.models = b.models[:0]
+	return nil
+}
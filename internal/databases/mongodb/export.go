@@ -0,0 +1,68 @@
+package mongodb
+
+import (
+	"context"
+
+	app "github-stat/internal"
+
+	"github.com/google/go-github/github"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ExportDataset reads all repositories and then all pull requests document by document in their natural order
+// and writes them into w.
+//
+// Arguments:
+//   - ctx: context.Context of the export, the queries are cancelled with it.
+//   - dbConfig: app.DatabaseConfig containing the database configuration.
+//   - w: app.DatasetWriter receiving the repositories and pull requests.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func ExportDataset(ctx context.Context, dbConfig app.DatabaseConfig, w app.DatasetWriter) error {
+	client, err := ConnectByString(dbConfig.ConnectionString, ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+
+	db := client.Database(dbConfig.Database)
+
+	repos, err := db.Collection("repositories").Find(ctx, bson.D{})
+	if err != nil {
+		return err
+	}
+	defer repos.Close(ctx)
+
+	for repos.Next(ctx) {
+		var repo github.Repository
+		if err := repos.Decode(&repo); err != nil {
+			return err
+		}
+		if err := w.WriteRepo(&repo); err != nil {
+			return err
+		}
+	}
+	if err := repos.Err(); err != nil {
+		return err
+	}
+
+	pulls, err := db.Collection("pulls").Find(ctx, bson.D{})
+	if err != nil {
+		return err
+	}
+	defer pulls.Close(ctx)
+
+	for pulls.Next(ctx) {
+		var pull github.PullRequest
+		if err := pulls.Decode(&pull); err != nil {
+			return err
+		}
+		// The repository name is stored next to the fields of the pull request
+		repoName, _ := pulls.Current.Lookup("repo").StringValueOK()
+		if err := w.WritePull(repoName, &pull); err != nil {
+			return err
+		}
+	}
+	return pulls.Err()
+}
@@ -0,0 +1,255 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	app "github-stat/internal"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migration is a numbered change of the test database, Down reverts Up.
+// A released migration is never changed, a change is added as a new migration.
+type migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+// pullsIndex is the unique index of the pull requests by id and repo.
+var pullsIndex = bson.D{
+	{Key: "id", Value: 1},
+	{Key: "repo", Value: 1},
+}
+
+// migrations are applied in the order of their versions, the applied ones are recorded in schema_migrations.
+// MongoDB creates collections on first write, so the migrations create indexes. Creating an existing index
+// does nothing, databases created before the migrations were added are migrated without errors.
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "Create the unique index of the pulls",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndexes(ctx, db, map[string]bson.D{"pulls": pullsIndex})
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db, map[string]bson.D{"pulls": pullsIndex})
+		},
+	},
+	{
+		Version: 2,
+		Name:    "Create the unique indexes of the issues, reviews, review comments, commits and users",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndexes(ctx, db, entityIndexes)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db, entityIndexes)
+		},
+	},
+}
+
+// settingPinnedVersion is the _id of the document of schema_settings with the version the database was reverted to
+// by Migrate Schema, the imports do not migrate it.
+const settingPinnedVersion = "pinned_version"
+
+// LatestSchemaVersion returns the version of the last migration.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// GetSchemaVersion returns the migration versions of the test database.
+//
+// Arguments:
+//   - connectionString: string containing the MongoDB connection string.
+//   - dbName: string containing the name of the test database.
+//
+// Returns:
+//   - app.SchemaVersion: The applied and the latest versions.
+//   - error: An error object if an error occurs, otherwise nil.
+func GetSchemaVersion(connectionString string, dbName string) (app.SchemaVersion, error) {
+	ctx := context.Background()
+	client, err := ConnectByString(connectionString, ctx)
+	if err != nil {
+		return app.SchemaVersion{}, err
+	}
+	defer client.Disconnect(ctx)
+
+	return readSchemaVersion(ctx, client.Database(dbName))
+}
+
+// MigrateSchema applies or reverts the migrations until the test database is at the version.
+//
+// Arguments:
+//   - connectionString: string containing the MongoDB connection string.
+//   - dbName: string containing the name of the test database.
+//   - version: int containing the target version, 0 reverts all migrations, app.LatestMigration applies all.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func MigrateSchema(connectionString string, dbName string, version int) error {
+	ctx := context.Background()
+	client, err := ConnectByString(connectionString, ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(ctx)
+
+	if version == app.LatestMigration {
+		version = LatestSchemaVersion()
+	}
+	return migrate(ctx, client.Database(dbName), version)
+}
+
+// schemaVersion returns the version of the last applied migration, 0 if no migration is applied.
+func schemaVersion(ctx context.Context, db *mongo.Database) (int, error) {
+	var last struct {
+		Version int `bson:"_id"`
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}})
+	err := db.Collection("schema_migrations").FindOne(ctx, bson.D{}, opts).Decode(&last)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	return last.Version, err
+}
+
+// readSchemaVersion returns the applied and the latest versions and whether the database was reverted by Migrate Schema.
+func readSchemaVersion(ctx context.Context, db *mongo.Database) (app.SchemaVersion, error) {
+	version, err := schemaVersion(ctx, db)
+	if err != nil {
+		return app.SchemaVersion{}, err
+	}
+
+	err = db.Collection("schema_settings").FindOne(ctx, bson.D{{Key: "_id", Value: settingPinnedVersion}}).Err()
+	pinned := err == nil
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return app.SchemaVersion{}, err
+	}
+
+	return app.SchemaVersion{Version: version, Latest: LatestSchemaVersion(), Pinned: pinned}, nil
+}
+
+// upgradeSchema applies the pending migrations before an import, e.g. of a new database without indexes.
+// It returns an *app.SchemaBehindError if the migrations were reverted by Migrate Schema.
+func upgradeSchema(ctx context.Context, db *mongo.Database) error {
+	version, err := readSchemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	if err := version.Check(); err != nil {
+		return err
+	}
+	if !version.Behind() {
+		return nil
+	}
+
+	log.Printf("MongoDB: Migrating the database from version %d to %d before the import", version.Version, version.Latest)
+	return migrate(ctx, db, version.Latest)
+}
+
+// migrate applies the migrations up to the version and reverts the ones above it.
+// A version below the latest pins the database, so the imports do not apply the reverted migrations again.
+// A migration is recorded in schema_migrations after its indexes are created, a failed one is applied again.
+func migrate(ctx context.Context, db *mongo.Database, version int) error {
+	if version < 0 || version > LatestSchemaVersion() {
+		return fmt.Errorf("invalid schema version %d, the latest is %d", version, LatestSchemaVersion())
+	}
+
+	current, err := schemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	collection := db.Collection("schema_migrations")
+
+	for _, m := range migrations {
+		if m.Version <= current || m.Version > version {
+			continue
+		}
+		log.Printf("MongoDB: Migration %d: %s", m.Version, m.Name)
+		if err := m.Up(ctx, db); err != nil {
+			return fmt.Errorf("migration %d: %w", m.Version, err)
+		}
+		record := bson.D{
+			{Key: "_id", Value: m.Version},
+			{Key: "name", Value: m.Name},
+			{Key: "applied_at", Value: time.Now()},
+		}
+		if _, err := collection.InsertOne(ctx, record); err != nil {
+			return fmt.Errorf("migration %d: %w", m.Version, err)
+		}
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version > current || m.Version <= version {
+			continue
+		}
+		log.Printf("MongoDB: Migration %d: Revert: %s", m.Version, m.Name)
+		if err := m.Down(ctx, db); err != nil {
+			return fmt.Errorf("migration %d: revert: %w", m.Version, err)
+		}
+		if _, err := collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: m.Version}}); err != nil {
+			return fmt.Errorf("migration %d: revert: %w", m.Version, err)
+		}
+	}
+
+	settings := db.Collection("schema_settings")
+	pin := bson.D{{Key: "_id", Value: settingPinnedVersion}}
+	if version < LatestSchemaVersion() {
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "value", Value: version}}}}
+		_, err = settings.UpdateOne(ctx, pin, update, options.Update().SetUpsert(true))
+	} else {
+		_, err = settings.DeleteOne(ctx, pin)
+	}
+	return err
+}
+
+// createIndexes creates the unique indexes of the collections.
+func createIndexes(ctx context.Context, db *mongo.Database, indexes map[string]bson.D) error {
+	for collection, keys := range indexes {
+		indexModel := mongo.IndexModel{
+			Keys:    keys,
+			Options: options.Index().SetUnique(true),
+		}
+		if _, err := db.Collection(collection).Indexes().CreateOne(ctx, indexModel); err != nil {
+			return fmt.Errorf("%s: %w", collection, err)
+		}
+	}
+	return nil
+}
+
+// dropIndexes drops the indexes of the collections, missing collections and indexes are skipped.
+func dropIndexes(ctx context.Context, db *mongo.Database, indexes map[string]bson.D) error {
+	for collection, keys := range indexes {
+		_, err := db.Collection(collection).Indexes().DropOne(ctx, indexName(keys))
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && (cmdErr.Code == 26 || cmdErr.Code == 27) {
+			// NamespaceNotFound or IndexNotFound
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", collection, err)
+		}
+	}
+	return nil
+}
+
+// indexName returns the default name of an index created without a name, e.g. "id_1_repo_1".
+func indexName(keys bson.D) string {
+	name := ""
+	for i, key := range keys {
+		if i > 0 {
+			name += "_"
+		}
+		name += fmt.Sprintf("%s_%v", key.Key, key.Value)
+	}
+	return name
+}
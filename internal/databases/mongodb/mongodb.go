@@ -2,11 +2,15 @@ package mongodb
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 	"time"
 
+	app "github-stat/internal"
+
 	"github.com/google/go-github/github"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -131,24 +135,24 @@ func DeleteSchema(connectionString string, dbName string) error {
 // It connects to the MongoDB database using the provided connection string and queries the update times.
 //
 // Arguments:
-//   - dbConfig: map[string]string containing the database configuration,
+//   - dbConfig: app.DatabaseConfig containing the database configuration,
 //     including the connection string under the key "connectionString".
 //
 // Returns:
 //   - map[string]string: A map where keys are repository names and values are the latest update times.
 //   - error: An error object if an error occurs, otherwise nil.
-func GetPullsLatestUpdates(dbConfig map[string]string) (map[string]string, error) {
+func GetPullsLatestUpdates(dbConfig app.DatabaseConfig) (map[string]string, error) {
 	ctx := context.Background()
 
 	// Connect to the MongoDB database.
-	client, err := ConnectByString(dbConfig["connectionString"], ctx)
+	client, err := ConnectByString(dbConfig.ConnectionString, ctx)
 	if err != nil {
 		log.Printf("MongoDB: Connect Error: message: %s", err)
 		return nil, err
 	}
 	defer client.Disconnect(ctx)
 
-	db := client.Database(dbConfig["database"])
+	db := client.Database(dbConfig.Database)
 	collection := db.Collection("pulls")
 
 	// Define the aggregation pipeline to get the latest update times.
@@ -186,18 +190,18 @@ func GetPullsLatestUpdates(dbConfig map[string]string) (map[string]string, error
 }
 
 // GetDatasetLatestUpdates retrieves the latest update times for the dataset from the reports_dataset collection.
-func GetDatasetLatestUpdates(dbConfig map[string]string) (string, error) {
+func GetDatasetLatestUpdates(dbConfig app.DatabaseConfig) (string, error) {
 	ctx := context.Background()
 
 	// Connect to the MongoDB database.
-	client, err := ConnectByString(dbConfig["connectionString"], ctx)
+	client, err := ConnectByString(dbConfig.ConnectionString, ctx)
 	if err != nil {
 		log.Printf("MongoDB: Connect Error: %s", err)
 		return "", err
 	}
 	defer client.Disconnect(ctx)
 
-	db := client.Database(dbConfig["database"])
+	db := client.Database(dbConfig.Database)
 	collection := db.Collection("reports_dataset")
 
 	// Define the aggregation pipeline to get the latest update time.
@@ -530,3 +534,315 @@ func GetNestedField(data map[string]interface{}, fieldPath string) (interface{},
 	}
 	return value, nil
 }
+
+// ImportDataset imports repository and pull request data into a MongoDB database.
+// It connects to the MongoDB database using the provided connection string,
+// fetches the latest update times for each repository, and then updates the database
+// with new or updated repositories and pull requests based on their last update time.
+// Issues, reviews, review comments, commits and their authors (users) are upserted completely.
+// Documents are written in batches of dataset.WriteBatchSize() with BulkWrite.
+//
+// Arguments:
+//   - ctx: context.Context cancelling the import between two repositories.
+//   - dbConfig: app.DatabaseConfig containing the database configuration,
+//     including the connection string under the key "connectionString".
+//   - dataset: app.Dataset containing the source of the repositories and pull requests to import.
+//
+// Returns:
+//   - error: An error object if an error occurs, the error of ctx if the import is cancelled, otherwise nil.
+func ImportDataset(ctx context.Context, dbConfig app.DatabaseConfig, dataset app.Dataset) error {
+	log.Printf("%s process start: %v", dbConfig.DBType, dbConfig.ID)
+
+	// Initialize the report for tracking the import process.
+	report := app.ReportDatabases{
+		Type:          "GitHub Pulls",
+		DB:            "MongoDB",
+		StartedAt:     time.Now().Format("2006-01-02T15:04:05.000"),
+		StartedAtUnix: time.Now().UnixMilli(),
+	}
+
+	// The cancellation does not interrupt the writes, the import stops between two repositories
+	writeCtx := context.WithoutCancel(ctx)
+
+	// Connect to the MongoDB database.
+	client, err := ConnectByString(dbConfig.ConnectionString, writeCtx)
+	if err != nil {
+		log.Printf("MongoDB: Connect Error: message: %s", err)
+		return err
+	}
+	defer client.Disconnect(writeCtx)
+
+	log.Printf("Databases: MongoDB: Start")
+
+	db := client.Database(dbConfig.Database)
+	dbCollectionRepos := db.Collection("repositories")
+	dbCollectionPulls := db.Collection("pulls")
+
+	profileCmd := bson.D{
+		{Key: "profile", Value: 2},
+		{Key: "slowms", Value: 200},
+		{Key: "ratelimit", Value: 100},
+	}
+	var result bson.M
+	if err := db.RunCommand(writeCtx, profileCmd).Decode(&result); err != nil {
+		log.Printf("Error setting profiling: %v", err)
+	} else {
+		log.Printf("Profiling command result: %v", result)
+	}
+
+	// The unique indexes used by the upserts are created by the migrations, pending ones are applied
+	// unless they were reverted by Migrate Schema
+	if err := upgradeSchema(writeCtx, db); err != nil {
+		log.Printf("Error: MongoDB: %s: Migrating the schema: %v", dbConfig.ID, err)
+		return err
+	}
+
+	// Get the latest update times for each repository from the MongoDB database.
+	pullsLastUpdate, err := GetPullsLatestUpdates(dbConfig)
+	if err != nil {
+		log.Printf("Error getting latest updates: %v", err)
+		return err
+	}
+
+	// Upserts are collected and written in batches with BulkWrite.
+	batchSize := dataset.WriteBatchSize()
+	var repoModels, pullModels []mongo.WriteModel
+
+	writeRepos := func() error {
+		if _, _, err := upsertMany(writeCtx, dbCollectionRepos, repoModels); err != nil {
+			return err
+		}
+		repoModels = repoModels[:0]
+		return nil
+	}
+
+	writePulls := func() error {
+		inserted, updated, err := upsertMany(writeCtx, dbCollectionPulls, pullModels)
+		if err != nil {
+			return err
+		}
+		report.Counter.PullsInserted += inserted
+		report.Counter.PullsUpdated += updated
+		pullModels = pullModels[:0]
+		return nil
+	}
+
+	issues := &collectionBatch{coll: db.Collection("issues"), size: batchSize}
+	reviews := &collectionBatch{coll: db.Collection("reviews"), size: batchSize}
+	reviewComments := &collectionBatch{coll: db.Collection("review_comments"), size: batchSize}
+	commits := &collectionBatch{coll: db.Collection("commits"), size: batchSize}
+	users := &collectionBatch{coll: db.Collection("users"), size: batchSize}
+	seenUsers := make(map[int64]bool)
+
+	// Iterate over all repositories and update the database with new or updated repositories and pull requests.
+	// The dataset is read one repository at a time.
+	err = dataset.Each(ctx, func(data app.RepoData) error {
+		repo, pullRequests := data.Repo, data.Pulls
+		report.Counter.Repos++
+
+		repoModels = append(repoModels, upsertModel(bson.M{"id": repo.ID}, repo))
+		if len(repoModels) >= batchSize {
+			if err := writeRepos(); err != nil {
+				return err
+			}
+		}
+
+		if err := writeDetails(writeCtx, data, issues, reviews, reviewComments, commits, users, seenUsers, &report.Counter); err != nil {
+			return err
+		}
+
+		if len(pullRequests) == 0 {
+			report.Counter.ReposWithoutPRs++
+			return nil
+		}
+		report.Counter.ReposWithPRs++
+		repoName := *repo.Name
+
+		// Get the last update time for the current repository.
+		pullLastUpdate := pullsLastUpdate[repoName]
+		var lastUpdatedTime time.Time
+		if pullLastUpdate != "" {
+			lastUpdatedTime, err = time.Parse(time.RFC3339, pullLastUpdate)
+			if err != nil {
+				log.Printf("Error parsing startedAt: %v", err)
+				lastUpdatedTime = time.Time{} // Reset lastUpdatedTime in case of error
+			}
+		}
+
+		// Iterate over all pull requests and collect new or updated pull requests.
+		for _, pull := range pullRequests {
+			// Skip processing if lastUpdatedTime is not empty and the pull request is older
+			if !lastUpdatedTime.IsZero() && pull.UpdatedAt != nil && lastUpdatedTime.After(*pull.UpdatedAt) {
+				continue
+			}
+
+			pullModels = append(pullModels, upsertModel(bson.M{"id": pull.ID, "repo": repoName}, pull))
+			if len(pullModels) >= batchSize {
+				if err := writePulls(); err != nil {
+					return err
+				}
+			}
+		}
+
+		report.Counter.Pulls += len(pullRequests)
+		return nil
+	})
+	// A cancelled import writes the collected documents, so the report shows the written part of the dataset
+	report.Cancelled = errors.Is(err, context.Canceled)
+	if err != nil && !report.Cancelled {
+		return err
+	}
+
+	// Write the remaining upserts.
+	if err := writeRepos(); err != nil {
+		return err
+	}
+	if err := writePulls(); err != nil {
+		return err
+	}
+	for _, batch := range []*collectionBatch{issues, reviews, reviewComments, commits, users} {
+		if err := batch.flush(writeCtx); err != nil {
+			return err
+		}
+	}
+
+	// Finalize the report with end times and total duration.
+	report.FinishedAt = time.Now().Format("2006-01-02T15:04:05.000")
+	report.FinishedAtUnix = time.Now().UnixMilli()
+	report.TotalMilli = report.FinishedAtUnix - report.StartedAtUnix
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	log.Printf("Databases: MongoDB: Finish: Report: %s", reportJSON)
+	dbCollectionReport := db.Collection("reports_dataset")
+	_, err = dbCollectionReport.InsertOne(writeCtx, report)
+	if err != nil {
+		return err
+	}
+
+	if report.Cancelled {
+		log.Printf("%s process cancelled for database ID: %v after %d repositories", dbConfig.DBType, dbConfig.ID, report.Counter.Repos)
+		return ctx.Err()
+	}
+
+	log.Printf("%s process complete for database ID: %v", dbConfig.DBType, dbConfig.ID)
+	return nil
+}
+
+// entityIndexes are the unique indexes of the collections of the entities besides repositories and pull requests, migration 2.
+var entityIndexes = map[string]bson.D{
+	"issues":          {{Key: "id", Value: 1}, {Key: "repo", Value: 1}},
+	"reviews":         {{Key: "id", Value: 1}, {Key: "repo", Value: 1}},
+	"review_comments": {{Key: "id", Value: 1}, {Key: "repo", Value: 1}},
+	"commits":         {{Key: "sha", Value: 1}, {Key: "repo", Value: 1}},
+	"users":           {{Key: "id", Value: 1}},
+}
+
+// writeDetails adds the issues, reviews, review comments, commits and the new users of the repository to their batches.
+// The repository name and the pull request number are set by the filters of the upserts.
+func writeDetails(ctx context.Context, data app.RepoData, issues, reviews, reviewComments, commits, users *collectionBatch, seenUsers map[int64]bool, counter *app.ReportCounter) error {
+	repoName := data.Repo.GetName()
+
+	for _, issue := range data.Issues {
+		if err := issues.add(ctx, bson.M{"id": issue.GetID(), "repo": repoName}, issue); err != nil {
+			return err
+		}
+	}
+	for _, review := range data.Reviews {
+		filter := bson.M{"id": review.GetID(), "repo": repoName, "pull": app.PullNumber(review.GetPullRequestURL())}
+		if err := reviews.add(ctx, filter, review); err != nil {
+			return err
+		}
+	}
+	for _, comment := range data.ReviewComments {
+		filter := bson.M{"id": comment.GetID(), "repo": repoName, "pull": app.PullNumber(comment.GetPullRequestURL())}
+		if err := reviewComments.add(ctx, filter, comment); err != nil {
+			return err
+		}
+	}
+	for _, commit := range data.Commits {
+		if err := commits.add(ctx, bson.M{"sha": commit.GetSHA(), "repo": repoName}, commit); err != nil {
+			return err
+		}
+	}
+
+	// Users are written once per import, they are the authors of the entities of many repositories
+	newUsers := 0
+	for _, user := range data.Users() {
+		if seenUsers[user.GetID()] {
+			continue
+		}
+		seenUsers[user.GetID()] = true
+		newUsers++
+		if err := users.add(ctx, bson.M{"id": user.GetID()}, user); err != nil {
+			return err
+		}
+	}
+
+	counter.Issues += len(data.Issues)
+	counter.Reviews += len(data.Reviews)
+	counter.ReviewComments += len(data.ReviewComments)
+	counter.Commits += len(data.Commits)
+	counter.Users += newUsers
+
+	return nil
+}
+
+// GetDatasetInfo retrieves the dataset information from a MongoDB database.
+func GetDatasetInfo(dbConfig app.DatabaseConfig) (app.DatasetInfo, error) {
+	connectionString := dbConfig.ConnectionString
+	dbName := dbConfig.Database
+
+	mongo_ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	mongo, err := ConnectByString(connectionString, mongo_ctx)
+	if err != nil {
+		log.Printf("Error: Dataset: MongoDB: Connect: %s", err)
+		return app.DatasetInfo{
+			DBName: dbName,
+		}, nil
+	}
+	defer mongo.Disconnect(mongo_ctx)
+
+	mongo_pulls, err := CountDocuments(mongo, dbName, "pulls", bson.D{})
+	if err != nil {
+		log.Printf("Error: Dataset: MongoDB: Count Pulls: %s", err)
+		mongo_pulls = 0
+	}
+
+	mongo_repositories, err := CountDocuments(mongo, dbName, "repositories", bson.D{})
+	if err != nil {
+		log.Printf("Error: Dataset: MongoDB: Count Repos: %s", err)
+		mongo_repositories = 0
+	}
+
+	// Get the latest update from the reports_dataset collection
+	lastUpdate, err := GetDatasetLatestUpdates(dbConfig)
+	if err != nil {
+		log.Printf("Error: Dataset: MongoDB: Last update: %v", err)
+	}
+
+	counts := make(map[string]int)
+	for _, collection := range []string{"issues", "reviews", "review_comments", "commits", "users"} {
+		count, err := CountDocuments(mongo, dbName, collection, bson.D{})
+		if err != nil {
+			log.Printf("Error: Dataset: MongoDB: Count %s: %s", collection, err)
+		}
+		counts[collection] = int(count)
+	}
+
+	return app.DatasetInfo{
+		DBName:         dbName,
+		Repositories:   int(mongo_repositories),
+		PullRequests:   int(mongo_pulls),
+		Issues:         counts["issues"],
+		Reviews:        counts["reviews"],
+		ReviewComments: counts["review_comments"],
+		Commits:        counts["commits"],
+		Users:          counts["users"],
+		LastUpdate:     lastUpdate,
+	}, nil
+}
@@ -0,0 +1,79 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	app "github-stat/internal"
+
+	"github.com/google/go-github/github"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetPullDigest reads the IDs and the update times of all pull requests into a digest by repository.
+//
+// Arguments:
+//   - dbConfig: app.DatabaseConfig containing the database configuration.
+//
+// Returns:
+//   - app.DatasetDigest: The digests of the pull requests by repository name.
+//   - error: An error object if an error occurs, otherwise nil.
+func GetPullDigest(dbConfig app.DatabaseConfig) (app.DatasetDigest, error) {
+	ctx := context.Background()
+	client, err := ConnectByString(dbConfig.ConnectionString, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Disconnect(ctx)
+
+	projection := bson.D{{Key: "repo", Value: 1}, {Key: "id", Value: 1}, {Key: "updatedat", Value: 1}}
+	cursor, err := client.Database(dbConfig.Database).Collection("pulls").Find(ctx, bson.D{}, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	digest := make(app.DatasetDigest)
+	for cursor.Next(ctx) {
+		var pull struct {
+			Repo      string    `bson:"repo"`
+			ID        int64     `bson:"id"`
+			UpdatedAt time.Time `bson:"updatedat"` // Null is decoded as the zero time like a missing update time in the dataset
+		}
+		if err := cursor.Decode(&pull); err != nil {
+			return nil, err
+		}
+		digest.Add(pull.Repo, pull.ID, pull.UpdatedAt)
+	}
+	return digest, cursor.Err()
+}
+
+// GetPulls reads pull requests of a repository by their IDs.
+//
+// Arguments:
+//   - dbConfig: app.DatabaseConfig containing the database configuration.
+//   - repo: string containing the repository name.
+//   - ids: []int64 containing the IDs of the pull requests.
+//
+// Returns:
+//   - []*github.PullRequest: The pull requests found, missing ones are skipped.
+//   - error: An error object if an error occurs, otherwise nil.
+func GetPulls(dbConfig app.DatabaseConfig, repo string, ids []int64) ([]*github.PullRequest, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	ctx := context.Background()
+	client, err := ConnectByString(dbConfig.ConnectionString, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Disconnect(ctx)
+
+	filter := bson.D{
+		{Key: "repo", Value: repo},
+		{Key: "id", Value: bson.D{{Key: "$in", Value: ids}}},
+	}
+	return FindPullRequests(client, dbConfig.Database, "pulls", filter, bson.D{}, 0)
+}
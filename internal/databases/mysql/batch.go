@@ -0,0 +1,126 @@
+package mysql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// upsertRows writes the rows with one multi-row INSERT ... ON DUPLICATE KEY UPDATE statement.
+// MySQL reports 1 affected row for an insert, 2 for an update and 0 for an unchanged row,
+// so the existing rows are counted in the same transaction to tell inserts from updates.
+//
+// Arguments:
+//   - db: *sql.DB connection to the MySQL database.
+//   - table: string containing the name of the table.
+//   - columns: []string containing the columns of every row.
+//   - keys: []string containing the primary key columns, the other columns are updated.
+//   - rows: [][]interface{} containing the values in the order of the columns.
+//
+// Returns:
+//   - int: The number of inserted rows.
+//   - int: The number of updated rows.
+//   - error: An error object if an error occurs, otherwise nil.
+func upsertRows(db *sql.DB, table string, columns []string, keys []string, rows [][]interface{}) (int, int, error) {
+	if len(rows) == 0 {
+		return 0, 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	// Count the rows that already exist
+	keyIndexes := make([]int, len(keys))
+	for i, key := range keys {
+		keyIndexes[i] = slices.Index(columns, key)
+	}
+	keyArgs := make([]interface{}, 0, len(rows)*len(keys))
+	for _, row := range rows {
+		for _, index := range keyIndexes {
+			keyArgs = append(keyArgs, row[index])
+		}
+	}
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE (%s) IN (%s)",
+		table, strings.Join(keys, ", "), rowPlaceholders(len(keys), len(rows)))
+
+	var existing int
+	if err := tx.QueryRow(countQuery, keyArgs...).Scan(&existing); err != nil {
+		return 0, 0, err
+	}
+
+	// Write all rows with one statement
+	var updates []string
+	for _, column := range columns {
+		if !slices.Contains(keys, column) {
+			updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", column, column))
+		}
+	}
+	args := make([]interface{}, 0, len(rows)*len(columns))
+	for _, row := range rows {
+		args = append(args, row...)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s ON DUPLICATE KEY UPDATE %s",
+		table, strings.Join(columns, ", "), rowPlaceholders(len(columns), len(rows)), strings.Join(updates, ", "))
+
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, 0, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+
+	inserted := len(rows) - existing
+	updated := (int(affected) - inserted) / 2
+
+	return inserted, updated, nil
+}
+
+// rowPlaceholders returns "(?, ?), (?, ?)" for the given number of columns and rows.
+func rowPlaceholders(columns int, rows int) string {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", columns), ", ") + ")"
+	return strings.TrimSuffix(strings.Repeat(row+", ", rows), ", ")
+}
+
+// tableBatch collects the rows of a table and writes them with upsertRows when the batch is full.
+type tableBatch struct {
+	db      *sql.DB
+	table   string
+	columns []string // The last column holds the entity in JSON
+	keys    []string
+	size    int
+	rows    [][]interface{}
+}
+
+// addJSON adds a row with the values of the other columns and the entity in JSON.
+func (b *tableBatch) addJSON(entity interface{}, values ...interface{}) error {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+
+	b.rows = append(b.rows, append(values, data))
+	if len(b.rows) >= b.size {
+		return b.flush()
+	}
+	return nil
+}
+
+// flush writes the collected rows.
+func (b *tableBatch) flush() error {
+	if _, _, err := upsertRows(b.db, b.table, b.columns, b.keys, b.rows); err != nil {
+		return fmt.Errorf("%s: %w", b.table, err)
+	}
+	b.rows = b.rows[:0]
+	return nil
+}
@@ -0,0 +1,389 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	app "github-stat/internal"
+)
+
+// migration is a numbered change of the test schema, Down reverts Up.
+// A released migration is never changed, a schema change is added as a new migration.
+type migration struct {
+	Version int
+	Name    string
+	Mode    string // Schema mode the migration belongs to, empty for all modes
+	Up      []string
+	Down    []string
+}
+
+// migrations are applied in the order of their versions, the applied ones are recorded in schema_migrations.
+// Databases created before the migrations were added have the tables already, so the statements
+// of the first migrations must not fail on existing tables.
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "Create the repositories, pulls and reports tables",
+		Up: []string{
+			"CREATE TABLE IF NOT EXISTS repositories (id INT AUTO_INCREMENT PRIMARY KEY, data JSON);",
+			"CREATE TABLE IF NOT EXISTS repositoriesTest (id INT AUTO_INCREMENT PRIMARY KEY, data JSON);",
+			"CREATE TABLE IF NOT EXISTS pulls (id BIGINT NOT NULL, repo VARCHAR(255) NOT NULL, data JSON, PRIMARY KEY (id, repo), INDEX idx_repo (repo), INDEX idx_id (id));",
+			"CREATE TABLE IF NOT EXISTS pullsTest (id BIGINT NOT NULL, repo VARCHAR(255) NOT NULL, data JSON, PRIMARY KEY (id, repo), INDEX idx_repo (repo), INDEX idx_id (id));",
+			"CREATE TABLE IF NOT EXISTS reports_dataset (id INT AUTO_INCREMENT PRIMARY KEY, data JSON);",
+		},
+		Down: []string{
+			"DROP TABLE IF EXISTS repositories, repositoriesTest, pulls, pullsTest, reports_dataset;",
+		},
+	},
+	{
+		Version: 2,
+		Name:    "Create the issues, reviews, review comments, commits and users tables",
+		Up:      entityTablesSQL,
+		Down: []string{
+			"DROP TABLE IF EXISTS issues, reviews, review_comments, commits, users;",
+		},
+	},
+	{
+		Version: 3,
+		Name:    "Add the normalized columns of the repositories and pulls",
+		Mode:    app.SchemaModeNormalized,
+		Up: []string{
+			`ALTER TABLE pulls
+				ADD COLUMN state VARCHAR(16) GENERATED ALWAYS AS (JSON_UNQUOTE(JSON_EXTRACT(data, '$.state'))) STORED,
+				ADD COLUMN created_at DATETIME GENERATED ALWAYS AS (` + jsonDatetime("created_at") + `) STORED,
+				ADD COLUMN merged_at DATETIME GENERATED ALWAYS AS (` + jsonDatetime("merged_at") + `) STORED,
+				ADD COLUMN user_login VARCHAR(255) GENERATED ALWAYS AS (JSON_UNQUOTE(JSON_EXTRACT(data, '$.user.login'))) STORED,
+				ADD COLUMN additions INT GENERATED ALWAYS AS (` + jsonInt("additions") + `) STORED,
+				ADD COLUMN deletions INT GENERATED ALWAYS AS (` + jsonInt("deletions") + `) STORED,
+				ADD INDEX idx_state_created_at (state, created_at),
+				ADD INDEX idx_created_at (created_at),
+				ADD INDEX idx_merged_at (merged_at),
+				ADD INDEX idx_user_login (user_login);`,
+			`ALTER TABLE repositories
+				ADD COLUMN stargazers INT GENERATED ALWAYS AS (` + jsonInt("stargazers_count") + `) STORED,
+				ADD INDEX idx_stargazers (stargazers);`,
+		},
+		Down: []string{
+			"ALTER TABLE pulls DROP COLUMN state, DROP COLUMN created_at, DROP COLUMN merged_at, DROP COLUMN user_login, DROP COLUMN additions, DROP COLUMN deletions;",
+			"ALTER TABLE repositories DROP COLUMN stargazers;",
+		},
+	},
+}
+
+// jsonDatetime returns the expression of a generated column converting a GitHub timestamp, e.g. "2024-05-01T10:00:00Z".
+// JSON null is unquoted as the string "null", it is converted to NULL.
+func jsonDatetime(field string) string {
+	return fmt.Sprintf("CAST(REPLACE(REPLACE(NULLIF(JSON_UNQUOTE(JSON_EXTRACT(data, '$.%s')), 'null'), 'T', ' '), 'Z', '') AS DATETIME)", field)
+}
+
+// jsonInt returns the expression of a generated column reading an integer field.
+func jsonInt(field string) string {
+	return fmt.Sprintf("CAST(NULLIF(JSON_UNQUOTE(JSON_EXTRACT(data, '$.%s')), 'null') AS SIGNED)", field)
+}
+
+const migrationsTableSQL = "CREATE TABLE IF NOT EXISTS schema_migrations (version INT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP);"
+
+// settingsTableSQL creates the table of the schema settings, e.g. settingPinnedVersion.
+const settingsTableSQL = "CREATE TABLE IF NOT EXISTS schema_settings (name VARCHAR(64) NOT NULL PRIMARY KEY, value VARCHAR(255) NOT NULL);"
+
+// Names of the schema settings.
+const (
+	// settingPinnedVersion is the version the schema was reverted to by Migrate Schema, the imports do not migrate it.
+	settingPinnedVersion = "pinned_version"
+
+	// settingMode is the schema mode of the applied migrations.
+	settingMode = "mode"
+)
+
+// belongsTo reports whether the migration is applied in the schema mode.
+func (m migration) belongsTo(mode string) bool {
+	return m.Mode == "" || m.Mode == mode
+}
+
+// LatestSchemaVersion returns the version of the last migration of the schema mode, an empty mode is the JSON mode.
+func LatestSchemaVersion(mode string) int {
+	mode = modeOrDefault(mode)
+	latest := 0
+	for _, m := range migrations {
+		if m.belongsTo(mode) {
+			latest = m.Version
+		}
+	}
+	return latest
+}
+
+// modeOrDefault returns the schema mode, app.SchemaModeJSON if it is empty.
+func modeOrDefault(mode string) string {
+	if mode == "" {
+		return app.SchemaModeJSON
+	}
+	return mode
+}
+
+// GetSchemaVersion returns the migration versions and the schema mode of the test database.
+//
+// Arguments:
+//   - connection_string: string containing the connection string of the test database.
+//
+// Returns:
+//   - app.SchemaVersion: The applied and the latest versions of the schema mode.
+//   - error: An error object if an error occurs, otherwise nil.
+func GetSchemaVersion(connection_string string) (app.SchemaVersion, error) {
+	db, err := ConnectByString(connection_string)
+	if err != nil {
+		return app.SchemaVersion{}, err
+	}
+	defer db.Close()
+
+	return readSchemaVersion(db)
+}
+
+// MigrateSchema applies or reverts the migrations until the test database is at the version of the schema mode.
+// A mode other than the one of the database switches it: the migrations of the old mode are reverted
+// and the ones of the new mode are applied.
+//
+// Arguments:
+//   - connection_string: string containing the connection string of the test database.
+//   - version: int containing the target version, 0 reverts all migrations, app.LatestMigration applies all of the mode.
+//   - mode: string containing the schema mode, the migrations of the other modes are not applied.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func MigrateSchema(connection_string string, version int, mode string) error {
+	db, err := ConnectByString(connection_string)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if version == app.LatestMigration {
+		version = LatestSchemaVersion(mode)
+	}
+	return migrate(db, version, mode)
+}
+
+// appliedVersions returns the versions recorded in schema_migrations, none if the table does not exist.
+func appliedVersions(db *sql.DB) (map[int]bool, error) {
+	applied := make(map[int]bool)
+
+	exists, err := SelectInt(db, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'schema_migrations';")
+	if err != nil || exists == 0 {
+		return applied, err
+	}
+
+	rows, err := db.Query("SELECT version FROM schema_migrations;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// schemaMode returns the schema mode of the test database.
+// Databases migrated by older releases do not store it, their mode is detected from the normalized columns.
+func schemaMode(db *sql.DB) (string, error) {
+	mode, err := schemaSetting(db, settingMode)
+	if err != nil || mode != "" {
+		return mode, err
+	}
+
+	normalized, err := SelectInt(db, "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'pulls' AND column_name = 'state';")
+	if err != nil {
+		return "", err
+	}
+	if normalized > 0 {
+		return app.SchemaModeNormalized, nil
+	}
+	return app.SchemaModeJSON, nil
+}
+
+// readSchemaVersion returns the applied and the latest versions of the schema mode of the database
+// and whether the schema was reverted by Migrate Schema.
+func readSchemaVersion(db *sql.DB) (app.SchemaVersion, error) {
+	mode, err := schemaMode(db)
+	if err != nil {
+		return app.SchemaVersion{}, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return app.SchemaVersion{}, err
+	}
+	pinned, err := schemaSetting(db, settingPinnedVersion)
+	if err != nil {
+		return app.SchemaVersion{}, err
+	}
+
+	version := 0
+	for _, m := range migrations {
+		if applied[m.Version] && m.belongsTo(mode) {
+			version = m.Version
+		}
+	}
+	return app.SchemaVersion{Version: version, Latest: LatestSchemaVersion(mode), Mode: mode, Pinned: pinned != ""}, nil
+}
+
+// upgradeSchema applies the pending migrations of the schema mode of the database before an import,
+// e.g. of a database created by an older release.
+// It returns an *app.SchemaBehindError if the migrations were reverted by Migrate Schema.
+func upgradeSchema(db *sql.DB) error {
+	version, err := readSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if err := version.Check(); err != nil {
+		return err
+	}
+
+	stored, err := schemaSetting(db, settingMode)
+	if err != nil {
+		return err
+	}
+	if !version.Behind() && stored != "" {
+		return nil
+	}
+
+	// A database of an older release is migrated also if it is at the latest version,
+	// so its mode is stored and the records of the migrations of the other mode are removed
+	if version.Behind() {
+		log.Printf("MySQL: Migrating the schema from version %d to %d before the import", version.Version, version.Latest)
+	}
+	return migrate(db, version.Latest, version.Mode)
+}
+
+// schemaSetting returns a value of schema_settings, empty if it is not set.
+func schemaSetting(db *sql.DB, name string) (string, error) {
+	exists, err := SelectInt(db, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'schema_settings';")
+	if err != nil || exists == 0 {
+		return "", err
+	}
+
+	var value string
+	err = db.QueryRow("SELECT value FROM schema_settings WHERE name = ?;", name).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+// setSchemaSetting stores a value in schema_settings, an empty value removes it.
+func setSchemaSetting(db *sql.DB, name string, value string) error {
+	if value == "" {
+		_, err := db.Exec("DELETE FROM schema_settings WHERE name = ?;", name)
+		return err
+	}
+	_, err := db.Exec("INSERT INTO schema_settings (name, value) VALUES (?, ?) ON DUPLICATE KEY UPDATE value = VALUES(value);", name, value)
+	return err
+}
+
+// migrationPlan returns the changes migrate makes to move a database from the applied migrations
+// in the current mode to the version of the mode:
+//   - revert: the applied migrations to revert, in the reverse order.
+//   - forget: the records of the migrations of another mode that older releases recorded without running them.
+//   - apply: the migrations to apply, in the order of their versions.
+//
+// A mode switch applies the migrations of the new mode after the later migrations of all modes,
+// so a migration of a mode must not depend on them.
+func migrationPlan(applied map[int]bool, current string, version int, mode string) (revert, forget, apply []migration) {
+	// The migrations of another mode recorded by older releases did not run, they are not applied
+	ran := func(m migration) bool {
+		return applied[m.Version] && m.belongsTo(current)
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if applied[m.Version] && !ran(m) {
+			forget = append(forget, m)
+		} else if ran(m) && (m.Version > version || !m.belongsTo(mode)) {
+			revert = append(revert, m)
+		}
+	}
+
+	for _, m := range migrations {
+		if !ran(m) && m.Version <= version && m.belongsTo(mode) {
+			apply = append(apply, m)
+		}
+	}
+	return revert, forget, apply
+}
+
+// migrate applies the migrations of the schema mode up to the version and reverts the other ones.
+// Only the migrations that ran are recorded, the mode is stored in schema_settings.
+// A version below the latest of the mode pins the schema, so the imports do not apply the reverted migrations again.
+// MySQL commits DDL statements implicitly, a failed migration is not recorded
+// and its statements that succeeded stay applied.
+func migrate(db *sql.DB, version int, mode string) error {
+	mode = modeOrDefault(mode)
+	latest := LatestSchemaVersion(mode)
+	if version < 0 || version > latest {
+		return fmt.Errorf("invalid schema version %d, the latest of the %s mode is %d", version, mode, latest)
+	}
+
+	for _, query := range []string{migrationsTableSQL, settingsTableSQL} {
+		if err := executeSQL(db, query); err != nil {
+			return err
+		}
+	}
+
+	current, err := schemaMode(db)
+	if err != nil {
+		return err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return err
+	}
+
+	revert, forget, apply := migrationPlan(applied, current, version, mode)
+
+	for _, m := range revert {
+		log.Printf("MySQL: Migration %d: Revert: %s", m.Version, m.Name)
+		for _, query := range m.Down {
+			if err := executeSQL(db, query); err != nil {
+				return fmt.Errorf("migration %d: revert: %w", m.Version, err)
+			}
+		}
+		if _, err := db.Exec("DELETE FROM schema_migrations WHERE version = ?;", m.Version); err != nil {
+			return fmt.Errorf("migration %d: revert: %w", m.Version, err)
+		}
+	}
+
+	for _, m := range forget {
+		log.Printf("MySQL: Migration %d: Removing the record, it was not applied in the %s schema mode", m.Version, current)
+		if _, err := db.Exec("DELETE FROM schema_migrations WHERE version = ?;", m.Version); err != nil {
+			return fmt.Errorf("migration %d: %w", m.Version, err)
+		}
+	}
+
+	for _, m := range apply {
+		log.Printf("MySQL: Migration %d: %s", m.Version, m.Name)
+		for _, query := range m.Up {
+			if err := executeSQL(db, query); err != nil {
+				return fmt.Errorf("migration %d: %w", m.Version, err)
+			}
+		}
+		if _, err := db.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?);", m.Version, m.Name); err != nil {
+			return fmt.Errorf("migration %d: %w", m.Version, err)
+		}
+	}
+
+	if err := setSchemaSetting(db, settingMode, mode); err != nil {
+		return err
+	}
+
+	pinned := ""
+	if version < latest {
+		pinned = strconv.Itoa(version)
+	}
+	return setSchemaSetting(db, settingPinnedVersion, pinned)
+}
@@ -10,6 +10,8 @@ import (
 	"strings"
 	"time"
 
+	app "github-stat/internal"
+
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/google/go-github/github"
 )
@@ -152,17 +154,17 @@ func InsertPulls(db *sql.DB, pullRequests []*github.PullRequest, table string) e
 // It connects to the MySQL database using the provided connection string and queries the update times.
 //
 // Arguments:
-//   - dbConfig: map[string]string containing the database configuration,
+//   - dbConfig: app.DatabaseConfig containing the database configuration,
 //     including the connection string under the key "connectionString".
 //
 // Returns:
 //   - map[string]string: A map where keys are repository names and values are the latest update times.
 //   - error: An error object if an error occurs, otherwise nil.
-func GetPullsLatestUpdates(dbConfig map[string]string) (map[string]string, error) {
+func GetPullsLatestUpdates(dbConfig app.DatabaseConfig) (map[string]string, error) {
 	ctx := context.Background()
 
 	// Connect to the MySQL database.
-	db, err := ConnectByString(dbConfig["connectionString"])
+	db, err := ConnectByString(dbConfig.ConnectionString)
 	if err != nil {
 		log.Printf("MySQL: Error: message: %s", err)
 		return nil, err
@@ -231,7 +233,17 @@ func DropTable(db *sql.DB, name string) (string, error) {
 	return fmt.Sprintf("Table '%s' dropped successfully or did not exist.", name), nil
 }
 
-func InitSchema(connection_string string) error {
+// entityTablesSQL creates the tables of the entities besides repositories and pull requests, migration 2.
+var entityTablesSQL = []string{
+	"CREATE TABLE IF NOT EXISTS issues (id BIGINT NOT NULL, repo VARCHAR(255) NOT NULL, data JSON, PRIMARY KEY (id, repo), INDEX idx_repo (repo));",
+	"CREATE TABLE IF NOT EXISTS reviews (id BIGINT NOT NULL, repo VARCHAR(255) NOT NULL, pull INT NOT NULL, data JSON, PRIMARY KEY (id, repo), INDEX idx_repo_pull (repo, pull));",
+	"CREATE TABLE IF NOT EXISTS review_comments (id BIGINT NOT NULL, repo VARCHAR(255) NOT NULL, pull INT NOT NULL, data JSON, PRIMARY KEY (id, repo), INDEX idx_repo_pull (repo, pull));",
+	"CREATE TABLE IF NOT EXISTS commits (sha CHAR(40) NOT NULL, repo VARCHAR(255) NOT NULL, data JSON, PRIMARY KEY (sha, repo), INDEX idx_repo (repo));",
+	"CREATE TABLE IF NOT EXISTS users (id BIGINT NOT NULL PRIMARY KEY, login VARCHAR(255) NOT NULL, data JSON, INDEX idx_login (login));",
+}
+
+// InitSchema creates the test database if it does not exist and applies all migrations of the schema mode.
+func InitSchema(connection_string string, mode string) error {
 	newDBName, err := GetDbName(connection_string)
 	if err != nil {
 		log.Printf("getDbName: Error: %v\n", err)
@@ -272,27 +284,16 @@ func InitSchema(connection_string string) error {
 
 	log.Printf("Creating tables in database %s", newDBName)
 
-	schemaSQLs := []string{
-		"CREATE TABLE IF NOT EXISTS repositories (id INT AUTO_INCREMENT PRIMARY KEY, data JSON);",
-		"CREATE TABLE IF NOT EXISTS repositoriesTest (id INT AUTO_INCREMENT PRIMARY KEY, data JSON);",
-		"CREATE TABLE IF NOT EXISTS pulls (id BIGINT NOT NULL, repo VARCHAR(255) NOT NULL, data JSON, PRIMARY KEY (id, repo), INDEX idx_repo (repo), INDEX idx_id (id));",
-		"CREATE TABLE IF NOT EXISTS pullsTest (id BIGINT NOT NULL, repo VARCHAR(255) NOT NULL, data JSON, PRIMARY KEY (id, repo), INDEX idx_repo (repo), INDEX idx_id (id));",
-		"CREATE TABLE IF NOT EXISTS reports_dataset (id INT AUTO_INCREMENT PRIMARY KEY, data JSON);",
-	}
-
-	for _, query := range schemaSQLs {
-		log.Printf("Executing query: %s", query)
-		if err := executeSQL(newDB, query); err != nil {
-			log.Printf("Error executing query %s: %v", query, err)
-			// Drop the database if there is an error
-			dropErr := executeSQL(mainDB, fmt.Sprintf("DROP DATABASE IF EXISTS %s;", newDBName))
-			if dropErr != nil {
-				log.Printf("Error dropping database %s: %v", newDBName, dropErr)
-			} else {
-				log.Printf("Database %s dropped due to query error", newDBName)
-			}
-			return err
+	if err := migrate(newDB, LatestSchemaVersion(mode), mode); err != nil {
+		log.Printf("Error migrating database %s: %v", newDBName, err)
+		// Drop the database if there is an error
+		dropErr := executeSQL(mainDB, fmt.Sprintf("DROP DATABASE IF EXISTS %s;", newDBName))
+		if dropErr != nil {
+			log.Printf("Error dropping database %s: %v", newDBName, dropErr)
+		} else {
+			log.Printf("Database %s dropped due to migration error", newDBName)
 		}
+		return err
 	}
 
 	return nil
@@ -367,3 +368,300 @@ func executeSQL(db *sql.DB, query string) error {
 	_, err := db.Exec(query)
 	return err
 }
+
+// ImportDataset imports repository and pull request data into a MySQL database.
+// It connects to the MySQL database using the provided connection string,
+// fetches the latest update times for each repository, and then updates the database
+// with new or updated repositories and pull requests based on their last update time.
+// Issues, reviews, review comments, commits and their authors (users) are upserted completely.
+// Rows are written in batches of dataset.WriteBatchSize() with multi-row inserts.
+//
+// Arguments:
+//   - ctx: context.Context cancelling the import between two repositories.
+//   - dbConfig: app.DatabaseConfig containing the database configuration,
+//     including the connection string under the key "connectionString".
+//   - dataset: app.Dataset containing the source of the repositories and pull requests to import.
+//
+// Returns:
+//   - error: An error object if an error occurs, the error of ctx if the import is cancelled, otherwise nil.
+func ImportDataset(ctx context.Context, dbConfig app.DatabaseConfig, dataset app.Dataset) error {
+	log.Printf("%s process start: %v", dbConfig.DBType, dbConfig.ID)
+
+	// Initialize the report for tracking the import process.
+	report := app.ReportDatabases{
+		Type:          "GitHub Pulls",
+		DB:            "MySQL",
+		StartedAt:     time.Now().Format("2006-01-02T15:04:05.000"),
+		StartedAtUnix: time.Now().UnixMilli(),
+	}
+
+	// Connect to the MySQL database.
+	db, err := ConnectByString(dbConfig.ConnectionString)
+	if err != nil {
+		log.Printf("Databases: MySQL: Error: message: %s", err)
+		return err
+	}
+	defer db.Close()
+
+	log.Printf("Databases: MySQL: Start")
+
+	// Pending migrations are applied, unless they were reverted by Migrate Schema
+	if err := upgradeSchema(db); err != nil {
+		log.Printf("Databases: MySQL: Error: Migrating the schema: %v", err)
+		return err
+	}
+
+	// Get the latest update times for each repository from the MySQL database.
+	pullsLastUpdate, err := GetPullsLatestUpdates(dbConfig)
+	if err != nil {
+		log.Printf("Error getting latest updates: %v", err)
+		return err
+	}
+
+	// Rows are collected and written in batches with multi-row inserts.
+	batchSize := dataset.WriteBatchSize()
+	var repoRows, pullRows [][]interface{}
+
+	writeRepos := func() error {
+		if _, _, err := upsertRows(db, "repositories", []string{"id", "data"}, []string{"id"}, repoRows); err != nil {
+			return err
+		}
+		repoRows = repoRows[:0]
+		return nil
+	}
+
+	writePulls := func() error {
+		inserted, updated, err := upsertRows(db, "pulls", []string{"id", "repo", "data"}, []string{"id", "repo"}, pullRows)
+		if err != nil {
+			return err
+		}
+		report.Counter.PullsInserted += inserted
+		report.Counter.PullsUpdated += updated
+		pullRows = pullRows[:0]
+		return nil
+	}
+
+	issues := &tableBatch{db: db, table: "issues", columns: []string{"id", "repo", "data"}, keys: []string{"id", "repo"}, size: batchSize}
+	reviews := &tableBatch{db: db, table: "reviews", columns: []string{"id", "repo", "pull", "data"}, keys: []string{"id", "repo"}, size: batchSize}
+	reviewComments := &tableBatch{db: db, table: "review_comments", columns: []string{"id", "repo", "pull", "data"}, keys: []string{"id", "repo"}, size: batchSize}
+	commits := &tableBatch{db: db, table: "commits", columns: []string{"sha", "repo", "data"}, keys: []string{"sha", "repo"}, size: batchSize}
+	users := &tableBatch{db: db, table: "users", columns: []string{"id", "login", "data"}, keys: []string{"id"}, size: batchSize}
+	seenUsers := make(map[int64]bool)
+
+	// Iterate over all repositories and update the database with new or updated repositories and pull requests.
+	// The dataset is read one repository at a time.
+	err = dataset.Each(ctx, func(data app.RepoData) error {
+		repo, pullRequests := data.Repo, data.Pulls
+		report.Counter.Repos++
+		repoJSON, err := json.Marshal(repo)
+		if err != nil {
+			return err
+		}
+
+		repoRows = append(repoRows, []interface{}{repo.ID, repoJSON})
+		if len(repoRows) >= batchSize {
+			if err := writeRepos(); err != nil {
+				return err
+			}
+		}
+
+		if err := writeDetails(data, issues, reviews, reviewComments, commits, users, seenUsers, &report.Counter); err != nil {
+			return err
+		}
+
+		if len(pullRequests) == 0 {
+			report.Counter.ReposWithoutPRs++
+			return nil
+		}
+		report.Counter.ReposWithPRs++
+		repoName := *repo.Name
+
+		// Get the last update time for the current repository.
+		pullLastUpdate := pullsLastUpdate[repoName]
+		var lastUpdatedTime time.Time
+		if pullLastUpdate != "" {
+			lastUpdatedTime, err = time.Parse(time.RFC3339, pullLastUpdate)
+			if err != nil {
+				log.Printf("Error parsing startedAt: %v", err)
+				lastUpdatedTime = time.Time{} // Reset lastUpdatedTime in case of error
+			}
+		}
+
+		// Iterate over all pull requests and collect new or updated pull requests.
+		for _, pull := range pullRequests {
+			// Skip processing if lastUpdatedTime is not empty and the pull request is older
+			if !lastUpdatedTime.IsZero() && pull.UpdatedAt != nil && lastUpdatedTime.After(*pull.UpdatedAt) {
+				continue
+			}
+
+			pullJSON, err := json.Marshal(pull)
+			if err != nil {
+				return err
+			}
+
+			pullRows = append(pullRows, []interface{}{pull.ID, repoName, pullJSON})
+			if len(pullRows) >= batchSize {
+				if err := writePulls(); err != nil {
+					return err
+				}
+			}
+		}
+
+		report.Counter.Pulls += len(pullRequests)
+		return nil
+	})
+	// A cancelled import writes the collected rows, so the report shows the written part of the dataset
+	report.Cancelled = errors.Is(err, context.Canceled)
+	if err != nil && !report.Cancelled {
+		return err
+	}
+
+	// Write the remaining rows.
+	if err := writeRepos(); err != nil {
+		return err
+	}
+	if err := writePulls(); err != nil {
+		return err
+	}
+	for _, batch := range []*tableBatch{issues, reviews, reviewComments, commits, users} {
+		if err := batch.flush(); err != nil {
+			return err
+		}
+	}
+
+	// Finalize the report with end times and total duration.
+	report.FinishedAt = time.Now().Format("2006-01-02T15:04:05.000")
+	report.FinishedAtUnix = time.Now().UnixMilli()
+	report.TotalMilli = report.FinishedAtUnix - report.StartedAtUnix
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	log.Printf("Databases: MySQL: Finish: Report: %s", reportJSON)
+	_, err = db.Exec("INSERT INTO reports_dataset (data) VALUES (?)", reportJSON)
+	if err != nil {
+		return err
+	}
+
+	if report.Cancelled {
+		log.Printf("%s process cancelled for database ID: %v after %d repositories", dbConfig.DBType, dbConfig.ID, report.Counter.Repos)
+		return ctx.Err()
+	}
+
+	log.Printf("%s process complete for database ID: %v", dbConfig.DBType, dbConfig.ID)
+
+	return nil
+}
+
+// writeDetails adds the issues, reviews, review comments, commits and the new users of the repository to their batches.
+func writeDetails(data app.RepoData, issues, reviews, reviewComments, commits, users *tableBatch, seenUsers map[int64]bool, counter *app.ReportCounter) error {
+	repoName := data.Repo.GetName()
+
+	for _, issue := range data.Issues {
+		if err := issues.addJSON(issue, issue.GetID(), repoName); err != nil {
+			return err
+		}
+	}
+	for _, review := range data.Reviews {
+		if err := reviews.addJSON(review, review.GetID(), repoName, app.PullNumber(review.GetPullRequestURL())); err != nil {
+			return err
+		}
+	}
+	for _, comment := range data.ReviewComments {
+		if err := reviewComments.addJSON(comment, comment.GetID(), repoName, app.PullNumber(comment.GetPullRequestURL())); err != nil {
+			return err
+		}
+	}
+	for _, commit := range data.Commits {
+		if err := commits.addJSON(commit, commit.GetSHA(), repoName); err != nil {
+			return err
+		}
+	}
+
+	// Users are written once per import, they are the authors of the entities of many repositories
+	newUsers := 0
+	for _, user := range data.Users() {
+		if seenUsers[user.GetID()] {
+			continue
+		}
+		seenUsers[user.GetID()] = true
+		newUsers++
+		if err := users.addJSON(user, user.GetID(), user.GetLogin()); err != nil {
+			return err
+		}
+	}
+
+	counter.Issues += len(data.Issues)
+	counter.Reviews += len(data.Reviews)
+	counter.ReviewComments += len(data.ReviewComments)
+	counter.Commits += len(data.Commits)
+	counter.Users += newUsers
+
+	return nil
+}
+
+// GetDatasetInfo retrieves the dataset information from a MySQL database.
+func GetDatasetInfo(connectionString string) (app.DatasetInfo, error) {
+	my, err := ConnectByString(connectionString)
+	if err != nil {
+		log.Printf("Error: Dataset: MySQL: Connect: %s", err)
+		return app.DatasetInfo{}, err
+	}
+	defer my.Close()
+
+	dbName := ""
+	err = my.QueryRow(`SELECT DATABASE();`).Scan(&dbName)
+	if err != nil {
+		log.Printf("Error: Dataset: MySQL: Getting MySQL DB name: %v", err)
+		dbName, err = GetDbName(connectionString)
+		if err != nil {
+			log.Printf("Error: Dataset: MySQL: Getting PostgreSQL DB name: %v", err)
+		}
+		return app.DatasetInfo{
+			DBName: dbName,
+		}, nil
+	} else {
+		mysql_pulls, err := SelectInt(my, `SELECT COUNT(*) FROM pulls;`)
+		if err != nil {
+			log.Printf("Error: Dataset: MySQL: %s: Pulls: %v", dbName, err)
+		}
+		mysql_repositories, err := SelectInt(my, `SELECT COUNT(*) FROM repositories;`)
+		if err != nil {
+			log.Printf("Error: Dataset: MySQL: %s: Repos: %v", dbName, err)
+		}
+
+		// Get the latest update from the reports_dataset table
+		var lastUpdate string
+		err = my.QueryRow(`
+			SELECT JSON_UNQUOTE(JSON_EXTRACT(data, '$.finished_at'))
+			FROM reports_dataset
+			ORDER BY JSON_UNQUOTE(JSON_EXTRACT(data, '$.finished_at')) DESC
+			LIMIT 1
+		`).Scan(&lastUpdate)
+		if err != nil {
+			log.Printf("Error: Dataset: MySQL: %s: Last update: %v", dbName, err)
+		}
+
+		// The entity tables are missing in databases created before they were added until the next import
+		counts := make(map[string]int)
+		for _, table := range []string{"issues", "reviews", "review_comments", "commits", "users"} {
+			counts[table], err = SelectInt(my, fmt.Sprintf("SELECT COUNT(*) FROM %s;", table))
+			if err != nil {
+				log.Printf("Error: Dataset: MySQL: %s: %s: %v", dbName, table, err)
+			}
+		}
+
+		return app.DatasetInfo{
+			DBName:         dbName,
+			Repositories:   mysql_repositories,
+			PullRequests:   mysql_pulls,
+			Issues:         counts["issues"],
+			Reviews:        counts["reviews"],
+			ReviewComments: counts["review_comments"],
+			Commits:        counts["commits"],
+			Users:          counts["users"],
+			LastUpdate:     lastUpdate,
+		}, nil
+	}
+}
@@ -0,0 +1,96 @@
+package mysql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	app "github-stat/internal"
+
+	"github.com/google/go-github/github"
+)
+
+// GetPullDigest reads the IDs and the update times of all pull requests into a digest by repository.
+//
+// Arguments:
+//   - dbConfig: app.DatabaseConfig containing the database configuration.
+//
+// Returns:
+//   - app.DatasetDigest: The digests of the pull requests by repository name.
+//   - error: An error object if an error occurs, otherwise nil.
+func GetPullDigest(dbConfig app.DatabaseConfig) (app.DatasetDigest, error) {
+	db, err := ConnectByString(dbConfig.ConnectionString)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT repo, id, JSON_UNQUOTE(JSON_EXTRACT(data, '$.updated_at')) FROM pulls;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	digest := make(app.DatasetDigest)
+	for rows.Next() {
+		var repo string
+		var id int64
+		var updatedAt sql.NullString
+		if err := rows.Scan(&repo, &id, &updatedAt); err != nil {
+			return nil, err
+		}
+		// JSON null is unquoted as the string "null", it is parsed as the zero time like a missing update time in the dataset
+		updated, _ := time.Parse(time.RFC3339, updatedAt.String)
+		digest.Add(repo, id, updated)
+	}
+	return digest, rows.Err()
+}
+
+// GetPulls reads pull requests of a repository by their IDs.
+//
+// Arguments:
+//   - dbConfig: app.DatabaseConfig containing the database configuration.
+//   - repo: string containing the repository name.
+//   - ids: []int64 containing the IDs of the pull requests.
+//
+// Returns:
+//   - []*github.PullRequest: The pull requests found, missing ones are skipped.
+//   - error: An error object if an error occurs, otherwise nil.
+func GetPulls(dbConfig app.DatabaseConfig, repo string, ids []int64) ([]*github.PullRequest, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	db, err := ConnectByString(dbConfig.ConnectionString)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	args := []interface{}{repo}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	query := "SELECT data FROM pulls WHERE repo = ? AND id IN " + rowPlaceholders(len(ids), 1) + ";"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pullRequests []*github.PullRequest
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		var pr github.PullRequest
+		if err := json.Unmarshal([]byte(data), &pr); err != nil {
+			return nil, err
+		}
+		pullRequests = append(pullRequests, &pr)
+	}
+	return pullRequests, rows.Err()
+}
@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// copyUpsert writes the rows with COPY into a temporary staging table and merges them
+// into the table with one INSERT ... ON CONFLICT DO UPDATE statement.
+// The statement returns xmax = 0 for inserted rows, which tells inserts from updates.
+//
+// Arguments:
+//   - db: *sql.DB connection to the PostgreSQL database.
+//   - table: string containing the name of the table with the schema, e.g. "github.pulls".
+//   - columns: []string containing the columns of every row.
+//   - keys: []string containing the primary key columns, the other columns are updated.
+//   - rows: [][]interface{} containing the values in the order of the columns.
+//
+// Returns:
+//   - int: The number of inserted rows.
+//   - int: The number of updated rows.
+//   - error: An error object if an error occurs, otherwise nil.
+func copyUpsert(db *sql.DB, table string, columns []string, keys []string, rows [][]interface{}) (int, int, error) {
+	if len(rows) == 0 {
+		return 0, 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	// The staging table is dropped at the end of the transaction
+	staging := "staging_" + table[strings.LastIndex(table, ".")+1:]
+	_, err = tx.Exec(fmt.Sprintf("CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP", staging, table))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn(staging, columns...))
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			stmt.Close()
+			return 0, 0, err
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return 0, 0, err
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, 0, err
+	}
+
+	// Merge the staging table into the table
+	var updates []string
+	for _, column := range columns {
+		if !slices.Contains(keys, column) {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+		}
+	}
+	columnList := strings.Join(columns, ", ")
+	query := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s ON CONFLICT (%s) DO UPDATE SET %s RETURNING (xmax = 0)",
+		table, columnList, columnList, staging, strings.Join(keys, ", "), strings.Join(updates, ", "))
+
+	result, err := tx.Query(query)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer result.Close()
+
+	var inserted, updated int
+	for result.Next() {
+		var isInsert bool
+		if err := result.Scan(&isInsert); err != nil {
+			return 0, 0, err
+		}
+		if isInsert {
+			inserted++
+		} else {
+			updated++
+		}
+	}
+	if err := result.Err(); err != nil {
+		return 0, 0, err
+	}
+	result.Close()
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+
+	return inserted, updated, nil
+}
+
+// tableBatch collects the rows of a table and writes them with copyUpsert when the batch is full.
+type tableBatch struct {
+	db      *sql.DB
+	table   string
+	columns []string // The last column holds the entity in JSON
+	keys    []string
+	size    int
+	rows    [][]interface{}
+}
+
+// addJSON adds a row with the values of the other columns and the entity in JSON.
+func (b *tableBatch) addJSON(entity interface{}, values ...interface{}) error {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+
+	// COPY sends []byte as bytea, JSON is passed as text
+	b.rows = append(b.rows, append(values, string(data)))
+	if len(b.rows) >= b.size {
+		return b.flush()
+	}
+	return nil
+}
+
+// flush writes the collected rows.
+func (b *tableBatch) flush() error {
+	if _, _, err := copyUpsert(b.db, b.table, b.columns, b.keys, b.rows); err != nil {
+		return fmt.Errorf("%s: %w", b.table, err)
+	}
+	b.rows = b.rows[:0]
+	return nil
+}
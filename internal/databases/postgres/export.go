@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+
+	app "github-stat/internal"
+
+	"github.com/google/go-github/github"
+)
+
+// ExportDataset reads all repositories and then all pull requests row by row and writes them into w.
+//
+// Arguments:
+//   - ctx: context.Context of the export, the queries are cancelled with it.
+//   - dbConfig: app.DatabaseConfig containing the database configuration.
+//   - w: app.DatasetWriter receiving the repositories and pull requests.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func ExportDataset(ctx context.Context, dbConfig app.DatabaseConfig, w app.DatasetWriter) error {
+	db, err := ConnectByString(dbConfig.ConnectionString)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	repos, err := db.QueryContext(ctx, "SELECT data FROM github.repositories ORDER BY id;")
+	if err != nil {
+		return err
+	}
+	defer repos.Close()
+
+	for repos.Next() {
+		var data string
+		if err := repos.Scan(&data); err != nil {
+			return err
+		}
+		var repo github.Repository
+		if err := json.Unmarshal([]byte(data), &repo); err != nil {
+			return err
+		}
+		if err := w.WriteRepo(&repo); err != nil {
+			return err
+		}
+	}
+	if err := repos.Err(); err != nil {
+		return err
+	}
+
+	pulls, err := db.QueryContext(ctx, "SELECT repo, data FROM github.pulls;")
+	if err != nil {
+		return err
+	}
+	defer pulls.Close()
+
+	for pulls.Next() {
+		var repoName, data string
+		if err := pulls.Scan(&repoName, &data); err != nil {
+			return err
+		}
+		var pull github.PullRequest
+		if err := json.Unmarshal([]byte(data), &pull); err != nil {
+			return err
+		}
+		if err := w.WritePull(repoName, &pull); err != nil {
+			return err
+		}
+	}
+	return pulls.Err()
+}
@@ -0,0 +1,422 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	app "github-stat/internal"
+)
+
+// migration is a numbered change of the test schema, Down reverts Up.
+// A released migration is never changed, a schema change is added as a new migration.
+type migration struct {
+	Version int
+	Name    string
+	Mode    string // Schema mode the migration belongs to, empty for all modes
+	Up      string
+	Down    string
+}
+
+// migrations are applied in the order of their versions, the applied ones are recorded in github.schema_migrations.
+// Databases created before the migrations were added have the tables already, so the statements
+// of the first migrations must not fail on existing tables and indexes.
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "Create the repositories, pulls and reports tables",
+		Up: `
+		CREATE TABLE IF NOT EXISTS github.repositories (
+			id SERIAL PRIMARY KEY,
+			data JSONB
+		);
+		CREATE TABLE IF NOT EXISTS github.repositories_test (
+			id SERIAL PRIMARY KEY,
+			data JSONB
+		);
+		CREATE TABLE IF NOT EXISTS github.pulls (
+			id BIGINT NOT NULL,
+			repo VARCHAR(255) NOT NULL,
+			data JSON,
+			PRIMARY KEY (id, repo)
+		);
+		CREATE TABLE IF NOT EXISTS github.pulls_test (
+			id BIGINT NOT NULL,
+			repo VARCHAR(255) NOT NULL,
+			data JSON,
+			PRIMARY KEY (id, repo)
+		);
+		CREATE TABLE IF NOT EXISTS github.reports_dataset (
+			id SERIAL PRIMARY KEY,
+			data JSONB
+		);
+		CREATE INDEX IF NOT EXISTS idx_id_pulls ON github.pulls (id);
+		CREATE INDEX IF NOT EXISTS idx_repo_pulls ON github.pulls (repo);
+		CREATE INDEX IF NOT EXISTS idx_id_pulls_test ON github.pulls_test (id);
+		CREATE INDEX IF NOT EXISTS idx_repo_pulls_test ON github.pulls_test (repo);
+		`,
+		Down: `
+		DROP TABLE IF EXISTS github.repositories, github.repositories_test, github.pulls, github.pulls_test, github.reports_dataset;
+		`,
+	},
+	{
+		Version: 2,
+		Name:    "Create the issues, reviews, review comments, commits and users tables",
+		Up:      entityTablesSQL,
+		Down: `
+		DROP TABLE IF EXISTS github.issues, github.reviews, github.review_comments, github.commits, github.users;
+		`,
+	},
+	{
+		Version: 3,
+		Name:    "Add the normalized columns of the repositories and pulls",
+		Mode:    app.SchemaModeNormalized,
+		// Casts to timestamptz are not immutable because of the session settings, GitHub timestamps are
+		// in UTC with the "Z" suffix, so github.iso_timestamp does not depend on them
+		Up: `
+		CREATE OR REPLACE FUNCTION github.iso_timestamp(value text) RETURNS timestamptz
+			LANGUAGE sql IMMUTABLE RETURNS NULL ON NULL INPUT
+			AS $$ SELECT value::timestamptz $$;
+		ALTER TABLE github.pulls
+			ADD COLUMN state VARCHAR(16) GENERATED ALWAYS AS (data->>'state') STORED,
+			ADD COLUMN created_at TIMESTAMPTZ GENERATED ALWAYS AS (github.iso_timestamp(data->>'created_at')) STORED,
+			ADD COLUMN merged_at TIMESTAMPTZ GENERATED ALWAYS AS (github.iso_timestamp(data->>'merged_at')) STORED,
+			ADD COLUMN user_login VARCHAR(255) GENERATED ALWAYS AS (data->'user'->>'login') STORED,
+			ADD COLUMN additions INT GENERATED ALWAYS AS ((data->>'additions')::int) STORED,
+			ADD COLUMN deletions INT GENERATED ALWAYS AS ((data->>'deletions')::int) STORED;
+		CREATE INDEX idx_state_created_at_pulls ON github.pulls (state, created_at);
+		CREATE INDEX idx_created_at_pulls ON github.pulls (created_at);
+		CREATE INDEX idx_merged_at_pulls ON github.pulls (merged_at);
+		CREATE INDEX idx_user_login_pulls ON github.pulls (user_login);
+		ALTER TABLE github.repositories
+			ADD COLUMN stargazers INT GENERATED ALWAYS AS ((data->>'stargazers_count')::int) STORED;
+		CREATE INDEX idx_stargazers_repositories ON github.repositories (stargazers);
+		`,
+		Down: `
+		ALTER TABLE github.pulls
+			DROP COLUMN state, DROP COLUMN created_at, DROP COLUMN merged_at,
+			DROP COLUMN user_login, DROP COLUMN additions, DROP COLUMN deletions;
+		ALTER TABLE github.repositories DROP COLUMN stargazers;
+		DROP FUNCTION github.iso_timestamp(text);
+		`,
+	},
+}
+
+const migrationsTableSQL = `
+		CREATE SCHEMA IF NOT EXISTS github;
+		CREATE TABLE IF NOT EXISTS github.schema_migrations (
+			version INT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+		CREATE TABLE IF NOT EXISTS github.schema_settings (
+			name VARCHAR(64) PRIMARY KEY,
+			value VARCHAR(255) NOT NULL
+		);
+`
+
+// Names of the schema settings.
+const (
+	// settingPinnedVersion is the version the schema was reverted to by Migrate Schema, the imports do not migrate it.
+	settingPinnedVersion = "pinned_version"
+
+	// settingMode is the schema mode of the applied migrations.
+	settingMode = "mode"
+)
+
+// belongsTo reports whether the migration is applied in the schema mode.
+func (m migration) belongsTo(mode string) bool {
+	return m.Mode == "" || m.Mode == mode
+}
+
+// LatestSchemaVersion returns the version of the last migration of the schema mode, an empty mode is the JSON mode.
+func LatestSchemaVersion(mode string) int {
+	mode = modeOrDefault(mode)
+	latest := 0
+	for _, m := range migrations {
+		if m.belongsTo(mode) {
+			latest = m.Version
+		}
+	}
+	return latest
+}
+
+// modeOrDefault returns the schema mode, app.SchemaModeJSON if it is empty.
+func modeOrDefault(mode string) string {
+	if mode == "" {
+		return app.SchemaModeJSON
+	}
+	return mode
+}
+
+// GetSchemaVersion returns the migration versions and the schema mode of the test database.
+//
+// Arguments:
+//   - connection_string: string containing the connection string of the test database.
+//
+// Returns:
+//   - app.SchemaVersion: The applied and the latest versions of the schema mode.
+//   - error: An error object if an error occurs, otherwise nil.
+func GetSchemaVersion(connection_string string) (app.SchemaVersion, error) {
+	db, err := ConnectByString(connection_string)
+	if err != nil {
+		return app.SchemaVersion{}, err
+	}
+	defer db.Close()
+
+	return readSchemaVersion(db)
+}
+
+// MigrateSchema applies or reverts the migrations until the test database is at the version of the schema mode.
+// A mode other than the one of the database switches it: the migrations of the old mode are reverted
+// and the ones of the new mode are applied.
+//
+// Arguments:
+//   - connection_string: string containing the connection string of the test database.
+//   - version: int containing the target version, 0 reverts all migrations, app.LatestMigration applies all of the mode.
+//   - mode: string containing the schema mode, the migrations of the other modes are not applied.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func MigrateSchema(connection_string string, version int, mode string) error {
+	db, err := ConnectByString(connection_string)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if version == app.LatestMigration {
+		version = LatestSchemaVersion(mode)
+	}
+	return migrate(db, version, mode)
+}
+
+// appliedVersions returns the versions recorded in github.schema_migrations, none if the table does not exist.
+func appliedVersions(db *sql.DB) (map[int]bool, error) {
+	applied := make(map[int]bool)
+
+	var exists bool
+	if err := db.QueryRow("SELECT to_regclass('github.schema_migrations') IS NOT NULL;").Scan(&exists); err != nil || !exists {
+		return applied, err
+	}
+
+	rows, err := db.Query("SELECT version FROM github.schema_migrations;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// schemaMode returns the schema mode of the test database.
+// Databases migrated by older releases do not store it, their mode is detected from the normalized columns.
+func schemaMode(db *sql.DB) (string, error) {
+	mode, err := schemaSetting(db, settingMode)
+	if err != nil || mode != "" {
+		return mode, err
+	}
+
+	var normalized bool
+	err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = 'github' AND table_name = 'pulls' AND column_name = 'state');").Scan(&normalized)
+	if err != nil {
+		return "", err
+	}
+	if normalized {
+		return app.SchemaModeNormalized, nil
+	}
+	return app.SchemaModeJSON, nil
+}
+
+// readSchemaVersion returns the applied and the latest versions of the schema mode of the database
+// and whether the schema was reverted by Migrate Schema.
+func readSchemaVersion(db *sql.DB) (app.SchemaVersion, error) {
+	mode, err := schemaMode(db)
+	if err != nil {
+		return app.SchemaVersion{}, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return app.SchemaVersion{}, err
+	}
+	pinned, err := schemaSetting(db, settingPinnedVersion)
+	if err != nil {
+		return app.SchemaVersion{}, err
+	}
+
+	version := 0
+	for _, m := range migrations {
+		if applied[m.Version] && m.belongsTo(mode) {
+			version = m.Version
+		}
+	}
+	return app.SchemaVersion{Version: version, Latest: LatestSchemaVersion(mode), Mode: mode, Pinned: pinned != ""}, nil
+}
+
+// upgradeSchema applies the pending migrations of the schema mode of the database before an import,
+// e.g. of a database created by an older release.
+// It returns an *app.SchemaBehindError if the migrations were reverted by Migrate Schema.
+func upgradeSchema(db *sql.DB) error {
+	version, err := readSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if err := version.Check(); err != nil {
+		return err
+	}
+
+	stored, err := schemaSetting(db, settingMode)
+	if err != nil {
+		return err
+	}
+	if !version.Behind() && stored != "" {
+		return nil
+	}
+
+	// A database of an older release is migrated also if it is at the latest version,
+	// so its mode is stored and the records of the migrations of the other mode are removed
+	if version.Behind() {
+		log.Printf("PostgreSQL: Migrating the schema from version %d to %d before the import", version.Version, version.Latest)
+	}
+	return migrate(db, version.Latest, version.Mode)
+}
+
+// schemaSetting returns a value of github.schema_settings, empty if it is not set.
+func schemaSetting(db *sql.DB, name string) (string, error) {
+	var exists bool
+	if err := db.QueryRow("SELECT to_regclass('github.schema_settings') IS NOT NULL;").Scan(&exists); err != nil || !exists {
+		return "", err
+	}
+
+	var value string
+	err := db.QueryRow("SELECT value FROM github.schema_settings WHERE name = $1;", name).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+// setSchemaSetting stores a value in github.schema_settings, an empty value removes it.
+func setSchemaSetting(db *sql.DB, name string, value string) error {
+	if value == "" {
+		_, err := db.Exec("DELETE FROM github.schema_settings WHERE name = $1;", name)
+		return err
+	}
+	_, err := db.Exec("INSERT INTO github.schema_settings (name, value) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value;", name, value)
+	return err
+}
+
+// migrationPlan returns the changes migrate makes to move a database from the applied migrations
+// in the current mode to the version of the mode:
+//   - revert: the applied migrations to revert, in the reverse order.
+//   - forget: the records of the migrations of another mode that older releases recorded without running them.
+//   - apply: the migrations to apply, in the order of their versions.
+//
+// A mode switch applies the migrations of the new mode after the later migrations of all modes,
+// so a migration of a mode must not depend on them.
+func migrationPlan(applied map[int]bool, current string, version int, mode string) (revert, forget, apply []migration) {
+	// The migrations of another mode recorded by older releases did not run, they are not applied
+	ran := func(m migration) bool {
+		return applied[m.Version] && m.belongsTo(current)
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if applied[m.Version] && !ran(m) {
+			forget = append(forget, m)
+		} else if ran(m) && (m.Version > version || !m.belongsTo(mode)) {
+			revert = append(revert, m)
+		}
+	}
+
+	for _, m := range migrations {
+		if !ran(m) && m.Version <= version && m.belongsTo(mode) {
+			apply = append(apply, m)
+		}
+	}
+	return revert, forget, apply
+}
+
+// migrate applies the migrations of the schema mode up to the version and reverts the other ones.
+// Only the migrations that ran are recorded, the mode is stored in github.schema_settings.
+// A version below the latest of the mode pins the schema, so the imports do not apply the reverted migrations again.
+// Every migration runs in a transaction together with its record in github.schema_migrations.
+func migrate(db *sql.DB, version int, mode string) error {
+	mode = modeOrDefault(mode)
+	latest := LatestSchemaVersion(mode)
+	if version < 0 || version > latest {
+		return fmt.Errorf("invalid schema version %d, the latest of the %s mode is %d", version, mode, latest)
+	}
+
+	if err := executeSQL(db, migrationsTableSQL); err != nil {
+		return err
+	}
+
+	current, err := schemaMode(db)
+	if err != nil {
+		return err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return err
+	}
+
+	revert, forget, apply := migrationPlan(applied, current, version, mode)
+
+	for _, m := range revert {
+		log.Printf("PostgreSQL: Migration %d: Revert: %s", m.Version, m.Name)
+		if err := runMigration(db, m.Down, "DELETE FROM github.schema_migrations WHERE version = $1;", m.Version); err != nil {
+			return fmt.Errorf("migration %d: revert: %w", m.Version, err)
+		}
+	}
+
+	for _, m := range forget {
+		log.Printf("PostgreSQL: Migration %d: Removing the record, it was not applied in the %s schema mode", m.Version, current)
+		if _, err := db.Exec("DELETE FROM github.schema_migrations WHERE version = $1;", m.Version); err != nil {
+			return fmt.Errorf("migration %d: %w", m.Version, err)
+		}
+	}
+
+	for _, m := range apply {
+		log.Printf("PostgreSQL: Migration %d: %s", m.Version, m.Name)
+		if err := runMigration(db, m.Up, "INSERT INTO github.schema_migrations (version, name) VALUES ($1, $2);", m.Version, m.Name); err != nil {
+			return fmt.Errorf("migration %d: %w", m.Version, err)
+		}
+	}
+
+	if err := setSchemaSetting(db, settingMode, mode); err != nil {
+		return err
+	}
+
+	pinned := ""
+	if version < latest {
+		pinned = strconv.Itoa(version)
+	}
+	return setSchemaSetting(db, settingPinnedVersion, pinned)
+}
+
+// runMigration executes the statements of a migration and the query recording it in one transaction.
+func runMigration(db *sql.DB, statements string, record string, args ...interface{}) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(statements); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
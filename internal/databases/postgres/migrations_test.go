@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+
+	app "github-stat/internal"
+)
+
+func TestMigrationPlan(t *testing.T) {
+	json, normalized := app.SchemaModeJSON, app.SchemaModeNormalized
+	applied := func(versions ...int) map[int]bool {
+		set := make(map[int]bool)
+		for _, v := range versions {
+			set[v] = true
+		}
+		return set
+	}
+
+	tests := []struct {
+		name    string
+		applied map[int]bool
+		current string
+		version int
+		mode    string
+		revert  []int
+		forget  []int
+		apply   []int
+	}{
+		{name: "new JSON schema", applied: applied(), current: json, version: 2, mode: json, apply: []int{1, 2}},
+		{name: "new normalized schema", applied: applied(), current: json, version: 3, mode: normalized, apply: []int{1, 2, 3}},
+		{name: "JSON schema at the latest", applied: applied(1, 2), current: json, version: 2, mode: json},
+		{name: "JSON schema recorded by an older release", applied: applied(1, 2, 3), current: json, version: 2, mode: json, forget: []int{3}},
+		{name: "switch from JSON to normalized", applied: applied(1, 2), current: json, version: 3, mode: normalized, apply: []int{3}},
+		{name: "switch of a schema recorded by an older release", applied: applied(1, 2, 3), current: json, version: 3, mode: normalized, forget: []int{3}, apply: []int{3}},
+		{name: "switch from normalized to JSON", applied: applied(1, 2, 3), current: normalized, version: 2, mode: json, revert: []int{3}},
+		{name: "revert the normalized columns", applied: applied(1, 2, 3), current: normalized, version: 2, mode: normalized, revert: []int{3}},
+		{name: "revert all", applied: applied(1, 2, 3), current: normalized, version: 0, mode: normalized, revert: []int{3, 2, 1}},
+	}
+
+	versions := func(ms []migration) []int {
+		var vs []int
+		for _, m := range ms {
+			vs = append(vs, m.Version)
+		}
+		return vs
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revert, forget, apply := migrationPlan(tt.applied, tt.current, tt.version, tt.mode)
+			if got := versions(revert); !reflect.DeepEqual(got, tt.revert) {
+				t.Errorf("revert = %v, want %v", got, tt.revert)
+			}
+			if got := versions(forget); !reflect.DeepEqual(got, tt.forget) {
+				t.Errorf("forget = %v, want %v", got, tt.forget)
+			}
+			if got := versions(apply); !reflect.DeepEqual(got, tt.apply) {
+				t.Errorf("apply = %v, want %v", got, tt.apply)
+			}
+		})
+	}
+}
+
+func TestLatestSchemaVersion(t *testing.T) {
+	tests := []struct {
+		mode string
+		want int
+	}{
+		{mode: "", want: 2},
+		{mode: app.SchemaModeJSON, want: 2},
+		{mode: app.SchemaModeNormalized, want: 3},
+	}
+
+	for _, tt := range tests {
+		if got := LatestSchemaVersion(tt.mode); got != tt.want {
+			t.Errorf("LatestSchemaVersion(%q) = %d, want %d", tt.mode, got, tt.want)
+		}
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"strings"
 	"time"
 
+	app "github-stat/internal"
+
 	"github.com/google/go-github/github"
 	_ "github.com/lib/pq"
 )
@@ -191,7 +193,8 @@ func executeSQL(db *sql.DB, query string) error {
 	return fmt.Errorf("reached maximum retry limit for query: %s", query)
 }
 
-func InitSchema(connection_string string) error {
+// InitSchema creates the test database if it does not exist and applies all migrations of the schema mode.
+func InitSchema(connection_string string, mode string) error {
 
 	newDBName, err := GetDbName(connection_string)
 	if err != nil {
@@ -231,61 +234,75 @@ func InitSchema(connection_string string) error {
 	defer newDB.Close()
 
 	log.Printf("Creating schemas and tables in database %s", newDBName)
-	schemaSQL := `
-        CREATE EXTENSION IF NOT EXISTS pg_stat_monitor;
-        CREATE SCHEMA IF NOT EXISTS github;
-        CREATE TABLE IF NOT EXISTS github.repositories (
-            id SERIAL PRIMARY KEY,
-            data JSONB
-        );
-        CREATE TABLE IF NOT EXISTS github.repositories_test (
-            id SERIAL PRIMARY KEY,
-            data JSONB
-        );
-		CREATE TABLE IF NOT EXISTS github.pulls (
+
+	// pg_stat_monitor is optional, the queries are collected by PMM if it is installed
+	if err := executeSQL(newDB, "CREATE EXTENSION IF NOT EXISTS pg_stat_monitor;"); err != nil {
+		log.Printf("Error creating the pg_stat_monitor extension in %s: %s", newDBName, err)
+	}
+
+	if err := migrate(newDB, LatestSchemaVersion(mode), mode); err != nil {
+		log.Printf("Error migrating database %s: %s", newDBName, err)
+		return err
+	}
+
+	return nil
+}
+
+// entityTablesSQL creates the tables of the entities besides repositories and pull requests, migration 2.
+const entityTablesSQL = `
+		CREATE TABLE IF NOT EXISTS github.issues (
 			id BIGINT NOT NULL,
 			repo VARCHAR(255) NOT NULL,
-			data JSON,
+			data JSONB,
 			PRIMARY KEY (id, repo)
 		);
-		CREATE TABLE IF NOT EXISTS github.pulls_test (
+		CREATE TABLE IF NOT EXISTS github.reviews (
 			id BIGINT NOT NULL,
 			repo VARCHAR(255) NOT NULL,
-			data JSON,
+			pull INT NOT NULL,
+			data JSONB,
 			PRIMARY KEY (id, repo)
 		);
-        CREATE TABLE IF NOT EXISTS github.reports_dataset (
-            id SERIAL PRIMARY KEY,
-            data JSONB
-        );
-		CREATE INDEX idx_id_pulls ON github.pulls (id);
-		CREATE INDEX idx_repo_pulls ON github.pulls (repo);
-		CREATE INDEX idx_id_pulls_test ON github.pulls_test (id);
-		CREATE INDEX idx_repo_pulls_test ON github.pulls_test (repo);
-    `
-	err = executeSQL(newDB, schemaSQL)
-	if err != nil {
-		log.Printf("Error creating schemas and tables in %s: %s", newDBName, err)
-	}
-
-	return nil
-}
+		CREATE TABLE IF NOT EXISTS github.review_comments (
+			id BIGINT NOT NULL,
+			repo VARCHAR(255) NOT NULL,
+			pull INT NOT NULL,
+			data JSONB,
+			PRIMARY KEY (id, repo)
+		);
+		CREATE TABLE IF NOT EXISTS github.commits (
+			sha CHAR(40) NOT NULL,
+			repo VARCHAR(255) NOT NULL,
+			data JSONB,
+			PRIMARY KEY (sha, repo)
+		);
+		CREATE TABLE IF NOT EXISTS github.users (
+			id BIGINT PRIMARY KEY,
+			login VARCHAR(255) NOT NULL,
+			data JSONB
+		);
+		CREATE INDEX IF NOT EXISTS idx_repo_issues ON github.issues (repo);
+		CREATE INDEX IF NOT EXISTS idx_repo_pull_reviews ON github.reviews (repo, pull);
+		CREATE INDEX IF NOT EXISTS idx_repo_pull_review_comments ON github.review_comments (repo, pull);
+		CREATE INDEX IF NOT EXISTS idx_repo_commits ON github.commits (repo);
+		CREATE INDEX IF NOT EXISTS idx_login_users ON github.users (login);
+`
 
 // getLatestUpdatesFromPostgres retrieves the latest update times for each repository from a PostgreSQL database.
 // It connects to the PostgreSQL database using the provided connection string and queries the update times.
 //
 // Arguments:
-//   - dbConfig: map[string]string containing the database configuration,
+//   - dbConfig: app.DatabaseConfig containing the database configuration,
 //     including the connection string under the key "connectionString".
 //
 // Returns:
 //   - map[string]string: A map where keys are repository names and values are the latest update times.
 //   - error: An error object if an error occurs, otherwise nil.
-func GetPullsLatestUpdates(dbConfig map[string]string) (map[string]string, error) {
+func GetPullsLatestUpdates(dbConfig app.DatabaseConfig) (map[string]string, error) {
 	ctx := context.Background()
 
 	// Connect to the PostgreSQL database.
-	db, err := ConnectByString(dbConfig["connectionString"])
+	db, err := ConnectByString(dbConfig.ConnectionString)
 	if err != nil {
 		log.Printf("Check Pulls Latest Updates: PostgreSQL: Error: %s", err)
 		return nil, err
@@ -389,3 +406,303 @@ func GetDbName(connStr string) (string, error) {
 	}
 	return "", errors.New("dbname not found in connection string")
 }
+
+// ImportDataset imports repository and pull request data into a PostgreSQL database.
+// It connects to the PostgreSQL database using the provided connection string,
+// fetches the latest update times for each repository, and then updates the database
+// with new or updated repositories and pull requests based on their last update time.
+// Issues, reviews, review comments, commits and their authors (users) are upserted completely.
+// Rows are written in batches of dataset.WriteBatchSize() with COPY into a staging table.
+//
+// Arguments:
+//   - ctx: context.Context cancelling the import between two repositories.
+//   - dbConfig: app.DatabaseConfig containing the database configuration,
+//     including the connection string under the key "connectionString".
+//   - dataset: app.Dataset containing the source of the repositories and pull requests to import.
+//
+// Returns:
+//   - error: An error object if an error occurs, the error of ctx if the import is cancelled, otherwise nil.
+func ImportDataset(ctx context.Context, dbConfig app.DatabaseConfig, dataset app.Dataset) error {
+
+	log.Printf("%s process start: %v", dbConfig.DBType, dbConfig.ID)
+
+	// Initialize the report for tracking the import process.
+	report := app.ReportDatabases{
+		Type:          "GitHub Pulls",
+		DB:            "PostgreSQL",
+		StartedAt:     time.Now().Format("2006-01-02T15:04:05.000"),
+		StartedAtUnix: time.Now().UnixMilli(),
+	}
+
+	// Connect to the PostgreSQL database.
+	db, err := ConnectByString(dbConfig.ConnectionString)
+	if err != nil {
+		log.Printf("Databases: PostgreSQL: Start: Error: %s", err)
+		return err
+	}
+	defer db.Close()
+
+	log.Printf("Databases: PostgreSQL: Start")
+
+	// Pending migrations are applied, unless they were reverted by Migrate Schema
+	if err := upgradeSchema(db); err != nil {
+		log.Printf("Databases: PostgreSQL: Error: Migrating the schema: %v", err)
+		return err
+	}
+
+	// Get the latest update times for each repository from the PostgreSQL database.
+	pullsLastUpdate, err := GetPullsLatestUpdates(dbConfig)
+	if err != nil {
+		log.Printf("Error getting latest updates: %v", err)
+		return err
+	}
+
+	// Rows are collected and written in batches with COPY.
+	batchSize := dataset.WriteBatchSize()
+	var repoRows, pullRows [][]interface{}
+
+	writeRepos := func() error {
+		if _, _, err := copyUpsert(db, "github.repositories", []string{"id", "data"}, []string{"id"}, repoRows); err != nil {
+			return err
+		}
+		repoRows = repoRows[:0]
+		return nil
+	}
+
+	writePulls := func() error {
+		inserted, updated, err := copyUpsert(db, "github.pulls", []string{"id", "repo", "data"}, []string{"id", "repo"}, pullRows)
+		if err != nil {
+			return err
+		}
+		report.Counter.PullsInserted += inserted
+		report.Counter.PullsUpdated += updated
+		pullRows = pullRows[:0]
+		return nil
+	}
+
+	issues := &tableBatch{db: db, table: "github.issues", columns: []string{"id", "repo", "data"}, keys: []string{"id", "repo"}, size: batchSize}
+	reviews := &tableBatch{db: db, table: "github.reviews", columns: []string{"id", "repo", "pull", "data"}, keys: []string{"id", "repo"}, size: batchSize}
+	reviewComments := &tableBatch{db: db, table: "github.review_comments", columns: []string{"id", "repo", "pull", "data"}, keys: []string{"id", "repo"}, size: batchSize}
+	commits := &tableBatch{db: db, table: "github.commits", columns: []string{"sha", "repo", "data"}, keys: []string{"sha", "repo"}, size: batchSize}
+	users := &tableBatch{db: db, table: "github.users", columns: []string{"id", "login", "data"}, keys: []string{"id"}, size: batchSize}
+	seenUsers := make(map[int64]bool)
+
+	// Iterate over all repositories and update the database with new or updated repositories and pull requests.
+	// The dataset is read one repository at a time.
+	err = dataset.Each(ctx, func(data app.RepoData) error {
+		repo, pullRequests := data.Repo, data.Pulls
+		report.Counter.Repos++
+		repoJSON, err := json.Marshal(repo)
+		if err != nil {
+			return err
+		}
+
+		// COPY sends []byte as bytea, JSON is passed as text
+		repoRows = append(repoRows, []interface{}{repo.ID, string(repoJSON)})
+		if len(repoRows) >= batchSize {
+			if err := writeRepos(); err != nil {
+				return err
+			}
+		}
+
+		if err := writeDetails(data, issues, reviews, reviewComments, commits, users, seenUsers, &report.Counter); err != nil {
+			return err
+		}
+
+		if len(pullRequests) == 0 {
+			report.Counter.ReposWithoutPRs++
+			return nil
+		}
+		report.Counter.ReposWithPRs++
+		repoName := *repo.Name
+
+		// Get the last update time for the current repository.
+		pullLastUpdate := pullsLastUpdate[repoName]
+		var lastUpdatedTime time.Time
+		if pullLastUpdate != "" {
+			lastUpdatedTime, err = time.Parse(time.RFC3339, pullLastUpdate)
+			if err != nil {
+				log.Printf("Error: PostgreSQL: lastUpdatedTime: parsing startedAt: %v", err)
+				lastUpdatedTime = time.Time{} // Reset lastUpdatedTime in case of error
+			}
+		}
+
+		// Iterate over all pull requests and collect new or updated pull requests.
+		for _, pull := range pullRequests {
+			// Skip processing if lastUpdatedTime is not empty and the pull request is older
+			if !lastUpdatedTime.IsZero() && pull.UpdatedAt != nil && lastUpdatedTime.After(*pull.UpdatedAt) {
+				continue
+			}
+
+			pullJSON, err := json.Marshal(pull)
+			if err != nil {
+				return err
+			}
+
+			pullRows = append(pullRows, []interface{}{pull.ID, repoName, string(pullJSON)})
+			if len(pullRows) >= batchSize {
+				if err := writePulls(); err != nil {
+					return err
+				}
+			}
+		}
+
+		report.Counter.Pulls += len(pullRequests)
+		return nil
+	})
+	// A cancelled import writes the collected rows, so the report shows the written part of the dataset
+	report.Cancelled = errors.Is(err, context.Canceled)
+	if err != nil && !report.Cancelled {
+		return err
+	}
+
+	// Write the remaining rows.
+	if err := writeRepos(); err != nil {
+		return err
+	}
+	if err := writePulls(); err != nil {
+		return err
+	}
+	for _, batch := range []*tableBatch{issues, reviews, reviewComments, commits, users} {
+		if err := batch.flush(); err != nil {
+			return err
+		}
+	}
+
+	// Finalize the report with end times and total duration.
+	report.FinishedAt = time.Now().Format("2006-01-02T15:04:05.000")
+	report.FinishedAtUnix = time.Now().UnixMilli()
+	report.TotalMilli = report.FinishedAtUnix - report.StartedAtUnix
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	log.Printf("Datasets: PostgreSQL: Finish: Report: %s", reportJSON)
+	_, err = db.Exec("INSERT INTO github.reports_dataset (data) VALUES ($1)", reportJSON)
+	if err != nil {
+		return err
+	}
+
+	if report.Cancelled {
+		log.Printf("%s process cancelled for database ID: %v after %d repositories", dbConfig.DBType, dbConfig.ID, report.Counter.Repos)
+		return ctx.Err()
+	}
+
+	log.Printf("%s process complete for database ID: %v", dbConfig.DBType, dbConfig.ID)
+
+	return nil
+}
+
+// writeDetails adds the issues, reviews, review comments, commits and the new users of the repository to their batches.
+// Users are written once per import, the rows of one COPY must not have the same key.
+func writeDetails(data app.RepoData, issues, reviews, reviewComments, commits, users *tableBatch, seenUsers map[int64]bool, counter *app.ReportCounter) error {
+	repoName := data.Repo.GetName()
+
+	for _, issue := range data.Issues {
+		if err := issues.addJSON(issue, issue.GetID(), repoName); err != nil {
+			return err
+		}
+	}
+	for _, review := range data.Reviews {
+		if err := reviews.addJSON(review, review.GetID(), repoName, app.PullNumber(review.GetPullRequestURL())); err != nil {
+			return err
+		}
+	}
+	for _, comment := range data.ReviewComments {
+		if err := reviewComments.addJSON(comment, comment.GetID(), repoName, app.PullNumber(comment.GetPullRequestURL())); err != nil {
+			return err
+		}
+	}
+	for _, commit := range data.Commits {
+		if err := commits.addJSON(commit, commit.GetSHA(), repoName); err != nil {
+			return err
+		}
+	}
+
+	newUsers := 0
+	for _, user := range data.Users() {
+		if seenUsers[user.GetID()] {
+			continue
+		}
+		seenUsers[user.GetID()] = true
+		newUsers++
+		if err := users.addJSON(user, user.GetID(), user.GetLogin()); err != nil {
+			return err
+		}
+	}
+
+	counter.Issues += len(data.Issues)
+	counter.Reviews += len(data.Reviews)
+	counter.ReviewComments += len(data.ReviewComments)
+	counter.Commits += len(data.Commits)
+	counter.Users += newUsers
+
+	return nil
+}
+
+// GetDatasetInfo retrieves the dataset information from a PostgreSQL database.
+func GetDatasetInfo(connectionString string) (app.DatasetInfo, error) {
+	pg, err := ConnectByString(connectionString)
+	if err != nil {
+		log.Printf("Error: Dataset: Postgres: Connect: %s", err)
+
+	}
+	defer pg.Close()
+
+	dbName := ""
+	err = pg.QueryRow(`SELECT current_database();`).Scan(&dbName)
+	if err != nil {
+		log.Printf("Error: Dataset: Postgres: Getting PostgreSQL DB name: %v", err)
+		dbName, err = GetDbName(connectionString)
+		if err != nil {
+			log.Printf("Error: Dataset: Postgres: Getting PostgreSQL DB name: %v", err)
+		}
+		return app.DatasetInfo{
+			DBName: dbName,
+		}, nil
+	} else {
+
+		pg_pulls, err := SelectInt(pg, `SELECT COUNT(*) FROM github.pulls;`)
+		if err != nil {
+			log.Printf("Error: Dataset: Postgres: %s: Pulls: %v", dbName, err)
+		}
+		pg_repositories, err := SelectInt(pg, `SELECT COUNT(*) FROM github.repositories;`)
+		if err != nil {
+			log.Printf("Error: Dataset: Postgres: %s: Repos: %v", dbName, err)
+		}
+
+		// Get the latest update from the reports_dataset table
+		var lastUpdate string
+		err = pg.QueryRow(`
+			SELECT data->>'finished_at'
+			FROM github.reports_dataset
+			ORDER BY data->>'finished_at' DESC
+			LIMIT 1
+		`).Scan(&lastUpdate)
+		if err != nil {
+			log.Printf("Error: Dataset: Postgres: %s: Last update: %v", dbName, err)
+		}
+
+		// The entity tables are missing in databases created before they were added until the next import
+		counts := make(map[string]int)
+		for _, table := range []string{"issues", "reviews", "review_comments", "commits", "users"} {
+			counts[table], err = SelectInt(pg, fmt.Sprintf("SELECT COUNT(*) FROM github.%s;", table))
+			if err != nil {
+				log.Printf("Error: Dataset: Postgres: %s: %s: %v", dbName, table, err)
+			}
+		}
+
+		return app.DatasetInfo{
+			DBName:         dbName,
+			Repositories:   pg_repositories,
+			PullRequests:   pg_pulls,
+			Issues:         counts["issues"],
+			Reviews:        counts["reviews"],
+			ReviewComments: counts["review_comments"],
+			Commits:        counts["commits"],
+			Users:          counts["users"],
+			LastUpdate:     lastUpdate,
+		}, nil
+	}
+
+}
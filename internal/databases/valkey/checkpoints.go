@@ -0,0 +1,68 @@
+package valkey
+
+import (
+	"encoding/json"
+	"log"
+
+	app "github-stat/internal"
+)
+
+// githubCheckpointsKey is a hash of the GitHub fetch checkpoints, by full repository name ("owner/repo").
+const githubCheckpointsKey = "github_checkpoints"
+
+// GetGitHubCheckpoints retrieves the GitHub fetch checkpoints of all repositories.
+//
+// Returns:
+//   - map[string]app.GitHubCheckpoint: The checkpoints by full repository name.
+//   - error: An error object if an error occurs, otherwise nil.
+func GetGitHubCheckpoints() (map[string]app.GitHubCheckpoint, error) {
+	fields, err := Valkey.HGetAll(githubCheckpointsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints := make(map[string]app.GitHubCheckpoint, len(fields))
+	for repo, data := range fields {
+		var checkpoint app.GitHubCheckpoint
+		if err := json.Unmarshal([]byte(data), &checkpoint); err != nil {
+			log.Printf("Valkey: GitHub checkpoint %s: Error: %v", repo, err)
+			continue
+		}
+		checkpoints[repo] = checkpoint
+	}
+
+	return checkpoints, nil
+}
+
+// SaveGitHubCheckpoint saves the GitHub fetch checkpoint of a repository.
+//
+// Arguments:
+//   - repo: string containing the full repository name, e.g. "percona/pmm".
+//   - checkpoint: app.GitHubCheckpoint containing the fetch progress.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func SaveGitHubCheckpoint(repo string, checkpoint app.GitHubCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	return Valkey.HSet(githubCheckpointsKey, repo, data).Err()
+}
+
+// ResetGitHubCheckpoint removes the GitHub fetch checkpoint of a repository,
+// so its pull requests are fetched completely on the next run. An empty name removes all checkpoints.
+//
+// Arguments:
+//   - repo: string containing the full repository name, e.g. "percona/pmm".
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func ResetGitHubCheckpoint(repo string) error {
+	if repo == "" {
+		return Valkey.Del(githubCheckpointsKey).Err()
+	}
+
+	return Valkey.HDel(githubCheckpointsKey, repo).Err()
+}
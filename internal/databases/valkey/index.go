@@ -0,0 +1,176 @@
+package valkey
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis"
+)
+
+// Keys of the indexes that replace KEYS scans over the whole keyspace.
+const (
+	// databasesIndexKey is a set of the IDs of all databases.
+	databasesIndexKey = "index:databases"
+
+	// reportsIndexKey is a sorted set of report IDs, scored by the numeric report ID (start time).
+	reportsIndexKey = "index:reports_runs"
+
+	// indexBuiltSuffix is appended to an index key to form its marker key.
+	// The marker is set once the index has been rebuilt from the existing keys, so an empty index
+	// is not mistaken for an index that has never been built.
+	indexBuiltSuffix = ":built"
+
+	// databasesCounterKey is the prefix of the counters used to allocate database IDs per type.
+	databasesCounterKey = "counter:databases:"
+)
+
+// scanCount is the number of keys requested per SCAN iteration.
+const scanCount = 1000
+
+// scanKeys returns the keys matching the pattern using SCAN, which does not block Valkey like KEYS.
+//
+// Arguments:
+//   - pattern: string containing the key pattern, e.g. "databases:*".
+//
+// Returns:
+//   - []string: The matching keys.
+//   - error: An error object if an error occurs, otherwise nil.
+func scanKeys(pattern string) ([]string, error) {
+	var keys []string
+
+	iter := Valkey.Scan(0, pattern, scanCount).Iterator()
+	for iter.Next() {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// indexBuilt reports whether the marker of the index is set.
+//
+// Arguments:
+//   - indexKey: string containing the key of the index, e.g. databasesIndexKey.
+//
+// Returns:
+//   - bool: true if the index has been built.
+//   - error: An error object if an error occurs, otherwise nil.
+func indexBuilt(indexKey string) (bool, error) {
+	exists, err := Valkey.Exists(indexKey + indexBuiltSuffix).Result()
+	if err != nil {
+		return false, err
+	}
+	return exists > 0, nil
+}
+
+// rebuildDatabasesIndex fills the databases index from the existing hashes and sets its marker.
+// It is used when the marker is missing, e.g. after an upgrade from a release without indexes.
+//
+// Returns:
+//   - []string: The IDs of the databases found.
+//   - error: An error object if an error occurs, otherwise nil.
+func rebuildDatabasesIndex() ([]string, error) {
+	keys, err := scanKeys("databases:*")
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, key := range keys {
+		ids = append(ids, strings.TrimPrefix(key, "databases:"))
+	}
+
+	if len(ids) > 0 {
+		members := make([]interface{}, len(ids))
+		for i, id := range ids {
+			members[i] = id
+		}
+		err = Valkey.SAdd(databasesIndexKey, members...).Err()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = Valkey.Set(databasesIndexKey+indexBuiltSuffix, 1, 0).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// rebuildReportsIndex fills the reports index from the existing report hashes and sets its marker.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func rebuildReportsIndex() error {
+	keys, err := scanKeys("reports_runs:*")
+	if err != nil {
+		return err
+	}
+
+	var members []redis.Z
+	for _, key := range keys {
+		reportID := strings.TrimPrefix(key, "reports_runs:")
+		members = append(members, redis.Z{Score: reportScore(reportID), Member: reportID})
+	}
+
+	if len(members) > 0 {
+		err = Valkey.ZAdd(reportsIndexKey, members...).Err()
+		if err != nil {
+			return err
+		}
+	}
+
+	return Valkey.Set(reportsIndexKey+indexBuiltSuffix, 1, 0).Err()
+}
+
+// reportScore converts a report ID (start time in the format 20060102150405) into a sorted set score.
+func reportScore(reportID string) float64 {
+	score, err := strconv.ParseFloat(reportID, 64)
+	if err != nil {
+		return 0
+	}
+	return score
+}
+
+// NextDatabaseID allocates a new database ID for the specified database type.
+// IDs are allocated atomically with INCR, so concurrent control panels never get the same ID.
+// The counter is initialized from the largest existing ID on first use.
+//
+// Arguments:
+//   - dbType: string containing the type of the database (e.g., "mysql", "postgres", "mongodb").
+//
+// Returns:
+//   - string: The new database ID, e.g. "mysql-3".
+//   - error: An error object if an error occurs, otherwise nil.
+func NextDatabaseID(dbType string) (string, error) {
+	key := databasesCounterKey + dbType
+
+	exists, err := Valkey.Exists(key).Result()
+	if err != nil {
+		return "", err
+	}
+
+	if exists == 0 {
+		maxID, err := GetMaxID(dbType)
+		if err != nil {
+			return "", err
+		}
+		// Only the first control panel initializes the counter
+		err = Valkey.SetNX(key, maxID, 0).Err()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	next, err := Valkey.Incr(key).Result()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s-%d", dbType, next), nil
+}
@@ -2,11 +2,12 @@ package valkey
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-redis/redis"
 
@@ -15,6 +16,12 @@ import (
 
 var Valkey *redis.Client
 
+// ErrDatabaseNotFound is returned for a database ID without a hash in Valkey.
+var ErrDatabaseNotFound = errors.New("database not found")
+
+// DatabaseEventsChannel is the pub/sub channel where changes of database configurations are published.
+const DatabaseEventsChannel = "events:databases"
+
 // InitValkey initializes the Redis client using the provided environment variables.
 // It connects to the Redis server and verifies the connection.
 //
@@ -38,6 +45,7 @@ func InitValkey(envVars app.EnvVars) {
 }
 
 // GetMaxID retrieves the maximum ID for the specified database type from Redis.
+// New IDs are allocated with NextDatabaseID, GetMaxID is used to initialize its counter.
 //
 // Arguments:
 //   - dbType: string containing the type of the database (e.g., "mysql", "postgres", "mongodb").
@@ -46,18 +54,17 @@ func InitValkey(envVars app.EnvVars) {
 //   - int64: The maximum ID found for the specified database type.
 //   - error: An error object if an error occurs, otherwise nil.
 func GetMaxID(dbType string) (int64, error) {
-	keys, err := Valkey.Keys(fmt.Sprintf("databases:%s-*", dbType)).Result()
+	ids, err := getDatabaseIDs()
 	if err != nil {
 		return 0, err
 	}
 
 	var maxID int64
-	for _, key := range keys {
-		parts := strings.Split(key, "-")
-		if len(parts) < 2 {
+	for _, id := range ids {
+		if !strings.HasPrefix(id, dbType+"-") {
 			continue
 		}
-		idNum, err := strconv.ParseInt(parts[1], 10, 64)
+		idNum, err := strconv.ParseInt(strings.TrimPrefix(id, dbType+"-"), 10, 64)
 		if err != nil {
 			continue
 		}
@@ -72,67 +79,204 @@ func GetMaxID(dbType string) (int64, error) {
 // GetDatabases retrieves the details of all databases from Redis.
 //
 // Returns:
-//   - []map[string]string: A slice of maps, each containing the details of a database.
+//   - []app.DatabaseConfig: A slice of database configurations.
 //   - error: An error object if an error occurs, otherwise nil.
-func GetDatabases() ([]map[string]string, error) {
-	keys, err := Valkey.Keys("databases:*").Result()
+func GetDatabases() ([]app.DatabaseConfig, error) {
+	ids, err := getDatabaseIDs()
 	if err != nil {
 		return nil, err
 	}
 
-	var databases []map[string]string
-	for _, key := range keys {
-		fields, err := Valkey.HGetAll(key).Result()
-		if err != nil {
-			return nil, err
+	// Read all hashes in one round trip
+	cmds := make([]*redis.StringStringMapCmd, len(ids))
+	_, err = Valkey.Pipelined(func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.HGetAll("databases:" + id)
 		}
-		databases = append(databases, fields)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var databases []app.DatabaseConfig
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		if len(fields) == 0 {
+			// The hash has been deleted without updating the index
+			Valkey.SRem(databasesIndexKey, ids[i])
+			continue
+		}
+		databases = append(databases, parseDatabase("databases:"+ids[i], fields))
 	}
 
 	return databases, nil
 }
 
+// getDatabaseIDs returns the IDs of all databases from the index.
+// If the index has never been built, it is rebuilt with SCAN.
+func getDatabaseIDs() ([]string, error) {
+	built, err := indexBuilt(databasesIndexKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !built {
+		return rebuildDatabasesIndex()
+	}
+
+	return Valkey.SMembers(databasesIndexKey).Result()
+}
+
 // GetDatabase retrieves the details of a specified database from Redis.
 //
 // Arguments:
 //   - id: string containing the ID of the database.
 //
 // Returns:
-//   - map[string]string: A map containing the database details.
+//   - app.DatabaseConfig: The database configuration.
 //   - error: An error object if an error occurs, otherwise nil.
-func GetDatabase(id string) (map[string]string, error) {
+func GetDatabase(id string) (app.DatabaseConfig, error) {
 	key := "databases:" + id
 	fields, err := Valkey.HGetAll(key).Result()
 	if err != nil {
-		return nil, err
+		return app.DatabaseConfig{}, err
 	}
 	if len(fields) == 0 {
-		return nil, fmt.Errorf("database with id %s not found", id)
+		return app.DatabaseConfig{}, fmt.Errorf("%w: %s", ErrDatabaseNotFound, id)
 	}
-	return fields, nil
+	return parseDatabase(key, fields), nil
 }
 
-// AddDatabase adds or updates a database in Redis with the specified ID and fields.
+// CompareAndSetDatabaseField sets a field of a database only if it still has the expected value.
+// The field is read and written in one transaction with WATCH, so concurrent changes of the database
+// by the control panel and the dataset loader are not overwritten.
 //
 // Arguments:
 //   - id: string containing the ID of the database.
-//   - fields: map[string]string containing the fields and values to set for the database.
+//   - field: string containing the hash field (see app.Field* constants).
+//   - expected: string containing the value the field must have, empty for a field that is not set.
+//   - value: string containing the new value.
+//
+// Returns:
+//   - bool: true if the field was set.
+//   - string: The value of the field after the call.
+//   - error: ErrDatabaseNotFound if the database does not exist, another error if an error occurs, otherwise nil.
+func CompareAndSetDatabaseField(id, field, expected, value string) (bool, string, error) {
+	key := "databases:" + id
+
+	var set bool
+	var current string
+	compareAndSet := func(tx *redis.Tx) error {
+		set = false
+		fields, err := tx.HGetAll(key).Result()
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			return fmt.Errorf("%w: %s", ErrDatabaseNotFound, id)
+		}
+
+		current = fields[field]
+		if current != expected {
+			return nil
+		}
+
+		_, err = tx.Pipelined(func(pipe redis.Pipeliner) error {
+			pipe.HSet(key, field, value)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		set = true
+		current = value
+		return nil
+	}
+
+	// The transaction fails if the database is changed between the read and the write, it is retried
+	var err error
+	for attempt := 0; attempt < 5; attempt++ {
+		err = Valkey.Watch(compareAndSet, key)
+		if err != redis.TxFailedErr {
+			break
+		}
+	}
+	if err != nil {
+		return false, "", err
+	}
+
+	if set {
+		PublishDatabaseEvent(app.DatabaseEvent{Action: "update", ID: id, Fields: []string{field}})
+	}
+	return set, current, nil
+}
+
+// parseDatabase converts a database hash into app.DatabaseConfig.
+// Hashes written by older releases are rewritten in the current layout.
+func parseDatabase(key string, fields map[string]string) app.DatabaseConfig {
+	db, err := app.ParseDatabaseConfig(fields)
+	if err != nil {
+		log.Printf("Valkey: Database %s: Invalid fields: %v", key, err)
+	}
+
+	// The ID was not stored in the hash by the first releases
+	if db.ID == "" {
+		db.ID = strings.TrimPrefix(key, "databases:")
+	}
+
+	if app.NeedsMigration(fields) {
+		data := make(map[string]interface{})
+		for k, v := range db.Hash() {
+			data[k] = v
+		}
+		_, err := Valkey.HMSet(key, data).Result()
+		if err != nil {
+			log.Printf("Valkey: Database %s: Migration error: %v", key, err)
+		} else {
+			log.Printf("Valkey: Database %s: Migrated to version %d", key, app.DatabaseConfigVersion)
+		}
+	}
+
+	return db
+}
+
+// AddDatabase adds or updates a database in Redis.
+//
+// Arguments:
+//   - db: app.DatabaseConfig containing the database configuration.
+//   - fields: optional list of hash fields to write (see app.Field* constants).
+//     If empty, the whole configuration is written.
 //
 // Returns:
 //   - error: An error object if an error occurs, otherwise nil.
-func AddDatabase(id string, fields map[string]string) error {
-	key := fmt.Sprintf("databases:%s", id)
+func AddDatabase(db app.DatabaseConfig, fields ...string) error {
+	if db.ID == "" {
+		return fmt.Errorf("database id is required")
+	}
+
+	key := fmt.Sprintf("databases:%s", db.ID)
 
 	data := make(map[string]interface{})
-	for k, v := range fields {
+	for k, v := range db.Hash(fields...) {
 		data[k] = v
 	}
 
-	_, err := Valkey.HMSet(key, data).Result()
+	if len(data) == 0 {
+		return nil
+	}
+
+	_, err := Valkey.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.HMSet(key, data)
+		pipe.SAdd(databasesIndexKey, db.ID)
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
+	PublishDatabaseEvent(app.DatabaseEvent{Action: "update", ID: db.ID, Fields: fields})
+
 	return nil
 }
 
@@ -146,18 +290,224 @@ func AddDatabase(id string, fields map[string]string) error {
 func DeleteDatabase(id string) error {
 	key := fmt.Sprintf("databases:%s", id)
 
-	res, err := Valkey.Del(key).Result()
+	var del *redis.IntCmd
+	_, err := Valkey.TxPipelined(func(pipe redis.Pipeliner) error {
+		del = pipe.Del(key)
+		pipe.Del("dataset_verify:" + id)
+		pipe.SRem(databasesIndexKey, id)
+		return nil
+	})
 
-	log.Printf("Valkey: Delete DB: %s: Result: %v", id, res)
+	log.Printf("Valkey: Delete DB: %s: Result: %v", id, del.Val())
 
 	if err != nil {
 		log.Printf("Valkey: Delete DB: %s: Error: %v", id, err)
 		return err
 	}
 
+	PublishDatabaseEvent(app.DatabaseEvent{Action: "delete", ID: id})
+
 	return nil
 }
 
+// PublishDatabaseEvent notifies subscribers that a database configuration has changed.
+// Errors are only logged, because the subscribers also poll Valkey for changes.
+//
+// Arguments:
+//   - event: app.DatabaseEvent describing the change.
+func PublishDatabaseEvent(event app.DatabaseEvent) {
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Valkey: Publish: Error: %v", err)
+		return
+	}
+
+	err = Valkey.Publish(DatabaseEventsChannel, message).Err()
+	if err != nil {
+		log.Printf("Valkey: Publish: %s: Error: %v", event.ID, err)
+	}
+}
+
+// SubscribeDatabaseEvents subscribes to changes of database configurations.
+// The client reconnects automatically if the connection to Valkey is lost,
+// events published while disconnected are not delivered.
+//
+// Returns:
+//   - <-chan app.DatabaseEvent: A channel that receives the published events.
+func SubscribeDatabaseEvents() <-chan app.DatabaseEvent {
+	pubsub := Valkey.Subscribe(DatabaseEventsChannel)
+	events := make(chan app.DatabaseEvent, 100)
+
+	go func() {
+		defer close(events)
+		for msg := range pubsub.Channel() {
+			var event app.DatabaseEvent
+			err := json.Unmarshal([]byte(msg.Payload), &event)
+			if err != nil {
+				log.Printf("Valkey: Subscribe: Invalid event %q: %v", msg.Payload, err)
+				continue
+			}
+			events <- event
+		}
+	}()
+
+	return events
+}
+
+// workloadsKey stores the workloads available in the load generator.
+const workloadsKey = "workloads"
+
+// SaveWorkloads publishes the workloads available in the load generator, so the control panel can list them.
+//
+// Arguments:
+//   - workloads: []app.WorkloadInfo containing the workload descriptions.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func SaveWorkloads(workloads []app.WorkloadInfo) error {
+	data, err := json.Marshal(workloads)
+	if err != nil {
+		return err
+	}
+
+	return Valkey.Set(workloadsKey, data, 0).Err()
+}
+
+// GetWorkloads retrieves the workloads published by the load generator.
+//
+// Returns:
+//   - []app.WorkloadInfo: The workload descriptions, nil if the load generator has not published them yet.
+//   - error: An error object if an error occurs, otherwise nil.
+func GetWorkloads() ([]app.WorkloadInfo, error) {
+	data, err := Valkey.Get(workloadsKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var workloads []app.WorkloadInfo
+	if err := json.Unmarshal([]byte(data), &workloads); err != nil {
+		return nil, err
+	}
+	return workloads, nil
+}
+
+// SaveLoadStats saves the load statistics of a database to Valkey.
+// The statistics expire if the load generator stops updating them.
+//
+// Arguments:
+//   - id: string containing the ID of the database.
+//   - stats: app.LoadStats containing the measured load.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func SaveLoadStats(id string, stats app.LoadStats) error {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+
+	return Valkey.Set("load_stats:"+id, data, 30*time.Second).Err()
+}
+
+// GetLoadStats retrieves the load statistics of the specified databases from Valkey.
+//
+// Arguments:
+//   - ids: []string containing the IDs of the databases.
+//
+// Returns:
+//   - map[string]app.LoadStats: The statistics by database ID. Databases without statistics are omitted.
+//   - error: An error object if an error occurs, otherwise nil.
+func GetLoadStats(ids []string) (map[string]app.LoadStats, error) {
+	result := make(map[string]app.LoadStats)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = "load_stats:" + id
+	}
+
+	values, err := Valkey.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var stats app.LoadStats
+		if err := json.Unmarshal([]byte(data), &stats); err != nil {
+			log.Printf("Valkey: Load stats %s: Error: %v", ids[i], err)
+			continue
+		}
+		result[ids[i]] = stats
+	}
+
+	return result, nil
+}
+
+// SaveVerifyResult saves the result of the last dataset verification of a database to Valkey.
+//
+// Arguments:
+//   - result: app.VerifyResult containing the differences found in the database.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func SaveVerifyResult(result app.VerifyResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	return Valkey.Set("dataset_verify:"+result.ID, data, 0).Err()
+}
+
+// GetVerifyResults retrieves the results of the last dataset verification of the specified databases from Valkey.
+//
+// Arguments:
+//   - ids: []string containing the IDs of the databases.
+//
+// Returns:
+//   - map[string]app.VerifyResult: The results by database ID. Databases that were not verified are omitted.
+//   - error: An error object if an error occurs, otherwise nil.
+func GetVerifyResults(ids []string) (map[string]app.VerifyResult, error) {
+	result := make(map[string]app.VerifyResult)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = "dataset_verify:" + id
+	}
+
+	values, err := Valkey.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var verify app.VerifyResult
+		if err := json.Unmarshal([]byte(data), &verify); err != nil {
+			log.Printf("Valkey: Verify result %s: Error: %v", ids[i], err)
+			continue
+		}
+		result[ids[i]] = verify
+	}
+
+	return result, nil
+}
+
 // SaveReport saves a report to Redis with the specified report ID and report data.
 //
 // Arguments:
@@ -176,8 +526,12 @@ func SaveReport(reportID string, reportMap map[string]interface{}) error {
 		data[k] = v
 	}
 
-	// Save the data to Redis.
-	_, err := Valkey.HMSet(key, data).Result()
+	// Save the data to Redis and add the report to the index.
+	_, err := Valkey.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.HMSet(key, data)
+		pipe.ZAdd(reportsIndexKey, redis.Z{Score: reportScore(reportID), Member: reportID})
+		return nil
+	})
 	if err != nil {
 		log.Printf("Redis: Error: message: %s", err)
 		return err
@@ -187,6 +541,25 @@ func SaveReport(reportID string, reportMap map[string]interface{}) error {
 	return nil
 }
 
+// UpdateLatestReport adds fields to the latest report, e.g. the results of the jobs that run after it.
+//
+// Arguments:
+//   - fields: map[string]interface{} containing the report fields.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func UpdateLatestReport(fields map[string]interface{}) error {
+	reportIDs, err := Valkey.ZRevRange(reportsIndexKey, 0, 0).Result()
+	if err != nil {
+		return err
+	}
+	if len(reportIDs) == 0 {
+		return fmt.Errorf("no reports")
+	}
+
+	return Valkey.HMSet("reports_runs:"+reportIDs[0], fields).Err()
+}
+
 // SaveDatasetLoader saves the dataset loader data to Valkey.
 func SaveDatasetLoader(data map[string]interface{}) error {
 	// Marshal the data into JSON format
@@ -233,21 +606,31 @@ func GetDatasetState() map[string]interface{} {
 
 // GetLatestDatasetReport retrieves the latest dataset report from Valkey and returns it as a map.
 func GetLatestDatasetReport() (map[string]interface{}, error) {
-	// Get all keys that match the pattern "reports_runs:*"
-	keys, err := Valkey.Keys("reports_runs:*").Result()
+	built, err := indexBuilt(reportsIndexKey)
 	if err != nil {
-		log.Printf("Error getting report keys from Valkey: %v", err)
+		log.Printf("Error getting report index from Valkey: %v", err)
 		return nil, err
 	}
 
-	// Sort the keys in descending order by date
-	sort.Slice(keys, func(i, j int) bool {
-		return keys[i] > keys[j]
-	})
+	// Reports saved before the index existed are found with SCAN
+	if !built {
+		err = rebuildReportsIndex()
+		if err != nil {
+			log.Printf("Error rebuilding report index in Valkey: %v", err)
+			return nil, err
+		}
+	}
+
+	// Get the ID of the latest report from the index
+	reportIDs, err := Valkey.ZRevRange(reportsIndexKey, 0, 0).Result()
+	if err != nil {
+		log.Printf("Error getting report index from Valkey: %v", err)
+		return nil, err
+	}
 
 	// Get the latest report
-	if len(keys) > 0 {
-		latestKey := keys[0]
+	if len(reportIDs) > 0 {
+		latestKey := "reports_runs:" + reportIDs[0]
 		data, err := Valkey.HGetAll(latestKey).Result()
 		if err != nil {
 			log.Printf("Error getting latest report data from Valkey: %v", err)
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// GitHubEntities are the entities of a repository besides its pull requests (DATASET_GITHUB_ENTITIES).
+var GitHubEntities = []string{"issues", "reviews", "review_comments", "commits"}
+
+// PullNumber returns the number of the pull request from its API URL, e.g. the PullRequestURL of a review.
+//
+// Arguments:
+//   - url: string containing the URL, e.g. "https://api.github.com/repos/percona/pmm/pulls/42".
+//
+// Returns:
+//   - int: The number of the pull request, 0 if the URL has none.
+func PullNumber(url string) int {
+	number, _ := strconv.Atoi(path.Base(url))
+	return number
+}
+
+// RepoName returns the name of the repository from an API URL of one of its entities, e.g. the RepositoryURL of an issue.
+//
+// Arguments:
+//   - url: string containing the URL, e.g. "https://api.github.com/repos/percona/pmm/pulls/42".
+//
+// Returns:
+//   - string: The name of the repository without the owner, e.g. "pmm", empty if the URL has none.
+func RepoName(url string) string {
+	_, rest, found := strings.Cut(url, "/repos/")
+	if !found {
+		return ""
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// Users returns the authors of all entities of the repository without duplicates, ordered by ID.
+// Commits by a Git author without a GitHub account have no user.
+func (d RepoData) Users() []*github.User {
+	byID := make(map[int64]*github.User)
+	add := func(user *github.User) {
+		if user.GetID() != 0 {
+			byID[user.GetID()] = user
+		}
+	}
+
+	for _, pull := range d.Pulls {
+		add(pull.User)
+	}
+	for _, issue := range d.Issues {
+		add(issue.User)
+	}
+	for _, review := range d.Reviews {
+		add(review.User)
+	}
+	for _, comment := range d.ReviewComments {
+		add(comment.User)
+	}
+	for _, commit := range d.Commits {
+		add(commit.Author)
+	}
+
+	users := make([]*github.User, 0, len(byID))
+	for _, user := range byID {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].GetID() < users[j].GetID() })
+
+	return users
+}
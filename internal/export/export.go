@@ -0,0 +1,246 @@
+// Package export writes the repositories and pull requests of a dataset into files, so a dataset fetched
+// from GitHub once can be shared offline. The CSV files are read back by the dataset loader with
+// DATASET_LOAD_TYPE=csv.
+//
+// CSV and Parquet files have the layout of the tables: the repositories file has the columns "id,data"
+// and the pulls file "id,repo,data", with the GitHub API JSON in the data column. NDJSON files have
+// one GitHub API object per line.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/google/go-github/github"
+	"github.com/parquet-go/parquet-go"
+)
+
+// Export formats.
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+// Formats lists the supported export formats.
+var Formats = []string{FormatCSV, FormatNDJSON, FormatParquet}
+
+// FileNames returns the names of the repositories and pulls files of the format, e.g. "repositories.csv" and "pulls.csv".
+func FileNames(format string) (string, string) {
+	return "repositories." + format, "pulls." + format
+}
+
+// Writer writes the repositories and the pull requests of a dataset into two files.
+// It implements app.DatasetWriter.
+type Writer struct {
+	repos   encoder
+	pulls   encoder
+	closers []io.Closer
+	Repos   int // Repositories written
+	Pulls   int // Pull requests written
+}
+
+// encoder writes the rows of a file in one format, repo is empty in the repositories file.
+type encoder interface {
+	write(id int64, repo string, data []byte) error
+	close() error
+}
+
+// NewWriter returns a writer of the format.
+//
+// Arguments:
+//   - format: string containing the format, one of Formats.
+//   - repos: io.Writer of the repositories file.
+//   - pulls: io.Writer of the pulls file.
+//
+// Returns:
+//   - *Writer: The writer, Close must be called to write the end of the files.
+//   - error: An error object if the format is not supported, otherwise nil.
+func NewWriter(format string, repos, pulls io.Writer) (*Writer, error) {
+	w := &Writer{}
+
+	switch format {
+	case FormatCSV:
+		w.repos = newCSVEncoder(repos, "id", "data")
+		w.pulls = newCSVEncoder(pulls, "id", "repo", "data")
+	case FormatNDJSON:
+		w.repos = &ndjsonEncoder{w: bufio.NewWriter(repos)}
+		w.pulls = &ndjsonEncoder{w: bufio.NewWriter(pulls)}
+	case FormatParquet:
+		w.repos = newParquetEncoder(repos, func(id int64, repo string, data []byte) parquetRepo {
+			return parquetRepo{ID: id, Data: string(data)}
+		})
+		w.pulls = newParquetEncoder(pulls, func(id int64, repo string, data []byte) parquetPull {
+			return parquetPull{ID: id, Repo: repo, Data: string(data)}
+		})
+	default:
+		return nil, fmt.Errorf("unsupported export format %q, expected one of %v", format, Formats)
+	}
+
+	return w, nil
+}
+
+// Create creates the repositories and pulls files of the format in the directory, replacing existing ones.
+//
+// Arguments:
+//   - dir: string containing the directory, it is created if needed.
+//   - format: string containing the format, one of Formats.
+//
+// Returns:
+//   - *Writer: The writer, Close must be called to write the end of the files and close them.
+//   - error: An error object if an error occurs, otherwise nil.
+func Create(dir, format string) (*Writer, error) {
+	if !slices.Contains(Formats, format) {
+		return nil, fmt.Errorf("unsupported export format %q, expected one of %v", format, Formats)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	reposName, pullsName := FileNames(format)
+	repos, err := os.Create(filepath.Join(dir, reposName))
+	if err != nil {
+		return nil, err
+	}
+	pulls, err := os.Create(filepath.Join(dir, pullsName))
+	if err != nil {
+		repos.Close()
+		return nil, err
+	}
+
+	w, err := NewWriter(format, repos, pulls)
+	if err != nil {
+		repos.Close()
+		pulls.Close()
+		return nil, err
+	}
+	w.closers = []io.Closer{repos, pulls}
+
+	return w, nil
+}
+
+// WriteRepo writes a repository.
+func (w *Writer) WriteRepo(repo *github.Repository) error {
+	data, err := json.Marshal(repo)
+	if err != nil {
+		return err
+	}
+	w.Repos++
+	return w.repos.write(repo.GetID(), "", data)
+}
+
+// WritePull writes a pull request of the repository.
+func (w *Writer) WritePull(repoName string, pull *github.PullRequest) error {
+	data, err := json.Marshal(pull)
+	if err != nil {
+		return err
+	}
+	w.Pulls++
+	return w.pulls.write(pull.GetID(), repoName, data)
+}
+
+// Close writes the end of the files and closes the files opened by Create.
+func (w *Writer) Close() error {
+	err := errors.Join(w.repos.close(), w.pulls.close())
+	for _, c := range w.closers {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// csvEncoder writes the rows with a header, the JSON is quoted by encoding/csv.
+type csvEncoder struct {
+	w      *csv.Writer
+	header []string
+	record []string
+}
+
+func newCSVEncoder(w io.Writer, header ...string) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(w), header: header}
+}
+
+func (e *csvEncoder) write(id int64, repo string, data []byte) error {
+	if e.header != nil {
+		if err := e.w.Write(e.header); err != nil {
+			return err
+		}
+		e.header = nil
+	}
+
+	e.record = append(e.record[:0], strconv.FormatInt(id, 10))
+	if repo != "" {
+		e.record = append(e.record, repo)
+	}
+	e.record = append(e.record, string(data))
+	return e.w.Write(e.record)
+}
+
+func (e *csvEncoder) close() error {
+	// A file without rows has the header only
+	if e.header != nil {
+		if err := e.w.Write(e.header); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// ndjsonEncoder writes the GitHub API objects one per line, the repository name is in the object.
+type ndjsonEncoder struct {
+	w *bufio.Writer
+}
+
+func (e *ndjsonEncoder) write(id int64, repo string, data []byte) error {
+	if _, err := e.w.Write(data); err != nil {
+		return err
+	}
+	return e.w.WriteByte('\n')
+}
+
+func (e *ndjsonEncoder) close() error {
+	return e.w.Flush()
+}
+
+// parquetRowGroupRows is the number of rows of a Parquet row group, the writer keeps a row group in memory.
+const parquetRowGroupRows = 10000
+
+// parquetRepo is a row of the repositories Parquet file.
+type parquetRepo struct {
+	ID   int64  `parquet:"id"`
+	Data string `parquet:"data,zstd"`
+}
+
+// parquetPull is a row of the pulls Parquet file.
+type parquetPull struct {
+	ID   int64  `parquet:"id"`
+	Repo string `parquet:"repo,dict"`
+	Data string `parquet:"data,zstd"`
+}
+
+// parquetEncoder writes the rows into a Parquet file, row converts the values into a row of the file.
+type parquetEncoder[T any] struct {
+	w   *parquet.GenericWriter[T]
+	row func(id int64, repo string, data []byte) T
+}
+
+func newParquetEncoder[T any](w io.Writer, row func(id int64, repo string, data []byte) T) *parquetEncoder[T] {
+	return &parquetEncoder[T]{w: parquet.NewGenericWriter[T](w, parquet.MaxRowsPerRowGroup(parquetRowGroupRows)), row: row}
+}
+
+func (e *parquetEncoder[T]) write(id int64, repo string, data []byte) error {
+	_, err := e.w.Write([]T{e.row(id, repo, data)})
+	return err
+}
+
+func (e *parquetEncoder[T]) close() error {
+	return e.w.Close()
+}
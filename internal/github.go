@@ -2,166 +2,470 @@ package internal
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
+	"github-stat/internal/metrics"
+
 	"github.com/google/go-github/github"
-	"golang.org/x/oauth2"
 )
 
-type PullsLastUpdate struct {
-	Minimum string
-	Force   bool
+// GitHubCheckpoint is the fetch progress of the pull requests of one repository.
+// It is saved after every page, so a restarted dataset loader continues where it stopped.
+type GitHubCheckpoint struct {
+	LastUpdate string `json:"last_update"` // updated_at of the newest pull request of the last complete fetch (RFC3339), empty before the first one
+	NextPage   int    `json:"next_page"`   // Next page of an interrupted full fetch, 0 if there is none
+	Newest     string `json:"newest"`      // updated_at of the newest pull request of the fetch in progress (RFC3339)
+	ETag       string `json:"etag"`        // ETag of the first page of the last update, unchanged pull requests return 304 Not Modified
+	SavedAt    string `json:"saved_at"`
+	// Start of the last complete fetch of the other entities (RFC3339), the next one fetches the entities updated since
+	DetailsSince string `json:"details_since,omitempty"`
+	// Cursor of the next page of an interrupted full fetch with the GraphQL API, NextPage of the REST API
+	Cursor string `json:"cursor,omitempty"`
 }
 
-func FetchGitHubPullsByRepo(envVars EnvVars, repo *github.Repository, pullsLastUpdate map[string]*PullsLastUpdate, counterPulls map[string]*int) ([]*github.PullRequest, error) {
+// Full reports whether the pull requests of the repository are fetched completely:
+// on the first fetch, after a reset and when an interrupted full fetch is continued.
+func (c GitHubCheckpoint) Full() bool {
+	return c.LastUpdate == "" || c.NextPage > 0 || c.Cursor != ""
+}
 
-	ctx := context.Background()
+// observe keeps the update time of the newest pull request of the fetch in progress.
+func (c *GitHubCheckpoint) observe(pulls []*github.PullRequest) {
+	// RFC3339 times in UTC compare as strings
+	for _, pull := range pulls {
+		if pull.UpdatedAt == nil {
+			continue
+		}
+		if updated := pull.UpdatedAt.UTC().Format(time.RFC3339); updated > c.Newest {
+			c.Newest = updated
+		}
+	}
+}
 
-	var client *github.Client
+// complete finishes the checkpoint after a complete fetch, the next one starts from the newest pull request.
+func (c *GitHubCheckpoint) complete() {
+	if c.Newest > c.LastUpdate {
+		c.LastUpdate = c.Newest
+	}
+	if c.LastUpdate == "" {
+		// A repository without pull requests is updated from the beginning of time next time
+		c.LastUpdate = time.Time{}.Format(time.RFC3339)
+	}
+	c.NextPage = 0
+	c.Cursor = ""
+	c.Newest = ""
+}
 
-	if envVars.GitHub.Token == "" {
-		client = github.NewClient(nil)
-	} else {
-		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: envVars.GitHub.Token})
-		tc := oauth2.NewClient(ctx, ts)
-		client = github.NewClient(tc)
+// FetchGitHubPullsByRepo fetches the pull requests of the repository starting from the checkpoint.
+// A full fetch lists all pull requests by creation date and continues at the page of an interrupted fetch,
+// an update lists them by update date until the last update of the checkpoint.
+// The pull requests of every page are passed to savePage with the checkpoint after the page.
+//
+// Arguments:
+//   - envVars: EnvVars containing the GitHub token.
+//   - repo: *github.Repository to fetch the pull requests of.
+//   - checkpoint: GitHubCheckpoint containing the progress of the previous fetches.
+//   - counterPulls: map[string]*int containing the counters of the report.
+//   - savePage: function storing the pull requests and the checkpoint, the fetch stops at its first error.
+//
+// Returns:
+//   - GitHubCheckpoint: The checkpoint after the complete fetch.
+//   - error: An error object if an error occurs, otherwise nil.
+func FetchGitHubPullsByRepo(envVars EnvVars, repo *github.Repository, checkpoint GitHubCheckpoint, counterPulls map[string]*int, savePage func(pulls []*github.PullRequest, checkpoint GitHubCheckpoint) error) (GitHubCheckpoint, error) {
+
+	ctx := context.Background()
+	client, err := newGitHubClient(ctx, envVars.GitHub)
+	if err != nil {
+		return checkpoint, err
 	}
 
-	var allPulls []*github.PullRequest
+	*counterPulls["repos"]++
 
-	var lastUpdatedTime time.Time
-	var forceUpdate bool
-	var err error
+	full := checkpoint.Full()
 
-	repoName := repo.GetName()
-	pullLastUpdate := pullsLastUpdate[repoName].Minimum
-	forceUpdate = pullsLastUpdate[repoName].Force
+	opts := &github.PullRequestListOptions{
+		State:       "all",
+		Sort:        "updated",
+		Direction:   "desc",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
 
-	*counterPulls["repos"]++
+	var lastUpdatedTime time.Time
+	if full {
+		opts.Sort = "created"
+		opts.Page = checkpoint.NextPage
+		// The cursor of an interrupted GraphQL fetch is not a page, the fetch starts again
+		checkpoint.Cursor = ""
+		if opts.Page > 1 {
+			log.Printf("GitHub API: Repo Full: %s: Continue from page %d", repo.GetName(), opts.Page)
+		}
+	} else {
+		var err error
+		lastUpdatedTime, err = time.Parse(time.RFC3339, checkpoint.LastUpdate)
+		if err != nil {
+			log.Printf("Error parsing startedAt: %v", err)
+		}
+		// An interrupted update is repeated from the first page, it stops at the last update anyway
+		checkpoint.Newest = ""
+	}
 
-	if pullLastUpdate == "" || forceUpdate {
+	for {
+		// The first page of an update is conditional, it costs no quota if no pull request has changed
+		etag := ""
+		if !full && opts.Page <= 1 {
+			etag = checkpoint.ETag
+		}
 
-		opts := &github.PullRequestListOptions{
-			State:       "all",
-			Sort:        "created",
-			Direction:   "desc",
-			ListOptions: github.ListOptions{PerPage: 100},
+		var pulls []*github.PullRequest
+		resp, err := doGitHub(ctx, func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			pulls, resp, err = listPulls(ctx, client, repo, opts, etag)
+			return resp, err
+		})
+
+		*counterPulls["pulls_api_requests"]++
+		metrics.GitHubRequests.WithLabelValues("pulls").Inc()
+
+		if resp != nil && resp.StatusCode == http.StatusNotModified {
+			log.Printf("GitHub API: Repo Update: %s: Not modified", repo.GetName())
+			*counterPulls["repos_not_modified"]++
+			return checkpoint, nil
+		}
+		if err != nil {
+			return checkpoint, err
 		}
 
-		for {
-			pulls, resp, err := client.PullRequests.List(ctx, *repo.Owner.Login, *repo.Name, opts)
-			if err != nil {
-				return allPulls, err
-			}
+		if !full && opts.Page <= 1 {
+			checkpoint.ETag = resp.Header.Get("ETag")
+		}
 
-			*counterPulls["pulls_api_requests"]++
+		dateBreak := false
+		if full {
 			*counterPulls["pulls"] += len(pulls)
 			*counterPulls["pulls_full"] += len(pulls)
 
 			log.Printf("GitHub API: Repo Full: %s, Total requests: %d, repos: %d, pulls: %d", *repo.Name, *counterPulls["pulls_api_requests"], *counterPulls["repos"], *counterPulls["pulls"])
+		} else {
+			log.Printf("GitHub API: Repo Update: %s, Total requests: %d, repos: %d, pulls: %d", *repo.Name, *counterPulls["pulls_api_requests"], *counterPulls["repos"], *counterPulls["pulls"])
 
-			allPulls = append(allPulls, pulls...)
-
-			if resp.NextPage == 0 {
-				break
+			for i, pull := range pulls {
+				if pull.UpdatedAt != nil && lastUpdatedTime.After(*pull.UpdatedAt) {
+					pulls = pulls[:i]
+					dateBreak = true
+					break
+				}
 			}
+			*counterPulls["pulls"] += len(pulls)
+			*counterPulls["pulls_latest"] += len(pulls)
+		}
 
-			opts.Page = resp.NextPage
+		checkpoint.observe(pulls)
 
+		if full {
+			checkpoint.NextPage = resp.NextPage
 		}
+		checkpoint.SavedAt = time.Now().UTC().Format(time.RFC3339)
+
+		if err := savePage(pulls, checkpoint); err != nil {
+			return checkpoint, err
+		}
+
+		if resp.NextPage == 0 || dateBreak {
+			break
+		}
+
+		opts.Page = resp.NextPage
+	}
+
+	if full {
 		*counterPulls["repos_full"]++
 	} else {
+		*counterPulls["repos_latest"]++
+	}
+
+	checkpoint.complete()
+
+	return checkpoint, nil
+}
+
+// listPulls lists a page of the pull requests of the repository.
+// With an ETag the request is conditional and returns 304 Not Modified if the page has not changed.
+func listPulls(ctx context.Context, client *github.Client, repo *github.Repository, opts *github.PullRequestListOptions, etag string) ([]*github.PullRequest, *github.Response, error) {
+	if etag == "" {
+		return client.PullRequests.List(ctx, repo.GetOwner().GetLogin(), repo.GetName(), opts)
+	}
+
+	query := url.Values{
+		"state":     {opts.State},
+		"sort":      {opts.Sort},
+		"direction": {opts.Direction},
+		"per_page":  {strconv.Itoa(opts.PerPage)},
+	}
+	if opts.Page > 0 {
+		query.Set("page", strconv.Itoa(opts.Page))
+	}
+	u := fmt.Sprintf("repos/%s/%s/pulls?%s", url.PathEscape(repo.GetOwner().GetLogin()), url.PathEscape(repo.GetName()), query.Encode())
+
+	req, err := client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("If-None-Match", etag)
+
+	var pulls []*github.PullRequest
+	resp, err := client.Do(ctx, req, &pulls)
+	return pulls, resp, err
+}
+
+// FetchGitHubDetails fetches the entities of the repository besides its pull requests that are enabled
+// in DATASET_GITHUB_ENTITIES: issues, review comments and commits created or updated since the time
+// and the reviews of the given pull requests. The entities of every page are passed to savePage.
+//
+// Arguments:
+//   - envVars: EnvVars containing the GitHub token and the entities.
+//   - repo: *github.Repository to fetch the entities of.
+//   - since: time.Time of the previous fetch, the zero time fetches all entities.
+//   - pullNumbers: []int containing the numbers of the pull requests to fetch the reviews of.
+//   - counter: map[string]*int containing the counters of the report.
+//   - savePage: function storing the entities, the fetch stops at its first error.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func FetchGitHubDetails(envVars EnvVars, repo *github.Repository, since time.Time, pullNumbers []int, counter map[string]*int, savePage func(details RepoDetails) error) error {
+	ctx := context.Background()
+	client, err := newGitHubClient(ctx, envVars.GitHub)
+	if err != nil {
+		return err
+	}
 
-		opts := &github.PullRequestListOptions{
-			State:       "all",
-			Sort:        "updated",
-			Direction:   "desc",
-			ListOptions: github.ListOptions{PerPage: 100},
+	owner, name := repo.GetOwner().GetLogin(), repo.GetName()
+	entities := envVars.GitHub.Entities
+
+	if entities["issues"] {
+		opts := &github.IssueListByRepoOptions{State: "all", Sort: "updated", Direction: "asc", Since: since, ListOptions: github.ListOptions{PerPage: 100}}
+		err := fetchGitHubPages(ctx, "issues", counter, func(page int) ([]*github.Issue, *github.Response, error) {
+			opts.Page = page
+			all, resp, err := client.Issues.ListByRepo(ctx, owner, name, opts)
+
+			// The issues API also returns the pull requests, they are fetched separately
+			var issues []*github.Issue
+			for _, issue := range all {
+				if issue.PullRequestLinks == nil {
+					issues = append(issues, issue)
+				}
+			}
+			return issues, resp, err
+		}, func(issues []*github.Issue) error {
+			return savePage(RepoDetails{Issues: issues})
+		})
+		if err != nil {
+			return fmt.Errorf("issues: %w", err)
 		}
+	}
 
-		lastUpdatedTime, err = time.Parse(time.RFC3339, pullLastUpdate)
+	if entities["review_comments"] {
+		opts := &github.PullRequestListCommentsOptions{Sort: "updated", Direction: "asc", Since: since, ListOptions: github.ListOptions{PerPage: 100}}
+		err := fetchGitHubPages(ctx, "review_comments", counter, func(page int) ([]*github.PullRequestComment, *github.Response, error) {
+			opts.Page = page
+			// Number 0 lists the review comments of all pull requests of the repository
+			return client.PullRequests.ListComments(ctx, owner, name, 0, opts)
+		}, func(comments []*github.PullRequestComment) error {
+			return savePage(RepoDetails{ReviewComments: comments})
+		})
 		if err != nil {
-			log.Printf("Error parsing startedAt: %v", err)
+			return fmt.Errorf("review comments: %w", err)
 		}
+	}
 
-		for {
-			pulls, resp, err := client.PullRequests.List(ctx, *repo.Owner.Login, *repo.Name, opts)
+	if entities["commits"] {
+		opts := &github.CommitsListOptions{Since: since, ListOptions: github.ListOptions{PerPage: 100}}
+		err := fetchGitHubPages(ctx, "commits", counter, func(page int) ([]*github.RepositoryCommit, *github.Response, error) {
+			opts.Page = page
+			return client.Repositories.ListCommits(ctx, owner, name, opts)
+		}, func(commits []*github.RepositoryCommit) error {
+			return savePage(RepoDetails{Commits: commits})
+		})
+		// An empty repository has no commits and returns 409 Conflict
+		var respErr *github.ErrorResponse
+		if errors.As(err, &respErr) && respErr.Response != nil && respErr.Response.StatusCode == http.StatusConflict {
+			err = nil
+		}
+		if err != nil {
+			return fmt.Errorf("commits: %w", err)
+		}
+	}
+
+	if entities["reviews"] {
+		for _, number := range pullNumbers {
+			opts := &github.ListOptions{PerPage: 100}
+			err := fetchGitHubPages(ctx, "reviews", counter, func(page int) ([]*github.PullRequestReview, *github.Response, error) {
+				opts.Page = page
+				return client.PullRequests.ListReviews(ctx, owner, name, number, opts)
+			}, func(reviews []*github.PullRequestReview) error {
+				return savePage(RepoDetails{Reviews: reviews})
+			})
 			if err != nil {
-				return allPulls, err
+				return fmt.Errorf("reviews of pull request %d: %w", number, err)
 			}
-			*counterPulls["pulls_api_requests"]++
+		}
+	}
 
-			log.Printf("GitHub API: Repo Update: %s, Total requests: %d, repos: %d, pulls: %d", *repo.Name, *counterPulls["pulls_api_requests"], *counterPulls["repos"], *counterPulls["pulls"])
+	log.Printf("GitHub API: Repo Details: %s, Total requests: %d, issues: %d, reviews: %d, review comments: %d, commits: %d",
+		name, *counter["details_api_requests"], *counter["issues"], *counter["reviews"], *counter["review_comments"], *counter["commits"])
 
-			dateBreak := false
-			for _, pull := range pulls {
-				if pull.UpdatedAt != nil && lastUpdatedTime.After(*pull.UpdatedAt) {
+	return nil
+}
 
-					dateBreak = true
-					break
-				}
-				*counterPulls["pulls"]++
-				*counterPulls["pulls_latest"]++
+// fetchGitHubPages requests all pages of a list and passes every page to save.
+// The requests and the entities are counted in the counter under "details_api_requests" and the entity.
+func fetchGitHubPages[T any](ctx context.Context, entity string, counter map[string]*int, list func(page int) ([]T, *github.Response, error), save func(items []T) error) error {
+	page := 0
+	for {
+		var items []T
+		resp, err := doGitHub(ctx, func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			items, resp, err = list(page)
+			return resp, err
+		})
 
-				allPulls = append(allPulls, pull)
-			}
+		*counter["details_api_requests"]++
+		metrics.GitHubRequests.WithLabelValues(entity).Inc()
 
-			if resp.NextPage == 0 || dateBreak {
-				break
-			}
+		if err != nil {
+			return err
+		}
 
-			opts.Page = resp.NextPage
+		*counter[entity] += len(items)
+		if err := save(items); err != nil {
+			return err
+		}
 
+		if resp.NextPage == 0 {
+			return nil
 		}
+		page = resp.NextPage
+	}
+}
 
-		*counterPulls["repos_latest"]++
+// FetchGitHubRepos fetches the repositories of all sources of GITHUB_SOURCES. The repositories of
+// organizations and users are filtered by GITHUB_REPOS_INCLUDE and GITHUB_REPOS_EXCLUDE, repositories
+// listed explicitly are always fetched. A repository of several sources is returned once, for the first one.
+// A failed source is skipped, the repositories of the other sources are returned with the error.
+//
+// Arguments:
+//   - envVars: EnvVars containing the GitHub token, the sources and the filters.
+//
+// Returns:
+//   - []*github.Repository: The repositories of all sources.
+//   - map[int64]string: The source of every repository by repository ID.
+//   - int: The number of API requests.
+//   - error: An error object if a source fails, otherwise nil.
+func FetchGitHubRepos(envVars EnvVars) ([]*github.Repository, map[int64]string, int, error) {
+	ctx := context.Background()
+	client, err := newGitHubClient(ctx, envVars.GitHub)
+	if err != nil {
+		return nil, nil, 0, err
 	}
 
-	return allPulls, nil
+	return fetchGitHubRepos(envVars, func(source GitHubSource) ([]*github.Repository, int, error) {
+		return fetchGitHubSourceRepos(ctx, client, source)
+	})
 }
 
-func FetchGitHubRepos(envVars EnvVars) ([]*github.Repository, int, error) {
+// fetchGitHubRepos fetches the repositories of all sources with fetchSource, which returns the repositories
+// of a source with the number of API requests, and applies the filters of FetchGitHubRepos.
+func fetchGitHubRepos(envVars EnvVars, fetchSource func(source GitHubSource) ([]*github.Repository, int, error)) ([]*github.Repository, map[int64]string, int, error) {
+	var allRepos []*github.Repository
+	sources := make(map[int64]string)
+	var counter int
+	var errs []error
 
-	org := envVars.GitHub.Organisation
-	token := envVars.GitHub.Token
+	for _, source := range envVars.GitHub.Sources {
+		log.Printf("GitHub API: Fetch Repos: %s: Start", source)
 
-	log.Printf("GitHub API: Fetch Repos: Org: %s: Start", org)
-	ctx := context.Background()
-	var client *github.Client
+		repos, requests, err := fetchSource(source)
+		counter += requests
+		if err != nil {
+			log.Printf("GitHub API: Fetch Repos: %s: Error: %v", source, err)
+			errs = append(errs, fmt.Errorf("%s: %w", source, err))
+			continue
+		}
 
-	if token == "" {
-		client = github.NewClient(nil)
-	} else {
-		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-		tc := oauth2.NewClient(ctx, ts)
-		client = github.NewClient(tc)
+		added := 0
+		for _, repo := range repos {
+			if source.Type != "repo" && !envVars.GitHub.Included(repo) {
+				continue
+			}
+			if _, ok := sources[repo.GetID()]; ok {
+				continue
+			}
+			sources[repo.GetID()] = source.String()
+			allRepos = append(allRepos, repo)
+			added++
+		}
+
+		log.Printf("GitHub API: Fetch Repos: %s: Repos: %d of %d, Finish", source, added, len(repos))
 	}
 
-	opt := &github.RepositoryListByOrgOptions{
-		ListOptions: github.ListOptions{PerPage: 100},
-		Type:        "public",
+	log.Printf("GitHub API: Fetch Repos: Sources: %d, Repos: %d, API requests: %d", len(envVars.GitHub.Sources), len(allRepos), counter)
+	return allRepos, sources, counter, errors.Join(errs...)
+}
+
+// fetchGitHubSourceRepos fetches the repositories of one source and returns them with the number of API requests.
+func fetchGitHubSourceRepos(ctx context.Context, client *github.Client, source GitHubSource) ([]*github.Repository, int, error) {
+	if source.Type == "repo" {
+		var repo *github.Repository
+		_, err := doGitHub(ctx, func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			repo, resp, err = client.Repositories.Get(ctx, source.Owner, source.Repo)
+			return resp, err
+		})
+		metrics.GitHubRequests.WithLabelValues("repos").Inc()
+		if err != nil {
+			return nil, 1, err
+		}
+		return []*github.Repository{repo}, 1, nil
 	}
 
-	var counter int
+	listOptions := github.ListOptions{PerPage: 100}
 
+	var counter int
 	var allRepos []*github.Repository
 	for {
-		repos, resp, err := client.Repositories.ListByOrg(ctx, org, opt)
+		var repos []*github.Repository
+		resp, err := doGitHub(ctx, func() (*github.Response, error) {
+			var resp *github.Response
+			var err error
+			if source.Type == "user" {
+				repos, resp, err = client.Repositories.List(ctx, source.Owner, &github.RepositoryListOptions{Type: "owner", ListOptions: listOptions})
+			} else {
+				repos, resp, err = client.Repositories.ListByOrg(ctx, source.Owner, &github.RepositoryListByOrgOptions{Type: "public", ListOptions: listOptions})
+			}
+			return resp, err
+		})
 		if err != nil {
 			return nil, counter, err
 		}
 		allRepos = append(allRepos, repos...)
 		counter++
-		log.Printf("GitHub API: Fetch Repos: Org: %s: API Request: %d", org, counter)
+		metrics.GitHubRequests.WithLabelValues("repos").Inc()
+		log.Printf("GitHub API: Fetch Repos: %s: API Request: %d", source, counter)
 
 		if resp.NextPage == 0 {
 			break
 		} else {
-			opt.Page = resp.NextPage
+			listOptions.Page = resp.NextPage
 		}
 	}
 
-	log.Printf("GitHub API: Fetch Repos: Org: %s: Repos: %d: API requests: %d, Finish", org, len(allRepos), counter)
 	return allRepos, counter, nil
 }
@@ -0,0 +1,259 @@
+package internal
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+)
+
+var (
+	githubAppSourcesMu sync.Mutex
+	// githubAppSources caches the token sources of the GitHub App installations,
+	// so all clients share the installation token and it is refreshed only when it expires.
+	githubAppSources = make(map[string]oauth2.TokenSource)
+)
+
+// Authenticated reports whether the GitHub API requests are authenticated with a token or as a GitHub App.
+func (c ConfigGitHub) Authenticated() bool {
+	return c.Token != "" || c.AppID != 0
+}
+
+// newGitHubClient creates a GitHub API client for the API URL of the configuration, GitHub.com by default.
+// The requests are authenticated with an installation token of the GitHub App if it is configured,
+// otherwise with the personal access token if it is set.
+//
+// Arguments:
+//   - ctx: context.Context of the HTTP client.
+//   - cfg: ConfigGitHub containing the API URLs and the credentials.
+//
+// Returns:
+//   - *github.Client: The client.
+//   - error: An error object if an error occurs, otherwise nil.
+func newGitHubClient(ctx context.Context, cfg ConfigGitHub) (*github.Client, error) {
+	var httpClient *http.Client
+	switch {
+	case cfg.AppID != 0:
+		ts, err := githubAppTokenSource(cfg)
+		if err != nil {
+			return nil, err
+		}
+		httpClient = oauth2.NewClient(ctx, ts)
+	case cfg.Token != "":
+		httpClient = oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: cfg.Token}))
+	}
+
+	return newGitHubAPIClient(httpClient, cfg)
+}
+
+// newGitHubAPIClient creates a GitHub API client with the HTTP client for the API and upload URLs of the configuration.
+func newGitHubAPIClient(httpClient *http.Client, cfg ConfigGitHub) (*github.Client, error) {
+	client := github.NewClient(httpClient)
+	if cfg.APIURL == "" {
+		return client, nil
+	}
+
+	baseURL, err := githubURL(cfg.APIURL)
+	if err != nil {
+		return nil, err
+	}
+	client.BaseURL = baseURL
+	client.UploadURL = baseURL
+
+	if cfg.UploadURL != "" {
+		if client.UploadURL, err = githubURL(cfg.UploadURL); err != nil {
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+// githubURL parses an API URL, go-github requires the trailing slash.
+func githubURL(value string) (*url.URL, error) {
+	if !strings.HasSuffix(value, "/") {
+		value += "/"
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid GitHub API URL %q, expected http:// or https://", value)
+	}
+	return u, nil
+}
+
+// githubAppTokenSource returns the cached token source of the GitHub App installation of the configuration.
+func githubAppTokenSource(cfg ConfigGitHub) (oauth2.TokenSource, error) {
+	key := fmt.Sprintf("%s|%d|%d", cfg.APIURL, cfg.AppID, cfg.AppInstallationID)
+
+	githubAppSourcesMu.Lock()
+	defer githubAppSourcesMu.Unlock()
+
+	if ts, ok := githubAppSources[key]; ok {
+		return ts, nil
+	}
+
+	// Requests as the app itself are authenticated with a JWT signed by its private key
+	appClient, err := newGitHubAPIClient(&http.Client{Transport: &githubAppTransport{appID: cfg.AppID, key: cfg.AppPrivateKey}}, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &installationTokenSource{client: appClient, cfg: cfg, installationID: cfg.AppInstallationID}
+	ts := oauth2.ReuseTokenSource(nil, source)
+	githubAppSources[key] = ts
+
+	return ts, nil
+}
+
+// installationTokenSource creates installation tokens of a GitHub App, they are valid for one hour.
+type installationTokenSource struct {
+	client         *github.Client
+	cfg            ConfigGitHub
+	installationID int64
+}
+
+// Token creates a new installation token. It expires a minute early, so it is refreshed before a request is rejected.
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	ctx := context.Background()
+
+	if s.installationID == 0 {
+		id, err := s.findInstallation(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.installationID = id
+	}
+
+	// Apps.CreateInstallationToken of go-github v17 uses the retired /installations/{id}/access_tokens path
+	req, err := s.client.NewRequest("POST", fmt.Sprintf("app/installations/%d/access_tokens", s.installationID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	token := new(github.InstallationToken)
+	if _, err := s.client.Do(ctx, req, token); err != nil {
+		return nil, fmt.Errorf("GitHub App %d: creating an installation token: %w", s.cfg.AppID, err)
+	}
+
+	expiresAt := token.GetExpiresAt()
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(time.Hour)
+	}
+
+	log.Printf("GitHub API: App %d: Installation %d: New token, expires at %s", s.cfg.AppID, s.installationID, expiresAt.Format(time.RFC3339))
+
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		Expiry:      expiresAt.Add(-time.Minute),
+	}, nil
+}
+
+// findInstallation returns the installation of the app if GITHUB_APP_INSTALLATION_ID is not set:
+// the only installation or the one of the owner of the first source.
+func (s *installationTokenSource) findInstallation(ctx context.Context) (int64, error) {
+	installations, _, err := s.client.Apps.ListInstallations(ctx, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return 0, fmt.Errorf("GitHub App %d: listing the installations: %w", s.cfg.AppID, err)
+	}
+
+	if len(installations) == 1 {
+		return installations[0].GetID(), nil
+	}
+
+	var accounts []string
+	for _, installation := range installations {
+		account := installation.GetAccount().GetLogin()
+		if len(s.cfg.Sources) > 0 && strings.EqualFold(account, s.cfg.Sources[0].Owner) {
+			return installation.GetID(), nil
+		}
+		accounts = append(accounts, account)
+	}
+
+	return 0, fmt.Errorf("GitHub App %d: set GITHUB_APP_INSTALLATION_ID, the app has %d installations: %s",
+		s.cfg.AppID, len(installations), strings.Join(accounts, ", "))
+}
+
+// githubAppTransport authenticates the requests as the GitHub App with a new JWT for every request.
+type githubAppTransport struct {
+	appID int64
+	key   *rsa.PrivateKey
+}
+
+func (t *githubAppTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := githubAppJWT(t.appID, t.key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// githubAppJWT creates the JWT of a GitHub App signed with RS256. It is issued a minute in the past
+// against clock drift and expires after 9 minutes, GitHub accepts at most 10.
+func githubAppJWT(appID int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parseGitHubAppKey parses the PEM private key of a GitHub App, GitHub generates PKCS #1 keys.
+func parseGitHubAppKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA private key")
+	}
+	return rsaKey, nil
+}
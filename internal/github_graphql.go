@@ -0,0 +1,627 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github-stat/internal/metrics"
+
+	"github.com/google/go-github/github"
+)
+
+// graphQLPageSize is the number of repositories or pull requests of a page, the same as the REST requests,
+// so a page of the GraphQL API replaces one REST request.
+const graphQLPageSize = 100
+
+// graphQLRepoFields are the fields of a repository used by the dataset.
+const graphQLRepoFields = `
+fragment RepoFields on Repository {
+  databaseId id name nameWithOwner description url createdAt pushedAt updatedAt
+  owner { login __typename ... on Organization { databaseId } ... on User { databaseId } }
+  defaultBranchRef { name }
+  primaryLanguage { name }
+  isFork isPrivate isArchived hasIssuesEnabled forkCount stargazerCount diskUsage
+  openIssues: issues(states: OPEN) { totalCount }
+  openPulls: pullRequests(states: OPEN) { totalCount }
+  repositoryTopics(first: 20) { nodes { topic { name } } }
+}`
+
+// graphQLPullFields are the fields of a pull request used by the dataset.
+const graphQLPullFields = `
+fragment PullFields on PullRequest {
+  databaseId id number title body state url createdAt updatedAt closedAt mergedAt merged
+  additions deletions changedFiles
+  comments { totalCount }
+  commits { totalCount }
+  author { login __typename ... on User { databaseId } ... on Bot { databaseId } }
+  headRefName headRefOid headRepositoryOwner { login }
+  baseRefName baseRefOid
+  mergeCommit { oid }
+}`
+
+// graphQLClient sends queries to the GraphQL API of the GitHub server of the REST client.
+type graphQLClient struct {
+	client *github.Client
+}
+
+type graphQLError struct {
+	Type    string        `json:"type"`
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path"`
+}
+
+func (e graphQLError) Error() string {
+	return e.Message
+}
+
+type graphQLPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type graphQLConnection[T any] struct {
+	PageInfo graphQLPageInfo `json:"pageInfo"`
+	Nodes    []T             `json:"nodes"`
+}
+
+type graphQLCount struct {
+	TotalCount int `json:"totalCount"`
+}
+
+type graphQLActor struct {
+	Login      string `json:"login"`
+	Type       string `json:"__typename"`
+	DatabaseID int64  `json:"databaseId"`
+}
+
+type graphQLRepo struct {
+	DatabaseID       int64        `json:"databaseId"`
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	NameWithOwner    string       `json:"nameWithOwner"`
+	Description      *string      `json:"description"`
+	URL              string       `json:"url"`
+	CreatedAt        time.Time    `json:"createdAt"`
+	PushedAt         *time.Time   `json:"pushedAt"`
+	UpdatedAt        time.Time    `json:"updatedAt"`
+	Owner            graphQLActor `json:"owner"`
+	DefaultBranchRef *struct {
+		Name string `json:"name"`
+	} `json:"defaultBranchRef"`
+	PrimaryLanguage *struct {
+		Name string `json:"name"`
+	} `json:"primaryLanguage"`
+	IsFork           bool         `json:"isFork"`
+	IsPrivate        bool         `json:"isPrivate"`
+	IsArchived       bool         `json:"isArchived"`
+	HasIssuesEnabled bool         `json:"hasIssuesEnabled"`
+	ForkCount        int          `json:"forkCount"`
+	StargazerCount   int          `json:"stargazerCount"`
+	DiskUsage        int          `json:"diskUsage"`
+	OpenIssues       graphQLCount `json:"openIssues"`
+	OpenPulls        graphQLCount `json:"openPulls"`
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct {
+				Name string `json:"name"`
+			} `json:"topic"`
+		} `json:"nodes"`
+	} `json:"repositoryTopics"`
+}
+
+type graphQLPull struct {
+	DatabaseID          int64         `json:"databaseId"`
+	ID                  string        `json:"id"`
+	Number              int           `json:"number"`
+	Title               string        `json:"title"`
+	Body                string        `json:"body"`
+	State               string        `json:"state"`
+	URL                 string        `json:"url"`
+	CreatedAt           time.Time     `json:"createdAt"`
+	UpdatedAt           time.Time     `json:"updatedAt"`
+	ClosedAt            *time.Time    `json:"closedAt"`
+	MergedAt            *time.Time    `json:"mergedAt"`
+	Merged              bool          `json:"merged"`
+	Additions           int           `json:"additions"`
+	Deletions           int           `json:"deletions"`
+	ChangedFiles        int           `json:"changedFiles"`
+	Comments            graphQLCount  `json:"comments"`
+	Commits             graphQLCount  `json:"commits"`
+	Author              *graphQLActor `json:"author"`
+	HeadRefName         string        `json:"headRefName"`
+	HeadRefOid          string        `json:"headRefOid"`
+	HeadRepositoryOwner *graphQLActor `json:"headRepositoryOwner"`
+	BaseRefName         string        `json:"baseRefName"`
+	BaseRefOid          string        `json:"baseRefOid"`
+	MergeCommit         *struct {
+		Oid string `json:"oid"`
+	} `json:"mergeCommit"`
+}
+
+// graphQLRateLimit is the cost of a query in points of the GraphQL rate limit.
+type graphQLRateLimit struct {
+	Cost int `json:"cost"`
+}
+
+// newGraphQLClient creates a GraphQL client for the API URL and the credentials of the configuration.
+func newGraphQLClient(ctx context.Context, cfg ConfigGitHub) (*graphQLClient, error) {
+	client, err := newGitHubClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &graphQLClient{client: client}, nil
+}
+
+// endpoint returns the URL of the GraphQL API: https://api.github.com/graphql for GitHub.com,
+// https://host/api/graphql for GitHub Enterprise Server with the REST API at https://host/api/v3/.
+func (c *graphQLClient) endpoint() string {
+	if strings.HasSuffix(c.client.BaseURL.Path, "/api/v3/") {
+		return c.client.BaseURL.ResolveReference(&url.URL{Path: "../graphql"}).String()
+	}
+	return c.client.BaseURL.ResolveReference(&url.URL{Path: "graphql"}).String()
+}
+
+// query runs the query and decodes its data. A query can partially fail, e.g. for a repository that
+// does not exist: the errors with a path are returned with the data, the other errors fail the query.
+// A query rejected by the rate limit waits for the reset like the REST requests.
+func (c *graphQLClient) query(ctx context.Context, query string, variables map[string]interface{}, data interface{}) ([]graphQLError, error) {
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []graphQLError  `json:"errors"`
+	}
+
+	_, err := doGitHubResource(ctx, "graphql", func() (*github.Response, error) {
+		req, err := c.client.NewRequest("POST", c.endpoint(), map[string]interface{}{"query": query, "variables": variables})
+		if err != nil {
+			return nil, err
+		}
+
+		result.Data, result.Errors = nil, nil
+		resp, err := c.client.Do(ctx, req, &result)
+		if err != nil {
+			return resp, err
+		}
+
+		// An exhausted GraphQL rate limit is reported by an error of the response
+		for _, e := range result.Errors {
+			if e.Type == "RATE_LIMITED" {
+				return resp, &github.RateLimitError{Rate: resp.Rate, Response: resp.Response, Message: e.Message}
+			}
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var partial, failed []graphQLError
+	for _, e := range result.Errors {
+		if len(e.Path) > 0 {
+			partial = append(partial, e)
+		} else {
+			failed = append(failed, e)
+		}
+	}
+	if len(failed) > 0 || len(result.Data) == 0 || string(result.Data) == "null" {
+		errs := []error{errors.New("GraphQL query failed")}
+		for _, e := range failed {
+			errs = append(errs, e)
+		}
+		return nil, errors.Join(errs...)
+	}
+
+	return partial, json.Unmarshal(result.Data, data)
+}
+
+// aliasError returns the first error of the query path starting with the alias, nil if there is none.
+func aliasError(errs []graphQLError, alias string) error {
+	for _, e := range errs {
+		if name, ok := e.Path[0].(string); ok && name == alias {
+			return e
+		}
+	}
+	return nil
+}
+
+// FetchGitHubReposGraphQL fetches the repositories of all sources of GITHUB_SOURCES with the GraphQL API,
+// with the same filters and results as FetchGitHubRepos. A page of repositories takes one query.
+//
+// Arguments:
+//   - envVars: EnvVars containing the GitHub credentials, the sources and the filters.
+//
+// Returns:
+//   - []*github.Repository: The repositories of all sources.
+//   - map[int64]string: The source of every repository by repository ID.
+//   - int: The number of GraphQL queries.
+//   - error: An error object if a source fails, otherwise nil.
+func FetchGitHubReposGraphQL(envVars EnvVars) ([]*github.Repository, map[int64]string, int, error) {
+	ctx := context.Background()
+	client, err := newGraphQLClient(ctx, envVars.GitHub)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return fetchGitHubRepos(envVars, func(source GitHubSource) ([]*github.Repository, int, error) {
+		return client.fetchSourceRepos(ctx, source)
+	})
+}
+
+// fetchSourceRepos fetches the repositories of one source and returns them with the number of queries.
+func (c *graphQLClient) fetchSourceRepos(ctx context.Context, source GitHubSource) ([]*github.Repository, int, error) {
+	if source.Type == "repo" {
+		var data struct {
+			Repository *graphQLRepo `json:"repository"`
+		}
+		query := `query($owner: String!, $name: String!) { repository(owner: $owner, name: $name) { ...RepoFields } }` + graphQLRepoFields
+		errs, err := c.query(ctx, query, map[string]interface{}{"owner": source.Owner, "name": source.Repo}, &data)
+		metrics.GitHubRequests.WithLabelValues("graphql_repos").Inc()
+		if err == nil && len(errs) > 0 {
+			err = errs[0]
+		}
+		if err != nil {
+			return nil, 1, err
+		}
+		if data.Repository == nil {
+			return nil, 1, fmt.Errorf("repository %s not found", source)
+		}
+		return []*github.Repository{c.repository(*data.Repository)}, 1, nil
+	}
+
+	// The public repositories of an organization and the repositories owned by a user, like the REST requests
+	owner := `organization(login: $login) { repositories(first: 100, after: $cursor, privacy: PUBLIC) {`
+	if source.Type == "user" {
+		owner = `user(login: $login) { repositories(first: 100, after: $cursor, ownerAffiliations: [OWNER]) {`
+	}
+	query := `query($login: String!, $cursor: String) { owner: ` + owner +
+		` pageInfo { hasNextPage endCursor } nodes { ...RepoFields } } } }` + graphQLRepoFields
+
+	var counter int
+	var allRepos []*github.Repository
+	var cursor *string
+	for {
+		var data struct {
+			Owner *struct {
+				Repositories graphQLConnection[graphQLRepo] `json:"repositories"`
+			} `json:"owner"`
+		}
+		errs, err := c.query(ctx, query, map[string]interface{}{"login": source.Owner, "cursor": cursor}, &data)
+		counter++
+		metrics.GitHubRequests.WithLabelValues("graphql_repos").Inc()
+		if err == nil && len(errs) > 0 {
+			err = errs[0]
+		}
+		if err != nil {
+			return nil, counter, err
+		}
+		if data.Owner == nil {
+			return nil, counter, fmt.Errorf("%s %s not found", source.Type, source.Owner)
+		}
+
+		for _, repo := range data.Owner.Repositories.Nodes {
+			allRepos = append(allRepos, c.repository(repo))
+		}
+		log.Printf("GitHub GraphQL: Fetch Repos: %s: Query: %d", source, counter)
+
+		pageInfo := data.Owner.Repositories.PageInfo
+		if !pageInfo.HasNextPage {
+			break
+		}
+		cursor = &pageInfo.EndCursor
+	}
+
+	return allRepos, counter, nil
+}
+
+// graphQLPullsFetch is the fetch of the pull requests of a repository by FetchGitHubPullsGraphQL.
+type graphQLPullsFetch struct {
+	repo       *github.Repository
+	checkpoint GitHubCheckpoint
+	full       bool
+	lastUpdate time.Time
+	cursor     string
+}
+
+// FetchGitHubPullsGraphQL fetches the pull requests of the repositories with the GraphQL API, a query
+// fetches a page of GraphQLReposPerQuery repositories. Like FetchGitHubPullsByRepo, a full fetch lists
+// the pull requests by creation date and continues at the cursor of an interrupted fetch, an update lists
+// them by update date until the last update of the checkpoint. The pull requests of every page are passed
+// to savePage with the checkpoint after the page, every repository is passed to done when its fetch ends.
+//
+// Arguments:
+//   - envVars: EnvVars containing the GitHub credentials.
+//   - repos: []*github.Repository to fetch the pull requests of.
+//   - checkpoints: map[string]GitHubCheckpoint containing the progress of the previous fetches by full repository name.
+//   - counter: map[string]*int containing the counters of the report.
+//   - savePage: function storing the pull requests and the checkpoint, the fetch of the repository stops at its first error.
+//   - done: function called with the checkpoint after the complete fetch of the repository or with the error of the fetch.
+func FetchGitHubPullsGraphQL(envVars EnvVars, repos []*github.Repository, checkpoints map[string]GitHubCheckpoint, counter map[string]*int,
+	savePage func(repo *github.Repository, pulls []*github.PullRequest, checkpoint GitHubCheckpoint) error,
+	done func(repo *github.Repository, checkpoint GitHubCheckpoint, err error)) {
+
+	ctx := context.Background()
+	client, err := newGraphQLClient(ctx, envVars.GitHub)
+	if err != nil {
+		for _, repo := range repos {
+			done(repo, checkpoints[repo.GetFullName()], err)
+		}
+		return
+	}
+
+	queue := repos
+	var active []*graphQLPullsFetch
+
+	for len(queue) > 0 || len(active) > 0 {
+		// A repository whose fetch has ended leaves its place in the query to the next one
+		for len(active) < envVars.GitHub.GraphQLReposPerQuery && len(queue) > 0 {
+			active = append(active, newGraphQLPullsFetch(queue[0], checkpoints[queue[0].GetFullName()], counter))
+			queue = queue[1:]
+		}
+
+		query, variables := graphQLPullsQuery(active)
+
+		var data map[string]json.RawMessage
+		errs, err := client.query(ctx, query, variables, &data)
+
+		*counter["graphql_api_requests"]++
+		metrics.GitHubRequests.WithLabelValues("graphql_pulls").Inc()
+
+		if err != nil {
+			for _, f := range active {
+				done(f.repo, f.checkpoint, err)
+			}
+			active = nil
+			continue
+		}
+
+		var rateLimit graphQLRateLimit
+		if err := json.Unmarshal(data["rateLimit"], &rateLimit); err == nil {
+			*counter["graphql_cost"] += rateLimit.Cost
+		}
+
+		var next []*graphQLPullsFetch
+		for i, f := range active {
+			alias := fmt.Sprintf("r%d", i)
+
+			var repoData *struct {
+				PullRequests graphQLConnection[graphQLPull] `json:"pullRequests"`
+			}
+			err := aliasError(errs, alias)
+			if err == nil {
+				err = json.Unmarshal(data[alias], &repoData)
+			}
+			if err == nil && repoData == nil {
+				err = errors.New("repository not found")
+			}
+
+			var finished bool
+			if err == nil {
+				finished, err = f.savePage(client, repoData.PullRequests, counter, savePage)
+			}
+
+			switch {
+			case err != nil:
+				done(f.repo, f.checkpoint, err)
+			case finished:
+				done(f.repo, f.checkpoint, nil)
+			default:
+				next = append(next, f)
+			}
+		}
+		active = next
+
+		log.Printf("GitHub GraphQL: Pulls: Queries: %d, cost: %d, repos: %d, pulls: %d",
+			*counter["graphql_api_requests"], *counter["graphql_cost"], *counter["repos"], *counter["pulls"])
+	}
+}
+
+// newGraphQLPullsFetch starts the fetch of the pull requests of the repository from its checkpoint.
+func newGraphQLPullsFetch(repo *github.Repository, checkpoint GitHubCheckpoint, counter map[string]*int) *graphQLPullsFetch {
+	*counter["repos"]++
+
+	f := &graphQLPullsFetch{repo: repo, checkpoint: checkpoint, full: checkpoint.Full()}
+	if f.full {
+		// The page of an interrupted REST fetch is not a cursor, the fetch starts again
+		f.checkpoint.NextPage = 0
+		f.cursor = checkpoint.Cursor
+		if f.cursor != "" {
+			log.Printf("GitHub GraphQL: Repo Full: %s: Continue from the cursor", repo.GetName())
+		}
+	} else {
+		var err error
+		f.lastUpdate, err = time.Parse(time.RFC3339, checkpoint.LastUpdate)
+		if err != nil {
+			log.Printf("Error parsing startedAt: %v", err)
+		}
+		// An interrupted update is repeated from the first page, it stops at the last update anyway
+		f.checkpoint.Newest = ""
+	}
+	return f
+}
+
+// graphQLPullsQuery builds the query of the next page of the pull requests of the repositories,
+// the repository of the fetch i has the alias "ri".
+func graphQLPullsQuery(fetches []*graphQLPullsFetch) (string, map[string]interface{}) {
+	var params, fields strings.Builder
+	variables := make(map[string]interface{})
+
+	for i, f := range fetches {
+		orderBy := "{field: UPDATED_AT, direction: DESC}"
+		if f.full {
+			orderBy = "{field: CREATED_AT, direction: ASC}"
+		}
+
+		fmt.Fprintf(&params, ", $o%d: String!, $n%d: String!, $c%d: String", i, i, i)
+		fmt.Fprintf(&fields, " r%d: repository(owner: $o%d, name: $n%d) { pullRequests(first: %d, after: $c%d, orderBy: %s) { pageInfo { hasNextPage endCursor } nodes { ...PullFields } } }",
+			i, i, i, graphQLPageSize, i, orderBy)
+
+		variables[fmt.Sprintf("o%d", i)] = f.repo.GetOwner().GetLogin()
+		variables[fmt.Sprintf("n%d", i)] = f.repo.GetName()
+		if f.cursor != "" {
+			variables[fmt.Sprintf("c%d", i)] = f.cursor
+		}
+	}
+
+	query := "query(" + strings.TrimPrefix(params.String(), ", ") + ") { rateLimit { cost }" + fields.String() + " }" + graphQLPullFields
+	return query, variables
+}
+
+// savePage saves a page of the pull requests of the repository and reports whether its fetch is complete.
+func (f *graphQLPullsFetch) savePage(client *graphQLClient, page graphQLConnection[graphQLPull], counter map[string]*int,
+	savePage func(repo *github.Repository, pulls []*github.PullRequest, checkpoint GitHubCheckpoint) error) (bool, error) {
+
+	// The REST path takes a request for every page of the same size
+	*counter["pulls_rest_estimate"]++
+
+	dateBreak := false
+	pulls := make([]*github.PullRequest, 0, len(page.Nodes))
+	for _, node := range page.Nodes {
+		pull := client.pullRequest(f.repo, node)
+		if !f.full && lastUpdateAfter(f.lastUpdate, pull) {
+			dateBreak = true
+			break
+		}
+		pulls = append(pulls, pull)
+	}
+
+	*counter["pulls"] += len(pulls)
+	if f.full {
+		*counter["pulls_full"] += len(pulls)
+		f.checkpoint.Cursor = ""
+		if page.PageInfo.HasNextPage {
+			f.checkpoint.Cursor = page.PageInfo.EndCursor
+		}
+	} else {
+		*counter["pulls_latest"] += len(pulls)
+	}
+	f.cursor = page.PageInfo.EndCursor
+
+	f.checkpoint.observe(pulls)
+	f.checkpoint.SavedAt = time.Now().UTC().Format(time.RFC3339)
+
+	if err := savePage(f.repo, pulls, f.checkpoint); err != nil {
+		return false, err
+	}
+
+	if page.PageInfo.HasNextPage && !dateBreak {
+		return false, nil
+	}
+
+	if f.full {
+		*counter["repos_full"]++
+	} else {
+		*counter["repos_latest"]++
+	}
+	f.checkpoint.complete()
+
+	return true, nil
+}
+
+// lastUpdateAfter reports whether the pull request was updated before the last update of the checkpoint.
+func lastUpdateAfter(lastUpdate time.Time, pull *github.PullRequest) bool {
+	return pull.UpdatedAt != nil && lastUpdate.After(*pull.UpdatedAt)
+}
+
+// repository converts a repository of the GraphQL API to the REST representation stored in the dataset.
+func (c *graphQLClient) repository(r graphQLRepo) *github.Repository {
+	repo := &github.Repository{
+		ID:              github.Int64(r.DatabaseID),
+		NodeID:          github.String(r.ID),
+		Owner:           r.Owner.user(),
+		Name:            github.String(r.Name),
+		FullName:        github.String(r.NameWithOwner),
+		Description:     r.Description,
+		CreatedAt:       &github.Timestamp{Time: r.CreatedAt},
+		UpdatedAt:       &github.Timestamp{Time: r.UpdatedAt},
+		HTMLURL:         github.String(r.URL),
+		CloneURL:        github.String(r.URL + ".git"),
+		URL:             github.String(c.client.BaseURL.String() + "repos/" + r.NameWithOwner),
+		Fork:            github.Bool(r.IsFork),
+		Private:         github.Bool(r.IsPrivate),
+		Archived:        github.Bool(r.IsArchived),
+		HasIssues:       github.Bool(r.HasIssuesEnabled),
+		ForksCount:      github.Int(r.ForkCount),
+		StargazersCount: github.Int(r.StargazerCount),
+		WatchersCount:   github.Int(r.StargazerCount), // watchers_count of the REST API is the number of stars
+		Size:            github.Int(r.DiskUsage),
+		// open_issues_count of the REST API includes the pull requests
+		OpenIssuesCount: github.Int(r.OpenIssues.TotalCount + r.OpenPulls.TotalCount),
+	}
+	if r.PushedAt != nil {
+		repo.PushedAt = &github.Timestamp{Time: *r.PushedAt}
+	}
+	if r.DefaultBranchRef != nil {
+		repo.DefaultBranch = github.String(r.DefaultBranchRef.Name)
+	}
+	if r.PrimaryLanguage != nil {
+		repo.Language = github.String(r.PrimaryLanguage.Name)
+	}
+	for _, node := range r.RepositoryTopics.Nodes {
+		repo.Topics = append(repo.Topics, node.Topic.Name)
+	}
+	return repo
+}
+
+// pullRequest converts a pull request of the GraphQL API to the REST representation stored in the dataset.
+func (c *graphQLClient) pullRequest(repo *github.Repository, p graphQLPull) *github.PullRequest {
+	// MERGED of the GraphQL API is a closed pull request of the REST API
+	state := "closed"
+	if p.State == "OPEN" {
+		state = "open"
+	}
+
+	pull := &github.PullRequest{
+		ID:           github.Int64(p.DatabaseID),
+		NodeID:       github.String(p.ID),
+		Number:       github.Int(p.Number),
+		Title:        github.String(p.Title),
+		Body:         github.String(p.Body),
+		State:        github.String(state),
+		CreatedAt:    &p.CreatedAt,
+		UpdatedAt:    &p.UpdatedAt,
+		ClosedAt:     p.ClosedAt,
+		MergedAt:     p.MergedAt,
+		Merged:       github.Bool(p.Merged),
+		Comments:     github.Int(p.Comments.TotalCount),
+		Commits:      github.Int(p.Commits.TotalCount),
+		Additions:    github.Int(p.Additions),
+		Deletions:    github.Int(p.Deletions),
+		ChangedFiles: github.Int(p.ChangedFiles),
+		URL:          github.String(fmt.Sprintf("%srepos/%s/pulls/%d", c.client.BaseURL, repo.GetFullName(), p.Number)),
+		HTMLURL:      github.String(p.URL),
+		Head: &github.PullRequestBranch{
+			Ref: github.String(p.HeadRefName),
+			SHA: github.String(p.HeadRefOid),
+		},
+		Base: &github.PullRequestBranch{
+			Label: github.String(repo.GetOwner().GetLogin() + ":" + p.BaseRefName),
+			Ref:   github.String(p.BaseRefName),
+			SHA:   github.String(p.BaseRefOid),
+			Repo:  &github.Repository{ID: repo.ID, Name: repo.Name, FullName: repo.FullName},
+		},
+	}
+	if p.Author != nil {
+		pull.User = p.Author.user()
+	}
+	if p.HeadRepositoryOwner != nil {
+		pull.Head.Label = github.String(p.HeadRepositoryOwner.Login + ":" + p.HeadRefName)
+	}
+	if p.MergeCommit != nil {
+		pull.MergeCommitSHA = github.String(p.MergeCommit.Oid)
+	}
+	return pull
+}
+
+// user converts an actor of the GraphQL API, e.g. "User" or "Bot", to a user of the REST API.
+func (a graphQLActor) user() *github.User {
+	user := &github.User{Login: github.String(a.Login), Type: github.String(a.Type)}
+	if a.DatabaseID != 0 {
+		user.ID = github.Int64(a.DatabaseID)
+	}
+	return user
+}
@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github-stat/internal/metrics"
+
+	"github.com/google/go-github/github"
+)
+
+// GitHubRate is the state of the GitHub API rate limit seen by the last response.
+type GitHubRate struct {
+	Resource  string    `json:"resource,omitempty"` // "core" of the REST API or "graphql", they are limited separately
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+	WaitUntil time.Time `json:"wait_until"` // Set while the requests wait for the rate limit
+}
+
+// Waiting reports whether the requests wait for the rate limit.
+func (r GitHubRate) Waiting() bool {
+	return time.Now().Before(r.WaitUntil)
+}
+
+// githubMaxRetries is the number of retries of a request rejected by a rate limit.
+const githubMaxRetries = 5
+
+// githubSecondaryBackoff is the first wait after a secondary rate limit without Retry-After, it doubles on every retry.
+const githubSecondaryBackoff = time.Minute
+
+var (
+	githubRateMu sync.Mutex
+	// githubRates are the rate limits by resource, "core" and "graphql"
+	githubRates    = make(map[string]GitHubRate)
+	githubRateWait time.Time
+
+	// OnGitHubRateWait is called when the requests start or stop waiting for the rate limit,
+	// the dataset loader publishes the state in its status.
+	OnGitHubRateWait func(rate GitHubRate)
+)
+
+// GitHubRateState returns the state of the GitHub API rate limit, of the resource with the fewest
+// requests left if both the REST and the GraphQL API are used.
+func GitHubRateState() GitHubRate {
+	githubRateMu.Lock()
+	defer githubRateMu.Unlock()
+
+	var state GitHubRate
+	for _, resource := range []string{"core", "graphql"} {
+		rate, ok := githubRates[resource]
+		if ok && (state.Limit == 0 || rate.Remaining*state.Limit < state.Remaining*rate.Limit) {
+			state = rate
+		}
+	}
+	state.WaitUntil = githubRateWait
+
+	return state
+}
+
+func updateGitHubRate(resource string, rate github.Rate) {
+	if rate.Limit == 0 {
+		return
+	}
+
+	githubRateMu.Lock()
+	githubRates[resource] = GitHubRate{Resource: resource, Limit: rate.Limit, Remaining: rate.Remaining, Reset: rate.Reset.Time}
+	githubRateMu.Unlock()
+
+	metrics.GitHubRateRemaining.WithLabelValues(resource).Set(float64(rate.Remaining))
+}
+
+// doGitHub runs a GitHub API request and handles the rate limits:
+// when the last response used up the limit it waits for the reset before the request,
+// a primary rate limit error is retried after the reset, a secondary rate limit error
+// after Retry-After or an exponential backoff with jitter.
+//
+// Arguments:
+//   - ctx: context.Context cancelling the waits.
+//   - request: function running the request.
+//
+// Returns:
+//   - *github.Response: The response of the last attempt.
+//   - error: The error of the last attempt, otherwise nil.
+func doGitHub(ctx context.Context, request func() (*github.Response, error)) (*github.Response, error) {
+	return doGitHubResource(ctx, "core", request)
+}
+
+// doGitHubResource runs a request of the rate limit resource like doGitHub, "graphql" for the GraphQL API.
+func doGitHubResource(ctx context.Context, resource string, request func() (*github.Response, error)) (*github.Response, error) {
+	for attempt := 0; ; attempt++ {
+		githubRateMu.Lock()
+		rate := githubRates[resource]
+		githubRateMu.Unlock()
+
+		if rate.Limit > 0 && rate.Remaining == 0 && time.Now().Before(rate.Reset) {
+			log.Printf("GitHub API: Rate limit: 0 of %d requests left, waiting until %s", rate.Limit, rate.Reset.Format(time.RFC3339))
+			if err := waitGitHubRate(ctx, rate.Reset.Add(time.Second)); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := request()
+		if resp != nil {
+			updateGitHubRate(resource, resp.Rate)
+		}
+		if err == nil || attempt >= githubMaxRetries {
+			return resp, err
+		}
+
+		if reset, ok := primaryRateLimit(err); ok {
+			log.Printf("GitHub API: Rate limit exceeded, waiting until %s: %v", reset.Format(time.RFC3339), err)
+			if err := waitGitHubRate(ctx, reset.Add(time.Second)); err != nil {
+				return resp, err
+			}
+			continue
+		}
+
+		if retryAfter, ok := secondaryRateLimit(err); ok {
+			if retryAfter == 0 {
+				retryAfter = githubSecondaryBackoff << attempt
+			}
+			// Jitter keeps parallel clients from retrying at the same moment
+			retryAfter += time.Duration(rand.Int63n(int64(retryAfter)/2 + 1))
+			log.Printf("GitHub API: Secondary rate limit, retry %d in %v: %v", attempt+1, retryAfter.Round(time.Second), err)
+			if err := waitGitHubRate(ctx, time.Now().Add(retryAfter)); err != nil {
+				return resp, err
+			}
+			continue
+		}
+
+		return resp, err
+	}
+}
+
+// waitGitHubRate waits until the time and publishes the wait with OnGitHubRateWait.
+func waitGitHubRate(ctx context.Context, until time.Time) error {
+	setGitHubRateWait(until)
+	defer setGitHubRateWait(time.Time{})
+
+	timer := time.NewTimer(time.Until(until))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func setGitHubRateWait(until time.Time) {
+	githubRateMu.Lock()
+	githubRateWait = until
+	githubRateMu.Unlock()
+
+	if OnGitHubRateWait != nil {
+		OnGitHubRateWait(GitHubRateState())
+	}
+}
+
+// primaryRateLimit returns the reset time if the error is caused by the exhausted primary rate limit.
+func primaryRateLimit(err error) (time.Time, bool) {
+	var rateErr *github.RateLimitError
+	if errors.As(err, &rateErr) {
+		return rateErr.Rate.Reset.Time, true
+	}
+
+	var respErr *github.ErrorResponse
+	if errors.As(err, &respErr) && respErr.Response != nil && isRateLimitStatus(respErr.Response.StatusCode) &&
+		respErr.Response.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, _ := strconv.ParseInt(respErr.Response.Header.Get("X-RateLimit-Reset"), 10, 64)
+		return time.Unix(reset, 0), true
+	}
+
+	return time.Time{}, false
+}
+
+// secondaryRateLimit returns the Retry-After duration, 0 if it is not set,
+// if the error is caused by a secondary (abuse) rate limit.
+func secondaryRateLimit(err error) (time.Duration, bool) {
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		return abuseErr.GetRetryAfter(), true
+	}
+
+	// go-github only recognizes the old abuse rate limit responses
+	var respErr *github.ErrorResponse
+	if !errors.As(err, &respErr) || respErr.Response == nil || !isRateLimitStatus(respErr.Response.StatusCode) {
+		return 0, false
+	}
+
+	retryAfter, _ := strconv.Atoi(respErr.Response.Header.Get("Retry-After"))
+	message := strings.ToLower(respErr.Message)
+	if retryAfter > 0 || strings.Contains(message, "secondary rate limit") || strings.Contains(message, "abuse") {
+		return time.Duration(retryAfter) * time.Second, true
+	}
+
+	return 0, false
+}
+
+func isRateLimitStatus(code int) bool {
+	return code == http.StatusForbidden || code == http.StatusTooManyRequests
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// GitHubSource is a source of the repositories of the GitHub dataset (GITHUB_SOURCES):
+// the public repositories of an organization ("percona" or "org:percona"), the repositories
+// of a user ("user:dbazhenov") or a single repository ("percona/pmm").
+type GitHubSource struct {
+	Type  string // "org", "user" or "repo"
+	Owner string
+	Repo  string // Set for the "repo" type
+}
+
+// String returns the source in the GITHUB_SOURCES format, an organization is written without the prefix.
+func (s GitHubSource) String() string {
+	switch s.Type {
+	case "user":
+		return "user:" + s.Owner
+	case "repo":
+		return s.Owner + "/" + s.Repo
+	}
+	return s.Owner
+}
+
+// ParseGitHubSources parses a comma-separated list of sources.
+//
+// Arguments:
+//   - value: string containing the sources, e.g. "percona, user:dbazhenov, valkey-io/valkey".
+//
+// Returns:
+//   - []GitHubSource: The sources without duplicates in the order of the list.
+//   - error: An error object if a source is invalid, otherwise nil.
+func ParseGitHubSources(value string) ([]GitHubSource, error) {
+	var sources []GitHubSource
+	seen := make(map[GitHubSource]bool)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		var source GitHubSource
+		switch {
+		case strings.HasPrefix(entry, "org:"):
+			source = GitHubSource{Type: "org", Owner: strings.TrimPrefix(entry, "org:")}
+		case strings.HasPrefix(entry, "user:"):
+			source = GitHubSource{Type: "user", Owner: strings.TrimPrefix(entry, "user:")}
+		case strings.Contains(entry, "/"):
+			owner, repo, _ := strings.Cut(entry, "/")
+			source = GitHubSource{Type: "repo", Owner: owner, Repo: repo}
+			if repo == "" || strings.Contains(repo, "/") {
+				return nil, fmt.Errorf("invalid GitHub source %q, expected owner/repo", entry)
+			}
+		default:
+			source = GitHubSource{Type: "org", Owner: entry}
+		}
+		if source.Owner == "" {
+			return nil, fmt.Errorf("invalid GitHub source %q, the owner is empty", entry)
+		}
+
+		if !seen[source] {
+			seen[source] = true
+			sources = append(sources, source)
+		}
+	}
+
+	return sources, nil
+}
+
+// parseGlobs parses a comma-separated list of glob patterns and checks their syntax.
+func parseGlobs(key, value string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid environment variable %s: pattern %q: %v", key, pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// Included reports whether the repository passes the GITHUB_REPOS_INCLUDE and GITHUB_REPOS_EXCLUDE filters.
+// Patterns with a slash are matched against the full name ("percona/pmm*"), the others against the name ("pmm*").
+// A repository is included if it matches an include pattern, or there are none, and no exclude pattern.
+func (c ConfigGitHub) Included(repo *github.Repository) bool {
+	matchAny := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			name := repo.GetName()
+			if strings.Contains(pattern, "/") {
+				name = repo.GetFullName()
+			}
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(c.Include) > 0 && !matchAny(c.Include) {
+		return false
+	}
+	return !matchAny(c.Exclude)
+}
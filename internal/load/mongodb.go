@@ -1,199 +1,233 @@
 package load
 
 import (
-	"github-stat/internal/databases/mongodb"
-	"log"
-	"time"
+	"context"
+	"errors"
+	"fmt"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
-	"golang.org/x/exp/rand"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// MongoDBSwitch1 handles the logic when the first switch on the control panel is on.
-// These queries run in a loop in each connection.
-func MongoDBSwitch1(client *mongo.Client, db string, id int, dbConfig map[string]string) {
+// mongoExecutor runs the MongoDB operations of a workload.
+type mongoExecutor struct {
+	client   *mongo.Client
+	database string
+	ctx      context.Context
+}
 
-	// Get the list of unique repository ids.
-	ids, err := mongodb.GetUniqueIntegers(client, db, "repositories", "id")
-	if err != nil {
-		log.Printf("MongoDB: Switch 1: Error: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
-	} else if len(ids) > 0 {
-		// Get a random repository id.
-		randomIndex := rand.Intn(len(ids))
-		randomRepo := ids[randomIndex]
-
-		// Get the repository data.
-		filter := bson.D{{Key: "id", Value: randomRepo}}
-		repo, err := mongodb.FindOne(client, db, "repositories", filter, bson.D{})
-		if err != nil {
-			log.Printf("MongoDB: Switch 1: Error: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
-		}
+// NewMongoExecutor returns an executor for the database of a MongoDB connection.
+func NewMongoExecutor(client *mongo.Client, database string) Executor {
+	return &mongoExecutor{client: client, database: database, ctx: context.Background()}
+}
 
-		// Upsert or insert the repository data into the test collection.
-		if randomRepo%2 == 0 {
-			_, err = mongodb.UpsertOneDoc(client, db, "repositoriesTest", repo)
-			if err != nil {
-				log.Printf("MongoDB: Switch 1: Upsert One: Error: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
-			}
-		} else {
-			_, err = mongodb.InsertOneDoc(client, db, "repositoriesTest", repo)
-			if err != nil && !mongo.IsDuplicateKeyError(err) {
-				log.Printf("MongoDB: Switch 1: Insert One: Error: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
-			}
-		}
+func (e *mongoExecutor) DBType() string {
+	return "mongodb"
+}
+
+func (e *mongoExecutor) SchemaMode() string {
+	return ""
+}
 
-		// Delete old documents from the test collection.
-		filter_delete := bson.D{{Key: "id", Value: randomRepo}}
-		err = mongodb.DeleteDocuments(client, db, "repositoriesTest", filter_delete)
+// Exec runs the MongoDB operation of the query. Documents are returned as bson.M.
+// Duplicate key errors of inserts are ignored, they are expected when several connections
+// copy the same documents into the test collections.
+func (e *mongoExecutor) Exec(query Query, params Params) ([]Row, error) {
+	m := query.MongoDB
+	coll := e.client.Database(e.database).Collection(m.Collection)
+
+	var values [6]interface{}
+	for i, parsed := range []interface{}{m.filter, m.sort, m.pipeline, m.document, m.documents, m.update} {
+		value, err := substitute(parsed, params)
 		if err != nil {
-			log.Printf("MongoDB: Switch 1: Delete old documents: Error: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
+			return nil, err
 		}
+		values[i] = value
 	}
-
-	// Select a random document from the pulls collection.
-	_, err = mongodb.SelectRandomDocument(client, db, "pulls")
-	if err != nil {
-		log.Printf("MongoDB: Error: Switch 1: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
+	filter, sort, pipeline, document, documents, update := values[0], values[1], values[2], values[3], values[4], values[5]
+	if filter == nil {
+		filter = bson.D{}
 	}
-}
 
-// MongoDBSwitch2 handles the logic when the second switch on the control panel is on.
-// These queries run in a loop in each connection.
-func MongoDBSwitch2(client *mongo.Client, db string, id int, dbConfig map[string]string) {
-
-	// Select a random document from the pulls collection.
-	one_document, err := mongodb.SelectRandomDocument(client, db, "pulls")
-	if err != nil {
-		log.Printf("MongoDB: Error: Switch 2: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
-	} else if one_document != nil {
-		// Find the repository associated with the pull request.
-		filter := bson.D{{Key: "name", Value: one_document["repo"]}}
-		_, err := mongodb.FindOne(client, db, "repositories", filter, bson.D{})
+	switch m.Operation {
+	case "find":
+		opts := options.Find()
+		if sort != nil {
+			opts.SetSort(sort)
+		}
+		if m.Limit > 0 {
+			opts.SetLimit(m.Limit)
+		}
+		cursor, err := coll.Find(e.ctx, filter, opts)
 		if err != nil {
-			log.Printf("MongoDB: Error: Switch 2: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
+			return nil, err
 		}
+		return e.documents(cursor)
 
-		// Upsert or insert the pull request data into the test collection.
-		if id%2 == 0 {
-			_, err = mongodb.UpsertOneDoc(client, db, "pullsTest", one_document)
-			if err != nil {
-				log.Printf("MongoDB: Upsert One: Error: Switch 2: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
-			}
-		} else {
-			_, err = mongodb.InsertOneDoc(client, db, "pullsTest", one_document)
-			if err != nil && !mongo.IsDuplicateKeyError(err) {
-				log.Printf("MongoDB: Insert One: Error: Switch 2: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
-			}
+	case "aggregate":
+		if pipeline == nil {
+			pipeline = bson.A{}
 		}
-
-		// Delete old documents from the test collection.
-		filter_delete := bson.D{{Key: "id", Value: one_document["id"]}}
-		err = mongodb.DeleteDocuments(client, db, "pullsTest", filter_delete)
+		cursor, err := coll.Aggregate(e.ctx, pipeline)
 		if err != nil {
-			log.Printf("MongoDB: Delete old documents: Error: Switch 2: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
+			return nil, err
 		}
-	}
-}
-
-// MongoDBSwitch3 handles the logic when the third switch on the control panel is on.
-// These queries run in a loop in each connection.
-func MongoDBSwitch3(client *mongo.Client, db string, id int, dbConfig map[string]string) {
-	filterPulls := bson.D{}
-
-	// Find pull requests with no specific filter.
-	documents, err := mongodb.FindPullRequests(client, db, "pulls", filterPulls, bson.D{}, 100)
-	if err != nil {
-		log.Printf("MongoDB: Switch3: List docs: Error: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
-	}
+		return e.documents(cursor)
 
-	if len(documents) > 0 {
-		// Extract all ids from documents.
-		var interfaceDocs []interface{}
-		pulls_ids := make([]interface{}, len(documents))
-		for i, doc := range documents {
-			interfaceDocs = append(interfaceDocs, doc)
-			pulls_ids[i] = doc.ID
+	case "distinct":
+		distinct, err := coll.Distinct(e.ctx, m.Field, filter)
+		if err != nil {
+			return nil, err
 		}
-
-		// Insert pull request documents into the test collection.
-		_, err = mongodb.InsertManyDocuments(client, db, "pullsTest", interfaceDocs)
-		if err != nil && !mongo.IsDuplicateKeyError(err) {
-			log.Printf("MongoDB: Switch3: Insert: Error: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
+		rows := make([]Row, len(distinct))
+		for i, value := range distinct {
+			rows[i] = Row{value}
 		}
+		return rows, nil
 
-		// Create filter to delete documents by id.
-		filter_delete := bson.D{{Key: "id", Value: bson.D{{Key: "$in", Value: pulls_ids}}}}
-		err = mongodb.DeleteDocuments(client, db, "pullsTest", filter_delete)
+	case "count":
+		count, err := coll.CountDocuments(e.ctx, filter)
 		if err != nil {
-			log.Printf("MongoDB: Switch3: Delete old documents: Error: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
+			return nil, err
 		}
-	}
-
-	// Find repositories with no specific filter.
-	repos, err := mongodb.FindRepos(client, db, "repositories", filterPulls, bson.D{}, 100)
-	if err != nil {
-		log.Printf("MongoDB: Switch3: List docs: Error: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
-	}
+		return []Row{{count}}, nil
 
-	if len(repos) > 0 {
-		var reposDocs []interface{}
-		repos_ids := make([]interface{}, len(repos))
-		for i, doc := range repos {
-			reposDocs = append(reposDocs, doc)
-			repos_ids[i] = doc.ID
+	case "insert_one":
+		if document == nil {
+			return nil, nil
 		}
+		_, err := coll.InsertOne(e.ctx, document)
+		return nil, ignoreDuplicateKey(err)
 
-		// Insert repository documents into the test collection.
-		_, err = mongodb.InsertManyDocuments(client, db, "repositoriesTest", reposDocs)
-		if err != nil && !mongo.IsDuplicateKeyError(err) {
-			log.Printf("MongoDB: Switch3: Insert: Error: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
+	case "insert_many":
+		docs, ok := toList(documents)
+		if !ok {
+			return nil, fmt.Errorf("documents must be a list")
 		}
+		if len(docs) == 0 {
+			return nil, nil
+		}
+		_, err := coll.InsertMany(e.ctx, docs, options.InsertMany().SetOrdered(false))
+		return nil, ignoreDuplicateKey(err)
 
-		// Create filter to delete documents by id.
-		filter_repos_delete := bson.D{{Key: "id", Value: bson.D{{Key: "$in", Value: repos_ids}}}}
-		err = mongodb.DeleteDocuments(client, db, "repositoriesTest", filter_repos_delete)
-		if err != nil {
-			log.Printf("MongoDB: Switch3: Delete old documents: Error: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
-		} else {
-			log.Printf("MongoDB: Switch3: goroutine: %d: database: %s: Repos not found, probably database is empty, run dataset import", id, dbConfig["id"])
+	case "upsert_one":
+		if document == nil {
+			return nil, nil
 		}
+		if m.filter == nil {
+			filter = bson.M{"_id": field(document, "_id")}
+		}
+		_, err := coll.UpdateOne(e.ctx, filter, bson.M{"$set": document}, options.Update().SetUpsert(true))
+		return nil, err
+
+	case "update_many":
+		_, err := coll.UpdateMany(e.ctx, filter, update)
+		return nil, err
+
+	case "delete_many":
+		_, err := coll.DeleteMany(e.ctx, filter)
+		return nil, err
 	}
+
+	return nil, fmt.Errorf("unknown operation %q", m.Operation)
 }
 
-// MongoDBSwitch4 handles the logic when the fourth switch on the control panel is on.
-// These queries run in a loop in each connection.
-func MongoDBSwitch4(client *mongo.Client, db string, id int, dbConfig map[string]string) {
-	// Find repositories with more than 10 stargazers.
-	filter_repos := bson.D{{Key: "stargazerscount", Value: bson.D{{Key: "$gt", Value: 10}}}}
-	sort_repos := bson.D{{Key: "stargazerscount", Value: -1}}
-	_, err := mongodb.FindRepos(client, db, "repositories", filter_repos, sort_repos, 10)
-	if err != nil {
-		log.Printf("MongoDB: Switch4: Error: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
+// documents reads all documents of the cursor.
+func (e *mongoExecutor) documents(cursor *mongo.Cursor) ([]Row, error) {
+	var docs []bson.M
+	if err := cursor.All(e.ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	rows := make([]Row, len(docs))
+	for i, doc := range docs {
+		rows[i] = Row{doc}
 	}
+	return rows, nil
+}
 
-	// Get the current time minus 3 months.
-	time := time.Now().AddDate(0, -3, 0)
+// Transaction runs the function in a MongoDB transaction, which requires a replica set or a sharded cluster.
+func (e *mongoExecutor) Transaction(fn func(Executor) error) error {
+	if _, inTx := e.ctx.(mongo.SessionContext); inTx {
+		return fn(e)
+	}
 
-	// Find pull requests created within the last 3 months.
-	filterPulls := bson.D{{Key: "createdat", Value: bson.D{{Key: "$gt", Value: time}}}}
-	documents, err := mongodb.FindDocuments(client, db, "pulls", filterPulls, bson.D{}, 10)
+	session, err := e.client.StartSession()
 	if err != nil {
-		log.Printf("MongoDB: Switch4: List docs: Error: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
+		return err
 	}
+	defer session.EndSession(e.ctx)
+
+	_, err = session.WithTransaction(e.ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(&mongoExecutor{client: e.client, database: e.database, ctx: sc})
+	})
+	return err
+}
 
-	if len(documents) > 0 {
-		// Insert pull request documents into the test collection.
-		_, err = mongodb.InsertManyDocuments(client, db, "pullsTest", documents)
-		if err != nil && !mongo.IsDuplicateKeyError(err) {
-			log.Printf("MongoDB: Switch4: Insert Many Docs: Error: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
+// substitute replaces the placeholders in a parsed JSON value with the params.
+func substitute(value interface{}, params Params) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		match := fullPlaceholder.FindStringSubmatch(v)
+		if match == nil {
+			return v, nil
+		}
+		return params.Resolve(match[1])
+	case bson.D:
+		result := make(bson.D, len(v))
+		for i, e := range v {
+			item, err := substitute(e.Value, params)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = bson.E{Key: e.Key, Value: item}
 		}
+		return result, nil
+	case bson.A:
+		result := make(bson.A, len(v))
+		for i, e := range v {
+			item, err := substitute(e, params)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = item
+		}
+		return result, nil
+	}
+	return value, nil
+}
 
-		// Delete old documents from the test collection that are older than 3 months.
-		filter_delete := bson.D{{Key: "createdat", Value: bson.D{{Key: "$lt", Value: time}}}}
-		err = mongodb.DeleteDocuments(client, db, "pullsTest", filter_delete)
-		if err != nil {
-			log.Printf("MongoDB: Switch4: Delete old documents: Error: goroutine: %d: database: %s: message: %s", id, dbConfig["id"], err)
+// toList converts a list value into the documents of InsertMany.
+func toList(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, true
+	case []interface{}:
+		return v, true
+	case bson.A:
+		return []interface{}(v), true
+	}
+	return nil, false
+}
+
+// ignoreDuplicateKey hides duplicate key errors, which are expected when the same documents
+// are inserted into the test collections by several connections.
+func ignoreDuplicateKey(err error) error {
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) {
+		if bulkErr.WriteConcernError != nil {
+			return err
+		}
+		for _, writeErr := range bulkErr.WriteErrors {
+			if writeErr.Code != 11000 {
+				return err
+			}
 		}
+		return nil
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
 	}
+	return err
 }
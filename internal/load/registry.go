@@ -0,0 +1,152 @@
+package load
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	app "github-stat/internal"
+)
+
+// builtinFiles contains the built-in workloads, the four switches of the control panel.
+//
+//go:embed workloads/*.yaml
+var builtinFiles embed.FS
+
+// dbTypes are the database types the workload queries can be written for.
+var dbTypes = []string{"mysql", "postgres", "mongodb"}
+
+var (
+	workloads      = make(map[string]*Workload)
+	workloadsOrder []string
+	workloadsMutex sync.RWMutex
+)
+
+func init() {
+	files, err := builtinFiles.ReadDir("workloads")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, file := range files {
+		data, err := builtinFiles.ReadFile("workloads/" + file.Name())
+		if err != nil {
+			panic(err)
+		}
+		parsed, err := Parse(data)
+		if err != nil {
+			panic(fmt.Sprintf("load: built-in workloads: %s: %v", file.Name(), err))
+		}
+		for _, w := range parsed {
+			w.builtin = true
+			if err := register(w); err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// register adds a workload, names must be unique.
+func register(w *Workload) error {
+	workloadsMutex.Lock()
+	defer workloadsMutex.Unlock()
+
+	if _, exists := workloads[w.Name]; exists {
+		return fmt.Errorf("workload %q is already defined", w.Name)
+	}
+	workloads[w.Name] = w
+	workloadsOrder = append(workloadsOrder, w.Name)
+	return nil
+}
+
+// LoadWorkloads reads the workload files (*.yaml, *.yml) from the directory and adds them
+// to the built-in workloads. Invalid files are skipped and reported in the returned error.
+//
+// Arguments:
+//   - dir: string containing the path to the directory.
+//
+// Returns:
+//   - int: The number of loaded workloads.
+//   - error: An error if the directory or a file cannot be read or is invalid, otherwise nil.
+func LoadWorkloads(dir string) (int, error) {
+	var paths []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return 0, err
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		if _, err := os.Stat(dir); err != nil {
+			return 0, err
+		}
+	}
+	sort.Strings(paths)
+
+	var errs []error
+	loaded := 0
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		parsed, err := Parse(data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+
+		for _, w := range parsed {
+			if err := register(w); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+			log.Printf("Workloads: Loaded %s from %s", w.Name, path)
+			loaded++
+		}
+	}
+
+	return loaded, errors.Join(errs...)
+}
+
+// GetWorkload returns the workload with the given name.
+func GetWorkload(name string) (*Workload, bool) {
+	workloadsMutex.RLock()
+	defer workloadsMutex.RUnlock()
+
+	w, ok := workloads[name]
+	return w, ok
+}
+
+// Workloads returns the description of all workloads, the built-in ones first.
+func Workloads() []app.WorkloadInfo {
+	workloadsMutex.RLock()
+	defer workloadsMutex.RUnlock()
+
+	list := make([]app.WorkloadInfo, 0, len(workloadsOrder))
+	for _, name := range workloadsOrder {
+		w := workloads[name]
+
+		info := app.WorkloadInfo{
+			Name:        w.Name,
+			Title:       w.Title,
+			Description: w.Description,
+			Builtin:     w.builtin,
+		}
+		for _, dbType := range dbTypes {
+			if w.Supports(dbType) {
+				info.DBTypes = append(info.DBTypes, dbType)
+			}
+		}
+		list = append(list, info)
+	}
+	return list
+}
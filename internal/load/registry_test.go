@@ -0,0 +1,49 @@
+package load
+
+import (
+	"testing"
+
+	app "github-stat/internal"
+)
+
+func TestBuiltinWorkloads(t *testing.T) {
+	for _, name := range []string{"switch1", "switch2", "switch3", "switch4"} {
+		w, ok := GetWorkload(name)
+		if !ok {
+			t.Errorf("built-in workload %s is not registered", name)
+			continue
+		}
+		if !w.builtin {
+			t.Errorf("workload %s is not marked as built-in", name)
+		}
+		for _, dbType := range dbTypes {
+			if !w.Supports(dbType) {
+				t.Errorf("workload %s does not support %s", name, dbType)
+			}
+		}
+	}
+
+	info := Workloads()
+	if len(info) < 4 || info[0].Name != "switch1" || !info[0].Builtin {
+		t.Errorf("Workloads() does not list the built-in workloads first: %+v", info)
+	}
+}
+
+func TestBuiltinParity(t *testing.T) {
+	// The SQL queries of switch3 and switch4 are split between the goroutines by the database type
+	for _, name := range []string{"switch3", "switch4"} {
+		w, _ := GetWorkload(name)
+		for _, step := range w.Steps {
+			for _, query := range step.Queries {
+				for _, mode := range []string{app.SchemaModeJSON, app.SchemaModeNormalized} {
+					if query.Text("mysql", mode) != "" && query.Routines != "odd" {
+						t.Errorf("%s: %s: the MySQL query runs on %q goroutines in the %s mode, want odd", name, query.Name, query.Routines, mode)
+					}
+					if query.Text("postgres", mode) != "" && query.Routines != "even" {
+						t.Errorf("%s: %s: the PostgreSQL query runs on %q goroutines in the %s mode, want even", name, query.Name, query.Routines, mode)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	app "github-stat/internal"
+	"github-stat/internal/metrics"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/exp/rand"
+)
+
+// Executor runs the queries of a workload in one database connection.
+type Executor interface {
+	// DBType returns the database type: "mysql", "postgres" or "mongodb".
+	DBType() string
+
+	// SchemaMode returns the schema mode that selects the query variants, empty for MongoDB.
+	SchemaMode() string
+
+	// Exec runs a query with the params and returns the result rows.
+	// A SQL row holds the column values, a MongoDB row holds one document or value.
+	Exec(query Query, params Params) ([]Row, error)
+
+	// Transaction runs the function with an executor bound to a transaction.
+	// The transaction is committed if the function returns nil, otherwise it is rolled back.
+	Transaction(fn func(Executor) error) error
+}
+
+// WaitFunc is called before every query of a workload and blocks until the query may run,
+// so a target rate counts the statements and not the runs of the workloads. An error stops the run.
+type WaitFunc func() error
+
+// Row is one row of a query result.
+type Row []interface{}
+
+// Params holds the values generated for a run of a workload and the values captured by its queries.
+type Params map[string]interface{}
+
+// errNoRows stops a step when a query captures nothing, e.g. when the dataset is not imported yet.
+var errNoRows = errors.New("no rows to capture")
+
+// Resolve returns the value of a placeholder: a name or a dotted path to a field of a captured document.
+// A field of a list is resolved for every item.
+func (p Params) Resolve(ref string) (interface{}, error) {
+	parts := strings.Split(ref, ".")
+
+	value, ok := p[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown placeholder {{%s}}", ref)
+	}
+	for _, part := range parts[1:] {
+		value = field(value, part)
+	}
+	return value, nil
+}
+
+// field returns a field of a document, or the field of every document of a list.
+func field(value interface{}, name string) interface{} {
+	switch v := value.(type) {
+	case bson.M:
+		return v[name]
+	case map[string]interface{}:
+		return v[name]
+	case bson.D:
+		for _, e := range v {
+			if e.Key == name {
+				return e.Value
+			}
+		}
+	case bson.A:
+		return field([]interface{}(v), name)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, item := range v {
+			values[i] = field(item, name)
+		}
+		return values
+	}
+	return nil
+}
+
+// RunEnabled runs every workload enabled for the database once.
+//
+// Arguments:
+//   - exec: Executor bound to the connection of the goroutine.
+//   - routineID: int containing the ID of the load goroutine.
+//   - dbConfig: app.DatabaseConfig containing the database configuration.
+//   - wait: WaitFunc called before every query, nil runs the queries back-to-back.
+func RunEnabled(exec Executor, routineID int, dbConfig app.DatabaseConfig, wait WaitFunc) {
+	for _, name := range dbConfig.EnabledWorkloads() {
+		w, ok := GetWorkload(name)
+		if !ok {
+			warnOnce(dbConfig.ID+"/"+name, "%s: Warning: database: %s: workload %s is not available in this load generator", exec.DBType(), dbConfig.ID, name)
+			continue
+		}
+		if !w.Supports(exec.DBType()) {
+			warnOnce(dbConfig.ID+"/"+name, "%s: Warning: database: %s: workload %s has no queries for %s", exec.DBType(), dbConfig.ID, name, exec.DBType())
+			continue
+		}
+
+		err := Run(w, exec, routineID, dbConfig.ID, wait)
+		// The load is stopped while the query waits for its turn
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return
+		}
+		if err != nil {
+			log.Printf("%s: Error: %s: goroutine: %d: database: %s: message: %s", exec.DBType(), name, routineID, dbConfig.ID, err)
+		}
+	}
+}
+
+// Run runs one step of the workload picked by weight. The latency and the result of every query
+// are recorded in the metrics package under the workload name and the query name.
+//
+// Arguments:
+//   - w: *Workload to run.
+//   - exec: Executor bound to the database connection.
+//   - routineID: int containing the ID of the load goroutine, queries with a routines setting run on half of them.
+//   - databaseID: string containing the database ID used for the metrics.
+//   - wait: WaitFunc called before every query, nil runs the queries back-to-back.
+//
+// Returns:
+//   - error: The error of the first failed query or of wait, otherwise nil.
+func Run(w *Workload, exec Executor, routineID int, databaseID string, wait WaitFunc) error {
+	step := w.pickStep()
+
+	params, err := w.generateParams(exec, databaseID, wait)
+	if err != nil {
+		return err
+	}
+
+	run := func(exec Executor) error {
+		for _, query := range step.Queries {
+			if !query.Has(exec.DBType(), exec.SchemaMode()) || !query.RunsOn(routineID) {
+				continue
+			}
+			if err := runQuery(exec, w.Name, databaseID, query, params, wait); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if step.Transaction {
+		err = exec.Transaction(run)
+	} else {
+		err = run(exec)
+	}
+
+	if errors.Is(err, errNoRows) {
+		return nil
+	}
+	return err
+}
+
+// runQuery runs a query and stores the captured values in the params.
+func runQuery(exec Executor, workload, databaseID string, query Query, params Params, wait WaitFunc) error {
+	// The wait is not part of the latency of the query
+	if wait != nil {
+		if err := wait(); err != nil {
+			return err
+		}
+	}
+
+	done := metrics.Start(databaseID, workload, query.Name)
+	rows, err := exec.Exec(query, params)
+	done(err)
+	if err != nil {
+		return fmt.Errorf("%s: %w", query.Name, err)
+	}
+
+	if len(query.Capture) == 0 {
+		return nil
+	}
+
+	if query.Pick == "all" {
+		values := make([]interface{}, len(rows))
+		for i, row := range rows {
+			if len(row) == 1 {
+				values[i] = row[0]
+			} else {
+				values[i] = []interface{}(row)
+			}
+		}
+		params[query.Capture[0]] = values
+		return nil
+	}
+
+	if len(rows) == 0 {
+		return errNoRows
+	}
+
+	row := rows[0]
+	if query.Pick == "random" {
+		row = rows[rand.Intn(len(rows))]
+	}
+	for i, name := range query.Capture {
+		if i < len(row) {
+			params[name] = row[i]
+		}
+	}
+	return nil
+}
+
+// pickStep returns a random step, the probability of a step is proportional to its weight.
+func (w *Workload) pickStep() Step {
+	total := 0
+	for _, step := range w.Steps {
+		total += step.Weight
+	}
+
+	n := rand.Intn(total)
+	for _, step := range w.Steps {
+		if n < step.Weight {
+			return step
+		}
+		n -= step.Weight
+	}
+	return w.Steps[len(w.Steps)-1]
+}
+
+// generateParams generates the values of the workload params for one run.
+func (w *Workload) generateParams(exec Executor, databaseID string, wait WaitFunc) (Params, error) {
+	params := make(Params, len(w.Params))
+
+	for name, param := range w.Params {
+		switch param.Generator {
+		case "int":
+			params[name] = int64(param.Min + rand.Intn(param.Max-param.Min+1))
+		case "choice":
+			params[name] = param.Values[rand.Intn(len(param.Values))]
+		case "date":
+			days := param.Min + rand.Intn(param.Max-param.Min+1)
+			params[name] = time.Now().AddDate(0, 0, -days).UTC()
+		default:
+			values, err := lookup(exec, databaseID, param.Generator, wait)
+			if err != nil {
+				return nil, fmt.Errorf("param %s: %w", name, err)
+			}
+			if len(values) == 0 {
+				return nil, errNoRows
+			}
+			params[name] = values[rand.Intn(len(values))]
+		}
+	}
+
+	return params, nil
+}
+
+// lookupLimit is the number of values a lookup samples, so it does not read a whole table
+// of a large dataset into memory.
+const lookupLimit = 1000
+
+// lookupQueries sample the values of the generators that pick random rows of the dataset.
+// The MongoDB lookups return documents, the value is read from the Field of the query.
+var lookupQueries = map[string]Query{
+	"random_repo_id": {
+		Name:     "lookup_repo_ids",
+		MySQL:    fmt.Sprintf("SELECT id FROM repositories ORDER BY RAND() LIMIT %d", lookupLimit),
+		Postgres: fmt.Sprintf("SELECT id FROM github.repositories ORDER BY random() LIMIT %d", lookupLimit),
+		MongoDB:  sampleQuery("repositories", "id", false),
+	},
+	"random_pull_id": {
+		Name:     "lookup_pull_ids",
+		MySQL:    fmt.Sprintf("SELECT id FROM pulls ORDER BY RAND() LIMIT %d", lookupLimit),
+		Postgres: fmt.Sprintf("SELECT id FROM github.pulls ORDER BY random() LIMIT %d", lookupLimit),
+		MongoDB:  sampleQuery("pulls", "id", false),
+	},
+	"random_repo_name": {
+		Name:     "lookup_repo_names",
+		MySQL:    fmt.Sprintf("SELECT repo FROM (SELECT DISTINCT repo FROM pulls) AS repos ORDER BY RAND() LIMIT %d", lookupLimit),
+		Postgres: fmt.Sprintf("SELECT repo FROM (SELECT DISTINCT repo FROM github.pulls) AS repos ORDER BY random() LIMIT %d", lookupLimit),
+		MongoDB:  sampleQuery("pulls", "repo", true),
+	},
+}
+
+// sampleQuery returns an aggregation that samples lookupLimit values of the field with $sample,
+// distinct values are grouped first.
+func sampleQuery(collection string, field string, distinct bool) *MongoQuery {
+	sample := bson.D{{Key: "$sample", Value: bson.D{{Key: "size", Value: lookupLimit}}}}
+	if !distinct {
+		project := bson.D{{Key: "$project", Value: bson.D{{Key: field, Value: 1}}}}
+		return &MongoQuery{Collection: collection, Operation: "aggregate", Field: field, pipeline: bson.A{sample, project}}
+	}
+	group := bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$" + field}}}}
+	return &MongoQuery{Collection: collection, Operation: "aggregate", Field: "_id", pipeline: bson.A{group, sample}}
+}
+
+// lookupTTL is how long the values of a lookup are reused, so the lookups do not dominate the load.
+const lookupTTL = 30 * time.Second
+
+type lookupResult struct {
+	values  []interface{}
+	expires time.Time
+}
+
+var (
+	lookups      = make(map[string]lookupResult)
+	lookupsMutex sync.Mutex
+)
+
+// lookup returns the sampled values of a generator for the database, they are cached for lookupTTL.
+// A lookup query waits for its turn like the queries of the workloads.
+func lookup(exec Executor, databaseID, generator string, wait WaitFunc) ([]interface{}, error) {
+	key := databaseID + "/" + generator
+
+	lookupsMutex.Lock()
+	cached, ok := lookups[key]
+	lookupsMutex.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.values, nil
+	}
+
+	query := lookupQueries[generator]
+	if wait != nil {
+		if err := wait(); err != nil {
+			return nil, err
+		}
+	}
+	done := metrics.Start(databaseID, "lookup", query.Name)
+	rows, err := exec.Exec(query, nil)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		value := row[0]
+		if doc, ok := value.(bson.M); ok {
+			value = doc[query.MongoDB.Field]
+		}
+		values = append(values, value)
+	}
+
+	lookupsMutex.Lock()
+	lookups[key] = lookupResult{values: values, expires: time.Now().Add(lookupTTL)}
+	lookupsMutex.Unlock()
+
+	return values, nil
+}
+
+var warned sync.Map
+
+// warnOnce logs a message only the first time for the key, so a misconfiguration does not flood the log.
+func warnOnce(key string, format string, args ...interface{}) {
+	if _, loaded := warned.LoadOrStore(key, true); !loaded {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,76 @@
+package load
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// fakeExecutor returns the rows for every query and counts the queries.
+type fakeExecutor struct {
+	dbType  string
+	rows    []Row
+	queries []Query
+}
+
+func (e *fakeExecutor) DBType() string { return e.dbType }
+
+func (e *fakeExecutor) SchemaMode() string { return "" }
+
+func (e *fakeExecutor) Exec(query Query, params Params) ([]Row, error) {
+	e.queries = append(e.queries, query)
+	return e.rows, nil
+}
+
+func (e *fakeExecutor) Transaction(fn func(Executor) error) error { return fn(e) }
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name      string
+		exec      *fakeExecutor
+		generator string
+		want      []interface{}
+	}{
+		{
+			name:      "sql",
+			exec:      &fakeExecutor{dbType: "mysql", rows: []Row{{int64(1)}, {int64(2)}}},
+			generator: "random_pull_id",
+			want:      []interface{}{int64(1), int64(2)},
+		},
+		{
+			name:      "mongodb documents",
+			exec:      &fakeExecutor{dbType: "mongodb", rows: []Row{{bson.M{"_id": "x", "id": int64(7)}}}},
+			generator: "random_pull_id",
+			want:      []interface{}{int64(7)},
+		},
+		{
+			name:      "mongodb grouped names",
+			exec:      &fakeExecutor{dbType: "mongodb", rows: []Row{{bson.M{"_id": "percona/pmm"}}}},
+			generator: "random_repo_name",
+			want:      []interface{}{"percona/pmm"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			databaseID := "test-" + tt.name
+			for i := 0; i < 2; i++ {
+				values, err := lookup(tt.exec, databaseID, tt.generator, nil)
+				if err != nil {
+					t.Fatalf("lookup() error = %v", err)
+				}
+				if len(values) != len(tt.want) {
+					t.Fatalf("lookup() = %v, want %v", values, tt.want)
+				}
+				for j := range values {
+					if values[j] != tt.want[j] {
+						t.Errorf("lookup() = %v, want %v", values, tt.want)
+					}
+				}
+			}
+			if len(tt.exec.queries) != 1 {
+				t.Errorf("lookup() ran %d queries, want 1 cached for lookupTTL", len(tt.exec.queries))
+			}
+		})
+	}
+}
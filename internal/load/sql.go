@@ -0,0 +1,124 @@
+package load
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// sqlExecutor runs the SQL queries of a workload in MySQL or PostgreSQL.
+type sqlExecutor struct {
+	dbType     string
+	schemaMode string
+	db         *sql.DB
+	q          queryer
+}
+
+// NewSQLExecutor returns an executor for a MySQL ("mysql") or PostgreSQL ("postgres") connection.
+// The schema mode of the database selects the query variants.
+func NewSQLExecutor(db *sql.DB, dbType string, schemaMode string) Executor {
+	return &sqlExecutor{dbType: dbType, schemaMode: schemaMode, db: db, q: db}
+}
+
+func (e *sqlExecutor) DBType() string {
+	return e.dbType
+}
+
+func (e *sqlExecutor) SchemaMode() string {
+	return e.schemaMode
+}
+
+// Exec replaces the placeholders with bind parameters, runs the query and reads all rows.
+// Text values are returned as strings.
+func (e *sqlExecutor) Exec(query Query, params Params) ([]Row, error) {
+	text, args, err := e.bind(query.Text(e.dbType, e.schemaMode), params)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := e.q.Query(text, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Row
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		result = append(result, values)
+	}
+
+	return result, rows.Err()
+}
+
+// bind replaces the placeholders with "?" for MySQL or "$n" for PostgreSQL and returns the arguments.
+func (e *sqlExecutor) bind(text string, params Params) (string, []interface{}, error) {
+	var args []interface{}
+	var bindErr error
+	numbers := make(map[string]int)
+
+	result := placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		ref := placeholderPattern.FindStringSubmatch(match)[1]
+
+		if e.dbType == "postgres" {
+			if n, ok := numbers[ref]; ok {
+				return "$" + strconv.Itoa(n)
+			}
+		}
+
+		value, err := params.Resolve(ref)
+		if err != nil && bindErr == nil {
+			bindErr = err
+		}
+		args = append(args, value)
+
+		if e.dbType == "postgres" {
+			numbers[ref] = len(args)
+			return "$" + strconv.Itoa(len(args))
+		}
+		return "?"
+	})
+
+	return result, args, bindErr
+}
+
+// Transaction runs the function in a database transaction. Nested calls use the outer transaction.
+func (e *sqlExecutor) Transaction(fn func(Executor) error) error {
+	if _, inTx := e.q.(*sql.Tx); inTx {
+		return fn(e)
+	}
+
+	tx, err := e.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+
+	if err := fn(&sqlExecutor{dbType: e.dbType, schemaMode: e.schemaMode, db: e.db, q: tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,89 @@
+package load
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSQLBind(t *testing.T) {
+	params := Params{
+		"id":   int64(7),
+		"repo": "pmm",
+		"pull": map[string]interface{}{"number": 42},
+	}
+
+	tests := []struct {
+		name   string
+		dbType string
+		text   string
+		want   string
+		args   []interface{}
+	}{
+		{
+			name:   "mysql",
+			dbType: "mysql",
+			text:   "SELECT * FROM pulls WHERE id = {{id}} AND repo = {{ repo }}",
+			want:   "SELECT * FROM pulls WHERE id = ? AND repo = ?",
+			args:   []interface{}{int64(7), "pmm"},
+		},
+		{
+			name:   "mysql repeats the argument",
+			dbType: "mysql",
+			text:   "SELECT {{id}}, {{repo}}, {{id}}",
+			want:   "SELECT ?, ?, ?",
+			args:   []interface{}{int64(7), "pmm", int64(7)},
+		},
+		{
+			name:   "postgres",
+			dbType: "postgres",
+			text:   "SELECT * FROM github.pulls WHERE id = {{id}} AND repo = {{repo}}",
+			want:   "SELECT * FROM github.pulls WHERE id = $1 AND repo = $2",
+			args:   []interface{}{int64(7), "pmm"},
+		},
+		{
+			name:   "postgres reuses the number",
+			dbType: "postgres",
+			text:   "SELECT {{id}}, {{repo}}, {{id}}",
+			want:   "SELECT $1, $2, $1",
+			args:   []interface{}{int64(7), "pmm"},
+		},
+		{
+			name:   "field of a captured document",
+			dbType: "postgres",
+			text:   "SELECT {{pull.number}}",
+			want:   "SELECT $1",
+			args:   []interface{}{42},
+		},
+		{
+			name:   "no placeholders",
+			dbType: "mysql",
+			text:   "SELECT 1",
+			want:   "SELECT 1",
+			args:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &sqlExecutor{dbType: tt.dbType}
+			text, args, err := e.bind(tt.text, params)
+			if err != nil {
+				t.Fatalf("bind() error = %v", err)
+			}
+			if text != tt.want {
+				t.Errorf("bind() text = %q, want %q", text, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("bind() args = %#v, want %#v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestSQLBindUnknownPlaceholder(t *testing.T) {
+	e := &sqlExecutor{dbType: "mysql"}
+	_, _, err := e.bind("SELECT {{missing}}", Params{})
+	if err == nil {
+		t.Error("bind() succeeded with an unknown placeholder")
+	}
+}
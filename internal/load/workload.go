@@ -0,0 +1,352 @@
+// Package load runs the queries of the load generator. The queries are described by workloads
+// loaded from YAML files, the four switches of the control panel are the built-in workloads
+// from workloads/default.yaml.
+//
+// A workload file contains one or more YAML documents, each describing a workload:
+//
+//	name: recent_pulls            # unique name, letters, digits, "_" and "-"
+//	title: Recent pull requests   # shown on the control panel
+//	params:                       # values generated before every run
+//	  since: {generator: date, min: 30, max: 90}
+//	steps:                        # one step is picked by weight on every run
+//	  - name: read
+//	    weight: 3
+//	    transaction: false        # run all queries of the step in one transaction
+//	    queries:
+//	      - name: select_pulls
+//	        routines: odd             # run only on the odd (or even) load goroutines, all if not set
+//	        mysql: SELECT data FROM pulls WHERE created_at > {{since}} LIMIT 10
+//	        postgres: SELECT data FROM github.pulls WHERE created_at > {{since}} LIMIT 10
+//	        mongodb:
+//	          collection: pulls
+//	          operation: find
+//	          filter: '{"createdat": {"$gt": "{{since}}"}}'
+//	          limit: 10
+//
+// A SQL query can have a variant for the normalized test schema (mysql_normalized, postgres_normalized
+// or sql_normalized), it reads the generated columns instead of the JSON data. A query with only
+// a normalized variant runs only on the databases with the normalized schema.
+//
+// Placeholders {{name}} refer to the params and to the values captured by earlier queries of the step,
+// {{name.field}} reads a field of a captured document. In SQL they become bind parameters,
+// in MongoDB JSON a string that consists of one placeholder is replaced with the value.
+package load
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	app "github-stat/internal"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"gopkg.in/yaml.v3"
+)
+
+// Workload is a named set of query steps.
+type Workload struct {
+	Name        string           `yaml:"name"`
+	Title       string           `yaml:"title"`
+	Description string           `yaml:"description"`
+	Params      map[string]Param `yaml:"params"`
+	Steps       []Step           `yaml:"steps"`
+
+	builtin bool
+}
+
+// Step is a sequence of queries. On every run of a workload one step is picked by weight.
+type Step struct {
+	Name        string  `yaml:"name"`
+	Weight      int     `yaml:"weight"`      // Relative probability of the step, 1 if not set
+	Transaction bool    `yaml:"transaction"` // Run all queries of the step in one transaction
+	Queries     []Query `yaml:"queries"`
+}
+
+// Query is one operation of a step. A query without text for the database type is skipped.
+type Query struct {
+	Name     string      `yaml:"name"`
+	SQL      string      `yaml:"sql"`      // SQL used by MySQL and PostgreSQL unless overridden
+	MySQL    string      `yaml:"mysql"`    // SQL for MySQL
+	Postgres string      `yaml:"postgres"` // SQL for PostgreSQL
+	MongoDB  *MongoQuery `yaml:"mongodb"`  // Operation for MongoDB
+	Capture  []string    `yaml:"capture"`  // Names for the values of the result row (SQL columns or the MongoDB document)
+	Pick     string      `yaml:"pick"`     // Row to capture: "first" (default), "random" or "all"
+	Routines string      `yaml:"routines"` // Load goroutines that run the query: "odd", "even" or all if not set
+
+	// Variants for the normalized schema, the SQL above is used if they are not set
+	SQLNormalized      string `yaml:"sql_normalized"`
+	MySQLNormalized    string `yaml:"mysql_normalized"`
+	PostgresNormalized string `yaml:"postgres_normalized"`
+}
+
+// MongoQuery describes a MongoDB operation. Filter, Sort, Pipeline, Document, Documents and Update are JSON.
+type MongoQuery struct {
+	Collection string `yaml:"collection"`
+	Operation  string `yaml:"operation"` // One of mongoOperations
+	Filter     string `yaml:"filter"`
+	Sort       string `yaml:"sort"`
+	Limit      int64  `yaml:"limit"`
+	Pipeline   string `yaml:"pipeline"`
+	Field      string `yaml:"field"` // Field of the distinct operation
+	Document   string `yaml:"document"`
+	Documents  string `yaml:"documents"`
+	Update     string `yaml:"update"`
+
+	// Parsed JSON fields, placeholders are replaced on every run
+	filter, sort, pipeline, document, documents, update interface{}
+}
+
+// Param describes how a parameter value is generated before every run of a workload.
+type Param struct {
+	Generator string   `yaml:"generator"` // One of generators
+	Min       int      `yaml:"min"`       // int: smallest value, date: fewest days ago
+	Max       int      `yaml:"max"`       // int: largest value, date: most days ago
+	Values    []string `yaml:"values"`    // choice: values to pick from
+}
+
+var mongoOperations = []string{"find", "aggregate", "distinct", "count", "insert_one", "insert_many", "upsert_one", "update_many", "delete_many"}
+
+var generators = []string{"int", "choice", "date", "random_repo_id", "random_pull_id", "random_repo_name"}
+
+var (
+	namePattern        = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z0-9_]+)*)\s*\}\}`)
+	fullPlaceholder    = regexp.MustCompile(`^\s*\{\{\s*([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z0-9_]+)*)\s*\}\}\s*$`)
+)
+
+// Parse reads the workloads from a YAML file with one or more documents.
+//
+// Arguments:
+//   - data: []byte containing the YAML file.
+//
+// Returns:
+//   - []*Workload: The validated workloads.
+//   - error: An error if the file cannot be parsed or a workload is invalid, otherwise nil.
+func Parse(data []byte) ([]*Workload, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var workloads []*Workload
+	for {
+		w := &Workload{}
+		err := decoder.Decode(w)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if w.Name == "" && len(w.Steps) == 0 {
+			continue // empty document
+		}
+		if err := w.prepare(); err != nil {
+			return nil, fmt.Errorf("workload %q: %w", w.Name, err)
+		}
+		workloads = append(workloads, w)
+	}
+
+	return workloads, nil
+}
+
+// prepare validates the workload, sets the defaults and parses the MongoDB JSON.
+func (w *Workload) prepare() error {
+	if !namePattern.MatchString(w.Name) {
+		return errors.New("name must consist of letters, digits, \"_\" and \"-\"")
+	}
+	if w.Title == "" {
+		w.Title = w.Name
+	}
+	if len(w.Steps) == 0 {
+		return errors.New("at least one step is required")
+	}
+
+	for name, param := range w.Params {
+		if !slices.Contains(generators, param.Generator) {
+			return fmt.Errorf("param %s: unknown generator %q", name, param.Generator)
+		}
+		if param.Generator == "choice" && len(param.Values) == 0 {
+			return fmt.Errorf("param %s: values are required", name)
+		}
+		if param.Max < param.Min && (param.Generator == "int" || param.Generator == "date") {
+			return fmt.Errorf("param %s: max is less than min", name)
+		}
+	}
+
+	for i := range w.Steps {
+		step := &w.Steps[i]
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step%d", i+1)
+		}
+		if step.Weight < 0 {
+			return fmt.Errorf("step %s: weight must not be negative", step.Name)
+		}
+		if step.Weight == 0 {
+			step.Weight = 1
+		}
+		if len(step.Queries) == 0 {
+			return fmt.Errorf("step %s: at least one query is required", step.Name)
+		}
+
+		// Placeholders may refer to the params and to the values captured by earlier queries
+		known := make(map[string]bool)
+		for name := range w.Params {
+			known[name] = true
+		}
+
+		for j := range step.Queries {
+			query := &step.Queries[j]
+			if err := query.prepare(known); err != nil {
+				return fmt.Errorf("step %s: query %s: %w", step.Name, query.Name, err)
+			}
+			for _, name := range query.Capture {
+				known[name] = true
+			}
+		}
+	}
+
+	return nil
+}
+
+// prepare validates the query and parses the MongoDB JSON.
+func (q *Query) prepare(known map[string]bool) error {
+	if q.Name == "" {
+		return errors.New("name is required")
+	}
+	if q.SQL == "" && q.MySQL == "" && q.Postgres == "" && q.SQLNormalized == "" && q.MySQLNormalized == "" && q.PostgresNormalized == "" && q.MongoDB == nil {
+		return errors.New("sql, mysql, postgres, a normalized variant or mongodb is required")
+	}
+	if !slices.Contains([]string{"", "first", "random", "all"}, q.Pick) {
+		return fmt.Errorf("unknown pick %q", q.Pick)
+	}
+	if !slices.Contains([]string{"", "odd", "even"}, q.Routines) {
+		return fmt.Errorf("unknown routines %q", q.Routines)
+	}
+
+	texts := []string{q.SQL, q.MySQL, q.Postgres, q.SQLNormalized, q.MySQLNormalized, q.PostgresNormalized}
+
+	if m := q.MongoDB; m != nil {
+		if m.Collection == "" {
+			return errors.New("mongodb: collection is required")
+		}
+		if !slices.Contains(mongoOperations, m.Operation) {
+			return fmt.Errorf("mongodb: unknown operation %q", m.Operation)
+		}
+		if m.Operation == "distinct" && m.Field == "" {
+			return errors.New("mongodb: field is required for distinct")
+		}
+
+		fields := []struct {
+			name   string
+			text   string
+			result *interface{}
+		}{
+			{"filter", m.Filter, &m.filter},
+			{"sort", m.Sort, &m.sort},
+			{"pipeline", m.Pipeline, &m.pipeline},
+			{"document", m.Document, &m.document},
+			{"documents", m.Documents, &m.documents},
+			{"update", m.Update, &m.update},
+		}
+		for _, field := range fields {
+			value, err := parseJSON(field.text)
+			if err != nil {
+				return fmt.Errorf("mongodb: %s: %w", field.name, err)
+			}
+			*field.result = value
+			texts = append(texts, field.text)
+		}
+	}
+
+	for _, text := range texts {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			root, _, _ := strings.Cut(match[1], ".")
+			if !known[root] {
+				return fmt.Errorf("unknown placeholder {{%s}}", match[1])
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseJSON parses an extended JSON value. A text that consists of one placeholder is kept as is,
+// so it can be replaced with a captured document or list.
+func parseJSON(text string) (interface{}, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	if fullPlaceholder.MatchString(text) {
+		return strings.TrimSpace(text), nil
+	}
+
+	// Wrap the value, so arrays and scalars can be parsed as well as documents
+	var wrapper bson.D
+	if err := bson.UnmarshalExtJSON([]byte(`{"v":`+text+`}`), false, &wrapper); err != nil {
+		return nil, err
+	}
+	return wrapper[0].Value, nil
+}
+
+// Text returns the SQL of the query for the database type and the schema mode, or an empty string.
+func (q Query) Text(dbType string, schemaMode string) string {
+	if schemaMode == app.SchemaModeNormalized {
+		switch {
+		case dbType == "mysql" && q.MySQLNormalized != "":
+			return q.MySQLNormalized
+		case dbType == "postgres" && q.PostgresNormalized != "":
+			return q.PostgresNormalized
+		case (dbType == "mysql" || dbType == "postgres") && q.SQLNormalized != "":
+			return q.SQLNormalized
+		}
+	}
+
+	switch dbType {
+	case "mysql":
+		if q.MySQL != "" {
+			return q.MySQL
+		}
+		return q.SQL
+	case "postgres":
+		if q.Postgres != "" {
+			return q.Postgres
+		}
+		return q.SQL
+	}
+	return ""
+}
+
+// Has reports whether the query has an operation for the database type in the schema mode.
+// A query with only a normalized variant runs only on the databases with the normalized schema.
+func (q Query) Has(dbType string, schemaMode string) bool {
+	if dbType == "mongodb" {
+		return q.MongoDB != nil
+	}
+	return q.Text(dbType, schemaMode) != ""
+}
+
+// RunsOn reports whether the load goroutine with the ID runs the query.
+// The routines setting splits the goroutines of a database in two groups, e.g. only half of them delete the test rows.
+func (q Query) RunsOn(routineID int) bool {
+	switch q.Routines {
+	case "odd":
+		return routineID%2 != 0
+	case "even":
+		return routineID%2 == 0
+	}
+	return true
+}
+
+// Supports reports whether every step of the workload has a query for the database type.
+// The queries of the JSON schema are checked, the normalized schema runs them if they have no variant.
+func (w *Workload) Supports(dbType string) bool {
+	for _, step := range w.Steps {
+		if !slices.ContainsFunc(step.Queries, func(q Query) bool { return q.Has(dbType, app.SchemaModeJSON) }) {
+			return false
+		}
+	}
+	return true
+}
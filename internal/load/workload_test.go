@@ -0,0 +1,205 @@
+package load
+
+import (
+	"strings"
+	"testing"
+
+	app "github-stat/internal"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{
+			name: "invalid name",
+			yaml: "name: bad name\nsteps: [{queries: [{name: q, sql: SELECT 1}]}]",
+			err:  "name must consist of",
+		},
+		{
+			name: "no steps",
+			yaml: "name: w",
+			err:  "at least one step is required",
+		},
+		{
+			name: "unknown field",
+			yaml: "name: w\nweight: 1\nsteps: [{queries: [{name: q, sql: SELECT 1}]}]",
+			err:  "field weight not found",
+		},
+		{
+			name: "unknown generator",
+			yaml: "name: w\nparams: {p: {generator: uuid}}\nsteps: [{queries: [{name: q, sql: SELECT 1}]}]",
+			err:  `param p: unknown generator "uuid"`,
+		},
+		{
+			name: "choice without values",
+			yaml: "name: w\nparams: {p: {generator: choice}}\nsteps: [{queries: [{name: q, sql: SELECT 1}]}]",
+			err:  "param p: values are required",
+		},
+		{
+			name: "max less than min",
+			yaml: "name: w\nparams: {p: {generator: int, min: 5, max: 1}}\nsteps: [{queries: [{name: q, sql: SELECT 1}]}]",
+			err:  "param p: max is less than min",
+		},
+		{
+			name: "negative weight",
+			yaml: "name: w\nsteps: [{name: s, weight: -1, queries: [{name: q, sql: SELECT 1}]}]",
+			err:  "step s: weight must not be negative",
+		},
+		{
+			name: "step without queries",
+			yaml: "name: w\nsteps: [{name: s}]",
+			err:  "step s: at least one query is required",
+		},
+		{
+			name: "query without name",
+			yaml: "name: w\nsteps: [{name: s, queries: [{sql: SELECT 1}]}]",
+			err:  "name is required",
+		},
+		{
+			name: "query without operation",
+			yaml: "name: w\nsteps: [{name: s, queries: [{name: q}]}]",
+			err:  "sql, mysql, postgres, a normalized variant or mongodb is required",
+		},
+		{
+			name: "unknown pick",
+			yaml: "name: w\nsteps: [{name: s, queries: [{name: q, sql: SELECT 1, pick: last}]}]",
+			err:  `unknown pick "last"`,
+		},
+		{
+			name: "unknown routines",
+			yaml: "name: w\nsteps: [{name: s, queries: [{name: q, sql: SELECT 1, routines: first}]}]",
+			err:  `unknown routines "first"`,
+		},
+		{
+			name: "unknown mongodb operation",
+			yaml: "name: w\nsteps: [{name: s, queries: [{name: q, mongodb: {collection: pulls, operation: remove}}]}]",
+			err:  `mongodb: unknown operation "remove"`,
+		},
+		{
+			name: "distinct without field",
+			yaml: "name: w\nsteps: [{name: s, queries: [{name: q, mongodb: {collection: pulls, operation: distinct}}]}]",
+			err:  "mongodb: field is required for distinct",
+		},
+		{
+			name: "invalid mongodb json",
+			yaml: "name: w\nsteps: [{name: s, queries: [{name: q, mongodb: {collection: pulls, operation: find, filter: '{id:'}}]}]",
+			err:  "mongodb: filter:",
+		},
+		{
+			name: "unknown placeholder",
+			yaml: "name: w\nsteps: [{name: s, queries: [{name: q, sql: 'SELECT {{id}}'}]}]",
+			err:  "unknown placeholder {{id}}",
+		},
+		{
+			name: "placeholder captured by a later query",
+			yaml: "name: w\nsteps: [{name: s, queries: [{name: q1, sql: 'SELECT {{id}}'}, {name: q2, sql: SELECT 1, capture: [id]}]}]",
+			err:  "step s: query q1: unknown placeholder {{id}}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.yaml))
+			if err == nil {
+				t.Fatalf("Parse() succeeded, want error %q", tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Parse() error = %q, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	data := `
+name: w
+params:
+  since: {generator: date, min: 1, max: 2}
+steps:
+  - queries:
+      - name: q1
+        sql: SELECT id FROM pulls WHERE created_at > {{since}}
+        capture: [id]
+      - name: q2
+        sql: SELECT {{id}}
+---
+---
+name: w2
+steps: [{queries: [{name: q, mongodb: {collection: pulls, operation: find, filter: '{{filter}}'}}]}]
+params: {filter: {generator: choice, values: [a]}}
+`
+	workloads, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(workloads) != 2 {
+		t.Fatalf("Parse() returned %d workloads, want 2", len(workloads))
+	}
+
+	w := workloads[0]
+	if w.Title != "w" {
+		t.Errorf("Title = %q, want the name", w.Title)
+	}
+	if w.Steps[0].Name != "step1" || w.Steps[0].Weight != 1 {
+		t.Errorf("step = %q with weight %d, want step1 with weight 1", w.Steps[0].Name, w.Steps[0].Weight)
+	}
+	if w.Supports("mongodb") || !w.Supports("mysql") || !w.Supports("postgres") {
+		t.Errorf("Supports() is wrong for a SQL workload")
+	}
+
+	// A filter that consists of one placeholder is kept for the replacement
+	if filter := workloads[1].Steps[0].Queries[0].MongoDB.filter; filter != "{{filter}}" {
+		t.Errorf("filter = %v, want the placeholder", filter)
+	}
+}
+
+func TestQueryRunsOn(t *testing.T) {
+	tests := []struct {
+		routines  string
+		routineID int
+		want      bool
+	}{
+		{routines: "", routineID: 0, want: true},
+		{routines: "", routineID: 1, want: true},
+		{routines: "odd", routineID: 1, want: true},
+		{routines: "odd", routineID: 2, want: false},
+		{routines: "even", routineID: 0, want: true},
+		{routines: "even", routineID: 3, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := (Query{Routines: tt.routines}).RunsOn(tt.routineID); got != tt.want {
+			t.Errorf("RunsOn(%d) with routines %q = %v, want %v", tt.routineID, tt.routines, got, tt.want)
+		}
+	}
+}
+
+func TestQueryHas(t *testing.T) {
+	json, normalized := app.SchemaModeJSON, app.SchemaModeNormalized
+	tests := []struct {
+		name       string
+		query      Query
+		dbType     string
+		schemaMode string
+		want       bool
+	}{
+		{name: "sql in json", query: Query{SQL: "SELECT 1"}, dbType: "postgres", schemaMode: json, want: true},
+		{name: "sql in normalized", query: Query{SQL: "SELECT 1"}, dbType: "mysql", schemaMode: normalized, want: true},
+		{name: "other database", query: Query{MySQL: "SELECT 1"}, dbType: "postgres", schemaMode: json, want: false},
+		{name: "normalized only in json", query: Query{MySQLNormalized: "SELECT 1"}, dbType: "mysql", schemaMode: json, want: false},
+		{name: "normalized only in normalized", query: Query{MySQLNormalized: "SELECT 1"}, dbType: "mysql", schemaMode: normalized, want: true},
+		{name: "mongodb", query: Query{MongoDB: &MongoQuery{}}, dbType: "mongodb", want: true},
+		{name: "sql in mongodb", query: Query{SQL: "SELECT 1"}, dbType: "mongodb", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.Has(tt.dbType, tt.schemaMode); got != tt.want {
+				t.Errorf("Has(%q, %q) = %v, want %v", tt.dbType, tt.schemaMode, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// The histogram records latencies in microseconds in log-linear buckets, in the style of HdrHistogram.
+// Values below 2^subBucketBits are recorded exactly. Larger values are grouped by powers of two,
+// each power is split into 2^(subBucketBits-1) linear sub-buckets, so the relative error is below 2^-(subBucketBits-1) (about 3%).
+const (
+	subBucketBits  = 6
+	subBucketCount = 1 << subBucketBits // 64
+	subBucketHalf  = subBucketCount / 2 // 32
+
+	// maxValue is the largest recorded latency in microseconds (about 1 hour), larger values are clamped.
+	maxValue = int64(1)<<32 - 1
+
+	bucketCount = subBucketCount + (32-subBucketBits)*subBucketHalf
+)
+
+// Histogram is a latency histogram safe for concurrent use.
+type Histogram struct {
+	mu     sync.Mutex
+	counts [bucketCount]uint64
+	count  uint64
+	sum    int64
+	min    int64
+	max    int64
+}
+
+// NewHistogram returns an empty histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{min: math.MaxInt64}
+}
+
+// bucketIndex returns the bucket for a value in microseconds.
+func bucketIndex(value int64) int {
+	if value < subBucketCount {
+		return int(value)
+	}
+	exponent := bits.Len64(uint64(value)) - 1 // >= subBucketBits
+	shift := exponent - (subBucketBits - 1)
+	return subBucketCount + (exponent-subBucketBits)*subBucketHalf + int(value>>shift) - subBucketHalf
+}
+
+// bucketUpperBound returns the largest value in microseconds recorded into the bucket.
+func bucketUpperBound(index int) int64 {
+	if index < subBucketCount {
+		return int64(index)
+	}
+	exponent := (index-subBucketCount)/subBucketHalf + subBucketBits
+	shift := exponent - (subBucketBits - 1)
+	sub := int64((index-subBucketCount)%subBucketHalf + subBucketHalf)
+	return (sub+1)<<shift - 1
+}
+
+// Record adds a latency to the histogram.
+func (h *Histogram) Record(d time.Duration) {
+	value := d.Microseconds()
+	if value < 0 {
+		value = 0
+	}
+	if value > maxValue {
+		value = maxValue
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.counts[bucketIndex(value)]++
+	h.count++
+	h.sum += value
+	if value < h.min {
+		h.min = value
+	}
+	if value > h.max {
+		h.max = value
+	}
+}
+
+// Snapshot returns a copy of the histogram that can be read without locking.
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := HistogramSnapshot{
+		counts: h.counts,
+		Count:  h.count,
+		Sum:    time.Duration(h.sum) * time.Microsecond,
+		Max:    time.Duration(h.max) * time.Microsecond,
+	}
+	if h.count > 0 {
+		s.Min = time.Duration(h.min) * time.Microsecond
+	}
+	return s
+}
+
+// HistogramSnapshot is a point-in-time copy of a Histogram.
+type HistogramSnapshot struct {
+	counts [bucketCount]uint64
+	Count  uint64
+	Sum    time.Duration
+	Min    time.Duration
+	Max    time.Duration
+}
+
+// Mean returns the average latency.
+func (s HistogramSnapshot) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / time.Duration(s.Count)
+}
+
+// Percentile returns the latency below which the given percentage (0-100) of operations fall.
+// The result is the upper bound of the bucket, so it is never lower than the exact value.
+func (s HistogramSnapshot) Percentile(percent float64) time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(percent / 100 * float64(s.Count)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen uint64
+	for i, count := range s.counts {
+		seen += count
+		if seen >= rank {
+			value := time.Duration(bucketUpperBound(i)) * time.Microsecond
+			if value > s.Max {
+				value = s.Max
+			}
+			return value
+		}
+	}
+	return s.Max
+}
+
+// CountBelow returns the number of operations with a latency less than or equal to the limit.
+// Operations are counted by whole buckets: a bucket is counted only if its upper bound is not above the limit,
+// so operations in the bucket that contains the limit are left out and the result never exceeds the exact count.
+func (s HistogramSnapshot) CountBelow(limit time.Duration) uint64 {
+	value := limit.Microseconds()
+
+	var count uint64
+	for i, c := range s.counts {
+		if bucketUpperBound(i) > value {
+			break
+		}
+		count += c
+	}
+	return count
+}
+
+// Merge adds the operations of another snapshot, e.g. to compute percentiles over several operations.
+func (s *HistogramSnapshot) Merge(other HistogramSnapshot) {
+	if other.Count == 0 {
+		return
+	}
+	if s.Count == 0 || other.Min < s.Min {
+		s.Min = other.Min
+	}
+	if other.Max > s.Max {
+		s.Max = other.Max
+	}
+	for i, count := range other.counts {
+		s.counts[i] += count
+	}
+	s.Count += other.Count
+	s.Sum += other.Sum
+}
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketBoundaries(t *testing.T) {
+	tests := []struct {
+		value      int64
+		index      int
+		upperBound int64
+	}{
+		{value: 0, index: 0, upperBound: 0},
+		{value: 1, index: 1, upperBound: 1},
+		{value: 63, index: 63, upperBound: 63},
+		{value: 64, index: 64, upperBound: 65},
+		{value: 65, index: 64, upperBound: 65},
+		{value: 66, index: 65, upperBound: 67},
+		{value: 127, index: 95, upperBound: 127},
+		{value: 128, index: 96, upperBound: 131},
+		{value: 1000, index: 190, upperBound: 1007},
+		{value: maxValue, index: bucketCount - 1, upperBound: maxValue},
+	}
+
+	for _, tt := range tests {
+		index := bucketIndex(tt.value)
+		if index != tt.index {
+			t.Errorf("bucketIndex(%d) = %d, want %d", tt.value, index, tt.index)
+		}
+		if bound := bucketUpperBound(index); bound != tt.upperBound {
+			t.Errorf("bucketUpperBound(%d) = %d, want %d", index, bound, tt.upperBound)
+		}
+	}
+}
+
+func TestBucketsAreContiguous(t *testing.T) {
+	for i := 1; i < bucketCount; i++ {
+		lower := bucketUpperBound(i-1) + 1
+		if bucketIndex(lower) != i {
+			t.Fatalf("bucketIndex(%d) = %d, want %d", lower, bucketIndex(lower), i)
+		}
+		if bucketIndex(bucketUpperBound(i)) != i {
+			t.Fatalf("bucketIndex(%d) = %d, want %d", bucketUpperBound(i), bucketIndex(bucketUpperBound(i)), i)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []int64
+		percent float64
+		want    time.Duration
+	}{
+		{name: "empty", values: nil, percent: 50, want: 0},
+		{name: "single", values: []int64{42}, percent: 99, want: 42 * time.Microsecond},
+		{name: "exact p50", values: sequence(100), percent: 50, want: 50 * time.Microsecond},
+		{name: "exact p99", values: sequence(100), percent: 99, want: 99 * time.Microsecond},
+		{name: "bucketed p50", values: sequence(1000), percent: 50, want: 503 * time.Microsecond},
+		{name: "bucketed p99", values: sequence(1000), percent: 99, want: 991 * time.Microsecond},
+		{name: "clamped to max", values: sequence(1000), percent: 100, want: 1000 * time.Microsecond},
+		{name: "p0 is the first bucket", values: sequence(100), percent: 0, want: 1 * time.Microsecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := record(tt.values).Snapshot()
+			if got := s.Percentile(tt.percent); got != tt.want {
+				t.Errorf("Percentile(%v) = %v, want %v", tt.percent, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountBelow(t *testing.T) {
+	// 64 and 65 share the bucket [64, 65], 66 is in [66, 67]
+	s := record([]int64{10, 63, 64, 65, 66}).Snapshot()
+
+	tests := []struct {
+		limit time.Duration
+		want  uint64
+	}{
+		{limit: 0, want: 0},
+		{limit: 10 * time.Microsecond, want: 1},
+		{limit: 63 * time.Microsecond, want: 2},
+		// The bucket [64, 65] extends past the limit, so it is left out
+		{limit: 64 * time.Microsecond, want: 2},
+		{limit: 65 * time.Microsecond, want: 4},
+		{limit: 66 * time.Microsecond, want: 4},
+		{limit: 67 * time.Microsecond, want: 5},
+		{limit: time.Hour, want: 5},
+	}
+
+	for _, tt := range tests {
+		if got := s.CountBelow(tt.limit); got != tt.want {
+			t.Errorf("CountBelow(%v) = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
+
+// sequence returns the values 1..n.
+func sequence(n int64) []int64 {
+	values := make([]int64, n)
+	for i := range values {
+		values[i] = int64(i) + 1
+	}
+	return values
+}
+
+// record returns a histogram with the values in microseconds.
+func record(values []int64) *Histogram {
+	h := NewHistogram()
+	for _, value := range values {
+		h.Record(time.Duration(value) * time.Microsecond)
+	}
+	return h
+}
@@ -0,0 +1,120 @@
+// Package metrics keeps client-side statistics of the queries run by the load generator:
+// the number of operations, errors and a latency histogram for every database, switch and operation.
+// It also exports these statistics and the metrics of the other services in the Prometheus format.
+//
+// Usage in a load function:
+//
+//	done := metrics.Start(dbConfig.ID, "switch1", "select_repos")
+//	rows, err := db.Query(...)
+//	done(err)
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Key identifies a recorded operation.
+type Key struct {
+	Database  string // Database ID, e.g. "mysql-1"
+	Switch    string // Workload name, e.g. "switch1"
+	Operation string // Operation inside the switch, e.g. "select_repos"
+}
+
+// Operation holds the statistics of one operation.
+type Operation struct {
+	Latency *Histogram
+	errors  atomic.Uint64
+}
+
+// Series is a point-in-time copy of the statistics of one operation.
+type Series struct {
+	Key
+	Latency HistogramSnapshot // Latency of all executions, including failed ones
+	Errors  uint64            // Number of failed executions
+}
+
+var (
+	operations      = make(map[Key]*Operation)
+	operationsMutex sync.RWMutex
+)
+
+// get returns the statistics for the key, creating them on first use.
+func get(key Key) *Operation {
+	operationsMutex.RLock()
+	op, ok := operations[key]
+	operationsMutex.RUnlock()
+	if ok {
+		return op
+	}
+
+	operationsMutex.Lock()
+	defer operationsMutex.Unlock()
+
+	if op, ok = operations[key]; !ok {
+		op = &Operation{Latency: NewHistogram()}
+		operations[key] = op
+	}
+	return op
+}
+
+// Observe records one execution of an operation.
+func Observe(key Key, latency time.Duration, err error) {
+	op := get(key)
+	op.Latency.Record(latency)
+	if err != nil {
+		op.errors.Add(1)
+	}
+}
+
+// Start starts timing an operation. The returned function stops the timer and records
+// the result, it should be called with the error returned by the operation.
+func Start(database, switchName, operation string) func(err error) {
+	key := Key{Database: database, Switch: switchName, Operation: operation}
+	started := time.Now()
+
+	return func(err error) {
+		Observe(key, time.Since(started), err)
+	}
+}
+
+// Snapshot returns the statistics of all operations sorted by database, switch and operation.
+func Snapshot() []Series {
+	operationsMutex.RLock()
+	series := make([]Series, 0, len(operations))
+	for key, op := range operations {
+		series = append(series, Series{
+			Key:     key,
+			Latency: op.Latency.Snapshot(),
+			Errors:  op.errors.Load(),
+		})
+	}
+	operationsMutex.RUnlock()
+
+	sort.Slice(series, func(i, j int) bool {
+		a, b := series[i].Key, series[j].Key
+		if a.Database != b.Database {
+			return a.Database < b.Database
+		}
+		if a.Switch != b.Switch {
+			return a.Switch < b.Switch
+		}
+		return a.Operation < b.Operation
+	})
+
+	return series
+}
+
+// Delete removes the statistics of a database, e.g. after it was deleted on the control panel.
+func Delete(database string) {
+	operationsMutex.Lock()
+	defer operationsMutex.Unlock()
+
+	for key := range operations {
+		if key.Database == database {
+			delete(operations, key)
+		}
+	}
+}
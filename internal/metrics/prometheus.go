@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"log"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace is the prefix of all metric names exported by the demo app.
+const Namespace = "github_stat"
+
+// Load generator metrics.
+var (
+	// ActiveGoroutines is the number of load goroutines running for each database.
+	ActiveGoroutines = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "load",
+		Name:      "active_goroutines",
+		Help:      "Number of load goroutines running for the database.",
+	}, []string{"database", "db_type"})
+)
+
+// Dataset metrics.
+var (
+	// GitHubRequests counts the requests sent to the GitHub API.
+	GitHubRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "dataset",
+		Name:      "github_api_requests_total",
+		Help:      "Number of requests sent to the GitHub API.",
+	}, []string{"endpoint"})
+
+	// GitHubRateRemaining is the number of requests left in the GitHub API rate limit by resource, "core" or "graphql".
+	GitHubRateRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "dataset",
+		Name:      "github_rate_limit_remaining",
+		Help:      "Number of requests left in the GitHub API rate limit, as reported by the last response.",
+	}, []string{"resource"})
+
+	// ImportRepos is the number of repositories processed by the current import from the source.
+	ImportRepos = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "dataset",
+		Name:      "import_repos",
+		Help:      "Repositories of the current import from the source, by state: processed or total.",
+	}, []string{"state"})
+
+	// DatasetSize is the number of objects of the dataset in the on-disk store.
+	DatasetSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "dataset",
+		Name:      "objects",
+		Help:      "Number of objects of the dataset in the on-disk store.",
+	}, []string{"kind"})
+
+	// DatabaseImports counts the imports of the dataset into the databases by result.
+	DatabaseImports = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "dataset",
+		Name:      "database_imports_total",
+		Help:      "Number of finished imports of the dataset into a database, by result: done, error or cancelled.",
+	}, []string{"database", "result"})
+
+	// DatabaseMismatches is the number of differences from the dataset found by the last verification of a database.
+	DatabaseMismatches = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "dataset",
+		Name:      "database_mismatches",
+		Help:      "Number of differences between a database and the dataset found by the last verification.",
+	}, []string{"database"})
+
+	// DatabaseImportsInProgress is the number of imports into the databases running right now.
+	DatabaseImportsInProgress = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "dataset",
+		Name:      "database_imports_in_progress",
+		Help:      "Number of imports of the dataset into databases running right now.",
+	})
+
+	// HeapAlloc and MaxHeapAlloc are the heap statistics logged by the dataset loader.
+	HeapAlloc = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "dataset",
+		Name:      "heap_alloc_bytes",
+		Help:      "Heap memory allocated at the last memory check.",
+	})
+	MaxHeapAlloc = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "dataset",
+		Name:      "max_heap_alloc_bytes",
+		Help:      "Largest heap memory allocation observed by the memory checks.",
+	})
+)
+
+// Web metrics.
+var (
+	// HTTPRequests counts the requests served by the control panel.
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "web",
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests served by the control panel.",
+	}, []string{"handler", "method", "code"})
+)
+
+// latencyBuckets are the upper bounds in seconds of the exported query latency histogram.
+var latencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// RegisterLoad registers the metrics of the load generator, including the query statistics recorded with Start.
+func RegisterLoad() {
+	prometheus.MustRegister(ActiveGoroutines, queryCollector{})
+}
+
+// RegisterDataset registers the metrics of the dataset loader.
+func RegisterDataset() {
+	prometheus.MustRegister(GitHubRequests, GitHubRateRemaining, ImportRepos, DatasetSize, DatabaseImports, DatabaseImportsInProgress, DatabaseMismatches, HeapAlloc, MaxHeapAlloc)
+}
+
+// RegisterWeb registers the metrics of the control panel.
+func RegisterWeb() {
+	prometheus.MustRegister(HTTPRequests)
+}
+
+// Handler returns the HTTP handler that serves the registered metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// InstrumentHandler counts the requests served by a control panel handler.
+//
+// Arguments:
+//   - name: string containing the handler name used as the label value.
+//   - handler: the handler function to instrument.
+//
+// Returns:
+//   - http.HandlerFunc: The instrumented handler.
+func InstrumentHandler(name string, handler http.HandlerFunc) http.HandlerFunc {
+	counter := HTTPRequests.MustCurryWith(prometheus.Labels{"handler": name})
+	return promhttp.InstrumentHandlerCounter(counter, handler)
+}
+
+// Serve starts an HTTP server for /metrics on the given port in a separate goroutine.
+// An empty port disables the server.
+//
+// Arguments:
+//   - port: string containing the port to listen on.
+func Serve(port string) {
+	if port == "" {
+		log.Printf("Metrics: Disabled")
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	go func() {
+		log.Printf("Metrics: Serving /metrics on port %s", port)
+		if err := http.ListenAndServe(":"+port, mux); err != nil {
+			log.Printf("Metrics: Error: %v", err)
+		}
+	}()
+}
+
+var (
+	maxHeapAlloc      uint64
+	maxHeapAllocMutex sync.Mutex
+)
+
+// UpdateHeap sets the heap gauges from the memory statistics.
+func UpdateHeap(m *runtime.MemStats) {
+	maxHeapAllocMutex.Lock()
+	defer maxHeapAllocMutex.Unlock()
+
+	maxHeapAlloc = max(maxHeapAlloc, m.HeapAlloc)
+	HeapAlloc.Set(float64(m.HeapAlloc))
+	MaxHeapAlloc.Set(float64(maxHeapAlloc))
+}
+
+// queryCollector exports the query statistics recorded with Start and Observe.
+type queryCollector struct{}
+
+var (
+	queriesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(Namespace, "load", "queries_total"),
+		"Number of executed load queries, including failed ones.",
+		[]string{"database", "switch", "operation"}, nil)
+	queryErrorsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(Namespace, "load", "query_errors_total"),
+		"Number of failed load queries.",
+		[]string{"database", "switch", "operation"}, nil)
+	queryDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(Namespace, "load", "query_duration_seconds"),
+		"Latency of the load queries measured by the client.",
+		[]string{"database", "switch", "operation"}, nil)
+)
+
+// Describe implements prometheus.Collector.
+func (queryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queriesDesc
+	ch <- queryErrorsDesc
+	ch <- queryDurationDesc
+}
+
+// Collect implements prometheus.Collector.
+func (queryCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range Snapshot() {
+		labels := []string{s.Database, s.Switch, s.Operation}
+
+		ch <- prometheus.MustNewConstMetric(queriesDesc, prometheus.CounterValue, float64(s.Latency.Count), labels...)
+		ch <- prometheus.MustNewConstMetric(queryErrorsDesc, prometheus.CounterValue, float64(s.Errors), labels...)
+
+		buckets := make(map[float64]uint64, len(latencyBuckets))
+		for _, bound := range latencyBuckets {
+			buckets[bound] = s.Latency.CountBelow(time.Duration(bound * float64(time.Second)))
+		}
+		ch <- prometheus.MustNewConstHistogram(queryDurationDesc, s.Latency.Count, s.Latency.Sum.Seconds(), buckets, labels...)
+	}
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Load profile types.
+const (
+	ProfileRamp  = "ramp"  // Linear change from From to To connections over Minutes, then hold To
+	ProfileStep  = "step"  // Steps equal increments from From to To, spread over Minutes, then hold To
+	ProfileSine  = "sine"  // Sine wave between From and To with a period of Minutes, starting at From
+	ProfileSpike = "spike" // From connections with a spike to To for SpikeMinutes at the start of every period of Minutes
+)
+
+// LoadProfile changes the number of connections of a database over time.
+// It is stored as JSON in the "profile" field of the database hash and evaluated by the load generator.
+// While a profile is set, it overrides the connections slider of the control panel.
+type LoadProfile struct {
+	Type         string `json:"type"`                    // ProfileRamp, ProfileStep, ProfileSine or ProfileSpike
+	From         int    `json:"from"`                    // Connections at the start, the minimum of the sine, the base of the spikes
+	To           int    `json:"to"`                      // Connections at the end, the maximum of the sine, the height of the spikes
+	Minutes      int    `json:"minutes"`                 // Duration of the ramp or the steps, period of the sine or the spikes
+	Steps        int    `json:"steps,omitempty"`         // Number of increments of the step profile
+	SpikeMinutes int    `json:"spike_minutes,omitempty"` // Duration of every spike
+	StartedAt    int64  `json:"started_at"`              // Unix time when the profile was started
+}
+
+// Validate checks the profile settings.
+func (p LoadProfile) Validate() error {
+	var errs []error
+
+	switch p.Type {
+	case ProfileRamp, ProfileSine:
+	case ProfileStep:
+		if p.Steps < 1 || p.Steps > p.Minutes*60 {
+			errs = append(errs, errors.New("steps must be at least 1 and at most one per second"))
+		}
+	case ProfileSpike:
+		if p.SpikeMinutes < 1 || p.SpikeMinutes >= p.Minutes {
+			errs = append(errs, errors.New("spike minutes must be at least 1 and less than the period"))
+		}
+	default:
+		return fmt.Errorf("unknown profile type %q", p.Type)
+	}
+
+	if p.From < 0 || p.From > MaxConnections || p.To < 0 || p.To > MaxConnections {
+		errs = append(errs, fmt.Errorf("connections must be between 0 and %d", MaxConnections))
+	}
+	if p.Minutes < 1 {
+		errs = append(errs, errors.New("minutes must be at least 1"))
+	}
+
+	return errors.Join(errs...)
+}
@@ -10,6 +10,23 @@ type Report struct {
 	Counter        map[string]int
 	Databases      map[string]bool
 	Timer          map[string]int64
+	Sources        map[string]*ReportSource // Counts of every GitHub source, e.g. "percona" or "user:dbazhenov"
+	Requests       *ReportRequests          // GitHub API requests of the run
+}
+
+// ReportRequests compares the GitHub API requests of a run with the REST requests the same run would take
+// with DATASET_LOAD_TYPE=github, a page of repositories or pull requests of the GraphQL API replaces one.
+type ReportRequests struct {
+	REST         int `json:"rest"`
+	GraphQL      int `json:"graphql"`
+	GraphQLCost  int `json:"graphql_cost"` // Points of the GraphQL rate limit
+	RESTEstimate int `json:"rest_estimate"`
+}
+
+// ReportSource holds the counts of a GitHub source in the report of a run.
+type ReportSource struct {
+	Repos int `json:"repos"`
+	Pulls int `json:"pulls"` // Pull requests fetched in the run
 }
 
 type ReportDatabases struct {
@@ -20,6 +37,7 @@ type ReportDatabases struct {
 	FinishedAt     string `json:"finished_at"`
 	FinishedAtUnix int64  `json:"finished_at_unix"`
 	TotalMilli     int64  `json:"milliseconds"`
+	Cancelled      bool   `json:"cancelled,omitempty"` // The import was cancelled, the counters show the written part
 	Counter        ReportCounter
 }
 
@@ -30,4 +48,9 @@ type ReportCounter struct {
 	Pulls           int `json:"pulls"`
 	PullsInserted   int `json:"pulls_inserted"`
 	PullsUpdated    int `json:"pulls_updated"`
+	Issues          int `json:"issues"`
+	Reviews         int `json:"reviews"`
+	ReviewComments  int `json:"review_comments"`
+	Commits         int `json:"commits"`
+	Users           int `json:"users"`
 }
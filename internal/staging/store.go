@@ -0,0 +1,671 @@
+// Package staging keeps the dataset of the dataset service on disk instead of in memory.
+//
+// Every repository is stored in its own NDJSON segment, repos/<repository ID>.ndjson:
+// the first line is a header with the repository and a summary of its pull requests,
+// every following line is one entity of the repository, e.g. {"pull": {...}} or {"commit": {...}}.
+// Segments are written to a temporary file and renamed, so readers and restarts never see
+// a half-written segment. Only the headers are kept in memory, the entities are read
+// one repository at a time.
+//
+// Entities of a fetch in progress are appended to pending/<repository ID>.ndjson
+// and written into the segment of the repository once the fetch is complete.
+package staging
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	app "github-stat/internal"
+
+	"github.com/google/go-github/github"
+)
+
+// header is the first line of a segment.
+type header struct {
+	Repo       *github.Repository `json:"repo"`
+	Pulls      int                `json:"pulls"`                 // Number of pull requests in the segment
+	LastUpdate time.Time          `json:"last_update,omitempty"` // Newest updated_at of the pull requests
+}
+
+// record is a line of a segment or a pending file with one entity.
+// Segments and pending files written before the other entities were added hold plain pull requests.
+type record struct {
+	Pull          *github.PullRequest        `json:"pull,omitempty"`
+	Issue         *github.Issue              `json:"issue,omitempty"`
+	Review        *github.PullRequestReview  `json:"review,omitempty"`
+	ReviewComment *github.PullRequestComment `json:"review_comment,omitempty"`
+	Commit        *github.RepositoryCommit   `json:"commit,omitempty"`
+}
+
+// decodeRecord decodes a line into data.
+func decodeRecord(line []byte, data *app.RepoData) error {
+	var r record
+	if err := json.Unmarshal(line, &r); err != nil {
+		return err
+	}
+
+	switch {
+	case r.Pull != nil:
+		data.Pulls = append(data.Pulls, r.Pull)
+	case r.Issue != nil:
+		data.Issues = append(data.Issues, r.Issue)
+	case r.Review != nil:
+		data.Reviews = append(data.Reviews, r.Review)
+	case r.ReviewComment != nil:
+		data.ReviewComments = append(data.ReviewComments, r.ReviewComment)
+	case r.Commit != nil:
+		data.Commits = append(data.Commits, r.Commit)
+	default:
+		var pull github.PullRequest
+		if err := json.Unmarshal(line, &pull); err != nil {
+			return err
+		}
+		data.Pulls = append(data.Pulls, &pull)
+	}
+	return nil
+}
+
+// encodeRecords encodes every entity of data as a record.
+func encodeRecords(enc *json.Encoder, data app.RepoData) error {
+	var records []record
+	for _, pull := range data.Pulls {
+		records = append(records, record{Pull: pull})
+	}
+	for _, issue := range data.Issues {
+		records = append(records, record{Issue: issue})
+	}
+	for _, review := range data.Reviews {
+		records = append(records, record{Review: review})
+	}
+	for _, comment := range data.ReviewComments {
+		records = append(records, record{ReviewComment: comment})
+	}
+	for _, commit := range data.Commits {
+		records = append(records, record{Commit: commit})
+	}
+
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// segment is the in-memory summary of a stored repository.
+type segment struct {
+	Name       string
+	Pulls      int
+	LastUpdate time.Time
+}
+
+// meta describes the data in the store, it is saved in meta.json.
+type meta struct {
+	Source   string `json:"source"`   // Where the data comes from, e.g. "github:percona"
+	Complete bool   `json:"complete"` // Whether the last import from the source has finished
+}
+
+// Store is an on-disk dataset, safe for concurrent use by one writer and many readers.
+type Store struct {
+	dir string
+
+	mu       sync.RWMutex
+	segments map[int64]segment
+	meta     meta
+}
+
+// Open opens the store in the directory, creating it if needed, and reads the headers of all segments.
+// Temporary files left by an interrupted write are removed.
+//
+// Arguments:
+//   - dir: string containing the directory of the store.
+//
+// Returns:
+//   - *Store: The opened store.
+//   - error: An error object if an error occurs, otherwise nil.
+func Open(dir string) (*Store, error) {
+	return open(dir, false)
+}
+
+// OpenReadOnly opens an existing store for reading, e.g. while the dataset loader writes it.
+// Nothing in the directory is created or removed, the store must not be written.
+//
+// Arguments:
+//   - dir: string containing the directory of the store.
+//
+// Returns:
+//   - *Store: The opened store.
+//   - error: An error object if an error occurs, otherwise nil.
+func OpenReadOnly(dir string) (*Store, error) {
+	return open(dir, true)
+}
+
+func open(dir string, readOnly bool) (*Store, error) {
+	s := &Store{dir: dir, segments: make(map[int64]segment)}
+
+	if !readOnly {
+		if err := os.MkdirAll(s.reposDir(), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(s.pendingDir(), 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "meta.json"))
+	if err == nil {
+		if err := json.Unmarshal(data, &s.meta); err != nil {
+			log.Printf("Staging: Error: Reading meta.json: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(s.reposDir())
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(s.reposDir(), entry.Name())
+
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			if !readOnly {
+				os.Remove(path)
+			}
+			continue
+		}
+
+		id, err := strconv.ParseInt(strings.TrimSuffix(entry.Name(), ".ndjson"), 10, 64)
+		if err != nil || !strings.HasSuffix(entry.Name(), ".ndjson") {
+			continue
+		}
+
+		h, err := readHeader(path)
+		if err != nil && readOnly {
+			log.Printf("Staging: Error: Skipping unreadable segment %s: %v", entry.Name(), err)
+			continue
+		}
+		if err != nil {
+			log.Printf("Staging: Error: Removing unreadable segment %s: %v", entry.Name(), err)
+			os.Remove(path)
+			continue
+		}
+		s.segments[id] = segment{Name: h.Repo.GetName(), Pulls: h.Pulls, LastUpdate: h.LastUpdate}
+	}
+
+	repos, pulls := s.Counts()
+	log.Printf("Staging: Opened %s: repos: %d, pulls: %d, source: %q, complete: %v", dir, repos, pulls, s.meta.Source, s.meta.Complete)
+
+	return s, nil
+}
+
+func (s *Store) reposDir() string {
+	return filepath.Join(s.dir, "repos")
+}
+
+func (s *Store) segmentPath(id int64) string {
+	return filepath.Join(s.reposDir(), strconv.FormatInt(id, 10)+".ndjson")
+}
+
+func (s *Store) pendingDir() string {
+	return filepath.Join(s.dir, "pending")
+}
+
+func (s *Store) pendingPath(id int64) string {
+	return filepath.Join(s.pendingDir(), strconv.FormatInt(id, 10)+".ndjson")
+}
+
+// Source returns the source of the data and whether the last import from it has finished.
+func (s *Store) Source() (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.meta.Source, s.meta.Complete
+}
+
+// Begin starts an import from the source. Data of a different source is removed,
+// data of the same source is kept, so an interrupted import continues where it stopped.
+//
+// Arguments:
+//   - source: string identifying the source, e.g. "github:percona".
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func (s *Store) Begin(source string) error {
+	s.mu.RLock()
+	current := s.meta.Source
+	s.mu.RUnlock()
+
+	if current != source {
+		if repos, _ := s.Counts(); repos > 0 {
+			log.Printf("Staging: Source changed from %q to %q, removing the stored data", current, source)
+		}
+		if err := s.Retain(nil); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(s.pendingDir()); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(s.pendingDir(), 0755); err != nil {
+			return err
+		}
+	}
+
+	return s.saveMeta(meta{Source: source, Complete: false})
+}
+
+// Finish marks the import from the current source as finished.
+func (s *Store) Finish() error {
+	s.mu.RLock()
+	source := s.meta.Source
+	s.mu.RUnlock()
+
+	return s.saveMeta(meta{Source: source, Complete: true})
+}
+
+func (s *Store) saveMeta(m meta) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, "meta.json")
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.meta = m
+	s.mu.Unlock()
+
+	return nil
+}
+
+// PutRepo stores the repository with the given entities, replacing the stored ones.
+// Entities with the same ID, or SHA for commits, are stored once, the last one is kept,
+// e.g. a pull request that is in a GH Archive dump once per event.
+//
+// Arguments:
+//   - data: app.RepoData containing the repository with all its entities.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func (s *Store) PutRepo(data app.RepoData) error {
+	return s.write(merge(app.RepoData{}, data))
+}
+
+// MergeRepo stores the repository and adds the entities to the stored ones.
+// Stored entities with the same ID, or SHA for commits, are replaced, e.g. by the updates fetched from GitHub.
+//
+// Arguments:
+//   - data: app.RepoData containing the repository with the new or updated entities.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func (s *Store) MergeRepo(data app.RepoData) error {
+	stored, err := s.read(data.Repo.GetID())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return s.write(merge(stored, data))
+}
+
+// write replaces the segment of the repository.
+func (s *Store) write(data app.RepoData) error {
+	repo := data.Repo
+	h := header{Repo: repo, Pulls: len(data.Pulls)}
+	for _, pull := range data.Pulls {
+		if pull.UpdatedAt != nil && pull.UpdatedAt.After(h.LastUpdate) {
+			h.LastUpdate = *pull.UpdatedAt
+		}
+	}
+
+	path := s.segmentPath(repo.GetID())
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	err = enc.Encode(h)
+	if err == nil {
+		err = encodeRecords(enc, data)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		os.Remove(path + ".tmp")
+		return fmt.Errorf("staging: writing %s: %w", repo.GetName(), err)
+	}
+
+	s.mu.Lock()
+	s.segments[repo.GetID()] = segment{Name: repo.GetName(), Pulls: h.Pulls, LastUpdate: h.LastUpdate}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// AppendPending appends entities of a fetch in progress to the pending file of the repository.
+// The pending file survives restarts, the entities are added to the repository by CommitPending.
+//
+// Arguments:
+//   - data: app.RepoData containing the repository and the fetched entities.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func (s *Store) AppendPending(data app.RepoData) error {
+	var buf bytes.Buffer
+	if err := encodeRecords(json.NewEncoder(&buf), data); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.pendingPath(data.Repo.GetID()), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	// A line cut by a crash is terminated, so it does not swallow the first new entity
+	lines := buf.Bytes()
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			lines = append([]byte{'\n'}, lines...)
+		}
+	}
+
+	_, err = file.Write(lines)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// CommitPending writes the pending entities into the repository and removes the pending file.
+//
+// Arguments:
+//   - repo: *github.Repository to store.
+//   - replace: bool, true replaces the stored entities, false adds the pending ones to them.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func (s *Store) CommitPending(repo *github.Repository, replace bool) error {
+	pending, err := s.Pending(repo.GetID())
+	if err != nil {
+		return err
+	}
+	pending.Repo = repo
+
+	if replace {
+		err = s.PutRepo(pending)
+	} else {
+		err = s.MergeRepo(pending)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.pendingPath(repo.GetID())); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// DiscardPending removes the pending entities of a repository, e.g. those left by an interrupted import
+// that reads all entities again.
+//
+// Arguments:
+//   - id: int64 containing the ID of the repository.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func (s *Store) DiscardPending(id int64) error {
+	if err := os.Remove(s.pendingPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Pending reads the pending entities of a repository, Repo is not set.
+// Lines cut by a crash during AppendPending are skipped, their page is fetched again.
+//
+// Arguments:
+//   - id: int64 containing the ID of the repository.
+//
+// Returns:
+//   - app.RepoData: The pending entities.
+//   - error: An error object if an error occurs, otherwise nil.
+func (s *Store) Pending(id int64) (app.RepoData, error) {
+	var data app.RepoData
+
+	file, err := os.Open(s.pendingPath(id))
+	if os.IsNotExist(err) {
+		return data, nil
+	}
+	if err != nil {
+		return data, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if jsonErr := decodeRecord(line, &data); jsonErr != nil {
+				log.Printf("Staging: Error: Skipping a broken pending entity of %d: %v", id, jsonErr)
+			}
+		}
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return app.RepoData{}, err
+		}
+	}
+}
+
+// merge adds the entities of data to the stored ones, the entities of data replace stored ones with the same key.
+// The entities are sorted by key, the repository of data is used.
+func merge(stored, data app.RepoData) app.RepoData {
+	return app.RepoData{
+		Repo:  data.Repo,
+		Pulls: dedupe(append(stored.Pulls, data.Pulls...), (*github.PullRequest).GetID),
+		RepoDetails: app.RepoDetails{
+			Issues:         dedupe(append(stored.Issues, data.Issues...), (*github.Issue).GetID),
+			Reviews:        dedupe(append(stored.Reviews, data.Reviews...), (*github.PullRequestReview).GetID),
+			ReviewComments: dedupe(append(stored.ReviewComments, data.ReviewComments...), (*github.PullRequestComment).GetID),
+			Commits:        dedupe(append(stored.Commits, data.Commits...), (*github.RepositoryCommit).GetSHA),
+		},
+	}
+}
+
+// dedupe removes entities with the same key, the last one is kept, and sorts them by key.
+func dedupe[T any, K cmp.Ordered](items []T, key func(T) K) []T {
+	byKey := make(map[K]T, len(items))
+	for _, item := range items {
+		byKey[key(item)] = item
+	}
+
+	unique := make([]T, 0, len(byKey))
+	for _, item := range byKey {
+		unique = append(unique, item)
+	}
+	sort.Slice(unique, func(i, j int) bool { return key(unique[i]) < key(unique[j]) })
+
+	return unique
+}
+
+// Retain removes all repositories that are not in the set, nil removes all repositories.
+//
+// Arguments:
+//   - ids: map[int64]bool containing the IDs of the repositories to keep.
+//
+// Returns:
+//   - error: An error object if an error occurs, otherwise nil.
+func (s *Store) Retain(ids map[int64]bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for id := range s.segments {
+		if ids[id] {
+			continue
+		}
+		if err := os.Remove(s.segmentPath(id)); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+			continue
+		}
+		os.Remove(s.pendingPath(id))
+		delete(s.segments, id)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Has reports whether the repository is stored or has pending entities.
+func (s *Store) Has(id int64) bool {
+	s.mu.RLock()
+	_, ok := s.segments[id]
+	s.mu.RUnlock()
+	if ok {
+		return true
+	}
+
+	_, err := os.Stat(s.pendingPath(id))
+	return err == nil
+}
+
+// Counts returns the number of stored repositories and pull requests.
+func (s *Store) Counts() (int, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pulls := 0
+	for _, seg := range s.segments {
+		pulls += seg.Pulls
+	}
+	return len(s.segments), pulls
+}
+
+// LastUpdates returns the newest updated_at of the pull requests of every stored repository, by repository name.
+// Repositories without pull requests have a zero time.
+func (s *Store) LastUpdates() map[string]time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	updates := make(map[string]time.Time, len(s.segments))
+	for _, seg := range s.segments {
+		updates[seg.Name] = seg.LastUpdate
+	}
+	return updates
+}
+
+// Each calls fn for every stored repository with its entities, ordered by repository ID.
+// Only one repository is held in memory at a time. Repositories removed during the iteration are skipped.
+// It implements app.DatasetSource.
+//
+// Arguments:
+//   - fn: function called for every repository, the iteration stops at its first error.
+//
+// Returns:
+//   - error: The error returned by fn or an error reading a segment, otherwise nil.
+func (s *Store) Each(fn func(data app.RepoData) error) error {
+	s.mu.RLock()
+	ids := make([]int64, 0, len(s.segments))
+	for id := range s.segments {
+		ids = append(ids, id)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		data, err := s.read(id)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// read reads the repository and the entities of a segment.
+func (s *Store) read(id int64) (app.RepoData, error) {
+	file, err := os.Open(s.segmentPath(id))
+	if err != nil {
+		return app.RepoData{}, err
+	}
+	defer file.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(file))
+
+	var h header
+	if err := dec.Decode(&h); err != nil {
+		return app.RepoData{}, fmt.Errorf("staging: reading header of %d: %w", id, err)
+	}
+
+	data := app.RepoData{Repo: h.Repo, Pulls: make([]*github.PullRequest, 0, h.Pulls)}
+	for {
+		var line json.RawMessage
+		err := dec.Decode(&line)
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = decodeRecord(line, &data)
+		}
+		if err != nil {
+			return app.RepoData{}, fmt.Errorf("staging: reading entities of %s: %w", h.Repo.GetName(), err)
+		}
+	}
+
+	return data, nil
+}
+
+// readHeader reads the first line of a segment.
+func readHeader(path string) (header, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return header{}, err
+	}
+	defer file.Close()
+
+	var h header
+	if err := json.NewDecoder(bufio.NewReader(file)).Decode(&h); err != nil {
+		return header{}, err
+	}
+	if h.Repo == nil {
+		return header{}, errors.New("header without repository")
+	}
+	return h, nil
+}
@@ -1,37 +1,187 @@
 package internal
 
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/github"
+)
+
 // IndexData holds the various data related to databases and datasets
 type IndexData struct {
-	Databases        []map[string]string // Database configurations from Redis
-	DatabasesLoad    []map[string]string // Filtered database configurations with loadSwitch == true
-	DatabasesDataset []DatabaseInfo      // Data from databases in an array format
-	DatasetState     DatasetState        // Status and information about the dataset
+	Databases        []DatabaseConfig // Database configurations from Redis
+	DatabasesLoad    []DatabaseConfig // Filtered database configurations with loadSwitch == true
+	DatabasesDataset []DatabaseInfo   // Data from databases in an array format
+	DatasetState     DatasetState     // Status and information about the dataset
+	Workloads        []WorkloadInfo   // Workloads available in the load generator
 }
 
 // DatasetInfo contains information about the data from the dataset
 type DatasetInfo struct {
-	DBName       string `json:"db_name"`       // Name of the database
-	Repositories int    `json:"repositories"`  // Number of repositories
-	PullRequests int    `json:"pull_requests"` // Number of pull requests
-	LastUpdate   string `json:"last_update"`   // Last update date
+	DBName         string `json:"db_name"`         // Name of the database
+	Repositories   int    `json:"repositories"`    // Number of repositories
+	PullRequests   int    `json:"pull_requests"`   // Number of pull requests
+	Issues         int    `json:"issues"`          // Number of issues
+	Reviews        int    `json:"reviews"`         // Number of pull request reviews
+	ReviewComments int    `json:"review_comments"` // Number of pull request review comments
+	Commits        int    `json:"commits"`         // Number of commits
+	Users          int    `json:"users"`           // Number of users
+	LastUpdate     string `json:"last_update"`     // Last update date
 }
 
 // DatabaseInfo contains detailed information about the database for rendering in a table
 type DatabaseInfo struct {
-	ID           string `json:"id"`            // Database identifier
-	DBType       string `json:"db_type"`       // Type of database (mysql, postgres, mongodb)
-	DBName       string `json:"db_name"`       // Name of the database
-	Repositories int    `json:"repositories"`  // Number of repositories
-	PullRequests int    `json:"pull_requests"` // Number of pull requests
-	LastUpdate   string `json:"last_update"`   // Last update date
-	Status       string `json:"status"`        // Status of the dataset
+	ID             string `json:"id"`              // Database identifier
+	DBType         string `json:"db_type"`         // Type of database (mysql, postgres, mongodb)
+	DBName         string `json:"db_name"`         // Name of the database
+	Repositories   int    `json:"repositories"`    // Number of repositories
+	PullRequests   int    `json:"pull_requests"`   // Number of pull requests
+	Issues         int    `json:"issues"`          // Number of issues
+	Reviews        int    `json:"reviews"`         // Number of pull request reviews
+	ReviewComments int    `json:"review_comments"` // Number of pull request review comments
+	Commits        int    `json:"commits"`         // Number of commits
+	Users          int    `json:"users"`           // Number of users
+	LastUpdate     string `json:"last_update"`     // Last update date
+	Status         string `json:"status"`          // Status of the dataset
+
+	VerifyStatus string        `json:"verify_status"`    // Status of the verification of the data against the dataset
+	Verify       *VerifyResult `json:"verify,omitempty"` // Result of the last verification, nil if the database was not verified
 }
 
 // DatasetState contains the state of the dataset retrieved from Valkey
 type DatasetState struct {
-	Status     string `json:"status"`
-	Type       string `json:"type"`
-	ReposCount int    `json:"repos_count"`
-	PullsCount int    `json:"pulls_count"`
-	LastUpdate string `json:"last_update"`
+	Status     string      `json:"status"`
+	Type       string      `json:"type"`
+	ReposCount int         `json:"repos_count"`
+	PullsCount int         `json:"pulls_count"`
+	LastUpdate string      `json:"last_update"`
+	GitHubRate *GitHubRate `json:"github_rate"` // Rate limit of the GitHub API, nil if the loader has not used it
+}
+
+// DefaultBatchSize is the number of rows written by one statement when the batch size is not configured.
+const DefaultBatchSize = 500
+
+// RepoDetails holds the entities of a repository besides its pull requests
+type RepoDetails struct {
+	Issues         []*github.Issue              // Issues without the pull requests
+	Reviews        []*github.PullRequestReview  // Reviews of the pull requests, PullRequestURL links the pull request
+	ReviewComments []*github.PullRequestComment // Review comments of the pull requests
+	Commits        []*github.RepositoryCommit   // Commits of the default branch
+}
+
+// RepoData holds a repository with all its entities
+type RepoData struct {
+	Repo  *github.Repository
+	Pulls []*github.PullRequest
+	RepoDetails
+}
+
+// DatasetSource provides the GitHub data of the dataset service one repository at a time
+type DatasetSource interface {
+	// Each calls fn for every repository with all its entities and stops at the first error.
+	Each(fn func(data RepoData) error) error
+}
+
+// Dataset holds the GitHub data staged by the dataset service and written into databases
+type Dataset struct {
+	Source    DatasetSource // Repositories with their pull requests
+	BatchSize int           // Rows written by one statement, 0 uses DefaultBatchSize
+}
+
+// Each calls fn for every repository of the source like DatasetSource.Each. When ctx is cancelled
+// it stops before the next repository and returns the error of ctx.
+func (d Dataset) Each(ctx context.Context, fn func(data RepoData) error) error {
+	return d.Source.Each(func(data RepoData) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(data)
+	})
+}
+
+// WriteBatchSize returns the number of rows to write by one statement.
+func (d Dataset) WriteBatchSize() int {
+	if d.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+	return d.BatchSize
+}
+
+// DatasetWriter receives the repositories and pull requests read from a database or the dataset service
+type DatasetWriter interface {
+	WriteRepo(repo *github.Repository) error
+	WritePull(repoName string, pull *github.PullRequest) error
+}
+
+// SchemaVersion holds the migration versions of the test schema of a database
+type SchemaVersion struct {
+	Version int    `json:"version"` // Version of the last applied migration, 0 if no migration is applied
+	Latest  int    `json:"latest"`  // Version of the last migration of the backend in the schema mode
+	Mode    string `json:"mode"`    // Schema mode stored in the test schema, empty for backends without modes
+	Pinned  bool   `json:"pinned"`  // Migrations were reverted by Migrate Schema, the imports do not apply them again
+}
+
+// LatestMigration is the version passed to MigrateSchema to apply all migrations of the schema mode.
+const LatestMigration = -1
+
+// Behind reports whether migrations of the test schema are not applied.
+func (v SchemaVersion) Behind() bool {
+	return v.Version < v.Latest
+}
+
+// Check returns a *SchemaBehindError if the imports must not apply the pending migrations of the test schema.
+// The imports apply them, e.g. for a database created by an older release at version 0,
+// unless they were reverted with Migrate Schema.
+func (v SchemaVersion) Check() error {
+	if v.Pinned && v.Behind() {
+		return &SchemaBehindError{Version: v.Version, Latest: v.Latest}
+	}
+	return nil
+}
+
+// SchemaBehindError is the error of an import into a database whose test schema was reverted to an older migration
+type SchemaBehindError struct {
+	Version int
+	Latest  int
+}
+
+// Error returns the message shown as the dataset status, e.g. "Schema at v2 of 3, run Migrate Schema".
+func (e *SchemaBehindError) Error() string {
+	return fmt.Sprintf("Schema at v%d of %d, run Migrate Schema", e.Version, e.Latest)
+}
+
+// DatabaseEvent describes a change of a database configuration published by the control panel
+type DatabaseEvent struct {
+	Action string   `json:"action"`           // "update" or "delete"
+	ID     string   `json:"id"`               // ID of the changed database
+	Fields []string `json:"fields,omitempty"` // Changed hash fields, empty if the whole configuration was written
+}
+
+// LoadStats holds the load produced by the load generator for a database
+type LoadStats struct {
+	TargetQPS   int     `json:"target_qps"`   // Target queries per second, 0 if the load is not rate limited
+	AchievedQPS float64 `json:"achieved_qps"` // Queries per second measured over the last interval
+	Reached     bool    `json:"reached"`      // Whether the achieved rate is at least 95% of the target
+	UpdatedAt   string  `json:"updated_at"`   // Time of the measurement
+	Connections int     `json:"connections"`  // Number of running load connections
+	Phase       string  `json:"phase"`        // Current phase of the load profile, empty without a profile
+}
+
+// WorkloadInfo describes a workload available in the load generator, it is published to Valkey for the control panel.
+type WorkloadInfo struct {
+	Name        string   `json:"name"`        // Unique name, e.g. "switch1"
+	Title       string   `json:"title"`       // Title shown on the control panel
+	Description string   `json:"description"` // Longer description shown as a tooltip
+	DBTypes     []string `json:"db_types"`    // Database types the workload has queries for
+	Builtin     bool     `json:"builtin"`     // Whether the workload is compiled into the load generator
+}
+
+// Supports reports whether the workload has queries for the database type.
+func (w WorkloadInfo) Supports(dbType string) bool {
+	for _, t := range w.DBTypes {
+		if t == dbType {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,39 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSchemaVersionCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		version SchemaVersion
+		behind  bool
+		blocked bool
+	}{
+		{name: "created by an older release", version: SchemaVersion{Version: 0, Latest: 3}, behind: true},
+		{name: "new migration released", version: SchemaVersion{Version: 2, Latest: 3}, behind: true},
+		{name: "latest", version: SchemaVersion{Version: 3, Latest: 3}},
+		{name: "reverted to v0", version: SchemaVersion{Version: 0, Latest: 3, Pinned: true}, behind: true, blocked: true},
+		{name: "reverted one migration", version: SchemaVersion{Version: 2, Latest: 3, Pinned: true}, behind: true, blocked: true},
+		{name: "pinned at the latest", version: SchemaVersion{Version: 3, Latest: 3, Pinned: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.version.Behind(); got != tt.behind {
+				t.Errorf("Behind() = %v, want %v", got, tt.behind)
+			}
+
+			err := tt.version.Check()
+			var behindErr *SchemaBehindError
+			if blocked := errors.As(err, &behindErr); blocked != tt.blocked {
+				t.Fatalf("Check() = %v, want blocked %v", err, tt.blocked)
+			}
+			if tt.blocked && (behindErr.Version != tt.version.Version || behindErr.Latest != tt.version.Latest) {
+				t.Errorf("Check() = %+v, want the versions of %+v", behindErr, tt.version)
+			}
+		})
+	}
+}
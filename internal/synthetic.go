@@ -0,0 +1,285 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// Distributions of pull requests across the synthetic repositories.
+const (
+	SyntheticFixed   = "fixed"   // Every repository has PullsPerRepo pull requests
+	SyntheticUniform = "uniform" // Between 0 and 2*PullsPerRepo pull requests per repository
+	SyntheticZipf    = "zipf"    // A few large repositories and a long tail of small ones, like a real organization
+)
+
+// Validate checks the settings of the synthetic dataset.
+func (c ConfigSynthetic) Validate() error {
+	var errs []error
+
+	if c.Scale <= 0 {
+		errs = append(errs, errors.New("scale must be positive"))
+	}
+	if c.Repos < 1 {
+		errs = append(errs, errors.New("repos must be at least 1"))
+	}
+	if c.PullsPerRepo < 0 {
+		errs = append(errs, errors.New("pulls per repo must not be negative"))
+	}
+	if c.Days < 1 {
+		errs = append(errs, errors.New("days must be at least 1"))
+	}
+	if c.BodySize < 0 {
+		errs = append(errs, errors.New("body size must not be negative"))
+	}
+	switch c.Distribution {
+	case SyntheticFixed, SyntheticUniform, SyntheticZipf:
+	default:
+		errs = append(errs, fmt.Errorf("unknown distribution %q, expected fixed, uniform or zipf", c.Distribution))
+	}
+
+	return errors.Join(errs...)
+}
+
+// RepoCount returns the number of repositories for the scale factor.
+func (c ConfigSynthetic) RepoCount() int {
+	return max(1, int(math.Round(float64(c.Repos)*c.Scale)))
+}
+
+var (
+	syntheticAdjectives = []string{"fast", "quiet", "shiny", "brave", "lazy", "clever", "ancient", "modern", "tiny", "giant", "happy", "silent"}
+	syntheticNouns      = []string{"backup", "exporter", "operator", "proxy", "toolkit", "docs", "server", "agent", "monitor", "driver", "cluster", "engine"}
+	syntheticLanguages  = []string{"Go", "C++", "C", "Python", "Java", "Shell", "JavaScript", "Rust", "Perl", "Ruby"}
+	syntheticTopics     = []string{"mysql", "postgresql", "mongodb", "database", "monitoring", "kubernetes", "backup", "performance", "cloud", "observability"}
+	syntheticWords      = strings.Fields("the fix add update remove refactor query index table replica backup test docs config error memory cache connection timeout release build version support performance cluster operator metrics")
+)
+
+// syntheticUsers is the number of distinct pull request authors.
+const syntheticUsers = 500
+
+// GenerateSyntheticDataset generates repositories and pull requests similar to the ones returned by the GitHub API
+// and calls fn for every repository with its pull requests. The data depends only on the configuration:
+// the same seed and settings produce the same repositories, pull requests, IDs and dates in every run.
+//
+// Arguments:
+//   - c: ConfigSynthetic containing the seed, the scale factor and the shape of the data.
+//   - org: string containing the organization name used in the names and URLs.
+//   - fn: function called for every repository, in the order of the repository IDs.
+func GenerateSyntheticDataset(c ConfigSynthetic, org string, fn func(repo *github.Repository, pulls []*github.PullRequest)) {
+	counts := syntheticPullCounts(c)
+
+	var firstPullID int64 = 1_000_000_000
+	for i, count := range counts {
+		// Every repository has its own random source, so it does not depend on the other repositories
+		rng := rand.New(rand.NewSource(c.Seed*1_000_003 + int64(i)))
+
+		repo := syntheticRepo(c, org, i, count, rng)
+		pulls := make([]*github.PullRequest, count)
+
+		// Pull request numbers follow the creation dates
+		created := make([]time.Time, count)
+		for j := range created {
+			created[j] = randomTime(rng, c.EndDate.AddDate(0, 0, -c.Days), c.EndDate)
+		}
+		sort.Slice(created, func(a, b int) bool { return created[a].Before(created[b]) })
+
+		for j := range pulls {
+			pulls[j] = syntheticPull(c, repo, firstPullID+int64(j), j+1, created[j], rng)
+		}
+		firstPullID += int64(count)
+
+		fn(repo, pulls)
+	}
+}
+
+// syntheticPullCounts returns the number of pull requests of every repository.
+func syntheticPullCounts(c ConfigSynthetic) []int {
+	repos := c.RepoCount()
+	rng := rand.New(rand.NewSource(c.Seed))
+	counts := make([]int, repos)
+
+	switch c.Distribution {
+	case SyntheticFixed:
+		for i := range counts {
+			counts[i] = c.PullsPerRepo
+		}
+
+	case SyntheticUniform:
+		for i := range counts {
+			counts[i] = rng.Intn(2*c.PullsPerRepo + 1)
+		}
+
+	case SyntheticZipf:
+		// The repository of rank k gets a share of 1/k of all pull requests, the ranks are shuffled
+		var harmonic float64
+		for k := 1; k <= repos; k++ {
+			harmonic += 1 / float64(k)
+		}
+		total := float64(repos * c.PullsPerRepo)
+		for i, rank := range rng.Perm(repos) {
+			counts[i] = int(math.Round(total / float64(rank+1) / harmonic))
+		}
+	}
+
+	return counts
+}
+
+// syntheticRepo generates the repository with the given index.
+func syntheticRepo(c ConfigSynthetic, org string, index int, pulls int, rng *rand.Rand) *github.Repository {
+	name := fmt.Sprintf("%s-%s-%d", pick(rng, syntheticAdjectives), pick(rng, syntheticNouns), index+1)
+	fullName := org + "/" + name
+
+	// Repositories are created before the first pull request and updated at the end of the period
+	created := c.EndDate.AddDate(0, 0, -c.Days-rng.Intn(c.Days+1))
+	updated := randomTime(rng, c.EndDate.AddDate(0, 0, -1), c.EndDate)
+
+	topics := make([]string, 1+rng.Intn(3))
+	for i := range topics {
+		topics[i] = pick(rng, syntheticTopics)
+	}
+
+	stars := int(math.Round(math.Exp(rng.Float64() * 9))) // 1 to about 8000, most repositories have few stars
+
+	return &github.Repository{
+		ID:              github.Int64(int64(100_000 + index)),
+		NodeID:          github.String(fmt.Sprintf("R_synthetic%d", index+1)),
+		Owner:           &github.User{Login: github.String(org), Type: github.String("Organization")},
+		Name:            github.String(name),
+		FullName:        github.String(fullName),
+		Description:     github.String(sentence(rng, 6+rng.Intn(10))),
+		DefaultBranch:   github.String("main"),
+		CreatedAt:       &github.Timestamp{Time: created},
+		PushedAt:        &github.Timestamp{Time: updated},
+		UpdatedAt:       &github.Timestamp{Time: updated},
+		HTMLURL:         github.String("https://github.com/" + fullName),
+		CloneURL:        github.String("https://github.com/" + fullName + ".git"),
+		Language:        github.String(pick(rng, syntheticLanguages)),
+		Fork:            github.Bool(false),
+		ForksCount:      github.Int(stars / (2 + rng.Intn(8))),
+		OpenIssuesCount: github.Int(rng.Intn(pulls/10 + 1)),
+		StargazersCount: github.Int(stars),
+		WatchersCount:   github.Int(stars),
+		Size:            github.Int(100 + rng.Intn(500_000)),
+		Topics:          topics,
+		Private:         github.Bool(false),
+		HasIssues:       github.Bool(true),
+		Archived:        github.Bool(rng.Intn(20) == 0),
+		URL:             github.String("https://api.github.com/repos/" + fullName),
+	}
+}
+
+// syntheticPull generates a pull request of the repository.
+func syntheticPull(c ConfigSynthetic, repo *github.Repository, id int64, number int, created time.Time, rng *rand.Rand) *github.PullRequest {
+	fullName := repo.GetFullName()
+	userID := int64(rng.Intn(syntheticUsers))
+	user := &github.User{
+		ID:    github.Int64(5_000_000 + userID),
+		Login: github.String(fmt.Sprintf("user-%d", userID)),
+		Type:  github.String("User"),
+	}
+
+	pull := &github.PullRequest{
+		ID:        github.Int64(id),
+		Number:    github.Int(number),
+		Title:     github.String(sentence(rng, 3+rng.Intn(8))),
+		Body:      github.String(text(rng, c.BodySize)),
+		CreatedAt: timePtr(created),
+		User:      user,
+		Comments:  github.Int(rng.Intn(20)),
+		Commits:   github.Int(1 + rng.Intn(15)),
+		Additions: github.Int(rng.Intn(2000)),
+		Deletions: github.Int(rng.Intn(1000)),
+		URL:       github.String(fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d", fullName, number)),
+		HTMLURL:   github.String(fmt.Sprintf("https://github.com/%s/pull/%d", fullName, number)),
+		NodeID:    github.String(fmt.Sprintf("PR_synthetic%d", id)),
+		Head: &github.PullRequestBranch{
+			Label: github.String(fmt.Sprintf("%s:feature-%d", user.GetLogin(), number)),
+			Ref:   github.String(fmt.Sprintf("feature-%d", number)),
+			SHA:   github.String(randomSHA(rng)),
+			User:  user,
+		},
+		Base: &github.PullRequestBranch{
+			Label: github.String(repo.GetOwner().GetLogin() + ":main"),
+			Ref:   github.String("main"),
+			SHA:   github.String(randomSHA(rng)),
+			Repo:  &github.Repository{ID: repo.ID, Name: repo.Name, FullName: repo.FullName},
+		},
+	}
+	pull.ChangedFiles = github.Int(1 + rng.Intn(pull.GetCommits()*3))
+
+	// 30% of the pull requests are open, most of the closed ones are merged
+	updated := randomTime(rng, created, minTime(created.AddDate(0, 0, 30), c.EndDate))
+	switch state := rng.Intn(10); {
+	case state < 3:
+		pull.State = github.String("open")
+		pull.Merged = github.Bool(false)
+	case state < 9:
+		pull.State = github.String("closed")
+		pull.Merged = github.Bool(true)
+		pull.MergedAt = timePtr(updated)
+		pull.ClosedAt = timePtr(updated)
+		pull.MergeCommitSHA = github.String(randomSHA(rng))
+	default:
+		pull.State = github.String("closed")
+		pull.Merged = github.Bool(false)
+		pull.ClosedAt = timePtr(updated)
+	}
+	pull.UpdatedAt = timePtr(updated)
+
+	return pull
+}
+
+// randomTime returns a time between from and to, rounded to seconds like the GitHub API.
+func randomTime(rng *rand.Rand, from, to time.Time) time.Time {
+	if !to.After(from) {
+		return from.Truncate(time.Second)
+	}
+	return from.Add(time.Duration(rng.Int63n(int64(to.Sub(from))))).Truncate(time.Second)
+}
+
+// randomSHA returns a random commit SHA of 40 hex digits.
+func randomSHA(rng *rand.Rand) string {
+	return fmt.Sprintf("%016x%016x%08x", rng.Uint64(), rng.Uint64(), rng.Uint32())
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func pick(rng *rand.Rand, items []string) string {
+	return items[rng.Intn(len(items))]
+}
+
+// sentence returns the given number of random words starting with a capital letter.
+func sentence(rng *rand.Rand, words int) string {
+	parts := make([]string, words)
+	for i := range parts {
+		parts[i] = pick(rng, syntheticWords)
+	}
+	s := strings.Join(parts, " ")
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// text returns random sentences of exactly the given size in bytes.
+func text(rng *rand.Rand, size int) string {
+	var b strings.Builder
+	b.Grow(size + 100)
+	for b.Len() < size {
+		b.WriteString(sentence(rng, 5+rng.Intn(10)))
+		b.WriteString(". ")
+	}
+	return b.String()[:size]
+}
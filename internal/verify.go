@@ -0,0 +1,188 @@
+package internal
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// Kinds of the differences found by the dataset verification.
+const (
+	MismatchRepositories = "repositories" // Number of repositories
+	MismatchMissingRepo  = "missing_repo" // Pull requests of a repository are missing in the database
+	MismatchExtraRepo    = "extra_repo"   // The database has pull requests of a repository missing in the dataset
+	MismatchPulls        = "pulls"        // Number of pull requests of a repository
+	MismatchChecksum     = "checksum"     // Same number of pull requests with different IDs or update times
+	MismatchSample       = "sample"       // A field of a sampled pull request
+)
+
+// MaxVerifyMismatches is the number of differences kept in a VerifyResult, the others are only counted.
+const MaxVerifyMismatches = 100
+
+// RepoDigest holds the number of pull requests of a repository and a checksum of their IDs and update times.
+type RepoDigest struct {
+	Pulls    int    `json:"pulls"`
+	Checksum uint64 `json:"checksum"`
+}
+
+// DatasetDigest holds the digests of the pull requests by repository name.
+// The checksum does not depend on the order of the pull requests, so the
+// digests read from different databases are compared directly.
+type DatasetDigest map[string]*RepoDigest
+
+// Add adds a pull request to the digest of its repository.
+//
+// Arguments:
+//   - repo: string containing the repository name.
+//   - id: int64 containing the ID of the pull request.
+//   - updatedAt: time.Time containing the update time, zero if it is missing.
+func (d DatasetDigest) Add(repo string, id int64, updatedAt time.Time) {
+	digest, ok := d[repo]
+	if !ok {
+		digest = &RepoDigest{}
+		d[repo] = digest
+	}
+
+	// The databases keep the update times with second precision
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d|%d", id, updatedAt.Unix())
+
+	digest.Pulls++
+	digest.Checksum += h.Sum64()
+}
+
+// VerifyResult is the result of the comparison of a database with the dataset of the dataset loader.
+type VerifyResult struct {
+	ID            string           `json:"id"`
+	DBType        string           `json:"db_type"`
+	Status        string           `json:"status"` // "OK", "Mismatch" or "Error"
+	CheckedAt     string           `json:"checked_at"`
+	Milliseconds  int64            `json:"milliseconds"`
+	Repos         int              `json:"repos"`   // Repositories of the dataset
+	Pulls         int              `json:"pulls"`   // Pull requests of the dataset
+	Sampled       int              `json:"sampled"` // Pull requests compared field by field
+	MismatchCount int              `json:"mismatch_count"`
+	Mismatches    []VerifyMismatch `json:"mismatches,omitempty"` // The first MaxVerifyMismatches differences
+	Error         string           `json:"error,omitempty"`
+}
+
+// VerifyMismatch is a difference between a database and the dataset.
+type VerifyMismatch struct {
+	Repo     string `json:"repo,omitempty"` // Empty for the totals
+	Kind     string `json:"kind"`
+	Expected string `json:"expected"` // Value in the dataset
+	Actual   string `json:"actual"`   // Value in the database
+}
+
+// String returns the mismatch as a line of the control panel, e.g. "percona/mysql: pulls: expected 10, got 9".
+func (m VerifyMismatch) String() string {
+	if m.Repo == "" {
+		return fmt.Sprintf("%s: expected %s, got %s", m.Kind, m.Expected, m.Actual)
+	}
+	return fmt.Sprintf("%s: %s: expected %s, got %s", m.Repo, m.Kind, m.Expected, m.Actual)
+}
+
+// AddMismatches adds differences to the result, only the first MaxVerifyMismatches are kept.
+func (r *VerifyResult) AddMismatches(mismatches ...VerifyMismatch) {
+	for _, m := range mismatches {
+		r.MismatchCount++
+		if len(r.Mismatches) < MaxVerifyMismatches {
+			r.Mismatches = append(r.Mismatches, m)
+		}
+	}
+}
+
+// CompareDigests compares the pull requests of the dataset with the ones of a database by repository.
+//
+// Arguments:
+//   - expected: DatasetDigest of the dataset.
+//   - actual: DatasetDigest of the database.
+//
+// Returns:
+//   - []VerifyMismatch: The differences sorted by repository name.
+func CompareDigests(expected, actual DatasetDigest) []VerifyMismatch {
+	repos := make([]string, 0, len(expected))
+	for repo := range expected {
+		repos = append(repos, repo)
+	}
+	for repo := range actual {
+		if _, ok := expected[repo]; !ok {
+			repos = append(repos, repo)
+		}
+	}
+	sort.Strings(repos)
+
+	var mismatches []VerifyMismatch
+	for _, repo := range repos {
+		want, got := expected[repo], actual[repo]
+		switch {
+		case got == nil:
+			mismatches = append(mismatches, VerifyMismatch{Repo: repo, Kind: MismatchMissingRepo, Expected: pullsCount(want.Pulls), Actual: pullsCount(0)})
+		case want == nil:
+			mismatches = append(mismatches, VerifyMismatch{Repo: repo, Kind: MismatchExtraRepo, Expected: pullsCount(0), Actual: pullsCount(got.Pulls)})
+		case want.Pulls != got.Pulls:
+			mismatches = append(mismatches, VerifyMismatch{Repo: repo, Kind: MismatchPulls, Expected: fmt.Sprint(want.Pulls), Actual: fmt.Sprint(got.Pulls)})
+		case want.Checksum != got.Checksum:
+			mismatches = append(mismatches, VerifyMismatch{Repo: repo, Kind: MismatchChecksum, Expected: fmt.Sprintf("%016x", want.Checksum), Actual: fmt.Sprintf("%016x", got.Checksum)})
+		}
+	}
+	return mismatches
+}
+
+func pullsCount(pulls int) string {
+	return fmt.Sprintf("%d pulls", pulls)
+}
+
+// ComparePulls compares the key fields of a pull request of the dataset with the one read from a database.
+//
+// Arguments:
+//   - repo: string containing the repository name.
+//   - expected: *github.PullRequest of the dataset.
+//   - actual: *github.PullRequest of the database, nil if it is missing.
+//
+// Returns:
+//   - []VerifyMismatch: A mismatch for every different field.
+func ComparePulls(repo string, expected, actual *github.PullRequest) []VerifyMismatch {
+	prefix := fmt.Sprintf("#%d ", expected.GetNumber())
+	if actual == nil {
+		return []VerifyMismatch{{Repo: repo, Kind: MismatchSample, Expected: prefix + "present", Actual: "missing"}}
+	}
+
+	fields := []struct {
+		name             string
+		expected, actual interface{}
+	}{
+		{"number", expected.GetNumber(), actual.GetNumber()},
+		{"title", expected.GetTitle(), actual.GetTitle()},
+		{"state", expected.GetState(), actual.GetState()},
+		{"user", expected.GetUser().GetLogin(), actual.GetUser().GetLogin()},
+		{"updated_at", verifyTime(expected.GetUpdatedAt()), verifyTime(actual.GetUpdatedAt())},
+		{"merged_at", verifyTime(expected.GetMergedAt()), verifyTime(actual.GetMergedAt())},
+		{"additions", expected.GetAdditions(), actual.GetAdditions()},
+		{"deletions", expected.GetDeletions(), actual.GetDeletions()},
+	}
+
+	var mismatches []VerifyMismatch
+	for _, field := range fields {
+		if field.expected != field.actual {
+			mismatches = append(mismatches, VerifyMismatch{
+				Repo:     repo,
+				Kind:     MismatchSample,
+				Expected: fmt.Sprintf("%s%s=%v", prefix, field.name, field.expected),
+				Actual:   fmt.Sprintf("%v", field.actual),
+			})
+		}
+	}
+	return mismatches
+}
+
+// verifyTime formats a time of a pull request with second precision, the databases keep no fractions of seconds.
+func verifyTime(t time.Time) string {
+	if t.IsZero() {
+		return "null"
+	}
+	return t.UTC().Format(time.RFC3339)
+}